@@ -0,0 +1,85 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync"
+)
+
+// AttrKey defines a typed key of the channel attributes, the keys are compared by identity.
+type AttrKey[T any] struct {
+	*attrKey
+}
+
+// attrKey defines the identity of AttrKey
+type attrKey struct {
+	name string
+}
+
+// NewAttrKey create a new key of channel attributes, the name is used for description only.
+func NewAttrKey[T any](name string) AttrKey[T] {
+	return AttrKey[T]{attrKey: &attrKey{name: name}}
+}
+
+// Name of key
+func (k AttrKey[T]) Name() string {
+	return k.name
+}
+
+// attachmentKey is the reserved key of Channel.Attachment
+var attachmentKey = NewAttrKey[Attachment]("attachment")
+
+// attributeMap holds the attributes of channel, it is safe for concurrent use.
+type attributeMap struct {
+	values sync.Map // *attrKey - value
+}
+
+// Attribute defines the typed accessor of a channel attribute.
+type Attribute[T any] struct {
+	attrs *attributeMap
+	key   *attrKey
+}
+
+// Attr returns the accessor of the channel attribute with the key.
+func Attr[T any](channel Channel, key AttrKey[T]) Attribute[T] {
+	return Attribute[T]{attrs: channel.attributes(), key: key.attrKey}
+}
+
+// Get returns the value of attribute, false if the attribute is not set.
+func (a Attribute[T]) Get() (value T, ok bool) {
+	v, loaded := a.attrs.values.Load(a.key)
+	// the nil value of interface type can not be asserted.
+	value, _ = v.(T)
+	return value, loaded
+}
+
+// Set the value of attribute
+func (a Attribute[T]) Set(value T) {
+	a.attrs.values.Store(a.key, value)
+}
+
+// SetIfAbsent to set the value if the attribute is not set, returns the actual value and true if the attribute has been set.
+func (a Attribute[T]) SetIfAbsent(value T) (actual T, loaded bool) {
+	v, loaded := a.attrs.values.LoadOrStore(a.key, value)
+	actual, _ = v.(T)
+	return actual, loaded
+}
+
+// Del to remove the attribute
+func (a Attribute[T]) Del() {
+	a.attrs.values.Delete(a.key)
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestChannelAttr(t *testing.T) {
+
+	c, _ := newPipeChannel(1, newDiscardPipeline())
+	defer c.Close(nil)
+
+	principal := NewAttrKey[string]("principal")
+	counter := NewAttrKey[*int64]("counter")
+
+	if "principal" != principal.Name() {
+		t.Fatal(principal.Name())
+	}
+
+	if _, ok := Attr(c, principal).Get(); ok {
+		t.Fatal("the attribute should not be set")
+	}
+
+	Attr(c, principal).Set("alice")
+	if v, ok := Attr(c, principal).Get(); !ok || "alice" != v {
+		t.Fatal(v, ok)
+	}
+
+	if v, loaded := Attr(c, principal).SetIfAbsent("bob"); !loaded || "alice" != v {
+		t.Fatal(v, loaded)
+	}
+
+	// the keys with the same name are different keys.
+	if _, ok := Attr(c, NewAttrKey[string]("principal")).Get(); ok {
+		t.Fatal("the keys should be compared by identity")
+	}
+
+	Attr(c, principal).Del()
+	if _, ok := Attr(c, principal).Get(); ok {
+		t.Fatal("the attribute should be removed")
+	}
+
+	// only one of the concurrent writers wins.
+	var wg sync.WaitGroup
+	var winners int64
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, loaded := Attr(c, counter).SetIfAbsent(new(int64)); !loaded {
+				atomic.AddInt64(&winners, 1)
+			}
+		}()
+	}
+	if wg.Wait(); 1 != winners {
+		t.Fatal("unexpected winners:", winners)
+	}
+
+	// the attachment is stored with the reserved key.
+	if nil != c.Attachment() {
+		t.Fatal(c.Attachment())
+	}
+	c.SetAttachment("attachment")
+	if v, ok := Attr(c, attachmentKey).Get(); !ok || "attachment" != v || "attachment" != c.Attachment() {
+		t.Fatal(v, ok)
+	}
+	c.SetAttachment(nil)
+	if nil != c.Attachment() {
+		t.Fatal(c.Attachment())
+	}
+}
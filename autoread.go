@@ -0,0 +1,67 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync/atomic"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// SetAutoRead to pause or resume the reading of channel, the channel reads automatically by default.
+//
+// The paused channel stops reading from the transport, so the peer is pushed back by the flow control of tcp,
+// the disconnection of peer is still detected by a probe of one byte that is pushed back to the transport.
+func (c *channel) SetAutoRead(enable bool) {
+	if !enable {
+		atomic.StoreInt32(&c.readPaused, 1)
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&c.readPaused, 1, 0) {
+		select {
+		case c.readResume <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// IsAutoRead returns false if the reading of channel is paused
+func (c *channel) IsAutoRead() bool {
+	return 0 == atomic.LoadInt32(&c.readPaused)
+}
+
+// waitAutoRead to wait for the reading is resumed, returns false if the pipeline should read the error of transport.
+func (c *channel) waitAutoRead() bool {
+
+	// the probed byte will be read by the pipeline after resumed.
+	probe := make([]byte, 1)
+	n, err := c.transport.Read(probe)
+	if n > 0 {
+		c.transport.(utils.Unreader).Unread(probe[:n])
+	}
+
+	if nil != err {
+		return false
+	}
+
+	select {
+	case <-c.ctx.Done():
+	case <-c.readResume:
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestChannelAutoRead(t *testing.T) {
+
+	server, client := tcpPair(t)
+	defer client.Close()
+
+	// the pipeline closes the channel after EOF.
+	p := NewPipelineWith().AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		buffer := make([]byte, 64<<10)
+		if _, err := message.(io.Reader).Read(buffer); nil != err {
+			panic(err)
+		}
+	}), ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		ctx.Close(ex)
+	}))
+
+	c := newTransportChannel(1, p, &pipeTransport{Conn: server})
+	c.SetAutoRead(false)
+	c.serveChannel()
+	defer c.Close(nil)
+
+	const total = 16 << 20
+	written := make(chan error, 1)
+	go func() {
+		_, err := client.Write(make([]byte, total))
+		written <- err
+	}()
+
+	// the peer is pushed back by the flow control of tcp, only the probe is read.
+	time.Sleep(100 * time.Millisecond)
+	if n := c.Stats().BytesRead; n > 1 || c.IsAutoRead() {
+		t.Fatal("the paused channel should not read:", n)
+	}
+	select {
+	case <-written:
+		t.Fatal("the writing of peer should be blocked")
+	default:
+	}
+
+	// the probed byte is not lost after resumed.
+	c.SetAutoRead(true)
+	if err := <-written; nil != err {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); total != c.Stats().BytesRead; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the bytes are not read after resumed:", c.Stats().BytesRead)
+		}
+	}
+
+	// the disconnection is detected while paused.
+	c.SetAutoRead(false)
+	_ = client.Close()
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the channel should be closed after the peer disconnected")
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrAwaitTimeout will be returned by Await if no message arrived in time.
+var ErrAwaitTimeout = errors.New("await timeout")
+
+// Await to wait for the next message that reaches the tail of pipeline, e.g: the response of a health probe.
+//
+// A capturing handler is added to the last of pipeline until the message arrived, the channel closed
+// or the timeout elapsed, zero timeout means no timeout. The captured message is consumed by the caller,
+// the other messages go on to the tail of pipeline.
+func Await(channel Channel, timeout time.Duration) (Message, error) {
+
+	if !channel.IsActive() {
+		return nil, ErrChannelClosed
+	}
+
+	handler := &awaitHandler{message: make(chan Message, 1)}
+	if err := addAwaitHandler(channel, handler); nil != err {
+		return nil, err
+	}
+	defer channel.Pipeline().Remove(handler)
+
+	expired := make(chan struct{})
+	if timeout > 0 {
+		defer ClockFrom(channel.Context()).Schedule(timeout, func() { close(expired) })()
+	}
+
+	select {
+	case message := <-handler.message:
+		return message, nil
+	case <-channel.Done():
+	case <-expired:
+	}
+
+	// the message may be captured before expired.
+	if !atomic.CompareAndSwapInt32(&handler.state, awaitWaiting, awaitExpired) {
+		return <-handler.message, nil
+	}
+
+	if !channel.IsActive() {
+		return nil, ErrChannelClosed
+	}
+	return nil, ErrAwaitTimeout
+}
+
+// addAwaitHandler to add the handler to the last of pipeline, returns ErrChannelClosed if the channel is closed meanwhile.
+func addAwaitHandler(channel Channel, handler *awaitHandler) (err error) {
+	defer func() {
+		// the pipeline rejects the new handlers after the channel is closed.
+		if r := recover(); nil != r {
+			if channel.IsActive() {
+				panic(r)
+			}
+			err = ErrChannelClosed
+		}
+	}()
+
+	channel.Pipeline().AddLast(handler)
+	return nil
+}
+
+const (
+	awaitWaiting int32 = iota
+	awaitCaptured
+	awaitExpired
+)
+
+// awaitHandler to capture the first message for Await
+type awaitHandler struct {
+	state   int32
+	message chan Message
+}
+
+func (h *awaitHandler) HandleRead(ctx InboundContext, message Message) {
+	if atomic.CompareAndSwapInt32(&h.state, awaitWaiting, awaitCaptured) {
+		h.message <- message
+		return
+	}
+	ctx.HandleRead(message)
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+func TestAwait(t *testing.T) {
+
+	newChannel := func() (*channel, func(string)) {
+		p := NewPipelineWith().
+			AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}).
+			AddLast(textCodec{})
+		c, peer := newPipeChannel(1, p)
+		c.serveChannel()
+		t.Cleanup(func() { c.Close(nil) })
+		return c, func(s string) { go peer.Write([]byte(s)) }
+	}
+
+	t.Run("message", func(t *testing.T) {
+		c, send := newChannel()
+		send("pong$")
+		if message, err := Await(c, time.Second); nil != err || "pong" != message {
+			t.Fatal("unexpected message:", message, err)
+		}
+		if 2 != c.Pipeline().UserSize() {
+			t.Fatal("the capturing handler should be removed:", c.Pipeline().Dump())
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		c, _ := newChannel()
+		if message, err := Await(c, 10*time.Millisecond); ErrAwaitTimeout != err {
+			t.Fatal("unexpected result:", message, err)
+		}
+		if 2 != c.Pipeline().UserSize() {
+			t.Fatal("the capturing handler should be removed:", c.Pipeline().Dump())
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		c, _ := newChannel()
+		time.AfterFunc(10*time.Millisecond, func() { c.Close(nil) })
+		if message, err := Await(c, 0); ErrChannelClosed != err {
+			t.Fatal("unexpected result:", message, err)
+		}
+		if _, err := Await(c, 0); ErrChannelClosed != err {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+
+	t.Run("closing", func(t *testing.T) {
+		c, _ := newChannel()
+		c.Close(nil)
+
+		// the channel is closed after checked, the pipeline rejects the capturing handler.
+		if message, err := Await(&closingChannel{Channel: c}, 0); ErrChannelClosed != err {
+			t.Fatal("unexpected result:", message, err)
+		}
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		clock := &triggerClock{Clock: utils.RealClock(), scheduled: make(chan func(), 1)}
+		c := newContextChannel(ContextWithClock(context.Background(), clock), NewPipelineWith())
+		defer c.Close(nil)
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := Await(c, time.Hour)
+			result <- err
+		}()
+
+		// the timeout is driven by the clock of channel.
+		(<-clock.scheduled)()
+		if err := <-result; ErrAwaitTimeout != err {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+}
+
+// closingChannel to report the channel active only once, like closed right after checked
+type closingChannel struct {
+	Channel
+	checked int32
+}
+
+func (c *closingChannel) IsActive() bool {
+	return 1 == atomic.AddInt32(&c.checked, 1)
+}
+
+// triggerClock to hand over the scheduled functions to the test
+type triggerClock struct {
+	utils.Clock
+	scheduled chan func()
+}
+
+func (c *triggerClock) Schedule(d time.Duration, fn func()) func() {
+	c.scheduled <- fn
+	return func() {}
+}
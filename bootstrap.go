@@ -18,11 +18,17 @@ package netty
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-netty/go-netty/transport"
 	"github.com/go-netty/go-netty/transport/tcp"
+	"github.com/go-netty/go-netty/utils"
 )
 
 // Bootstrap makes it easy to bootstrap a channel
@@ -31,34 +37,121 @@ type Bootstrap interface {
 	Context() context.Context
 	// Listen create a listener
 	Listen(url string, option ...transport.Option) Listener
+	// ListenAll create a listener for each url with the same options, the returned listener runs them together.
+	ListenAll(urls []string, option ...transport.Option) Listener
 	// Connect to remote endpoint
 	Connect(url string, attachment Attachment, option ...transport.Option) (Channel, error)
-	// Shutdown boostrap
+	// ConnectContext to connect to remote endpoint, the dialing is canceled if ctx or the bootstrap is done.
+	ConnectContext(ctx context.Context, url string, attachment Attachment, option ...transport.Option) (Channel, error)
+	// Shutdown boostrap, the active channels are closed with ErrBootstrapClosed
 	Shutdown()
+	// ShutdownGracefully to stop accepting and wait for the active channels to be closed until the ctx is done,
+	// returns the number of the channels that are closed forcibly.
+	ShutdownGracefully(ctx context.Context) int
+	// DebugSnapshot returns the runtime information of the active channels
+	DebugSnapshot() []ChannelDebugInfo
+	// ActiveChannels returns the number of the active channels
+	ActiveChannels() int
+	// RangeChannels calls fn for each active channel, the iteration stops if fn returns false
+	RangeChannels(fn func(channel Channel) bool)
+	// ConnectionStats returns the counters of the accepted channels
+	ConnectionStats() ConnectionStats
 }
 
+// ErrBootstrapClosed will be returned by Connect & Listener.Sync after the bootstrap is shut down,
+// and the channels closed by Shutdown are closed with it.
+var ErrBootstrapClosed = errors.New("bootstrap has been closed")
+
+// ErrListenerClosed will be returned by Listener.Sync after the listener is closed, it wraps net.ErrClosed.
+var ErrListenerClosed = fmt.Errorf("listener has been closed: %w", net.ErrClosed)
+
 // NewBootstrap create a new Bootstrap with default config.
 func NewBootstrap(option ...Option) Bootstrap {
 
+	opts := defaultBootstrapOptions()
+	for i := range option {
+		option[i](opts)
+	}
+
+	return newBootstrap(opts)
+}
+
+// NewBootstrapE create a new Bootstrap like NewBootstrap, but the invalid options are reported as BootstrapErrors
+// instead of panicking, including the invalid channel options that panic at the first channel otherwise.
+func NewBootstrapE(option ...Option) (Bootstrap, error) {
+
+	opts := defaultBootstrapOptions()
+
+	var errs BootstrapErrors
+	for i := range option {
+		if err := applyOption(opts, option[i]); nil != err {
+			errs = append(errs, fmt.Errorf("option[%d] %s: %w", i, bootstrapOptionName(option[i]), err))
+		}
+	}
+
+	if errs = append(errs, opts.validate()...); len(errs) > 0 {
+		opts.bootstrapCancel()
+		return nil, errs
+	}
+
+	return newBootstrap(opts), nil
+}
+
+// defaultBootstrapOptions to create the options with the default components
+func defaultBootstrapOptions() *bootstrapOptions {
 	opts := &bootstrapOptions{
 		channelIDFactory: SequenceID(),
 		pipelineFactory:  NewPipeline(),
 		channelFactory:   NewChannel(128),
 		transportFactory: tcp.New(),
+		clock:            utils.RealClock(),
 	}
 	opts.bootstrapCtx, opts.bootstrapCancel = context.WithCancel(context.Background())
+	return opts
+}
 
-	for i := range option {
-		option[i](opts)
+// newBootstrap to create the bootstrap with the applied options
+func newBootstrap(opts *bootstrapOptions) Bootstrap {
+
+	// the timers of channels are driven by the wheel of bootstrap.
+	var ownedWheel bool
+	if nil == opts.timerWheel {
+		opts.timerWheel, ownedWheel = utils.NewTimerWheel(utils.WithTimerClock(opts.clock), utils.WithTimerLogger(opts.logger)), true
+	}
+	opts.bootstrapCtx = context.WithValue(opts.bootstrapCtx, timerWheelKey{}, opts.timerWheel)
+	opts.bootstrapCtx = context.WithValue(opts.bootstrapCtx, clockKey{}, opts.clock)
+	if nil != opts.logger {
+		opts.bootstrapCtx = ContextWithLogger(opts.bootstrapCtx, opts.logger)
+	}
+	if nil != opts.unhandledMessage {
+		opts.bootstrapCtx = context.WithValue(opts.bootstrapCtx, unhandledMessageKey{}, opts.unhandledMessage)
+	}
+	if nil != opts.channelName {
+		opts.bootstrapCtx = context.WithValue(opts.bootstrapCtx, channelNameKey{}, opts.channelName)
+	}
+	if nil != opts.interceptor {
+		opts.bootstrapCtx = context.WithValue(opts.bootstrapCtx, pipelineInterceptorKey{}, opts.interceptor)
+	}
+	if nil != opts.recoverPolicy {
+		opts.bootstrapCtx = context.WithValue(opts.bootstrapCtx, recoverPolicyKey{}, opts.recoverPolicy)
+	}
+
+	// the channel options of bootstrap are applied before the options of ChannelFactory.
+	if len(opts.channelOptions) > 0 {
+		opts.bootstrapCtx = context.WithValue(opts.bootstrapCtx, channelOptionsKey{}, opts.channelOptions)
 	}
 
-	return &bootstrap{bootstrapOptions: opts}
+	return &bootstrap{bootstrapOptions: opts, ownedWheel: ownedWheel}
 }
 
 // bootstrap implement
 type bootstrap struct {
+	active      int64           // 64-bit aligned for atomic operations
+	connections connectionLimit // 64-bit aligned for atomic operations
 	*bootstrapOptions
-	listeners sync.Map // url - Listener
+	listeners  sync.Map // url - Listener
+	channels   sync.Map // id - Channel
+	ownedWheel bool
 }
 
 // Context to get context
@@ -67,7 +160,12 @@ func (bs *bootstrap) Context() context.Context {
 }
 
 // serveTransport to serve channel
-func (bs *bootstrap) serveTransport(transport transport.Transport, attachment Attachment, childChannel bool) Channel {
+func (bs *bootstrap) serveTransport(ctx context.Context, transport transport.Transport, attachment Attachment, childChannel bool) Channel {
+
+	// the accepted transports are wrapped before the channels are created.
+	if childChannel && nil != bs.childTransport {
+		transport = bs.childTransport(transport)
+	}
 
 	// create a new pipeline
 	pipeline := bs.pipelineFactory()
@@ -76,60 +174,245 @@ func (bs *bootstrap) serveTransport(transport transport.Transport, attachment At
 	cid := bs.channelIDFactory()
 
 	// create a channel
-	channel := bs.channelFactory(cid, bs.bootstrapCtx, pipeline, transport)
+	channel := bs.channelFactory(cid, ctx, pipeline, transport)
+
+	// the attachment of the accepted channels are created by the factory.
+	if childChannel && nil == attachment && nil != bs.childAttachment {
+		attachment = bs.childAttachment(transport)
+	}
 
 	// set the attachment if necessary
 	if nil != attachment {
 		channel.SetAttachment(attachment)
 	}
 
-	// initialization pipeline
+	initializer := bs.clientInitializer
 	if childChannel {
-		bs.childInitializer(channel)
-	} else {
-		bs.clientInitializer(channel)
+		initializer = bs.childInitializer
+	}
+
+	if nil != bs.channelCreated {
+		bs.channelCreated(channel)
+	}
+
+	if nil != bs.channelInactive {
+		channel.OnClose(func(err error) { bs.channelInactive(channel, err) })
+	}
+
+	// initialization pipeline, the initializer is optional for the pipeline template.
+	if nil != initializer {
+		bs.initializeChannel(channel, initializer)
+	}
+
+	// the initialized channels are registered until closed.
+	if !bs.noRegistry {
+		bs.channels.Store(cid, channel)
+		atomic.AddInt64(&bs.active, 1)
+		channel.onClose(func() {
+			bs.channels.Delete(cid)
+			atomic.AddInt64(&bs.active, -1)
+		})
+	}
+
+	if nil != bs.channelActive {
+		bs.channelActive(channel)
 	}
 
 	// serve channel.
 	channel.Pipeline().ServeChannel(channel)
+
+	// the accepted channels that never send a byte are closed after the handshake timeout.
+	if childChannel && bs.handshakeTimeout > 0 {
+		bs.watchHandshake(channel, bs.handshakeTimeout)
+	}
+
+	// the channel registered during the shutdown may be missed by Shutdown.
+	if nil != bs.bootstrapCtx.Err() {
+		channel.Close(ErrBootstrapClosed)
+	}
 	return channel
 }
 
+// transportOf to find the transport factory by the scheme of address
+func (bs *bootstrap) transportOf(options *transport.Options) TransportFactory {
+	if nil != options.Address {
+		if factory, ok := bs.transports[options.Address.Scheme]; ok {
+			return factory
+		}
+	}
+	return bs.transportFactory
+}
+
+// initializeChannel to close the channel if the initializer panics, so the close callbacks are not missed.
+func (bs *bootstrap) initializeChannel(channel Channel, initializer ChannelInitializer) {
+	defer func() {
+		if v := recover(); nil != v {
+			channel.Close(AsException(v, debug.Stack()))
+			panic(v)
+		}
+	}()
+	initializer(channel)
+}
+
 // Connect to the remote server with options
 func (bs *bootstrap) Connect(url string, attachment Attachment, option ...transport.Option) (Channel, error) {
+	return bs.ConnectContext(bs.bootstrapCtx, url, attachment, option...)
+}
+
+// ConnectContext to connect to the remote server with the context of dialing
+//
+// The ctx only bounds the dialing, e.g: context.WithTimeout for a connect timeout,
+// the connected channel follows the lifecycle of bootstrap.
+func (bs *bootstrap) ConnectContext(ctx context.Context, url string, attachment Attachment, option ...transport.Option) (Channel, error) {
 
-	options, err := transport.ParseOptions(bs.Context(), url, option...)
+	if nil != bs.bootstrapCtx.Err() {
+		return nil, ErrBootstrapClosed
+	}
+
+	// the dialing is canceled by the shutdown of bootstrap as well.
+	if ctx != bs.bootstrapCtx {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-bs.bootstrapCtx.Done():
+				cancel()
+			case <-stop:
+			}
+		}()
+	}
+
+	options, err := transport.ParseOptions(ctx, url, option...)
 	if nil != err {
 		return nil, err
 	}
 
 	// connect to remote endpoint
-	t, err := bs.transportFactory.Connect(options)
+	t, err := bs.transportOf(options).Connect(options)
 	if nil != err {
+		if nil != bs.bootstrapCtx.Err() {
+			return nil, ErrBootstrapClosed
+		}
 		return nil, err
 	}
 
 	// serve client transport
-	return bs.serveTransport(t, attachment, false), nil
+	return bs.serveTransport(bs.bootstrapCtx, t, attachment, false), nil
 }
 
 // Listen to the address with options
 func (bs *bootstrap) Listen(url string, option ...transport.Option) Listener {
-	l := &listener{bs: bs, url: url, option: option}
+	l := &listener{bs: bs, url: url, option: option, done: make(chan struct{})}
 	bs.listeners.Store(url, l)
 	return l
 }
 
 // Shutdown the bootstrap
 func (bs *bootstrap) Shutdown() {
+	logger := LoggerFrom(bs.bootstrapCtx)
+	logger.Infof("shutting down the bootstrap")
 	bs.bootstrapCancel()
 
 	bs.listeners.Range(func(key, value interface{}) bool {
-		value.(Listener).Close()
+		if err := value.(Listener).Close(); nil != err {
+			logger.Warnf("failed to close the listener %v: %v", key, err)
+		} else {
+			logger.Debugf("the listener %v has been closed", key)
+		}
+		return true
+	})
+
+	// the read loops are blocked by reading, so the channels are closed to interrupt them.
+	var closed int
+	bs.RangeChannels(func(channel Channel) bool {
+		channel.Close(ErrBootstrapClosed)
+		closed++
+		return true
+	})
+	if closed > 0 {
+		logger.Infof("%d active channels have been closed", closed)
+	}
+
+	if bs.ownedWheel {
+		bs.timerWheel.Stop()
+	}
+
+	logger.Infof("the bootstrap has been shut down")
+}
+
+// ShutdownGracefully to shutdown the bootstrap after the active channels are drained
+//
+// The listeners are closed first, then DrainEvent is fired to every active channel, so the handlers can finish
+// the in-flight requests and close the channel, e.g: by Channel.CloseGracefully. The remaining channels are
+// closed with the error of ctx when it is done.
+func (bs *bootstrap) ShutdownGracefully(ctx context.Context) int {
+	logger := LoggerFrom(bs.bootstrapCtx)
+
+	bs.listeners.Range(func(key, value interface{}) bool {
+		if err := value.(Listener).Close(); nil != err {
+			logger.Warnf("failed to close the listener %v: %v", key, err)
+		}
+		return true
+	})
+
+	deadline, _ := ctx.Deadline()
+	activeChannels := bs.activeChannels()
+	logger.Infof("draining %d active channels", len(activeChannels))
+
+	// the events are fired concurrently, so a handler that takes time does not delay the others.
+	for _, channel := range activeChannels {
+		go channel.Trigger(DrainEvent{Deadline: deadline})
+	}
+
+	var forceClosed int
+	for _, channel := range activeChannels {
+		select {
+		case <-channel.Done():
+		case <-ctx.Done():
+			if channel.IsActive() {
+				channel.Close(ctx.Err())
+				forceClosed++
+			}
+		}
+	}
+
+	if forceClosed > 0 {
+		logger.Warnf("%d channels are closed forcibly: %v", forceClosed, ctx.Err())
+	}
+
+	bs.Shutdown()
+	return forceClosed
+}
+
+// ActiveChannels returns the number of the active channels
+func (bs *bootstrap) ActiveChannels() int {
+	return int(atomic.LoadInt64(&bs.active))
+}
+
+// RangeChannels calls fn for each active channel
+func (bs *bootstrap) RangeChannels(fn func(channel Channel) bool) {
+	bs.channels.Range(func(key, value interface{}) bool {
+		if channel := value.(Channel); channel.IsActive() {
+			return fn(channel)
+		}
 		return true
 	})
 }
 
+// activeChannels returns the active channels of bootstrap
+func (bs *bootstrap) activeChannels() []Channel {
+	var channels []Channel
+	bs.RangeChannels(func(channel Channel) bool {
+		channels = append(channels, channel)
+		return true
+	})
+	return channels
+}
+
 // removeListener close the listener with url
 func (bs *bootstrap) removeListener(url string) {
 	bs.listeners.Delete(url)
@@ -138,10 +421,15 @@ func (bs *bootstrap) removeListener(url string) {
 type Listener interface {
 	// Close the listener
 	Close() error
-	// Sync waits for this listener until it is done
+	// Sync waits for this listener until it is done, ErrListenerClosed or ErrBootstrapClosed is returned if it is closed
 	Sync() error
 	// Async nonblock waits for this listener
 	Async(func(error))
+	// Addr returns the bound address of listener, nil before bound or the transport does not expose it.
+	Addr() net.Addr
+	// OnReady register a callback to be invoked with the bound address before the listener starts accepting,
+	// it is invoked immediately if the listener has been bound.
+	OnReady(fn func(addr net.Addr))
 }
 
 // impl Listener
@@ -151,50 +439,229 @@ type listener struct {
 	option   []transport.Option
 	options  *transport.Options
 	acceptor transport.Acceptor
+	done     chan struct{}
+	once     sync.Once
+	mutex    sync.Mutex
+	bound    bool
+	addr     net.Addr
+	ready    []func(addr net.Addr)
 }
 
 // Close listener
 func (l *listener) Close() error {
-	if l.acceptor != nil {
+	l.once.Do(func() { close(l.done) })
+	l.mutex.Lock()
+	acceptor := l.acceptor
+	l.mutex.Unlock()
+	if acceptor != nil {
 		l.bs.removeListener(l.url)
-		return l.acceptor.Close()
+		return acceptor.Close()
 	}
 	return nil
 }
 
-func (l *listener) Sync() error {
+// Addr returns the bound address of listener
+func (l *listener) Addr() net.Addr {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.addr
+}
+
+// OnReady register the callback of bound address
+func (l *listener) OnReady(fn func(addr net.Addr)) {
+	l.mutex.Lock()
+	if !l.bound {
+		l.ready = append(l.ready, fn)
+		l.mutex.Unlock()
+		return
+	}
+	addr := l.addr
+	l.mutex.Unlock()
+	fn(addr)
+}
+
+// bind to create the acceptor and notify the ready callbacks
+func (l *listener) bind() error {
 
+	l.mutex.Lock()
 	if nil != l.acceptor {
+		l.mutex.Unlock()
 		return fmt.Errorf("duplicate call Listener:Sync")
 	}
 
-	var err error
-	if l.options, err = transport.ParseOptions(l.bs.Context(), l.url, l.option...); nil != err {
+	// the listener closed before bound should not start accepting.
+	select {
+	case <-l.done:
+		l.mutex.Unlock()
+		return ErrListenerClosed
+	default:
+	}
+
+	options, err := transport.ParseOptions(l.bs.Context(), l.url, l.option...)
+	if nil != err {
+		l.mutex.Unlock()
 		return err
 	}
 
-	if l.acceptor, err = l.bs.transportFactory.Listen(l.options); nil != err {
+	acceptor, err := l.bs.transportOf(options).Listen(options)
+	if nil != err {
+		l.mutex.Unlock()
 		return err
 	}
 
+	l.options, l.acceptor = options, acceptor
+	l.bound, l.addr = true, transport.AcceptorAddr(acceptor)
+	ready, addr := l.ready, l.addr
+	l.ready = nil
+	l.mutex.Unlock()
+
+	for _, fn := range ready {
+		fn(addr)
+	}
+	return nil
+}
+
+func (l *listener) Sync() error {
+
+	if nil != l.bs.bootstrapCtx.Err() {
+		return ErrBootstrapClosed
+	}
+
+	if err := l.bind(); nil != err {
+		return err
+	}
+
+	// the accepted channels carry the url of listener.
+	ctx := context.WithValue(l.bs.bootstrapCtx, listenerKey{}, l.url)
+	logger := utils.LoggerWith(LoggerFrom(ctx), "listener", l.url)
+
+	// the temporary errors are retried with backoff, e.g: too many open files.
+	policy := transport.AcceptPolicyFrom(l.options.Context)
+	if nil == policy.OnError {
+		policy.OnError = func(err error, delay time.Duration) {
+			logger.Warnf("accept error: %v, retrying in %v", err, delay)
+		}
+	}
+
+	n := transport.AcceptorCountFrom(l.options.Context, l.acceptor)
+	if 1 == n {
+		return l.acceptLoop(ctx, policy)
+	}
+
+	// the first result of the loops is returned, the others are stopped by closing the acceptor.
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() { results <- l.acceptLoop(ctx, policy) }()
+	}
+
+	err := <-results
+	l.Close()
+	for i := 1; i < n; i++ {
+		<-results
+	}
+	return err
+}
+
+// acceptLoop to accept and serve the transports until the acceptor or bootstrap is closed
+func (l *listener) acceptLoop(ctx context.Context, policy transport.AcceptPolicy) error {
+
+	rejected := l.bs.acceptRejected
+	if nil == rejected {
+		logger := utils.LoggerWith(LoggerFrom(ctx), "listener", l.url)
+		rejected = func(t transport.Transport, err error) {
+			logger.Debugf("rejected the transport from %s: %v", t.RemoteAddr(), err)
+		}
+	}
+
+	var retryDelay time.Duration
 	for {
+		// the accepting is paused until an accepted channel is closed.
+		var reserved bool
+		if ConnectionOverflowPause == l.bs.connectionOverflow {
+			for !reserved && l.bs.waitConnection(l.done) {
+				reserved = l.bs.acquireConnection()
+			}
+		}
+
 		// accept the transport
 		t, err := l.acceptor.Accept()
 		if nil != err {
-			return err
+			if reserved {
+				l.bs.releaseConnection()
+			}
+			if policy.Retryable(err) && nil == l.bs.Context().Err() {
+				retryDelay = policy.Backoff(retryDelay)
+				policy.OnError(err, retryDelay)
+				if sleepContext(l.bs.Context(), ClockFrom(ctx), retryDelay) {
+					continue
+				}
+			}
+			return l.closedError(err)
+		}
+		retryDelay = 0
+
+		// the filtered transports are closed before the channels are created.
+		if nil != l.bs.acceptFilter {
+			if err = l.bs.acceptFilter(t); nil != err {
+				if reserved {
+					l.bs.releaseConnection()
+				}
+				atomic.AddInt64(&l.bs.connections.filtered, 1)
+				_ = t.Close()
+				rejected(t, err)
+				continue
+			}
+		}
+
+		// the transports beyond the limit are closed at once.
+		if !reserved && !l.bs.acquireConnection() {
+			atomic.AddInt64(&l.bs.connections.rejected, 1)
+			_ = t.Close()
+			continue
 		}
 
 		select {
 		case <-l.bs.Context().Done():
 			// bootstrap has been closed
+			l.bs.releaseConnection()
 			return t.Close()
 		default:
 			// serve child transport
-			l.bs.serveTransport(t, nil, true)
+			channel := l.bs.serveTransport(ctx, t, nil, true)
+			channel.OnClose(func(error) { l.bs.releaseConnection() })
 		}
 	}
 }
 
+// closedError to translate the accept error caused by closing into a sentinel error.
+func (l *listener) closedError(err error) error {
+	if nil != l.bs.Context().Err() {
+		return ErrBootstrapClosed
+	}
+	select {
+	case <-l.done:
+		return ErrListenerClosed
+	default:
+	}
+	if ctxErr := l.options.Context.Err(); nil != ctxErr {
+		return ctxErr
+	}
+	return err
+}
+
+// sleepContext to wait for the duration of clock, returns false if the context is done.
+func sleepContext(ctx context.Context, clock Clock, d time.Duration) bool {
+	wakeup := make(chan struct{})
+	defer clock.Schedule(d, func() { close(wakeup) })()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-wakeup:
+		return true
+	}
+}
+
 func (l *listener) Async(fn func(err error)) {
 	go func() {
 		fn(l.Sync())
@@ -18,13 +18,19 @@ package netty
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/go-netty/go-netty/transport"
 	"github.com/go-netty/go-netty/transport/tcp"
 	"github.com/go-netty/go-netty/utils"
 )
@@ -57,7 +63,7 @@ func TestBootstrap(t *testing.T) {
 	)
 
 	bootstrap.Listen("127.0.0.1:9527", tcp.WithOptions(tcpOptions)).Async(func(err error) {
-		if nil != err && !strings.Contains(err.Error(), "use of closed network connection") {
+		if nil != err && ErrBootstrapClosed != err {
 			t.Fatal(err)
 		}
 	})
@@ -221,3 +227,822 @@ func (textCodec) HandleWrite(ctx OutboundContext, message Message) {
 		ctx.HandleWrite(message)
 	}
 }
+
+func TestBootstrapMalformedAddress(t *testing.T) {
+
+	bootstrap := NewBootstrap(WithTransport(tcp.New()))
+	defer bootstrap.Shutdown()
+
+	for _, address := range []string{"127.0.0.1", "tcp://localhost", "udp://127.0.0.1:9527", "tcp://[::1"} {
+		if _, err := bootstrap.Connect(address, nil); nil == err {
+			t.Fatal("malformed address should be rejected by Connect:", address)
+		}
+
+		if err := bootstrap.Listen(address).Sync(); nil == err {
+			t.Fatal("malformed address should be rejected by Listen:", address)
+		}
+	}
+}
+
+func TestBootstrapTimerWheel(t *testing.T) {
+
+	shared := utils.NewTimerWheel()
+	defer shared.Stop()
+
+	bs := NewBootstrap(WithTimerWheel(shared))
+	if shared != TimerWheelFrom(bs.Context()) {
+		t.Fatal("the shared wheel is lost")
+	}
+	bs.Shutdown()
+
+	// the shared wheel is not owned by bootstrap.
+	fired := make(chan struct{})
+	shared.Schedule(time.Millisecond, func() { close(fired) })
+	<-fired
+
+	bs = NewBootstrap()
+	defer bs.Shutdown()
+	if wheel := TimerWheelFrom(bs.Context()); nil == wheel || utils.DefaultTimerWheel() == wheel {
+		t.Fatal("the bootstrap should create its own wheel")
+	}
+
+	if utils.DefaultTimerWheel() != TimerWheelFrom(context.Background()) {
+		t.Fatal("the default wheel should be returned")
+	}
+}
+
+// countingClock to count the timers scheduled on the real clock
+type countingClock struct {
+	utils.Clock
+	scheduled int32
+}
+
+func (c *countingClock) Schedule(d time.Duration, fn func()) func() {
+	atomic.AddInt32(&c.scheduled, 1)
+	return c.Clock.Schedule(d, fn)
+}
+
+func TestBootstrapClock(t *testing.T) {
+
+	clock := &countingClock{Clock: utils.RealClock()}
+	bs := NewBootstrap(WithClock(clock))
+	defer bs.Shutdown()
+
+	if clock != ClockFrom(bs.Context()) {
+		t.Fatal("the clock is lost")
+	}
+
+	// the wheel of bootstrap is driven by the clock.
+	fired := make(chan struct{})
+	TimerWheelFrom(bs.Context()).Schedule(time.Millisecond, func() { close(fired) })
+	<-fired
+
+	if 0 == atomic.LoadInt32(&clock.scheduled) {
+		t.Fatal("the wheel should be ticked by the clock")
+	}
+
+	if _, ok := ClockFrom(context.Background()).(*countingClock); ok {
+		t.Fatal("the real clock should be returned")
+	}
+}
+
+func TestBootstrapShutdownGracefully(t *testing.T) {
+
+	drained := make(chan DrainEvent, 2)
+	bs := NewBootstrap(WithClientInitializer(func(channel Channel) {
+		channel.Pipeline().
+			AddLast(EventHandlerFunc(func(ctx EventContext, event Event) {
+				if e, ok := event.(DrainEvent); ok {
+					drained <- e
+					// the busy channel ignores the event.
+					if "idle" == ctx.Channel().Attachment() {
+						ctx.Channel().Close(nil)
+					}
+				}
+			})).
+			AddLast(ignoreException)
+	}))
+
+	var channels []Channel
+	for _, attachment := range []string{"idle", "busy"} {
+		local, _ := net.Pipe()
+		channels = append(channels, bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, attachment, false))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if forceClosed := bs.ShutdownGracefully(ctx); 1 != forceClosed {
+		t.Fatal("unexpected force closed:", forceClosed)
+	}
+
+	for i := 0; i < 2; i++ {
+		if e := <-drained; e.Deadline.IsZero() {
+			t.Fatal("the deadline of ctx should be carried")
+		}
+	}
+
+	if channels[0].IsActive() || nil != channels[0].CloseErr() {
+		t.Fatal("the idle channel should be closed by itself:", channels[0].CloseErr())
+	}
+	if channels[1].IsActive() || context.DeadlineExceeded != channels[1].CloseErr() {
+		t.Fatal("the busy channel should be closed forcibly:", channels[1].CloseErr())
+	}
+	if 0 != len(bs.DebugSnapshot()) {
+		t.Fatal("the channels should be removed from bootstrap")
+	}
+}
+
+func TestBootstrapActiveChannels(t *testing.T) {
+
+	serve := func(bs Bootstrap, n int) (channels []Channel) {
+		for i := 0; i < n; i++ {
+			local, _ := net.Pipe()
+			channel := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, false)
+			t.Cleanup(func() { channel.Close(nil) })
+			channels = append(channels, channel)
+		}
+		return
+	}
+
+	bs := NewBootstrap(WithClientInitializer(func(channel Channel) { channel.Pipeline().AddLast(ignoreException) }))
+	defer bs.Shutdown()
+
+	channels := serve(bs, 3)
+	if 3 != bs.ActiveChannels() {
+		t.Fatal("unexpected active channels:", bs.ActiveChannels())
+	}
+
+	var ranged int
+	bs.RangeChannels(func(channel Channel) bool {
+		ranged++
+		return ranged < 2
+	})
+	if 2 != ranged {
+		t.Fatal("the iteration should be stopped:", ranged)
+	}
+
+	channels[0].Close(nil)
+	if 2 != bs.ActiveChannels() {
+		t.Fatal("the closed channel should be removed:", bs.ActiveChannels())
+	}
+	bs.RangeChannels(func(channel Channel) bool {
+		if channel == channels[0] {
+			t.Fatal("the closed channel should not be ranged")
+		}
+		return true
+	})
+
+	unregistered := NewBootstrap(WithoutChannelRegistry(), WithClientInitializer(func(channel Channel) { channel.Pipeline().AddLast(ignoreException) }))
+	defer unregistered.Shutdown()
+
+	serve(unregistered, 2)
+	if 0 != unregistered.ActiveChannels() || 0 != len(unregistered.DebugSnapshot()) {
+		t.Fatal("the channels should not be registered")
+	}
+}
+
+// flakyFactory to listen an acceptor that fails with the errors in order.
+type flakyFactory struct {
+	errs []error
+}
+
+func (f *flakyFactory) Schemes() transport.Schemes { return transport.Schemes{"tcp"} }
+
+func (f *flakyFactory) Connect(options *transport.Options) (transport.Transport, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *flakyFactory) Listen(options *transport.Options) (transport.Acceptor, error) {
+	return f, nil
+}
+
+func (f *flakyFactory) Accept() (transport.Transport, error) {
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return nil, err
+}
+
+func (f *flakyFactory) Close() error { return nil }
+
+func TestListenerAcceptRetry(t *testing.T) {
+
+	temporary := &net.OpError{Op: "accept", Err: syscall.EMFILE}
+	bs := NewBootstrap(WithTransport(&flakyFactory{errs: []error{temporary, temporary, temporary, net.ErrClosed}}))
+	defer bs.Shutdown()
+
+	var delays []time.Duration
+	err := bs.Listen("tcp://127.0.0.1:9527", transport.WithAcceptPolicy(transport.AcceptPolicy{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 2 * time.Millisecond,
+		OnError: func(err error, delay time.Duration) {
+			if temporary != err {
+				t.Error("unexpected error:", err)
+			}
+			delays = append(delays, delay)
+		},
+	})).Sync()
+
+	if net.ErrClosed != err {
+		t.Fatal("the permanent error should be returned:", err)
+	}
+	if 3 != len(delays) || time.Millisecond != delays[0] || 2*time.Millisecond != delays[2] {
+		t.Fatal("unexpected delays:", delays)
+	}
+}
+
+// blockingFactory to connect until the context of dialing is done.
+type blockingFactory struct {
+	flakyFactory
+}
+
+func (f *blockingFactory) Connect(options *transport.Options) (transport.Transport, error) {
+	<-options.Context.Done()
+	return nil, options.Context.Err()
+}
+
+func TestBootstrapConnectContext(t *testing.T) {
+
+	bs := NewBootstrap(WithTransport(&blockingFactory{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := bs.ConnectContext(ctx, "tcp://127.0.0.1:9527", nil); context.DeadlineExceeded != err {
+		t.Fatal("the dialing should be timed out:", err)
+	}
+
+	// the dialing is canceled by the shutdown of bootstrap.
+	time.AfterFunc(20*time.Millisecond, bs.Shutdown)
+	if _, err := bs.ConnectContext(context.Background(), "tcp://127.0.0.1:9527", nil); ErrBootstrapClosed != err {
+		t.Fatal("the dialing should be canceled:", err)
+	}
+}
+
+// barrierAcceptor to accept the first n transports only if they are accepted concurrently.
+type barrierAcceptor struct {
+	flakyFactory
+	n       int32
+	calls   int32
+	barrier chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (a *barrierAcceptor) Listen(options *transport.Options) (transport.Acceptor, error) {
+	return a, nil
+}
+
+func (a *barrierAcceptor) Accept() (transport.Transport, error) {
+
+	if k := atomic.AddInt32(&a.calls, 1); k <= a.n {
+		if k == a.n {
+			close(a.barrier)
+		}
+		select {
+		case <-a.barrier:
+			local, _ := net.Pipe()
+			return &pipeTransport{Conn: local}, nil
+		case <-time.After(3 * time.Second):
+			return nil, errors.New("the accept loops are not running concurrently")
+		}
+	}
+
+	<-a.closed
+	return nil, net.ErrClosed
+}
+
+func (a *barrierAcceptor) AcceptConcurrently() {}
+
+func (a *barrierAcceptor) Close() error {
+	a.once.Do(func() { close(a.closed) })
+	return nil
+}
+
+func TestListenerAcceptorCount(t *testing.T) {
+
+	acceptor := &barrierAcceptor{n: 4, barrier: make(chan struct{}), closed: make(chan struct{})}
+	bs := NewBootstrap(WithTransport(acceptor), WithChildInitializer(func(channel Channel) {
+		channel.Pipeline().AddLast(ignoreException)
+	}))
+	defer bs.Shutdown()
+
+	listener := bs.Listen("tcp://127.0.0.1:9527", transport.WithAcceptorCount(4))
+	result := make(chan error, 2)
+	listener.Async(func(err error) { result <- err })
+
+	// every loop receives a transport.
+	for deadline := time.Now().Add(3 * time.Second); 4 != bs.ActiveChannels(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("unexpected active channels:", bs.ActiveChannels())
+		}
+	}
+
+	listener.Close()
+	if err := <-result; ErrListenerClosed != err {
+		t.Fatal("unexpected error:", err)
+	}
+
+	select {
+	case err := <-result:
+		t.Fatal("the error should be delivered once:", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestListenerAcceptorCountStress(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	url := "tcp://" + ln.Addr().String()
+	ln.Close()
+
+	bs := NewBootstrap(WithChildInitializer(func(channel Channel) {
+		channel.Pipeline().
+			AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}).
+			AddLast(ignoreException)
+	}))
+	defer bs.Shutdown()
+
+	listener := bs.Listen(url, transport.WithAcceptorCount(4))
+	result := make(chan error, 1)
+	listener.Async(func(err error) { result <- err })
+
+	const clients = 200
+	var conns = make(chan net.Conn, clients)
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", ln.Addr().String()); nil == err {
+			conns <- conn
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i < clients; i++ {
+		go func() {
+			if conn, err := net.Dial("tcp", ln.Addr().String()); nil == err {
+				conns <- conn
+			}
+		}()
+	}
+
+	for i := 0; i < clients; i++ {
+		defer (<-conns).Close()
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); clients != bs.ActiveChannels(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("unexpected active channels:", bs.ActiveChannels())
+		}
+	}
+
+	listener.Close()
+	if err := <-result; nil == err {
+		t.Fatal("the error of closed listener should be returned")
+	}
+}
+
+// pipeAcceptor to accept the pipe transports up to the count, then wait for closing.
+type pipeAcceptor struct {
+	barrierAcceptor
+	count    int32
+	accepted int32
+}
+
+func (a *pipeAcceptor) Listen(options *transport.Options) (transport.Acceptor, error) {
+	return a, nil
+}
+
+func (a *pipeAcceptor) Accept() (transport.Transport, error) {
+	if atomic.AddInt32(&a.accepted, 1) <= a.count {
+		local, _ := net.Pipe()
+		return &pipeTransport{Conn: local}, nil
+	}
+	<-a.closed
+	return nil, net.ErrClosed
+}
+
+func TestBootstrapMaxConnections(t *testing.T) {
+
+	// serve to listen the acceptor with the policy and wait for the expected stats.
+	serve := func(policy ConnectionOverflowPolicy, expected ConnectionStats) (*pipeAcceptor, Bootstrap) {
+		acceptor := &pipeAcceptor{barrierAcceptor: barrierAcceptor{closed: make(chan struct{})}, count: 5}
+		bs := NewBootstrap(WithTransport(acceptor), WithMaxConnections(2, policy), WithChildInitializer(func(channel Channel) {
+			channel.Pipeline().AddLast(ignoreException)
+		}))
+		t.Cleanup(bs.Shutdown)
+
+		bs.Listen("tcp://127.0.0.1:9527").Async(func(err error) {})
+		for deadline := time.Now().Add(3 * time.Second); expected != bs.ConnectionStats(); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("unexpected stats:", bs.ConnectionStats())
+			}
+		}
+		return acceptor, bs
+	}
+
+	closeOne := func(bs Bootstrap) {
+		bs.RangeChannels(func(channel Channel) bool {
+			channel.Close(nil)
+			return false
+		})
+	}
+
+	t.Run("close", func(t *testing.T) {
+		_, bs := serve(ConnectionOverflowClose, ConnectionStats{Active: 2, Rejected: 3})
+		if 2 != bs.ActiveChannels() {
+			t.Fatal("the rejected transports should not be served:", bs.ActiveChannels())
+		}
+		closeOne(bs)
+		if (ConnectionStats{Active: 1, Rejected: 3}) != bs.ConnectionStats() {
+			t.Fatal("unexpected stats:", bs.ConnectionStats())
+		}
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		acceptor, bs := serve(ConnectionOverflowPause, ConnectionStats{Active: 2})
+		time.Sleep(20 * time.Millisecond)
+		if accepted := atomic.LoadInt32(&acceptor.accepted); 2 != accepted {
+			t.Fatal("the accepting should be paused:", accepted)
+		}
+
+		// the accepting is resumed by the closed channel.
+		closeOne(bs)
+		for deadline := time.Now().Add(3 * time.Second); 3 != atomic.LoadInt32(&acceptor.accepted); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("the accepting should be resumed")
+			}
+		}
+		if (ConnectionStats{Active: 2}) != bs.ConnectionStats() {
+			t.Fatal("unexpected stats:", bs.ConnectionStats())
+		}
+	})
+}
+
+func TestBootstrapShutdownClosesChannels(t *testing.T) {
+
+	inactive := make(chan Exception, 1)
+	bs := NewBootstrap(WithClientInitializer(func(channel Channel) {
+		channel.Pipeline().
+			AddLast(InactiveHandlerFunc(func(ctx InactiveContext, ex Exception) { inactive <- ex })).
+			AddLast(ignoreException)
+	}))
+
+	local, _ := net.Pipe()
+	channel := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, false)
+
+	bs.Shutdown()
+	if channel.IsActive() || ErrBootstrapClosed != channel.CloseErr() {
+		t.Fatal("the active channel should be closed:", channel.CloseErr())
+	}
+	if ex := <-inactive; !errors.Is(ex, ErrBootstrapClosed) {
+		t.Fatal("unexpected exception:", ex)
+	}
+
+	// the transports served after shutdown are closed at once.
+	local, _ = net.Pipe()
+	if late := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, false); late.IsActive() {
+		t.Fatal("the late channel should be closed")
+	}
+
+	if _, err := bs.Connect("tcp://127.0.0.1:9527", nil); ErrBootstrapClosed != err {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := bs.Listen("tcp://127.0.0.1:9527").Sync(); ErrBootstrapClosed != err {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestBootstrapShutdownListener(t *testing.T) {
+
+	bs := NewBootstrap()
+	listener := bs.Listen("tcp://127.0.0.1:0")
+
+	ready := make(chan struct{})
+	listener.OnReady(func(net.Addr) { close(ready) })
+
+	result := make(chan error, 1)
+	listener.Async(func(err error) { result <- err })
+	<-ready
+
+	// no connection is accepted, the blocked Accept is interrupted by the shutdown.
+	bs.Shutdown()
+	select {
+	case err := <-result:
+		if ErrBootstrapClosed != err {
+			t.Fatal("unexpected error:", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the listener should be stopped by the shutdown")
+	}
+}
+
+func TestListenerClosedError(t *testing.T) {
+
+	bs := NewBootstrap()
+	defer bs.Shutdown()
+
+	listener := bs.Listen("tcp://127.0.0.1:0")
+	ready := make(chan struct{})
+	listener.OnReady(func(net.Addr) { close(ready) })
+
+	result := make(chan error, 1)
+	listener.Async(func(err error) { result <- err })
+	<-ready
+
+	listener.Close()
+	if err := <-result; ErrListenerClosed != err || !errors.Is(err, net.ErrClosed) {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestListenerAddr(t *testing.T) {
+
+	bs := NewBootstrap(WithChildInitializer(func(channel Channel) {
+		channel.Pipeline().AddLast(ignoreException)
+	}))
+	defer bs.Shutdown()
+
+	l := bs.Listen("tcp://127.0.0.1:0")
+	if nil != l.Addr() {
+		t.Fatal("the address should be nil before bound")
+	}
+
+	ready := make(chan net.Addr, 1)
+	l.OnReady(func(addr net.Addr) { ready <- addr })
+
+	closed := make(chan error, 1)
+	l.Async(func(err error) { closed <- err })
+
+	addr := <-ready
+	if tcpAddr, ok := addr.(*net.TCPAddr); !ok || 0 == tcpAddr.Port {
+		t.Fatal("unexpected bound address:", addr)
+	}
+	if l.Addr() != addr {
+		t.Fatal("unexpected address:", l.Addr())
+	}
+
+	// the callback registered after bound is invoked at once.
+	var late net.Addr
+	l.OnReady(func(addr net.Addr) { late = addr })
+	if late != addr {
+		t.Fatal("unexpected late address:", late)
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	l.Close()
+	<-closed
+}
+
+func TestBootstrapLifecycleHooks(t *testing.T) {
+
+	var mutex sync.Mutex
+	var events []string
+	record := func(event string) {
+		mutex.Lock()
+		events = append(events, event)
+		mutex.Unlock()
+	}
+
+	var panicking bool
+	bs := NewBootstrap(
+		WithChannelCreated(func(channel Channel) { record("created") }),
+		WithChannelActive(func(channel Channel) { record("active") }),
+		WithChannelInactive(func(channel Channel, err error) { record("inactive") }),
+		WithClientInitializer(func(channel Channel) {
+			record("initializer")
+			if panicking {
+				panic("initializer")
+			}
+			channel.Pipeline().AddLast(ignoreException)
+		}),
+	)
+	defer bs.Shutdown()
+
+	local, _ := net.Pipe()
+	channel := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, false)
+	channel.Close(nil)
+	<-channel.Done()
+
+	mutex.Lock()
+	if got := strings.Join(events, ","); "created,initializer,active,inactive" != got {
+		t.Fatal("unexpected events:", got)
+	}
+	events = nil
+	mutex.Unlock()
+
+	// the inactive hook is called even if the initializer panics.
+	panicking = true
+	func() {
+		defer func() {
+			if v := recover(); "initializer" != v {
+				t.Fatal("unexpected panic:", v)
+			}
+		}()
+		local, _ = net.Pipe()
+		bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, false)
+	}()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if got := strings.Join(events, ","); "created,initializer,inactive" != got {
+		t.Fatal("unexpected events:", got)
+	}
+}
+
+func TestBootstrapChildAttachment(t *testing.T) {
+
+	attached := make(chan Attachment, 1)
+	bs := NewBootstrap(
+		WithChildAttachment(func(t transport.Transport) Attachment { return "child:" + t.RemoteAddr().Network() }),
+		WithChildInitializer(func(channel Channel) {
+			channel.Pipeline().
+				AddLast(ActiveHandlerFunc(func(ctx ActiveContext) { attached <- ctx.Channel().Attachment() })).
+				AddLast(ignoreException)
+		}),
+	)
+	defer bs.Shutdown()
+
+	local, _ := net.Pipe()
+	channel := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, true)
+	defer channel.Close(nil)
+
+	if attachment := <-attached; "child:pipe" != attachment {
+		t.Fatal("unexpected attachment:", attachment)
+	}
+
+	// the attachment of client channels is passed by Connect.
+	local, _ = net.Pipe()
+	client := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, "client", false)
+	defer client.Close(nil)
+	if "client" != client.Attachment() {
+		t.Fatal("unexpected attachment:", client.Attachment())
+	}
+}
+
+func TestBootstrapChildTransport(t *testing.T) {
+
+	var wrapped int32
+	bs := NewBootstrap(
+		WithChildTransport(func(t transport.Transport) transport.Transport {
+			atomic.AddInt32(&wrapped, 1)
+			return transport.Throttle(t, 0, 1<<20)
+		}),
+		WithChildInitializer(func(channel Channel) { channel.Pipeline().AddLast(ignoreException) }),
+	)
+	defer bs.Shutdown()
+
+	local, _ := net.Pipe()
+	channel := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, true)
+	defer channel.Close(nil)
+	if 1 != atomic.LoadInt32(&wrapped) || local != channel.Transport().RawTransport() {
+		t.Fatal("the accepted transport should be wrapped:", atomic.LoadInt32(&wrapped))
+	}
+
+	// the client transports are not wrapped.
+	local, _ = net.Pipe()
+	client := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, false)
+	defer client.Close(nil)
+	if 1 != atomic.LoadInt32(&wrapped) {
+		t.Fatal("the client transport should not be wrapped")
+	}
+}
+
+// pipeFactory to connect the pipe transports of scheme pipe://
+type pipeFactory struct{}
+
+func (f *pipeFactory) Schemes() transport.Schemes { return transport.Schemes{"pipe"} }
+
+func (f *pipeFactory) Connect(options *transport.Options) (transport.Transport, error) {
+	local, _ := net.Pipe()
+	return &pipeTransport{Conn: local}, nil
+}
+
+func (f *pipeFactory) Listen(options *transport.Options) (transport.Acceptor, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestBootstrapTransports(t *testing.T) {
+
+	bs := NewBootstrap(WithTransports(&pipeFactory{}, tcp.New()), WithClientInitializer(func(channel Channel) {
+		channel.Pipeline().AddLast(ignoreException)
+	}))
+	defer bs.Shutdown()
+
+	channel, err := bs.Connect("pipe://peer", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if network := channel.Transport().RemoteAddr().Network(); "pipe" != network {
+		t.Fatal("unexpected transport:", network)
+	}
+	channel.Close(nil)
+
+	if err = bs.Listen("pipe://peer").Sync(); nil == err || "not supported" != err.Error() {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the url without scheme falls back to the default factory.
+	l := bs.Listen("127.0.0.1:0")
+	ready := make(chan net.Addr, 1)
+	l.OnReady(func(addr net.Addr) { ready <- addr })
+	l.Async(func(error) {})
+	if _, ok := (<-ready).(*net.TCPAddr); !ok {
+		t.Fatal("the tcp factory should be used")
+	}
+	l.Close()
+
+	// the schemes can not be registered by different factories.
+	defer func() {
+		if nil == recover() {
+			t.Fatal("the conflicting schemes should panic")
+		}
+	}()
+	NewBootstrap(WithTransports(tcp.New(), &flakyFactory{}))
+}
+
+func TestNewBootstrapE(t *testing.T) {
+
+	bs, err := NewBootstrapE(WithChildInitializer(func(channel Channel) {}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	bs.Shutdown()
+
+	bs, err = NewBootstrapE(
+		WithMaxConnections(-1, ConnectionOverflowClose),
+		WithWriteQueueSize(-1),
+		WithTransport(nil),
+		WithTransports(tcp.New(), &flakyFactory{}),
+	)
+	if nil != bs {
+		t.Fatal("the bootstrap should not be created")
+	}
+
+	var errs BootstrapErrors
+	if !errors.As(err, &errs) || 4 != len(errs) {
+		t.Fatal("unexpected errors:", err)
+	}
+
+	expected := []string{
+		"option[0] netty.WithMaxConnections: n must be a non-negative integer",
+		"option[1] netty.WithWriteQueueSize: size must be a non-negative integer",
+		"option[3] netty.WithTransports: conflicting transport factories of scheme: tcp",
+		"the transport factory must not be nil, see WithTransport",
+	}
+	for index := range expected {
+		if expected[index] != errs[index].Error() {
+			t.Fatal("unexpected error:", errs[index])
+		}
+	}
+}
+
+func TestBootstrapAcceptFilter(t *testing.T) {
+
+	var accepted, created int32
+	banned := errors.New("banned")
+	rejected := make(chan error, 5)
+
+	acceptor := &pipeAcceptor{barrierAcceptor: barrierAcceptor{closed: make(chan struct{})}, count: 5}
+	bs := NewBootstrap(
+		WithTransport(acceptor),
+		WithAcceptFilter(func(t transport.Transport) error {
+			// reject the odd transports.
+			if 1 == atomic.AddInt32(&accepted, 1)%2 {
+				return banned
+			}
+			return nil
+		}),
+		WithAcceptRejected(func(t transport.Transport, err error) { rejected <- err }),
+		WithChildInitializer(func(channel Channel) {
+			atomic.AddInt32(&created, 1)
+			channel.Pipeline().AddLast(ignoreException)
+		}),
+	)
+	defer bs.Shutdown()
+
+	bs.Listen("tcp://127.0.0.1:9527").Async(func(err error) {})
+	for i := 0; i < 3; i++ {
+		if err := <-rejected; banned != err {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for deadline := time.Now().Add(3 * time.Second); 2 != bs.ActiveChannels(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("unexpected active channels:", bs.ActiveChannels())
+		}
+	}
+
+	if stats := bs.ConnectionStats(); 3 != stats.Filtered || 0 != stats.Rejected || 2 != atomic.LoadInt32(&created) {
+		t.Fatal("the filtered transports should not be served:", stats)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"net"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// Buffers defines an outbound message composed of multiple []byte
+//
+// The outbound handlers could prepend a header or append a trailer as its own slice without copying,
+// all slices will be gathered into single Writev call by the write loop of channel.
+// The pooled resources attached by ReleaseAfterWrite will be released after written.
+type Buffers struct {
+	buffers   net.Buffers
+	releasers []utils.Releaser
+}
+
+// NewBuffers create a Buffers with slices
+func NewBuffers(buffs ...[]byte) *Buffers {
+	return &Buffers{buffers: append(make(net.Buffers, 0, len(buffs)+2), buffs...)}
+}
+
+// Prepend slices to the head of buffers
+func (b *Buffers) Prepend(buffs ...[]byte) *Buffers {
+	b.buffers = append(append(make(net.Buffers, 0, len(buffs)+len(b.buffers)+1), buffs...), b.buffers...)
+	return b
+}
+
+// Append slices to the end of buffers
+func (b *Buffers) Append(buffs ...[]byte) *Buffers {
+	b.buffers = append(b.buffers, buffs...)
+	return b
+}
+
+// Len returns the number of bytes of buffers
+func (b *Buffers) Len() int64 {
+	return utils.CountOf(b.buffers)
+}
+
+// Count returns the number of slices
+func (b *Buffers) Count() int {
+	return len(b.buffers)
+}
+
+// Bytes returns the slices of buffers
+func (b *Buffers) Bytes() [][]byte {
+	return b.buffers
+}
+
+// ReleaseAfterWrite to attach the pooled resource which will be released after the buffers written
+func (b *Buffers) ReleaseAfterWrite(releaser utils.Releaser) *Buffers {
+	b.releasers = append(b.releasers, releaser)
+	return b
+}
+
+// Release all attached resources
+func (b *Buffers) Release() {
+	releasers := b.releasers
+	b.releasers = nil
+	for _, r := range releasers {
+		r.Release()
+	}
+}
+
+// Read to consume the buffers as a io.Reader, it's the fallback for the handlers that need bytes.
+func (b *Buffers) Read(p []byte) (int, error) {
+	return b.buffers.Read(p)
+}
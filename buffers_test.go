@@ -0,0 +1,161 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countReleaser struct {
+	released int32
+}
+
+func (c *countReleaser) Release() {
+	atomic.AddInt32(&c.released, 1)
+}
+
+func TestBuffers(t *testing.T) {
+
+	buffers := NewBuffers([]byte("payload")).
+		Prepend([]byte("header:")).
+		Append([]byte(":checksum"))
+
+	if 3 != buffers.Count() || int64(len("header:payload:checksum")) != buffers.Len() {
+		t.Fatal("unexpected buffers:", buffers.Count(), buffers.Len())
+	}
+
+	releaser := &countReleaser{}
+	buffers.ReleaseAfterWrite(releaser)
+
+	data, err := ioutil.ReadAll(buffers)
+	if nil != err || "header:payload:checksum" != string(data) {
+		t.Fatal(string(data), err)
+	}
+
+	buffers.Release()
+	buffers.Release()
+	if 1 != atomic.LoadInt32(&releaser.released) {
+		t.Fatal("unexpected release count:", releaser.released)
+	}
+}
+
+func TestBuffersWrite(t *testing.T) {
+
+	p := NewPipelineWith()
+	p.AddLast(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		// prepend the header without copying.
+		ctx.HandleWrite(message.(*Buffers).Prepend([]byte{byte(message.(*Buffers).Len())}))
+	}))
+
+	c, peer := newPipeChannel(1, p)
+	c.serveChannel()
+	defer c.Close(nil)
+	defer c.Close(nil)
+
+	var releasers []*countReleaser
+	var payloads = [][]byte{[]byte("hello"), []byte("go-netty")}
+	for _, payload := range payloads {
+		releaser := &countReleaser{}
+		releasers = append(releasers, releaser)
+		c.Write(NewBuffers(payload).ReleaseAfterWrite(releaser))
+	}
+
+	for _, payload := range payloads {
+		frame := make([]byte, len(payload)+1)
+		if _, err := io.ReadFull(peer, frame); nil != err {
+			t.Fatal(err)
+		}
+
+		if int(frame[0]) != len(payload) || !bytes.Equal(payload, frame[1:]) {
+			t.Fatal("unexpected frame:", frame)
+		}
+	}
+
+	// the resources will be released after the buffers written.
+	deadline := time.Now().Add(time.Second)
+	for _, releaser := range releasers {
+		for 1 != atomic.LoadInt32(&releaser.released) {
+			if time.Now().After(deadline) {
+				t.Fatal("the resources have not been released")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestBuffersWriteAtomic(t *testing.T) {
+
+	c, peer := newPipeChannel(1, NewPipelineWith())
+	c.serveChannel()
+	defer c.Close(nil)
+
+	const writers, count = 8, 100
+
+	// every frame is written by one Writev entry, so the header and payload of frames are never interleaved.
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(id byte) {
+			defer wg.Done()
+			for n := 0; n < count; n++ {
+				c.Write([][]byte{{id}, bytes.Repeat([]byte{id}, 7)})
+			}
+		}(byte('a' + i))
+	}
+
+	frame := make([]byte, 8)
+	for i := 0; i < writers*count; i++ {
+		if _, err := io.ReadFull(peer, frame); nil != err {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bytes.Repeat(frame[:1], 8), frame) {
+			t.Fatal("the frames are interleaved:", string(frame))
+		}
+	}
+	wg.Wait()
+}
+
+func BenchmarkHeaderPayload(b *testing.B) {
+
+	header, payload := make([]byte, 16), make([]byte, 4096)
+
+	for name, message := range map[string]func() Message{
+		"concat": func() Message {
+			return append(append(make([]byte, 0, len(header)+len(payload)), header...), payload...)
+		},
+		"writev": func() Message { return [][]byte{header, payload} },
+	} {
+		b.Run(name, func(b *testing.B) {
+
+			c := newWritePathChannel()
+			defer c.Close(nil)
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(header) + len(payload)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Write(message())
+			}
+		})
+	}
+}
@@ -19,39 +19,99 @@ package netty
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"runtime/debug"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/go-netty/go-netty/transport"
 	"github.com/go-netty/go-netty/utils"
 )
 
+var (
+	// ErrChannelClosed will be returned when write to an inactive channel.
+	ErrChannelClosed = errors.New("the channel has been closed")
+	// ErrWriteQueueFull will be returned when the send queue of channel is full.
+	ErrWriteQueueFull = errors.New("the send queue of channel is full")
+)
+
 // Channel is defines a server-side-channel & client-side-channel
 type Channel interface {
 	// ID channel id
 	ID() int64
 
+	// Name of channel, it is the decimal channel id by default, see WithChannelName
+	Name() string
+
+	// IsServer returns true if the channel is accepted by a listener, false if it is connected by the bootstrap.
+	IsServer() bool
+
 	// Write message through the Pipeline
 	Write(Message) bool
 
+	// WriteAndFlush to write message and flush the pending bytes without the delay of FlushPolicy
+	WriteAndFlush(Message) bool
+
+	// WriteWithPromise to write message through the Pipeline, the future is completed after the bytes
+	// of message are written by transport, or failed with the write error or ErrChannelClosed.
+	WriteWithPromise(Message) WriteFuture
+
+	// TryWrite to write message through the Pipeline without waiting for the send queue,
+	// returns ErrChannelClosed if the channel is inactive, ErrWriteQueueFull if the send queue is full.
+	TryWrite(Message) error
+
+	// Flush the pending bytes without the delay of FlushPolicy
+	Flush()
+
+	// SetAutoRead to pause or resume the reading of channel, the channel reads automatically by default.
+	SetAutoRead(enable bool)
+
+	// IsAutoRead returns false if the reading of channel is paused
+	IsAutoRead() bool
+
+	// PassthroughMode to relay the bytes read from transport to the target channel without the pipelines,
+	// the nil target exits the passthrough mode.
+	PassthroughMode(target Channel, option ...PassthroughOption)
+
 	// Trigger user event
 	Trigger(event Event)
 
 	// Close through the Pipeline
 	Close(err error)
 
+	// CloseGracefully to reject the new writes and close the channel after the queued bytes are flushed,
+	// the channel is closed immediately with the timeout error if the bytes are not flushed in time.
+	CloseGracefully(timeout time.Duration) error
+
+	// CloseWrite to reject the new writes and shut down the writing side of transport after the queued bytes are flushed,
+	// the channel keeps reading until the peer closes, see transport.CloseWrite
+	CloseWrite() error
+
 	// IsActive return true if the Channel is active and so connected
 	IsActive() bool
 
+	// IsWritable returns false if the queued bytes have reached the high watermark, see WithWriteWatermark
+	IsWritable() bool
+
+	// OnClose to add a callback that is called with the error of Close after the channel is closed,
+	// it is called at once if the channel has been closed.
+	OnClose(fn func(err error))
+
+	// Done returns a chan that is closed after the channel is closed and the inactive event is handled
+	Done() <-chan struct{}
+
+	// CloseErr returns the error that the channel is closed with
+	CloseErr() error
+
 	// Writev to write [][]byte for optimize syscall
 	Writev([][]byte) (int64, error)
 
-	// LocalAddr local address
+	// LocalAddr local address, it is delegated to the transport
 	LocalAddr() string
 
-	// RemoteAddr remote address
+	// RemoteAddr remote address, it is delegated to the transport
 	RemoteAddr() string
 
 	// Transport get transport of channel
@@ -66,52 +126,244 @@ type Channel interface {
 	// SetAttachment set attachment
 	SetAttachment(Attachment)
 
-	// Context channel context
+	// Context channel context, it is derived from the bootstrap context and canceled before the channel inactive.
 	Context() context.Context
 
+	// Stats returns the counters of channel
+	Stats() ChannelStats
+
 	// Start send & write routines.
 	serveChannel()
+
+	// writeBuffers to write [][]byte and release the resources after written
+	writeBuffers(p [][]byte, releaser utils.Releaser) (int64, error)
+
+	// writeBuffer to write []byte and release the resources after written
+	writeBuffer(p []byte, releaser utils.Releaser) (int64, error)
+
+	// counters returns the atomic counters of channel
+	counters() *channelStats
+
+	// debugInfo returns the runtime information of channel
+	debugInfo() ChannelDebugInfo
+
+	// onClose to add a function that is called after the channel is closed
+	onClose(fn func())
+
+	// attributes returns the attributes of channel, see Attr
+	attributes() *attributeMap
+}
+
+// ChannelOption defines an option of channel
+type ChannelOption func(options *channelOptions)
+
+// channelOptions
+type channelOptions struct {
+	newQueue       func(capacity int) outboundQueue
+	flushPolicy    FlushPolicy
+	queueSize      int
+	overflowPolicy WriteOverflowPolicy
+	watermark      writeWatermark
+	saturation     queueSaturation
+	maxPending     int64
+}
+
+// WriteOverflowPolicy defines the behavior of writing to a full send queue.
+type WriteOverflowPolicy int
+
+const (
+	// WriteOverflowBlock to wait until the send queue has space, it is the default policy.
+	WriteOverflowBlock WriteOverflowPolicy = iota
+	// WriteOverflowFail to fail the write with ErrWriteQueueFull, the error is fired to the pipeline as an exception.
+	WriteOverflowFail
+	// WriteOverflowDropOldest to drop the oldest entry of send queue, the chan based queue is used for evicting.
+	WriteOverflowDropOldest
+)
+
+// FlushPolicy defines the policy to delay the flushing of write loop for bigger batches.
+//
+// The pending bytes are flushed when MaxDelay elapsed since the first pending message,
+// or MaxBytes / MaxMessages are reached, zero limit means unlimited.
+type FlushPolicy struct {
+	MaxDelay    time.Duration
+	MaxBytes    int
+	MaxMessages int
+}
+
+// enabled returns true if the flushing should be delayed
+func (p FlushPolicy) enabled() bool {
+	return p.MaxDelay > 0
+}
+
+// WithChanQueue to use the chan based send queue instead of the lock-free queue.
+func WithChanQueue() ChannelOption {
+	return func(options *channelOptions) {
+		options.newQueue = newChanQueue
+	}
+}
+
+// WithChannelWriteQueueSize to set the capacity of send queue, it overrides the capacity of ChannelFactory.
+func WithChannelWriteQueueSize(size int) ChannelOption {
+	return func(options *channelOptions) {
+		utils.AssertIf(size < 0, "size must be a non-negative integer")
+		options.queueSize = size
+	}
+}
+
+// WithChannelWriteOverflowPolicy to set the policy of writing to a full send queue.
+func WithChannelWriteOverflowPolicy(policy WriteOverflowPolicy) ChannelOption {
+	return func(options *channelOptions) {
+		utils.AssertIf(policy < WriteOverflowBlock || policy > WriteOverflowDropOldest, "unknown overflow policy: %d", policy)
+		options.overflowPolicy = policy
+	}
+}
+
+// WithChannelMaxPendingWriteBytes to limit the bytes queued to be written, the overflow policy is applied
+// when the limit is exceeded, zero means unlimited. A message larger than the limit is queued alone.
+func WithChannelMaxPendingWriteBytes(n int) ChannelOption {
+	return func(options *channelOptions) {
+		utils.AssertIf(n < 0, "n must be a non-negative integer")
+		options.maxPending = int64(n)
+	}
+}
+
+// WithChannelFlushPolicy to delay the flushing of channel, it overrides the policy of bootstrap.
+func WithChannelFlushPolicy(maxDelay time.Duration, maxBytes int, maxMessages int) ChannelOption {
+	return func(options *channelOptions) {
+		options.flushPolicy = FlushPolicy{MaxDelay: maxDelay, MaxBytes: maxBytes, MaxMessages: maxMessages}
+	}
+}
+
+// channelOptionsKey is the context key of the channel options from bootstrap
+type channelOptionsKey struct{}
+
+// parseChannelOptions to apply the options of bootstrap and then the options of channel over the defaults
+func parseChannelOptions(ctx context.Context, option ...ChannelOption) *channelOptions {
+	options := &channelOptions{newQueue: newMPSCQueue}
+	if defaults, ok := ctx.Value(channelOptionsKey{}).([]ChannelOption); ok {
+		for i := range defaults {
+			defaults[i](options)
+		}
+	}
+	for i := range option {
+		option[i](options)
+	}
+	return options
 }
 
 // NewChannel create a ChannelFactory
-func NewChannel(capacity int) ChannelFactory {
+func NewChannel(capacity int, option ...ChannelOption) ChannelFactory {
 	return func(id int64, ctx context.Context, pipeline Pipeline, transport transport.Transport) Channel {
-		return newChannelWith(ctx, pipeline, transport, id, capacity)
+		return newChannelWith(ctx, pipeline, transport, id, capacity, parseChannelOptions(ctx, option...))
 	}
 }
 
 // NewBufferedChannel create a ChannelFactory with buffered transport
-func NewBufferedChannel(capacity int, sizeRead int) ChannelFactory {
+func NewBufferedChannel(capacity int, sizeRead int, option ...ChannelOption) ChannelFactory {
 	return func(id int64, ctx context.Context, pipeline Pipeline, tran transport.Transport) Channel {
 		tran = transport.BufferedTransport(tran, sizeRead)
-		return newChannelWith(ctx, pipeline, tran, id, capacity)
+		return newChannelWith(ctx, pipeline, tran, id, capacity, parseChannelOptions(ctx, option...))
 	}
 }
 
 // newChannelWith internal method for NewChannel & NewBufferedChannel
-func newChannelWith(ctx context.Context, pipeline Pipeline, transport transport.Transport, id int64, capacity int) Channel {
+func newChannelWith(ctx context.Context, pipeline Pipeline, tran transport.Transport, id int64, capacity int, options *channelOptions) Channel {
+	if options.queueSize > 0 {
+		capacity = options.queueSize
+	}
+
+	// the oldest entry can be evicted by the writers from the chan based queue only.
+	newQueue := options.newQueue
+	if WriteOverflowDropOldest == options.overflowPolicy {
+		newQueue = newChanQueue
+	}
+
 	childCtx, cancel := context.WithCancel(ctx)
-	return &channel{
-		id:        id,
-		ctx:       childCtx,
-		cancel:    cancel,
-		pipeline:  pipeline,
-		transport: transport,
-		sendQueue: make(chan [][]byte, capacity),
+	c := &channel{
+		id:          id,
+		name:        channelNameFrom(ctx)(id),
+		server:      "" != listenerURL(ctx),
+		ctx:         childCtx,
+		cancel:      cancel,
+		pipeline:    pipeline,
+		created:     ClockFrom(ctx).Now(),
+		sendQueue:   newQueue(capacity),
+		overflow:    options.overflowPolicy,
+		watermark:   options.watermark,
+		saturation:  options.saturation,
+		maxPending:  options.maxPending,
+		flushPolicy: options.flushPolicy,
+		flushSignal: make(chan struct{}, 1),
+		doneSignal:  make(chan struct{}),
+		readResume:  make(chan struct{}, 1),
+	}
+	// the bytes peeked before serving will be drained by the read loop.
+	c.transport = transport.PushbackTransport(&statsTransport{Transport: tran, channel: c})
+	return c
+}
+
+// outboundEntry defines the bytes waiting to be written
+type outboundEntry struct {
+	buffer   []byte
+	buffers  [][]byte
+	releaser utils.Releaser
+}
+
+// size returns the number of bytes of entry
+func (e *outboundEntry) size() int {
+	if nil != e.buffers {
+		return int(utils.CountOf(e.buffers))
 	}
+	return len(e.buffer)
+}
+
+// appendTo to append the bytes of entry to buffers
+func (e *outboundEntry) appendTo(buffers net.Buffers) net.Buffers {
+	if nil != e.buffers {
+		return append(buffers, e.buffers...)
+	}
+	// the barriers of written carry no bytes.
+	if 0 == len(e.buffer) {
+		return buffers
+	}
+	return append(buffers, e.buffer)
 }
 
 // implement of Channel
 type channel struct {
-	id         int64
-	ctx        context.Context
-	cancel     context.CancelFunc
-	transport  transport.Transport
-	pipeline   Pipeline
-	attachment Attachment
-	sendQueue  chan [][]byte
-	activeWait sync.WaitGroup
-	closed     int32
+	stats       channelStats // 64-bit aligned for atomic operations
+	writability writability  // 64-bit aligned for atomic operations
+	id          int64
+	name        string
+	server      bool
+	ctx         context.Context
+	cancel      context.CancelFunc
+	transport   transport.Transport
+	pipeline    Pipeline
+	attrs       attributeMap
+	sendQueue   outboundQueue
+	overflow    WriteOverflowPolicy
+	watermark   writeWatermark
+	saturation  queueSaturation
+	maxPending  int64
+	flushPolicy FlushPolicy
+	flushSignal chan struct{}
+	flushBatch  uint32       // the batch requested by Flush, numbered by batches+1
+	batches     uint32       // the number of batches assembled by the write loop
+	passthrough atomic.Value // *passthroughMode
+	activeWait  sync.WaitGroup
+	closed      int32
+	closing     int32
+	shutdown    atomic.Value // *writePromise of the barrier queued by shutdownWrite
+	readPaused  int32
+	readResume  chan struct{}
+	created     time.Time
+	closeMutex  sync.Mutex
+	closeHooks  []func()
+	closeErr    error
+	doneSignal  chan struct{}
+	drainMutex  sync.Mutex
+	drained     int32
 }
 
 // ID get channel id
@@ -119,17 +371,97 @@ func (c *channel) ID() int64 {
 	return c.id
 }
 
+// Name of channel
+func (c *channel) Name() string {
+	return c.name
+}
+
+// IsServer returns true if the channel is accepted by a listener
+func (c *channel) IsServer() bool {
+	return c.server
+}
+
 // Write message through the Pipeline
 func (c *channel) Write(message Message) bool {
+	return nil == c.write(message)
+}
+
+// write message through the Pipeline, returns ErrChannelClosed or ErrWriteQueueFull if failed.
+func (c *channel) write(message Message) error {
+
+	// the channel is closing gracefully.
+	if 1 == atomic.LoadInt32(&c.closing) {
+		Recycle(message)
+		return ErrChannelClosed
+	}
 
 	select {
 	case <-c.ctx.Done():
-		return false
+		Recycle(message)
+		return ErrChannelClosed
+	default:
+		return c.invokeWrite(message)
+	}
+}
+
+// TryWrite to write message through the Pipeline without waiting for the send queue.
+//
+// The send queue is checked before the message is encoded, the concurrent writers may still
+// fill it up, in which case the encoded bytes wait for the queue like Write.
+func (c *channel) TryWrite(message Message) error {
+
+	if !c.IsActive() {
+		Recycle(message)
+		return ErrChannelClosed
+	}
+
+	if c.sendQueue.size() >= c.sendQueue.capacity() ||
+		(c.maxPending > 0 && atomic.LoadInt64(&c.writability.queuedBytes) >= c.maxPending) {
+		Recycle(message)
+		return ErrWriteQueueFull
+	}
+
+	return c.write(message)
+}
+
+// WriteWithPromise to write message through the Pipeline and returns the future of written.
+//
+// The promise is queued after the bytes of message as a barrier, so the futures are completed in the order of writes.
+func (c *channel) WriteWithPromise(message Message) WriteFuture {
+
+	promise := newWritePromise()
+	if !c.Write(message) {
+		promise.complete(ErrChannelClosed)
+		return promise
+	}
+
+	if _, err := c.writeBuffer(nil, promise); nil != err {
+		promise.complete(ErrChannelClosed)
+	}
+	return promise
+}
+
+// WriteAndFlush to write message and flush the pending bytes without the delay of FlushPolicy
+func (c *channel) WriteAndFlush(message Message) bool {
+	ok := c.Write(message)
+	c.Flush()
+	return ok
+}
+
+// Flush the pending bytes without the delay of FlushPolicy
+func (c *channel) Flush() {
+	// nothing to flush, the signal would cut the delay of the next batch.
+	if c.flushPolicy.enabled() && atomic.LoadInt64(&c.writability.queuedBytes) > 0 {
+		atomic.StoreUint32(&c.flushBatch, atomic.LoadUint32(&c.batches)+1)
+		c.signalFlush()
+	}
+}
+
+// signalFlush to wake up the write loop waiting for the delayed batch
+func (c *channel) signalFlush() {
+	select {
+	case c.flushSignal <- struct{}{}:
 	default:
-		c.invokeMethod(func() {
-			c.pipeline.FireChannelWrite(message)
-		})
-		return true
 	}
 }
 
@@ -143,28 +475,220 @@ func (c *channel) Trigger(event Event) {
 
 // Close through the Pipeline
 func (c *channel) Close(err error) {
-	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
-		c.cancel()
-		c.transport.Close()
 
-		c.invokeMethod(func() {
-			c.pipeline.FireChannelInactive(AsException(err, debug.Stack()))
-		})
+	// the error is set with the closed flag, so the callbacks of closed channel can see it.
+	c.closeMutex.Lock()
+	closing := atomic.CompareAndSwapInt32(&c.closed, 0, 1)
+	if closing {
+		c.closeErr = err
+	}
+	c.closeMutex.Unlock()
+
+	if !closing {
+		return
+	}
+
+	c.cancel()
+	c.transport.Close()
+	// the write loop may be waiting for the delayed batch.
+	c.signalFlush()
+
+	c.invokeMethod(func() {
+		c.pipeline.FireChannelInactive(AsException(err, debug.Stack()))
+	})
+
+	c.closeMutex.Lock()
+	hooks := c.closeHooks
+	c.closeHooks = nil
+	c.closeMutex.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+	close(c.doneSignal)
+}
+
+// CloseGracefully to close the channel after the queued bytes are flushed
+func (c *channel) CloseGracefully(timeout time.Duration) error {
+
+	// the writing side may have been shut down by CloseWrite, then the channel is closed after its barrier.
+	promise, err := c.shutdownWrite()
+	if nil != err && nil == promise {
+		return err
+	}
+
+	expired := make(chan struct{})
+	defer ClockFrom(c.ctx).Schedule(timeout, func() { close(expired) })()
+
+	select {
+	case <-promise.Done():
+		if err := promise.Err(); nil != err {
+			return err
+		}
+		c.Close(nil)
+		return nil
+	case <-c.ctx.Done():
+		return ErrChannelClosed
+	case <-expired:
+		err := fmt.Errorf("close timeout: the written bytes have not been flushed in %s", timeout)
+		c.Close(err)
+		return err
+	}
+}
+
+// CloseWrite to shut down the writing side of transport after the queued bytes are flushed
+func (c *channel) CloseWrite() error {
+
+	promise, err := c.shutdownWrite()
+	if nil != err {
+		return ErrChannelClosed
+	}
+
+	select {
+	case <-promise.Done():
+		if err := promise.Err(); nil != err {
+			return err
+		}
+		return transport.CloseWrite(c.transport)
+	case <-c.ctx.Done():
+		return ErrChannelClosed
+	}
+}
+
+// shutdownWrite to reject the new writes and queue a barrier that is completed after the queued bytes are written,
+// the barrier of the previous shutdown is returned with ErrChannelClosed if the writing side has been shut down.
+func (c *channel) shutdownWrite() (*writePromise, error) {
+
+	if !c.IsActive() {
+		return nil, ErrChannelClosed
 	}
+
+	promise := newWritePromise()
+	if !c.shutdown.CompareAndSwap(nil, promise) {
+		return c.shutdown.Load().(*writePromise), ErrChannelClosed
+	}
+
+	atomic.StoreInt32(&c.closing, 1)
+	if !c.sendQueue.put(outboundEntry{releaser: promise}, c.ctx.Done()) {
+		return nil, ErrChannelClosed
+	}
+	c.drainLate()
+	c.Flush()
+	return promise, nil
+}
+
+// OnClose to add a callback that is called with the error of Close after the channel is closed
+func (c *channel) OnClose(fn func(err error)) {
+	c.onClose(func() { fn(c.CloseErr()) })
+}
+
+// Done returns a chan that is closed after the channel is closed and the inactive event is handled
+func (c *channel) Done() <-chan struct{} {
+	return c.doneSignal
+}
+
+// CloseErr returns the error that the channel is closed with
+func (c *channel) CloseErr() error {
+	c.closeMutex.Lock()
+	defer c.closeMutex.Unlock()
+	return c.closeErr
+}
+
+// onClose to add a function that is called after the channel is closed, it is called at once if closed.
+func (c *channel) onClose(fn func()) {
+	c.closeMutex.Lock()
+	if c.IsActive() {
+		c.closeHooks = append(c.closeHooks, fn)
+		c.closeMutex.Unlock()
+		return
+	}
+	c.closeMutex.Unlock()
+	fn()
 }
 
 // Writev to write [][]byte for optimize syscall
 func (c *channel) Writev(p [][]byte) (n int64, err error) {
+	return c.writeBuffers(p, nil)
+}
+
+// writeBuffers to write [][]byte and release the resources after written
+func (c *channel) writeBuffers(p [][]byte, releaser utils.Releaser) (n int64, err error) {
+
+	if err = c.enqueue(outboundEntry{buffers: p, releaser: releaser}); nil != err {
+		return 0, err
+	}
+	return utils.CountOf(p), nil
+}
+
+// writeBuffer to write []byte and release the resources after written
+func (c *channel) writeBuffer(p []byte, releaser utils.Releaser) (n int64, err error) {
+
+	if err = c.enqueue(outboundEntry{buffer: p, releaser: releaser}); nil != err {
+		return 0, err
+	}
+	return int64(len(p)), nil
+}
+
+// enqueue to queue the entry to the send queue with the overflow policy
+func (c *channel) enqueue(entry outboundEntry) error {
 
 	select {
 	case <-c.ctx.Done():
-		return 0, errors.New("broken pipe")
-	case c.sendQueue <- p:
-		for _, d := range p {
-			n += int64(len(d))
+		return errors.New("broken pipe")
+	default:
+		if 1 == atomic.LoadInt32(&c.closing) {
+			return errors.New("broken pipe")
+		}
+	}
+
+	// the bytes are counted before queued, so the write loop never sees the negative count.
+	size := int64(entry.size())
+	for !c.reserveWritability(size) {
+		switch c.overflow {
+		case WriteOverflowFail:
+			return ErrWriteQueueFull
+		case WriteOverflowDropOldest:
+			if oldest, ok := c.sendQueue.poll(); ok {
+				c.updateWritability(-int64(oldest.size()))
+				completeEntry(oldest.releaser, ErrWriteQueueFull)
+				continue
+			}
+			// the pending bytes are being written by the write loop.
+			fallthrough
+		default:
+			if !c.waitWritability(size) {
+				return errors.New("broken pipe")
+			}
+		}
+	}
+
+	switch c.overflow {
+	case WriteOverflowFail:
+		if !c.sendQueue.offer(entry) {
+			c.updateWritability(-size)
+			return ErrWriteQueueFull
+		}
+	case WriteOverflowDropOldest:
+		for !c.sendQueue.offer(entry) {
+			oldest, ok := c.sendQueue.poll()
+			if !ok {
+				// nothing can be dropped, e.g: the capacity of send queue is zero.
+				c.updateWritability(-size)
+				return ErrWriteQueueFull
+			}
+			c.updateWritability(-int64(oldest.size()))
+			completeEntry(oldest.releaser, ErrWriteQueueFull)
+		}
+	default:
+		if !c.sendQueue.put(entry, c.ctx.Done()) {
+			c.updateWritability(-size)
+			return errors.New("broken pipe")
 		}
-		return
 	}
+
+	c.updateSaturation()
+	c.drainLate()
+	return nil
 }
 
 // IsActive return true if the Channel is active and so connected
@@ -194,12 +718,18 @@ func (c *channel) RemoteAddr() string {
 
 // Attachment get attachment of channel
 func (c *channel) Attachment() Attachment {
-	return c.attachment
+	attachment, _ := Attr(c, attachmentKey).Get()
+	return attachment
 }
 
 // SetAttachment set attachment of channel
 func (c *channel) SetAttachment(v Attachment) {
-	c.attachment = v
+	Attr(c, attachmentKey).Set(v)
+}
+
+// attributes returns the attributes of channel
+func (c *channel) attributes() *attributeMap {
+	return &c.attrs
 }
 
 // Context get context of channel
@@ -209,39 +739,67 @@ func (c *channel) Context() context.Context {
 
 // start write & read routines
 func (c *channel) serveChannel() {
+	now := ClockFrom(c.ctx).Now().UnixNano()
+	atomic.StoreInt64(&c.stats.activeSince, now)
+	atomic.StoreInt64(&c.stats.lastActivity, now)
+
+	labels := c.profileLabels()
 	c.activeWait.Add(1)
-	go c.readLoop()
-	go c.writeLoop()
+	goWithLabels(labels, c.readLoop)
+	goWithLabels(labels, c.writeLoop)
 	c.activeWait.Wait()
 }
 
 func (c *channel) invokeMethod(fn func()) {
+	defer c.recoverException()
+	fn()
+}
+
+// invokeRead to read message without closure allocations
+func (c *channel) invokeRead() {
+	defer c.recoverException()
+	atomic.AddInt64(&c.stats.reads, 1)
+	c.pipeline.FireChannelRead(c.transport)
+}
 
+// invokeWrite to write message without closure allocations, returns ErrWriteQueueFull if the send queue overflowed.
+func (c *channel) invokeWrite(message Message) (err error) {
 	defer func() {
-		if err := recover(); nil != err && 0 == atomic.LoadInt32(&c.closed) {
-			c.pipeline.FireChannelException(AsException(err, debug.Stack()))
-
-			if e, ok := err.(error); ok {
-				var ne net.Error
-				if errors.As(e, &ne) && !ne.Temporary() {
-					c.Close(e)
-				}
+		if r := recover(); nil != r {
+			if e, ok := r.(error); ok && errors.Is(e, ErrWriteQueueFull) {
+				err = ErrWriteQueueFull
+			}
+			if 0 == atomic.LoadInt32(&c.closed) {
+				recoverPanic(c.pipeline.ContextAt(0), r, debug.Stack())
 			}
 		}
 	}()
+	c.pipeline.FireChannelWrite(message)
+	return nil
+}
 
-	fn()
+// recoverException to route the panic to the pipeline, it must be called by defer directly.
+//
+//go:noinline
+func (c *channel) recoverException() {
+	if err := recover(); nil != err && 0 == atomic.LoadInt32(&c.closed) {
+		recoverPanic(c.pipeline.ContextAt(0), err, debug.Stack())
+	}
 }
 
 // reading message of channel
 func (c *channel) readLoop() {
 
 	defer func() {
-		if err := recover(); nil != err {
+		err := recover()
+		if nil != err {
 			c.Close(AsException(err, debug.Stack()))
 		} else {
 			c.Close(nil)
 		}
+		// return the pooled read buffer of transport.
+		utils.Release(c.transport)
+		repanic(err)
 	}()
 
 	func() {
@@ -254,9 +812,14 @@ func (c *channel) readLoop() {
 		case <-c.ctx.Done():
 			return
 		default:
-			c.invokeMethod(func() {
-				c.pipeline.FireChannelRead(c.transport)
-			})
+			switch mode := c.passthroughMode(); {
+			case nil != mode:
+				c.invokeRelay(mode)
+			case !c.IsAutoRead() && c.waitAutoRead():
+				// check the state again after resumed.
+			default:
+				c.invokeRead()
+			}
 		}
 	}
 }
@@ -265,54 +828,146 @@ func (c *channel) readLoop() {
 func (c *channel) writeLoop() {
 
 	defer func() {
-		if err := recover(); nil != err {
+		err := recover()
+		if nil != err {
 			c.Close(AsException(err, debug.Stack()))
 		} else {
 			c.Close(nil)
 		}
+		// release the resources of unsent buffers.
+		c.drainMutex.Lock()
+		atomic.StoreInt32(&c.drained, 1)
+		c.releaseQueue()
+		c.drainMutex.Unlock()
+		repanic(err)
 	}()
 
-	var bufferCap = cap(c.sendQueue)
+	var bufferCap = c.sendQueue.capacity()
 	var buffers = make(net.Buffers, 0, bufferCap)
 	var indexes = make([]int, 0, bufferCap)
+	var releasers = make([]utils.Releaser, 0, bufferCap)
+	var pendingBytes int
+
+	// append the entry to the pending batch.
+	appendEntry := func(entry outboundEntry) {
+		buffers = entry.appendTo(buffers)
+		indexes = append(indexes, len(buffers))
+		if nil != entry.releaser {
+			releasers = append(releasers, entry.releaser)
+		}
+		pendingBytes += entry.size()
+	}
 
 	// Try to combine packet sending to optimize sending performance
-	sendWithWritev := func(data [][]byte, queue <-chan [][]byte) (int64, error) {
-
-		// reuse buffer.
-		sendBuffers := buffers[:0]
-		sendIndexes := indexes[:0]
-
-		// append first packet.
-		sendBuffers = append(sendBuffers, data...)
-		sendIndexes = append(sendIndexes, len(sendBuffers))
-
-		// more packet will be merged.
-		for {
-			select {
-			case data := <-queue:
-				sendBuffers = append(sendBuffers, data...)
-				sendIndexes = append(sendIndexes, len(sendBuffers))
-				// 合并到一定数量的buffer之后直接发送，防止无限撑大buffer
-				// 最大一次合并发送的size由sendQueue的cap来决定
-				if len(sendIndexes) >= bufferCap {
-					return c.transport.Writev(transport.Buffers{Buffers: sendBuffers, Indexes: sendIndexes})
-				}
-			default:
-				return c.transport.Writev(transport.Buffers{Buffers: sendBuffers, Indexes: sendIndexes})
+	// 合并到一定数量的buffer之后直接发送，防止无限撑大buffer
+	// 最大一次合并发送的size由sendQueue的cap来决定
+	appendQueued := func() {
+		for len(indexes) < bufferCap {
+			entry, ok := c.sendQueue.poll()
+			if !ok {
+				return
 			}
+			appendEntry(entry)
 		}
 	}
 
-	for {
+	// the pending batch is not full according to the flush policy.
+	policy := c.flushPolicy
+	batchable := func() bool {
+		return len(indexes) < bufferCap &&
+			(policy.MaxBytes <= 0 || pendingBytes < policy.MaxBytes) &&
+			(policy.MaxMessages <= 0 || len(indexes) < policy.MaxMessages)
+	}
+
+	// wait for more entries until the delay elapsed or the flush is required, returns false if the channel closed.
+	clock := ClockFrom(c.ctx)
+	appendDelayed := func() bool {
+		// drop the stale signal of the written batches or the canceled timer, the flush requested after
+		// the previous batch is kept by flushBatch, and the signal of Close is checked by the context.
 		select {
-		case buf := <-c.sendQueue:
-			// combine send bytes to reduce syscall.
-			utils.AssertLong(sendWithWritev(buf, c.sendQueue))
-			// flush buffer
-			utils.Assert(c.transport.Flush())
-		case <-c.ctx.Done():
+		case <-c.flushSignal:
+		default:
+		}
+		if atomic.LoadUint32(&c.batches)+1 == atomic.LoadUint32(&c.flushBatch) {
+			return nil == c.ctx.Err()
+		}
+
+		defer clock.Schedule(policy.MaxDelay, c.signalFlush)()
+		for batchable() && nil == c.ctx.Err() {
+			entry, ok := c.sendQueue.take(c.flushSignal)
+			if !ok {
+				break
+			}
+			appendEntry(entry)
+			appendQueued()
+		}
+		return nil == c.ctx.Err()
+	}
+
+	// release the resources of written buffers, the promises are completed with the result of write.
+	releaseWritten := func(err error) {
+		c.updateWritability(-int64(pendingBytes))
+		for i, r := range releasers {
+			completeEntry(r, err)
+			releasers[i] = nil
+		}
+		releasers = releasers[:0]
+		buffers, indexes, pendingBytes = buffers[:0], indexes[:0], 0
+	}
+	defer releaseWritten(ErrChannelClosed)
+
+	for {
+		entry, ok := c.sendQueue.take(c.ctx.Done())
+		if !ok {
 			return
 		}
+
+		// combine send bytes to reduce syscall.
+		appendEntry(entry)
+		appendQueued()
+		if policy.enabled() && batchable() && !appendDelayed() {
+			return
+		}
+		atomic.AddUint32(&c.batches, 1)
+		c.updateSaturation()
+
+		n, err := c.transport.Writev(transport.Buffers{Buffers: buffers, Indexes: indexes})
+		if nil == err {
+			atomic.AddInt64(&c.stats.bytesWritten, n)
+			atomic.AddInt64(&c.stats.writes, 1)
+			c.touch()
+			// flush buffer
+			err = c.transport.Flush()
+		}
+		// the buffers has been written or failed.
+		releaseWritten(err)
+		utils.Assert(err)
+	}
+}
+
+// drainLate to release the entries queued after the write loop has drained the send queue.
+func (c *channel) drainLate() {
+	if 1 == atomic.LoadInt32(&c.drained) {
+		c.drainMutex.Lock()
+		c.releaseQueue()
+		c.drainMutex.Unlock()
+	}
+}
+
+// releaseQueue to release the resources of unsent buffers
+func (c *channel) releaseQueue() {
+	var discarded int
+	for {
+		entry, ok := c.sendQueue.poll()
+		if !ok {
+			break
+		}
+		c.updateWritability(-int64(entry.size()))
+		completeEntry(entry.releaser, ErrChannelClosed)
+		discarded++
+	}
+
+	if discarded > 0 {
+		LoggerFrom(c.ctx).Debugf("channel(%s) discarded %d unsent buffers after closed", c.name, discarded)
 	}
 }
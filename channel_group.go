@@ -0,0 +1,237 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// ChannelGroup defines a set of active channels for broadcasting and mass close,
+// the channels are removed from the group automatically after closed.
+type ChannelGroup interface {
+	// Add the channel to group, returns false if the channel is inactive or already added.
+	Add(channel Channel) bool
+	// Remove the channel from group, returns false if the channel is not found.
+	Remove(channel Channel) bool
+	// Find the channel by id
+	Find(id int64) Channel
+	// Size returns the number of channels
+	Size() int
+	// Range calls fn for every channel until fn returns false, the channels can be added or removed in fn.
+	Range(fn func(channel Channel) bool)
+	// Write the message to all channels by the non-blocking TryWrite, returns the errors of failed channels.
+	Write(message Message) map[int64]error
+	// WriteMatched to write the message to the matched channels, returns the errors of failed channels.
+	WriteMatched(message Message, matcher ChannelMatcher) map[int64]error
+	// Close all channels with the error
+	Close(err error)
+	// CloseMatched to close the matched channels with the error
+	CloseMatched(matcher ChannelMatcher, err error)
+}
+
+// ChannelMatcher defines a predicate of channels
+type ChannelMatcher func(channel Channel) bool
+
+// ChannelGroupOption defines an option of ChannelGroup
+type ChannelGroupOption func(options *channelGroupOptions)
+
+// channelGroupOptions
+type channelGroupOptions struct {
+	workers int
+}
+
+// WithGroupWorkers to limit the number of goroutines that write or close the channels concurrently, default is 16.
+func WithGroupWorkers(workers int) ChannelGroupOption {
+	return func(options *channelGroupOptions) {
+		utils.AssertIf(workers <= 0, "workers must be a positive integer")
+		options.workers = workers
+	}
+}
+
+// NewChannelGroup create a new empty ChannelGroup
+func NewChannelGroup(option ...ChannelGroupOption) ChannelGroup {
+	options := channelGroupOptions{workers: 16}
+	for i := range option {
+		option[i](&options)
+	}
+	return &channelGroup{options: options, channels: make(map[int64]Channel)}
+}
+
+// implement of ChannelGroup
+type channelGroup struct {
+	options  channelGroupOptions
+	mutex    sync.RWMutex
+	channels map[int64]Channel
+}
+
+func (g *channelGroup) Add(channel Channel) bool {
+
+	if !channel.IsActive() {
+		return false
+	}
+
+	g.mutex.Lock()
+	if _, ok := g.channels[channel.ID()]; ok {
+		g.mutex.Unlock()
+		return false
+	}
+	g.channels[channel.ID()] = channel
+	g.mutex.Unlock()
+
+	// it is called at once if the channel has been closed.
+	channel.OnClose(func(err error) { g.Remove(channel) })
+	return true
+}
+
+func (g *channelGroup) Remove(channel Channel) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if found, ok := g.channels[channel.ID()]; !ok || found != channel {
+		return false
+	}
+	delete(g.channels, channel.ID())
+	return true
+}
+
+func (g *channelGroup) Find(id int64) Channel {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.channels[id]
+}
+
+func (g *channelGroup) Size() int {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return len(g.channels)
+}
+
+func (g *channelGroup) Range(fn func(channel Channel) bool) {
+	for _, channel := range g.snapshot(nil) {
+		if !fn(channel) {
+			return
+		}
+	}
+}
+
+func (g *channelGroup) Write(message Message) map[int64]error {
+	return g.WriteMatched(message, nil)
+}
+
+func (g *channelGroup) WriteMatched(message Message, matcher ChannelMatcher) map[int64]error {
+
+	channels := g.snapshot(matcher)
+
+	// every channel holds a reference of the message, the message must embed RefCount to be shared.
+	switch n := len(channels); {
+	case 0 == n:
+		Recycle(message)
+		return nil
+	case n > 1:
+		if _, ok := message.(Recyclable); ok {
+			Retain(message, n-1)
+		}
+	}
+
+	return g.fanout(channels, func(channel Channel) error {
+		return channel.TryWrite(message)
+	})
+}
+
+func (g *channelGroup) Close(err error) {
+	g.CloseMatched(nil, err)
+}
+
+func (g *channelGroup) CloseMatched(matcher ChannelMatcher, err error) {
+	g.fanout(g.snapshot(matcher), func(channel Channel) error {
+		channel.Close(err)
+		return nil
+	})
+}
+
+// snapshot returns the matched channels, nil matcher matches all.
+func (g *channelGroup) snapshot(matcher ChannelMatcher) []Channel {
+	g.mutex.RLock()
+	channels := make([]Channel, 0, len(g.channels))
+	for _, channel := range g.channels {
+		channels = append(channels, channel)
+	}
+	g.mutex.RUnlock()
+
+	// the matcher is called without the lock, so it can access the group.
+	if nil != matcher {
+		matched := channels[:0]
+		for _, channel := range channels {
+			if matcher(channel) {
+				matched = append(matched, channel)
+			}
+		}
+		channels = matched
+	}
+	return channels
+}
+
+// fanout to call fn for the channels with the bounded workers, returns the errors of failed channels.
+func (g *channelGroup) fanout(channels []Channel, fn func(channel Channel) error) map[int64]error {
+
+	var mutex sync.Mutex
+	var errs map[int64]error
+	call := func(channel Channel) {
+		if err := fn(channel); nil != err {
+			mutex.Lock()
+			if nil == errs {
+				errs = make(map[int64]error)
+			}
+			errs[channel.ID()] = err
+			mutex.Unlock()
+		}
+	}
+
+	workers := g.options.workers
+	if len(channels) < workers {
+		workers = len(channels)
+	}
+
+	// a single worker needs not to start goroutines.
+	if workers <= 1 {
+		for _, channel := range channels {
+			call(channel)
+		}
+		return errs
+	}
+
+	tasks := make(chan Channel, len(channels))
+	for _, channel := range channels {
+		tasks <- channel
+	}
+	close(tasks)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for channel := range tasks {
+				call(channel)
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
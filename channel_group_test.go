@@ -0,0 +1,96 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestChannelGroup(t *testing.T) {
+
+	group := NewChannelGroup(WithGroupWorkers(2))
+
+	var peers []net.Conn
+	for id := int64(1); id <= 3; id++ {
+		c, peer := newPipeChannel(id, newDiscardPipeline())
+		c.serveChannel()
+		defer c.Close(nil)
+
+		if !group.Add(c) || group.Add(c) {
+			t.Fatal("the channel should be added once")
+		}
+		peers = append(peers, peer)
+	}
+
+	// the write loop of saturated channel is not served.
+	saturated, _ := newPipeChannel(4, newDiscardPipeline(), WithChannelWriteQueueSize(1))
+	defer saturated.Close(nil)
+	saturated.Write([]byte("x"))
+	group.Add(saturated)
+
+	if 4 != group.Size() || saturated != group.Find(4) {
+		t.Fatal("unexpected group size:", group.Size())
+	}
+
+	received := make(chan string, len(peers))
+	for _, peer := range peers {
+		go func(peer net.Conn) {
+			buffer := make([]byte, 5)
+			_, _ = io.ReadFull(peer, buffer)
+			received <- string(buffer)
+		}(peer)
+	}
+
+	// the saturated channel does not block the others.
+	if errs := group.Write([]byte("hello")); 1 != len(errs) || ErrWriteQueueFull != errs[4] {
+		t.Fatal("unexpected errors:", errs)
+	}
+	for range peers {
+		if data := <-received; "hello" != data {
+			t.Fatal("unexpected message:", data)
+		}
+	}
+
+	// the closed channels are removed automatically.
+	group.CloseMatched(func(channel Channel) bool { return channel.ID() > 2 }, nil)
+	if 2 != group.Size() || nil != group.Find(3) || saturated.IsActive() {
+		t.Fatal("the matched channels should be closed and removed:", group.Size())
+	}
+
+	var ids []int64
+	group.Range(func(channel Channel) bool {
+		ids = append(ids, channel.ID())
+		return false
+	})
+	if 1 != len(ids) {
+		t.Fatal("the range should be stopped:", ids)
+	}
+
+	group.Close(nil)
+	if 0 != group.Size() {
+		t.Fatal("all channels should be removed after closed")
+	}
+
+	// the inactive channel can not be added.
+	closed, _ := newPipeChannel(5, newDiscardPipeline())
+	closed.Close(nil)
+	if group.Add(closed) {
+		t.Fatal("the inactive channel should not be added")
+	}
+}
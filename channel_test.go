@@ -0,0 +1,827 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// pipeTransport for testing
+type pipeTransport struct {
+	net.Conn
+}
+
+func (p *pipeTransport) Writev(buffs transport.Buffers) (int64, error) {
+	return buffs.Buffers.WriteTo(p.Conn)
+}
+
+func (p *pipeTransport) Flush() error {
+	return nil
+}
+
+func (p *pipeTransport) RawTransport() interface{} {
+	return p.Conn
+}
+
+// newPipeChannel create a channel attached to the pipeline without serving it, returns the peer connection.
+func newPipeChannel(id int64, p Pipeline, option ...ChannelOption) (*channel, net.Conn) {
+	local, peer := net.Pipe()
+	return newTransportChannel(id, p, &pipeTransport{Conn: local}, option...), peer
+}
+
+// newTransportChannel create a channel over the transport attached to the pipeline without serving it.
+func newTransportChannel(id int64, p Pipeline, tran transport.Transport, option ...ChannelOption) *channel {
+	c := newChannelWith(context.Background(), p, tran, id, 128, parseChannelOptions(context.Background(), option...)).(*channel)
+	p.(*pipeline).channel = c
+	return c
+}
+
+// newDiscardPipeline create a pipeline that discards the inbound bytes, so the read loop is blocked by reading.
+func newDiscardPipeline() Pipeline {
+	return NewPipelineWith().AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		_, _ = io.Copy(ioutil.Discard, message.(io.Reader))
+	}))
+}
+
+func TestChannelPeekHandover(t *testing.T) {
+
+	received := make(chan string, 1)
+	p := NewPipelineWith()
+	p.AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		data, err := ioutil.ReadAll(io.LimitReader(message.(io.Reader), 16))
+		if nil != err {
+			panic(err)
+		}
+		received <- string(data)
+	}))
+
+	c, peer := newPipeChannel(1, p)
+	defer c.Close(nil)
+
+	go func() { _, _ = peer.Write([]byte("PROXY TCP4 hello")) }()
+
+	// sniff the protocol before the pipeline takes over.
+	head, err := utils.Peek(c.Transport(), 5, time.Second)
+	if nil != err || "PROXY" != string(head) {
+		t.Fatal(string(head), err)
+	}
+
+	c.serveChannel()
+
+	if data := <-received; "PROXY TCP4 hello" != data {
+		t.Fatal("the bytes are lost after peeking:", data)
+	}
+}
+
+func TestChannelSendQueue(t *testing.T) {
+
+	const writers = 64
+	const count = 100
+
+	for name, option := range map[string][]ChannelOption{"mpsc": nil, "chan": {WithChanQueue()}} {
+		t.Run(name, func(t *testing.T) {
+
+			c, peer := newPipeChannel(1, newDiscardPipeline(), option...)
+			c.serveChannel()
+			defer c.Close(nil)
+
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for n := 0; n < count; n++ {
+						if _, err := c.writeBuffer([]byte("x"), nil); nil != err {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+
+			data, err := ioutil.ReadAll(io.LimitReader(peer, writers*count))
+			if wg.Wait(); nil != err || writers*count != len(data) {
+				t.Fatal(len(data), err)
+			}
+		})
+	}
+}
+
+// countingTransport to count the calls of Writev
+type countingTransport struct {
+	*pipeTransport
+	writes int64
+}
+
+func (c *countingTransport) Writev(buffs transport.Buffers) (int64, error) {
+	atomic.AddInt64(&c.writes, 1)
+	return c.pipeTransport.Writev(buffs)
+}
+
+func TestChannelFlushPolicy(t *testing.T) {
+
+	// the flushing is delayed for a long time unless the batch is full or flushed explicitly.
+	const maxDelay = 5 * time.Second
+
+	cases := []struct {
+		name   string
+		option ChannelOption
+		write  func(c *channel)
+		want   string
+	}{
+		{"max-messages", WithChannelFlushPolicy(maxDelay, 0, 3), func(c *channel) {
+			for _, p := range []string{"a", "b", "c"} {
+				_, _ = c.writeBuffer([]byte(p), nil)
+			}
+		}, "abc"},
+		{"max-bytes", WithChannelFlushPolicy(maxDelay, 4, 0), func(c *channel) {
+			_, _ = c.writeBuffer([]byte("ab"), nil)
+			_, _ = c.Writev([][]byte{[]byte("cd"), []byte("ef")})
+		}, "abcdef"},
+		{"flush", WithChannelFlushPolicy(maxDelay, 0, 0), func(c *channel) {
+			_, _ = c.writeBuffer([]byte("ab"), nil)
+			c.Flush()
+		}, "ab"},
+		{"write-and-flush", WithChannelFlushPolicy(maxDelay, 0, 0), func(c *channel) {
+			c.WriteAndFlush([]byte("ab"))
+		}, "ab"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			c, peer := newPipeChannel(1, newDiscardPipeline(), tc.option)
+			counting := &countingTransport{pipeTransport: &pipeTransport{Conn: c.transport.RawTransport().(net.Conn)}}
+			c.transport = counting
+			c.serveChannel()
+			defer c.Close(nil)
+
+			begin := time.Now()
+			tc.write(c)
+
+			data := make([]byte, len(tc.want))
+			if _, err := io.ReadFull(peer, data); nil != err || tc.want != string(data) {
+				t.Fatal(string(data), err)
+			}
+
+			if elapsed := time.Since(begin); elapsed >= maxDelay {
+				t.Fatal("the flushing must not be delayed:", elapsed)
+			}
+
+			if 1 != atomic.LoadInt64(&counting.writes) {
+				t.Fatal("the messages should be written in a batch:", counting.writes)
+			}
+		})
+	}
+}
+
+func TestChannelFlushStale(t *testing.T) {
+
+	t.Run("flush-nothing", func(t *testing.T) {
+		const maxDelay = 100 * time.Millisecond
+		c, peer := newPipeChannel(1, newDiscardPipeline(), WithChannelFlushPolicy(maxDelay, 0, 0))
+		c.serveChannel()
+		defer c.Close(nil)
+
+		// nothing is pending, the next batch is still delayed.
+		c.Flush()
+		begin := time.Now()
+		_, _ = c.writeBuffer([]byte("ab"), nil)
+
+		data := make([]byte, 2)
+		if _, err := io.ReadFull(peer, data); nil != err || "ab" != string(data) {
+			t.Fatal(string(data), err)
+		}
+		if elapsed := time.Since(begin); elapsed < maxDelay/2 {
+			t.Fatal("the batch should be delayed:", elapsed)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		const maxDelay = 5 * time.Second
+		c, _ := newPipeChannel(1, newDiscardPipeline(), WithChannelFlushPolicy(maxDelay, 0, 0))
+		c.serveChannel()
+
+		future := c.WriteWithPromise([]byte("ab"))
+		c.Close(nil)
+
+		// the delayed batch is released once the channel closed.
+		select {
+		case <-future.Done():
+			if ErrChannelClosed != future.Err() {
+				t.Fatal("unexpected error:", future.Err())
+			}
+		case <-time.After(maxDelay / 2):
+			t.Fatal("the write loop should not wait for the delay after closed")
+		}
+	})
+}
+
+func TestChannelFlushPolicyOptions(t *testing.T) {
+
+	if parseChannelOptions(context.Background()).flushPolicy.enabled() {
+		t.Fatal("the flush policy must be disabled by default")
+	}
+
+	bs := NewBootstrap(WithFlushPolicy(time.Millisecond, 1024, 16))
+	defer bs.Shutdown()
+
+	if policy := parseChannelOptions(bs.Context()).flushPolicy; (FlushPolicy{MaxDelay: time.Millisecond, MaxBytes: 1024, MaxMessages: 16}) != policy {
+		t.Fatal("unexpected policy:", policy)
+	}
+
+	// the options of channel override the bootstrap.
+	if policy := parseChannelOptions(bs.Context(), WithChannelFlushPolicy(0, 0, 0)).flushPolicy; policy.enabled() {
+		t.Fatal("unexpected policy:", policy)
+	}
+
+	batching := NewBootstrap(WithWriteBatching(4096, time.Millisecond))
+	defer batching.Shutdown()
+
+	if policy := parseChannelOptions(batching.Context()).flushPolicy; (FlushPolicy{MaxDelay: time.Millisecond, MaxBytes: 4096}) != policy {
+		t.Fatal("unexpected policy:", policy)
+	}
+}
+
+func TestChannelFlushLatency(t *testing.T) {
+
+	const maxDelay = 5 * time.Millisecond
+	const samples = 100
+
+	// measure the latency from writing to receiving.
+	measure := func(option ...ChannelOption) (p50, p99 time.Duration) {
+		c, peer := newPipeChannel(1, newDiscardPipeline(), option...)
+		c.serveChannel()
+		defer c.Close(nil)
+
+		var latencies = make([]time.Duration, 0, samples)
+		var data = make([]byte, 8)
+		for i := 0; i < samples; i++ {
+			begin := time.Now()
+			_, _ = c.writeBuffer([]byte("sporadic"), nil)
+			if _, err := io.ReadFull(peer, data); nil != err {
+				t.Fatal(err)
+			}
+			latencies = append(latencies, time.Since(begin))
+		}
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		return latencies[samples/2], latencies[samples*99/100]
+	}
+
+	_, baseline := measure()
+	p50, p99 := measure(WithChannelFlushPolicy(maxDelay, 0, 0))
+
+	if p50 < maxDelay {
+		t.Fatal("the flushing should be delayed:", p50)
+	}
+
+	// allow the scheduling latency of timer.
+	if added := p99 - baseline; added > maxDelay+3*time.Millisecond {
+		t.Fatal("the added latency exceeds the max delay:", added, "p99:", p99, "baseline:", baseline)
+	}
+}
+
+func BenchmarkFlushPolicy(b *testing.B) {
+
+	for _, bc := range []struct {
+		name   string
+		option []ChannelOption
+	}{
+		{"immediate", nil},
+		{"delay-50us", []ChannelOption{WithChannelFlushPolicy(50*time.Microsecond, 64<<10, 0)}},
+		{"delay-200us", []ChannelOption{WithChannelFlushPolicy(200*time.Microsecond, 64<<10, 0)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if nil != err {
+				b.Fatal(err)
+			}
+			defer ln.Close()
+
+			go func() {
+				if conn, err := ln.Accept(); nil == err {
+					_, _ = io.Copy(ioutil.Discard, conn)
+				}
+			}()
+
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if nil != err {
+				b.Fatal(err)
+			}
+
+			counting := &countingTransport{pipeTransport: &pipeTransport{Conn: conn}}
+			c := newTransportChannel(1, newDiscardPipeline(), counting, bc.option...)
+			c.serveChannel()
+			defer c.Close(nil)
+
+			// small messages that trickle in, the write loop is given a chance to run after every message.
+			var message = make([]byte, 64)
+
+			b.SetBytes(int64(len(message)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = c.writeBuffer(message, nil)
+				runtime.Gosched()
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(atomic.LoadInt64(&counting.writes))/float64(b.N), "writev/op")
+		})
+	}
+}
+
+func TestChannelTryWrite(t *testing.T) {
+
+	for name, option := range map[string][]ChannelOption{"mpsc": nil, "chan": {WithChanQueue()}} {
+		t.Run(name, func(t *testing.T) {
+
+			// the write loop is not served, so the send queue will be saturated.
+			c, _ := newPipeChannel(1, newDiscardPipeline(), option...)
+
+			for i := 0; i < c.sendQueue.capacity(); i++ {
+				if err := c.TryWrite([]byte("x")); nil != err {
+					t.Fatal(i, err)
+				}
+			}
+
+			if err := c.TryWrite([]byte("x")); ErrWriteQueueFull != err {
+				t.Fatal("the send queue should be full:", err)
+			}
+
+			c.Close(nil)
+			if err := c.TryWrite([]byte("x")); ErrChannelClosed != err {
+				t.Fatal("write after close:", err)
+			}
+		})
+	}
+}
+
+func TestChannelOnClose(t *testing.T) {
+
+	c, _ := newPipeChannel(1, newDiscardPipeline())
+	c.serveChannel()
+
+	closeErr := errors.New("close on purpose")
+	notified := make(chan error, 1)
+	c.OnClose(func(err error) { notified <- err })
+
+	select {
+	case <-c.Done():
+		t.Fatal("the channel should be active")
+	default:
+	}
+
+	c.Close(closeErr)
+	<-c.Done()
+
+	if err := <-notified; closeErr != err || closeErr != c.CloseErr() {
+		t.Fatal("unexpected close error:", err, c.CloseErr())
+	}
+
+	// the callback is called at once after closed.
+	var called error
+	c.OnClose(func(err error) { called = err })
+	if closeErr != called {
+		t.Fatal("the callback of closed channel should be called at once:", called)
+	}
+}
+
+func TestChannelAddr(t *testing.T) {
+
+	server, client := tcpPair(t)
+	defer client.Close()
+
+	addrs := make(chan [2]string, 1)
+	p := newDiscardPipeline()
+	p.AddFirst(ActiveHandlerFunc(func(ctx ActiveContext) {
+		addrs <- [2]string{ctx.Channel().LocalAddr(), ctx.Channel().RemoteAddr()}
+		ctx.HandleActive()
+	}))
+
+	c := newTransportChannel(1, p, &pipeTransport{Conn: server})
+	c.serveChannel()
+	defer c.Close(nil)
+
+	// the addresses are delegated to the transport.
+	if got := <-addrs; client.RemoteAddr().String() != got[0] || client.LocalAddr().String() != got[1] {
+		t.Fatal("unexpected addresses:", got)
+	}
+}
+
+func TestChannelWriteOverflow(t *testing.T) {
+
+	// newChannel create a channel of the bootstrap without serving it.
+	newChannel := func(option ...Option) (*channel, net.Conn, chan Exception) {
+		bs := NewBootstrap(option...)
+		t.Cleanup(bs.Shutdown)
+
+		exceptions := make(chan Exception, 16)
+		p := newDiscardPipeline().AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+			exceptions <- ex
+		}))
+
+		local, peer := net.Pipe()
+		c := NewChannel(128)(1, bs.Context(), p, &pipeTransport{Conn: local}).(*channel)
+		p.(*pipeline).channel = c
+		t.Cleanup(func() { c.Close(nil) })
+		return c, peer, exceptions
+	}
+
+	t.Run("size", func(t *testing.T) {
+		c, _, _ := newChannel(WithWriteQueueSize(4))
+		if 4 != c.sendQueue.capacity() {
+			t.Fatal("unexpected capacity:", c.sendQueue.capacity())
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		c, _, exceptions := newChannel(WithWriteQueueSize(4), WithWriteOverflowPolicy(WriteOverflowFail))
+		for i := 0; i < 4; i++ {
+			if !c.Write([]byte("x")) {
+				t.Fatal(i, "the write should be queued")
+			}
+		}
+
+		if c.Write([]byte("x")) {
+			t.Fatal("the write should be failed")
+		}
+		if ex := <-exceptions; !errors.Is(ex, ErrWriteQueueFull) {
+			t.Fatal("unexpected exception:", ex)
+		}
+		if err := c.TryWrite([]byte("x")); ErrWriteQueueFull != err {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		// the ring of queue is rounded up to a power of two, the size of queue is still the limit.
+		for _, size := range []int{3, 5, 100} {
+			c, _, _ := newChannel(WithWriteQueueSize(size), WithWriteOverflowPolicy(WriteOverflowFail), WithQueueSaturation(1, 0.5))
+			for i := 0; i < size; i++ {
+				if err := c.TryWrite([]byte("x")); nil != err {
+					t.Fatal(size, i, "the write should be queued:", err)
+				}
+			}
+
+			if err := c.TryWrite([]byte("x")); ErrWriteQueueFull != err {
+				t.Fatal(size, "the write beyond the limit should be failed:", err)
+			}
+			if size != c.sendQueue.size() || size != c.sendQueue.capacity() {
+				t.Fatal(size, "unexpected size of queue:", c.sendQueue.size(), c.sendQueue.capacity())
+			}
+			if 1 != atomic.LoadInt32(&c.writability.saturated) {
+				t.Fatal(size, "the full queue should be saturated")
+			}
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		c, peer, _ := newChannel(WithWriteQueueSize(3))
+		for i := 0; i < 3; i++ {
+			c.Write([]byte("x"))
+		}
+
+		// the write beyond the limit blocks until the write loop takes an entry.
+		blocked := make(chan struct{})
+		go func() {
+			defer close(blocked)
+			c.Write([]byte("y"))
+		}()
+
+		select {
+		case <-blocked:
+			t.Fatal("the write beyond the limit should be blocked")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		c.serveChannel()
+		if data, err := ioutil.ReadAll(io.LimitReader(peer, 4)); nil != err || "xxxy" != string(data) {
+			t.Fatal(string(data), err)
+		}
+		<-blocked
+	})
+
+	t.Run("unbuffered", func(t *testing.T) {
+		bs := NewBootstrap()
+		t.Cleanup(bs.Shutdown)
+
+		p := newDiscardPipeline()
+		local, peer := net.Pipe()
+		c := NewChannel(0)(1, bs.Context(), p, &pipeTransport{Conn: local}).(*channel)
+		p.(*pipeline).channel = c
+		t.Cleanup(func() { c.Close(nil) })
+		c.serveChannel()
+
+		// the writes are taken by the write loop one by one.
+		written := make(chan bool, 1)
+		go func() { written <- c.Write([]byte("a")) && c.Write([]byte("b")) }()
+		if data, err := ioutil.ReadAll(io.LimitReader(peer, 2)); nil != err || "ab" != string(data) || !<-written {
+			t.Fatal(string(data), err)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		c, peer, exceptions := newChannel(WithWriteQueueSize(4), WithWriteOverflowPolicy(WriteOverflowDropOldest))
+		for _, s := range []string{"a", "b", "c", "d", "e", "f"} {
+			if !c.Write([]byte(s)) {
+				t.Fatal(s, "the write should be queued")
+			}
+		}
+
+		c.serveChannel()
+		if data, err := ioutil.ReadAll(io.LimitReader(peer, 4)); nil != err || "cdef" != string(data) {
+			t.Fatal("the oldest entries should be dropped:", string(data), err)
+		}
+
+		select {
+		case ex := <-exceptions:
+			t.Fatal("unexpected exception:", ex)
+		default:
+		}
+	})
+
+	t.Run("drop unbuffered", func(t *testing.T) {
+		bs := NewBootstrap(WithWriteOverflowPolicy(WriteOverflowDropOldest))
+		t.Cleanup(bs.Shutdown)
+
+		p := newDiscardPipeline()
+		local, _ := net.Pipe()
+		c := NewChannel(0)(1, bs.Context(), p, &pipeTransport{Conn: local}).(*channel)
+		p.(*pipeline).channel = c
+		t.Cleanup(func() { c.Close(nil) })
+
+		// nothing can be dropped from the send queue without capacity.
+		written := make(chan bool, 1)
+		go func() { written <- c.Write([]byte("x")) }()
+
+		select {
+		case ok := <-written:
+			if ok || 0 != c.Stats().PendingBytes {
+				t.Fatal("unexpected result:", ok, c.Stats().PendingBytes)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the write should be failed instead of spinning")
+		}
+	})
+}
+
+func TestChannelCloseGracefully(t *testing.T) {
+
+	server, client := tcpPair(t)
+	defer client.Close()
+
+	var inactive int32
+	p := newDiscardPipeline().AddLast(InactiveHandlerFunc(func(ctx InactiveContext, ex Exception) {
+		atomic.AddInt32(&inactive, 1)
+	}))
+
+	c := newTransportChannel(1, p, &pipeTransport{Conn: server})
+	c.serveChannel()
+
+	received := make(chan int, 1)
+	go func() {
+		n, _ := io.Copy(ioutil.Discard, client)
+		received <- int(n)
+	}()
+
+	const chunk, count = 64 << 10, 64
+	for i := 0; i < count; i++ {
+		if !c.Write(make([]byte, chunk)) {
+			t.Fatal(i, "the write should be queued")
+		}
+	}
+
+	if err := c.CloseGracefully(5 * time.Second); nil != err {
+		t.Fatal(err)
+	}
+
+	if n := <-received; chunk*count != n {
+		t.Fatal("the peer should receive every byte:", n)
+	}
+
+	<-c.Done()
+	if c.Write([]byte("x")) || 1 != atomic.LoadInt32(&inactive) {
+		t.Fatal("the inactive handler should be called once:", atomic.LoadInt32(&inactive))
+	}
+
+	if err := c.CloseGracefully(time.Second); ErrChannelClosed != err {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestChannelCloseGracefullyClock(t *testing.T) {
+
+	clock := &triggerClock{Clock: utils.RealClock(), scheduled: make(chan func(), 1)}
+	c := newContextChannel(ContextWithClock(context.Background(), clock), newDiscardPipeline())
+	c.serveChannel()
+	defer c.Close(nil)
+
+	// nobody reads the pipe, the queued bytes can not be flushed.
+	c.Write([]byte("hello"))
+
+	result := make(chan error, 1)
+	go func() {
+		result <- c.CloseGracefully(time.Hour)
+	}()
+
+	// the timeout is driven by the clock of channel.
+	(<-clock.scheduled)()
+	if err := <-result; nil == err || !strings.Contains(err.Error(), "close timeout") || c.IsActive() {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestChannelCloseWrite(t *testing.T) {
+
+	server, client := tcpPair(t)
+	defer client.Close()
+
+	received := make(chan string, 1)
+	var inactive int32
+	p := NewPipelineWith().AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		buffer := make([]byte, 5)
+		utils.AssertLength(io.ReadFull(message.(io.Reader), buffer))
+		received <- string(buffer)
+	}), ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		// the read side hits EOF.
+		ctx.Close(ex)
+	}), InactiveHandlerFunc(func(ctx InactiveContext, ex Exception) {
+		atomic.AddInt32(&inactive, 1)
+	}))
+
+	c := newTransportChannel(1, p, &pipeTransport{Conn: server})
+	c.serveChannel()
+	defer c.Close(nil)
+
+	c.Write([]byte("hello"))
+	if err := c.CloseWrite(); nil != err {
+		t.Fatal(err)
+	}
+
+	// the peer reads the queued bytes and then EOF.
+	if data, err := ioutil.ReadAll(client); nil != err || "hello" != string(data) {
+		t.Fatal(string(data), err)
+	}
+	if c.Write([]byte("x")) || ErrChannelClosed != c.CloseWrite() {
+		t.Fatal("the writing side should be closed")
+	}
+
+	// the channel keeps reading after half-closed.
+	if _, err := client.Write([]byte("world")); nil != err {
+		t.Fatal(err)
+	}
+	if data := <-received; "world" != data || 0 != atomic.LoadInt32(&inactive) {
+		t.Fatal("the channel should keep reading:", data)
+	}
+
+	_ = client.Close()
+	<-c.Done()
+	if 1 != atomic.LoadInt32(&inactive) {
+		t.Fatal("the inactive handler should be called once after EOF")
+	}
+
+	// the pipe can not be half-closed.
+	pc, _ := newPipeChannel(2, newDiscardPipeline())
+	pc.serveChannel()
+	defer pc.Close(nil)
+	if err := pc.CloseWrite(); transport.ErrHalfCloseUnsupported != err {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestChannelCloseWriteGracefully(t *testing.T) {
+
+	server, client := tcpPair(t)
+	defer client.Close()
+
+	c := newTransportChannel(1, newDiscardPipeline(), &pipeTransport{Conn: server})
+	c.serveChannel()
+	defer c.Close(nil)
+
+	c.Write([]byte("hello"))
+	if err := c.CloseWrite(); nil != err {
+		t.Fatal(err)
+	}
+
+	// the channel is closed at once, the writing side has been shut down.
+	if err := c.CloseGracefully(time.Second); nil != err || c.IsActive() {
+		t.Fatal("the channel should be closed after CloseWrite:", err, c.IsActive())
+	}
+
+	if data, err := ioutil.ReadAll(client); nil != err || "hello" != string(data) {
+		t.Fatal(string(data), err)
+	}
+}
+
+func TestChannelName(t *testing.T) {
+
+	if c, _ := newPipeChannel(1, newDiscardPipeline()); "1" != c.Name() {
+		t.Fatal("the name should be the decimal id by default:", c.Name())
+	}
+
+	bs := NewBootstrap(WithChannelName(NodeSequenceName("node-7")))
+	defer bs.Shutdown()
+
+	local, _ := net.Pipe()
+	p := newDiscardPipeline()
+	c := newChannelWith(bs.Context(), p, &pipeTransport{Conn: local}, 123, 128, parseChannelOptions(bs.Context()))
+	p.(*pipeline).channel = c
+	if "node-7:000123" != c.Name() {
+		t.Fatal("unexpected name:", c.Name())
+	}
+
+	// the exceptions carry the name of channel.
+	ce := asChannelException(AsException(errors.New("broken"), nil), c).(*ChannelException)
+	if "node-7:000123" != ce.ChannelName() || !strings.Contains(ce.channelInfo(), "name=node-7:000123") {
+		t.Fatal("unexpected exception:", ce.channelInfo())
+	}
+
+	random := NodeRandomName("node-7")
+	if a, b := random(1), random(1); a == b || !strings.HasPrefix(a, "node-7:") || len("node-7:")+16 != len(a) {
+		t.Fatal("unexpected random names:", a, b)
+	}
+	if name := NodeRandomName("")(1); strings.Contains(name, ":") {
+		t.Fatal("the empty node should not be prefixed:", name)
+	}
+}
+
+func TestChannelContext(t *testing.T) {
+
+	bs := NewBootstrap()
+	defer bs.Shutdown()
+
+	type valueKey struct{}
+	ctx := context.WithValue(bs.Context(), valueKey{}, "value")
+
+	inactive := make(chan error, 1)
+	p := newDiscardPipeline().AddLast(InactiveHandlerFunc(func(ctx InactiveContext, ex Exception) {
+		inactive <- ctx.Channel().Context().Err()
+	}))
+
+	local, _ := net.Pipe()
+	c := NewChannel(128)(1, ctx, p, &pipeTransport{Conn: local})
+	p.(*pipeline).channel = c.(*channel)
+
+	if "value" != c.Context().Value(valueKey{}) || nil != c.Context().Err() {
+		t.Fatal("the context of channel should be derived from the context of bootstrap")
+	}
+
+	c.Close(nil)
+	if err := <-inactive; context.Canceled != err {
+		t.Fatal("the context should be canceled before the channel inactive:", err)
+	}
+}
+
+func TestChannelIsServer(t *testing.T) {
+
+	bs := NewBootstrap()
+	defer bs.Shutdown()
+
+	local, _ := net.Pipe()
+	client := NewChannel(128)(1, bs.Context(), NewPipeline()(), &pipeTransport{Conn: local})
+	if client.IsServer() {
+		t.Fatal("the connected channel should be the client side")
+	}
+
+	// the accepted channels carry the url of listener.
+	ctx := context.WithValue(bs.Context(), listenerKey{}, "tcp://127.0.0.1:9527")
+	server := NewChannel(128)(2, ctx, NewPipeline()(), &pipeTransport{Conn: local})
+	if !server.IsServer() {
+		t.Fatal("the accepted channel should be the server side")
+	}
+
+	ce := asChannelException(AsException(errors.New("failure"), nil), server).(*ChannelException)
+	if !ce.IsServer() || !strings.Contains(ce.channelInfo(), "side=server") {
+		t.Fatal("unexpected exception:", ce.channelInfo())
+	}
+}
@@ -22,9 +22,14 @@ import "github.com/go-netty/go-netty"
 type MockHandlerContext struct {
 	MockChannel       func() netty.Channel
 	MockHandler       func() netty.Handler
+	MockName          func() string
+	MockPrev          func() netty.HandlerContext
+	MockNext          func() netty.HandlerContext
 	MockWrite         func(message netty.Message)
+	MockRetain        func(message netty.Message)
 	MockClose         func(err error)
 	MockTrigger       func(event netty.Event)
+	MockFireRead      func(message netty.Message)
 	MockAttachment    func() netty.Attachment
 	MockSetAttachment func(attachment netty.Attachment)
 	MockHandleRead    func(message netty.Message)
@@ -47,6 +52,30 @@ func (m MockHandlerContext) Handler() netty.Handler {
 	return nil
 }
 
+// Name to mock Name of HandlerContext
+func (m MockHandlerContext) Name() string {
+	if m.MockName != nil {
+		return m.MockName()
+	}
+	return ""
+}
+
+// Prev to mock Prev of HandlerContext
+func (m MockHandlerContext) Prev() netty.HandlerContext {
+	if m.MockPrev != nil {
+		return m.MockPrev()
+	}
+	return nil
+}
+
+// Next to mock Next of HandlerContext
+func (m MockHandlerContext) Next() netty.HandlerContext {
+	if m.MockNext != nil {
+		return m.MockNext()
+	}
+	return nil
+}
+
 // Write to mock Write of HandlerContext
 func (m MockHandlerContext) Write(message netty.Message) {
 	if m.MockWrite != nil {
@@ -54,6 +83,13 @@ func (m MockHandlerContext) Write(message netty.Message) {
 	}
 }
 
+// Retain to mock Retain of HandlerContext
+func (m MockHandlerContext) Retain(message netty.Message) {
+	if m.MockRetain != nil {
+		m.MockRetain(message)
+	}
+}
+
 // Close to mock Close of HandlerContext
 func (m MockHandlerContext) Close(err error) {
 	if m.MockClose != nil {
@@ -68,6 +104,13 @@ func (m MockHandlerContext) Trigger(event netty.Event) {
 	}
 }
 
+// FireRead to mock FireRead of HandlerContext
+func (m MockHandlerContext) FireRead(message netty.Message) {
+	if m.MockFireRead != nil {
+		m.MockFireRead(message)
+	}
+}
+
 // Attachment to mock Attachment of HandlerContext
 func (m MockHandlerContext) Attachment() netty.Attachment {
 	if m.MockAttachment != nil {
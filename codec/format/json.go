@@ -60,7 +60,11 @@ func (j *jsonCodec) HandleRead(ctx netty.InboundContext, message netty.Message)
 
 	// decode to map
 	var object = make(map[string]interface{})
-	utils.Assert(jsonDecoder.Decode(&object))
+	err := jsonDecoder.Decode(&object)
+
+	// the message has been consumed.
+	utils.Release(message)
+	utils.Assert(err)
 
 	// post object
 	ctx.HandleRead(object)
@@ -69,6 +73,8 @@ func (j *jsonCodec) HandleRead(ctx netty.InboundContext, message netty.Message)
 func (j *jsonCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
 	// marshal object to json bytes
 	data := utils.AssertBytes(json.Marshal(message))
+	// the object has been encoded.
+	netty.Recycle(message)
 	// post json
 	ctx.HandleWrite(data)
 }
@@ -44,6 +44,9 @@ func (*textCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
 	sb := strings.Builder{}
 	sb.Write(textBytes)
 
+	// the message has been consumed.
+	utils.Release(message)
+
 	// post text
 	ctx.HandleRead(sb.String())
 }
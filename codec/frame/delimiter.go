@@ -49,40 +49,46 @@ func (*delimiterCodec) CodecName() string {
 
 func (d *delimiterCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
 
-	// wrap to io.Reader
-	reader := utils.MustToReader(message)
+	// rent frame buffer from the pool, the consumer of the frame should release it.
+	frameBuff := utils.NewByteBuf(16)
+	if err := d.readFrame(utils.MustToReader(message), frameBuff); nil != err {
+		frameBuff.Release()
+		panic(err)
+	}
 
-	readBuff := make([]byte, 0, 16)
-	tempBuff := make([]byte, 1)
-	for len(readBuff) < d.maxFrameLength {
-		// read 1 byte
-		n := utils.AssertLength(reader.Read(tempBuff[:]))
+	// post message
+	ctx.HandleRead(frameBuff)
+}
 
-		// append to buffer
-		readBuff = append(readBuff, tempBuff[:n]...)
+func (d *delimiterCodec) readFrame(reader io.Reader, frameBuff *utils.ByteBuf) error {
+
+	for frameBuff.ReadableBytes() < d.maxFrameLength {
+		// read 1 byte
+		if _, err := frameBuff.WriteFromReader(reader, 1); nil != err {
+			return err
+		}
 
 		// check delimiter in received buffer
-		if len(readBuff) >= len(d.delimiter) && bytes.Equal(d.delimiter, readBuff[len(readBuff)-len(d.delimiter):]) {
+		if readBuff := frameBuff.Bytes(); len(readBuff) >= len(d.delimiter) && bytes.Equal(d.delimiter, readBuff[len(readBuff)-len(d.delimiter):]) {
 
 			// strip delimiter
 			if d.stripDelimiter {
-				readBuff = readBuff[:len(readBuff)-len(d.delimiter)]
+				_ = frameBuff.SetWriterIndex(frameBuff.WriterIndex() - len(d.delimiter))
 			}
 
-			// post message
-			ctx.HandleRead(bytes.NewReader(readBuff))
-			return
+			return nil
 		}
 	}
 
-	utils.Assert(fmt.Errorf("frame length too large, readBuffLength(%d) >= maxFrameLength(%d)",
-		len(readBuff), d.maxFrameLength))
-
+	return fmt.Errorf("frame length too large, readBuffLength(%d) >= maxFrameLength(%d)",
+		frameBuff.ReadableBytes(), d.maxFrameLength)
 }
 
 func (d *delimiterCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
 
 	switch r := message.(type) {
+	case *netty.Buffers:
+		ctx.HandleWrite(r.Append(d.delimiter))
 	case []byte:
 		ctx.HandleWrite([][]byte{
 			// body
@@ -91,6 +97,8 @@ func (d *delimiterCodec) HandleWrite(ctx netty.OutboundContext, message netty.Me
 			d.delimiter,
 		})
 	default:
+		// the body is read by the head synchronously.
+		defer netty.Recycle(message)
 		ctx.HandleWrite(io.MultiReader(
 			// body
 			utils.MustToReader(message),
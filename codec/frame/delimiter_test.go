@@ -24,6 +24,8 @@ import (
 	"testing"
 
 	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/nettytest"
+	"github.com/go-netty/go-netty/nettytest/fuzz"
 )
 
 func TestDelimiterCodec(t *testing.T) {
@@ -69,3 +71,73 @@ func TestDelimiterCodec(t *testing.T) {
 	}
 
 }
+
+func TestDelimiterCodecEmbedded(t *testing.T) {
+
+	ec := nettytest.NewEmbeddedChannel(DelimiterCodec(16, "\r\n", true))
+
+	// the frames are encoded with delimiter.
+	ec.WriteOutbound([]byte("hello"), "world")
+	var stream []byte
+	for message := ec.ReadOutbound(); nil != message; message = ec.ReadOutbound() {
+		stream = append(stream, utils.MustToBytes(message)...)
+	}
+
+	if "hello\r\nworld\r\n" != string(stream) {
+		t.Fatalf("unexpected stream: %q", stream)
+	}
+
+	// the stream is decoded to a frame per read, like the read loop of channel.
+	reader := bytes.NewReader(stream)
+	ec.WriteInbound(reader, reader)
+	for _, want := range []string{"hello", "world"} {
+		frame := ec.ReadInbound()
+		if nil == frame {
+			t.Fatal("missing frame:", want)
+		}
+		if got := string(utils.MustToBytes(frame)); want != got {
+			t.Fatal("unexpected frame:", got, "want:", want)
+		}
+		utils.Release(frame)
+	}
+
+	// the frame is too large.
+	ec.WriteInbound(strings.Repeat("x", 32))
+	if ex := ec.CheckException(); nil == ex || !strings.Contains(ex.Error(), "frame length too large") {
+		t.Fatal("unexpected exception:", ex)
+	}
+
+	if ec.FinishAndClose() {
+		t.Fatal("unexpected messages")
+	}
+}
+
+func BenchmarkDelimiterCodec(b *testing.B) {
+
+	codec := DelimiterCodec(1024, "\n", true)
+	frame := []byte(strings.Repeat("go-netty", 64) + "\n")
+	ctx := MockHandlerContext{
+		MockHandleRead: func(message netty.Message) {
+			// consume the frame
+			utils.Release(message)
+		},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		codec.HandleRead(ctx, bytes.NewReader(frame))
+	}
+}
+
+func TestDelimiterCodecGolden(t *testing.T) {
+	handlers := func() []netty.Handler {
+		return []netty.Handler{DelimiterCodec(1024, "\r\n", true)}
+	}
+	nettytest.CheckDecodeGolden(t, "delimiter", handlers)
+	nettytest.CheckEncodeGolden(t, "delimiter", handlers)
+}
+
+func FuzzDelimiterCodec(f *testing.F) {
+	codec := func() netty.Handler { return DelimiterCodec(1024, "\r\n", true) }
+	fuzz.FuzzDecoder(f, codec, fuzz.WithSeedFiles("testdata/delimiter.bin"), fuzz.WithEncoder(codec))
+}
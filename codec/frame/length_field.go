@@ -83,24 +83,31 @@ func (l *lengthFieldCodec) HandleRead(ctx netty.InboundContext, message netty.Me
 	headerBuffer := make([]byte, lengthFieldEndOffset)
 	n, err := io.ReadFull(reader, headerBuffer)
 
-	utils.AssertIf(n != len(headerBuffer) || nil != err, "read header fail, headerLength: %d, read: %d, error: %w", len(headerBuffer), n, err)
+	if n != len(headerBuffer) || nil != err {
+		panic(fmt.Errorf("read header fail, headerLength: %d, read: %d, error: %w", len(headerBuffer), n, err))
+	}
 
 	lengthFieldBuff := headerBuffer[l.lengthFieldOffset:lengthFieldEndOffset]
 
 	frameLength := unpackFieldLength(l.byteOrder, l.lengthFieldLength, lengthFieldBuff)
 
-	utils.AssertIf(frameLength < 0, "negative pre-adjustment length field: %d", frameLength)
+	if frameLength < 0 {
+		panic(fmt.Errorf("negative pre-adjustment length field: %d", frameLength))
+	}
 
 	frameLength += int64(l.lengthAdjustment + lengthFieldEndOffset)
 
-	utils.AssertIf(frameLength < int64(lengthFieldEndOffset),
-		"Adjusted frame length (%d) is less than lengthFieldEndOffset: %d", frameLength, lengthFieldEndOffset)
+	if frameLength < int64(lengthFieldEndOffset) {
+		panic(fmt.Errorf("Adjusted frame length (%d) is less than lengthFieldEndOffset: %d", frameLength, lengthFieldEndOffset))
+	}
 
-	utils.AssertIf(frameLength > int64(l.maxFrameLength),
-		"Frame length too large, frameLength(%d) > maxFrameLength(%d)", frameLength, l.maxFrameLength)
+	if frameLength > int64(l.maxFrameLength) {
+		panic(fmt.Errorf("Frame length too large, frameLength(%d) > maxFrameLength(%d)", frameLength, l.maxFrameLength))
+	}
 
-	utils.AssertIf(int64(l.initialBytesToStrip) > frameLength,
-		"Adjusted frame length (%d) is less than initialBytesToStrip: %d", frameLength, l.initialBytesToStrip)
+	if int64(l.initialBytesToStrip) > frameLength {
+		panic(fmt.Errorf("Adjusted frame length (%d) is less than initialBytesToStrip: %d", frameLength, l.initialBytesToStrip))
+	}
 
 	frameReader := io.MultiReader(
 		// lengthFieldOffset + lengthFieldLength
@@ -112,7 +119,9 @@ func (l *lengthFieldCodec) HandleRead(ctx netty.InboundContext, message netty.Me
 	// strip bytes
 	if l.initialBytesToStrip > 0 {
 		n, err := io.CopyN(ioutil.Discard, frameReader, int64(l.initialBytesToStrip))
-		utils.AssertIf(nil != err || int64(l.initialBytesToStrip) != n, "initialBytesToStrip: %d -> %d, %w", l.initialBytesToStrip, n, err)
+		if nil != err || int64(l.initialBytesToStrip) != n {
+			panic(fmt.Errorf("initialBytesToStrip: %d -> %d, %w", l.initialBytesToStrip, n, err))
+		}
 	}
 
 	ctx.HandleRead(frameReader)
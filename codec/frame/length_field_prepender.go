@@ -50,16 +50,27 @@ type lengthFieldPrepender struct {
 
 func (l *lengthFieldPrepender) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
 
+	// prepend the header without copying the body.
+	if buffers, ok := message.(*netty.Buffers); ok {
+		ctx.HandleWrite(buffers.Prepend(l.packLength(buffers.Len())))
+		return
+	}
+
+	// the body may be a view of the message, recycle it after written.
+	defer netty.Recycle(message)
 	bodyBytes := utils.MustToBytes(message)
 
-	length := len(bodyBytes) + l.lengthAdjustment
+	// HEAD | BODY
+	ctx.HandleWrite([][]byte{l.packLength(int64(len(bodyBytes))), bodyBytes})
+}
+
+func (l *lengthFieldPrepender) packLength(bodyLength int64) []byte {
+
+	length := bodyLength + int64(l.lengthAdjustment)
 	if l.lengthIncludesLengthFieldLength {
-		length += l.lengthFieldLength
+		length += int64(l.lengthFieldLength)
 	}
 
 	// head buffer
-	lengthBuff := packFieldLength(l.byteOrder, l.lengthFieldLength, int64(length))
-
-	// HEAD | BODY
-	ctx.HandleWrite([][]byte{lengthBuff, bodyBytes})
+	return packFieldLength(l.byteOrder, l.lengthFieldLength, length)
 }
@@ -23,6 +23,8 @@ import (
 	"testing"
 
 	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/nettytest"
+	"github.com/go-netty/go-netty/nettytest/fuzz"
 )
 
 func TestLengthFieldCodec(t *testing.T) {
@@ -67,3 +69,59 @@ func TestLengthFieldCodec(t *testing.T) {
 		})
 	}
 }
+
+func TestLengthFieldPrependerBuffers(t *testing.T) {
+
+	prepender := LengthFieldPrepender(binary.BigEndian, 2, 0, false)
+	ctx := MockHandlerContext{
+		MockHandleWrite: func(message netty.Message) {
+			buffers, ok := message.(*netty.Buffers)
+			if !ok || 3 != buffers.Count() {
+				t.Fatalf("unexpected message: %T", message)
+			}
+			if dst := utils.MustToBytes(buffers); !bytes.Equal(dst, []byte("\x00\x0cheader:body!")) {
+				t.Fatalf("%q", dst)
+			}
+		},
+	}
+
+	prepender.HandleWrite(ctx, netty.NewBuffers([]byte("header:"), []byte("body!")))
+}
+
+func benchmarkLengthFieldPrepender(b *testing.B, message func(header, body []byte) netty.Message) {
+
+	prepender := LengthFieldPrepender(binary.BigEndian, 4, 0, false)
+	header, body := make([]byte, 16), make([]byte, 4096)
+	ctx := MockHandlerContext{MockHandleWrite: func(message netty.Message) {}}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(header) + len(body)))
+	for i := 0; i < b.N; i++ {
+		prepender.HandleWrite(ctx, message(header, body))
+	}
+}
+
+func BenchmarkLengthFieldPrependerCopy(b *testing.B) {
+	benchmarkLengthFieldPrepender(b, func(header, body []byte) netty.Message {
+		return [][]byte{header, body}
+	})
+}
+
+func BenchmarkLengthFieldPrependerBuffers(b *testing.B) {
+	benchmarkLengthFieldPrepender(b, func(header, body []byte) netty.Message {
+		return netty.NewBuffers(header, body)
+	})
+}
+
+func TestLengthFieldCodecGolden(t *testing.T) {
+	handlers := func() []netty.Handler {
+		return []netty.Handler{LengthFieldCodec(binary.BigEndian, 1024, 0, 2, 0, 2)}
+	}
+	nettytest.CheckDecodeGolden(t, "length_field", handlers)
+	nettytest.CheckEncodeGolden(t, "length_field", handlers)
+}
+
+func FuzzLengthFieldCodec(f *testing.F) {
+	codec := func() netty.Handler { return LengthFieldCodec(binary.BigEndian, 1024, 0, 2, 0, 2) }
+	fuzz.FuzzDecoder(f, codec, fuzz.WithSeedFiles("testdata/length_field.bin"), fuzz.WithEncoder(codec))
+}
@@ -28,12 +28,12 @@ import (
 // PacketCodec create packet codec
 func PacketCodec(maxFrameLength int) codec.Codec {
 	utils.AssertIf(maxFrameLength <= 0, "maxFrameLength must be a positive integer")
-	return &packetCodec{maxFrameLength: maxFrameLength, buffer: make([]byte, maxFrameLength)}
+	return &packetCodec{maxFrameLength: maxFrameLength, buffer: utils.WrapByteBuf(make([]byte, maxFrameLength))}
 }
 
 type packetCodec struct {
 	maxFrameLength int
-	buffer         []byte
+	buffer         *utils.ByteBuf
 }
 
 func (*packetCodec) CodecName() string {
@@ -42,12 +42,12 @@ func (*packetCodec) CodecName() string {
 
 func (p *packetCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
 
-	reader := utils.MustToReader(message)
-	n, err := reader.Read(p.buffer)
-	if nil != err && !errors.Is(err, io.EOF) {
+	// the packet is a view of the buffer of codec, it is valid until the next read.
+	p.buffer.Reset()
+	if _, err := p.buffer.WriteFromReader(utils.MustToReader(message), p.maxFrameLength); nil != err && !errors.Is(err, io.EOF) {
 		panic(err)
 	}
-	ctx.HandleRead(p.buffer[:n])
+	ctx.HandleRead(p.buffer.Bytes())
 }
 
 func (*packetCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
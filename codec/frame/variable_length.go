@@ -25,12 +25,12 @@ import (
 // VariableLengthCodec create maximum received length codec
 func VariableLengthCodec(maxReadLength int) codec.Codec {
 	utils.AssertIf(maxReadLength <= 0, "maxReadLength must be a positive integer")
-	return &variableLengthCodec{maxReadLength: maxReadLength, buffer: make([]byte, maxReadLength)}
+	return &variableLengthCodec{maxReadLength: maxReadLength, buffer: utils.WrapByteBuf(make([]byte, maxReadLength))}
 }
 
 type variableLengthCodec struct {
 	maxReadLength int // maximum received length
-	buffer        []byte
+	buffer        *utils.ByteBuf
 }
 
 func (*variableLengthCodec) CodecName() string {
@@ -39,9 +39,12 @@ func (*variableLengthCodec) CodecName() string {
 
 func (v *variableLengthCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
 
-	reader := utils.MustToReader(message)
-	var n = utils.AssertLength(reader.Read(v.buffer))
-	ctx.HandleRead(v.buffer[:n])
+	// the frame is a view of the buffer of codec, it is valid until the next read.
+	v.buffer.Reset()
+	if _, err := v.buffer.WriteFromReader(utils.MustToReader(message), v.maxReadLength); nil != err {
+		panic(err)
+	}
+	ctx.HandleRead(v.buffer.Bytes())
 }
 
 func (*variableLengthCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
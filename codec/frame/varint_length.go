@@ -18,6 +18,7 @@ package frame
 
 import (
 	"encoding/binary"
+	"fmt"
 	"github.com/go-netty/go-netty"
 	"github.com/go-netty/go-netty/codec"
 	"github.com/go-netty/go-netty/utils"
@@ -46,18 +47,34 @@ func (v *varintLengthFieldCodec) HandleRead(ctx netty.InboundContext, message ne
 
 	frameLength, err := binary.ReadUvarint(utils.NewByteReader(reader))
 	utils.Assert(err)
-	utils.AssertIf(frameLength > uint64(v.maxFrameLength),
-		"frame length too large, frameLength(%d) > maxFrameLength(%d)", frameLength, v.maxFrameLength)
+	if frameLength > uint64(v.maxFrameLength) {
+		panic(fmt.Errorf("frame length too large, frameLength(%d) > maxFrameLength(%d)", frameLength, v.maxFrameLength))
+	}
 
 	ctx.HandleRead(io.LimitReader(reader, int64(frameLength)))
 }
 
 func (v *varintLengthFieldCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
 
+	// prepend the header without copying the body.
+	if buffers, ok := message.(*netty.Buffers); ok {
+		if buffers.Len() > int64(v.maxFrameLength) {
+			panic(fmt.Errorf("frame length too large, frameLength(%d) > maxFrameLength(%d)", buffers.Len(), v.maxFrameLength))
+		}
+
+		var head = make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(head, uint64(buffers.Len()))
+		ctx.HandleWrite(buffers.Prepend(head[:n]))
+		return
+	}
+
+	// the body may be a view of the message, recycle it after written.
+	defer netty.Recycle(message)
 	bodyBytes := utils.MustToBytes(message)
 
-	utils.AssertIf(len(bodyBytes) > v.maxFrameLength,
-		"frame length too large, frameLength(%d) > maxFrameLength(%d)", len(bodyBytes), v.maxFrameLength)
+	if len(bodyBytes) > v.maxFrameLength {
+		panic(fmt.Errorf("frame length too large, frameLength(%d) > maxFrameLength(%d)", len(bodyBytes), v.maxFrameLength))
+	}
 
 	// encode header
 	var head = [binary.MaxVarintLen64]byte{}
@@ -21,6 +21,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/nettytest"
+	"github.com/go-netty/go-netty/nettytest/fuzz"
 	"github.com/go-netty/go-netty/utils"
 	"strings"
 	"testing"
@@ -58,3 +60,44 @@ func TestVarintLengthFieldCodec(t *testing.T) {
 		})
 	}
 }
+
+func TestVarintLengthFieldCodecEmbedded(t *testing.T) {
+
+	ec := nettytest.NewEmbeddedChannel(VarintLengthFieldCodec(1024))
+
+	ec.WriteOutbound("123456789", strings.NewReader(strings.Repeat("x", 300)))
+	var stream []byte
+	for message := ec.ReadOutbound(); nil != message; message = ec.ReadOutbound() {
+		stream = append(stream, utils.MustToBytes(message)...)
+	}
+
+	// the stream is decoded to a frame per read, the frame is valid until the next read.
+	reader := bytes.NewReader(stream)
+	for _, want := range []string{"123456789", strings.Repeat("x", 300)} {
+		ec.WriteInbound(reader)
+		frame := ec.ReadInbound()
+		if nil == frame {
+			t.Fatal("missing frame")
+		}
+		if got := string(utils.MustToBytes(frame)); want != got {
+			t.Fatal("unexpected frame:", got, "want:", want)
+		}
+	}
+
+	if ec.FinishAndClose() || nil != ec.CheckException() {
+		t.Fatal("unexpected messages or exceptions")
+	}
+}
+
+func TestVarintLengthFieldCodecGolden(t *testing.T) {
+	handlers := func() []netty.Handler {
+		return []netty.Handler{VarintLengthFieldCodec(1024)}
+	}
+	nettytest.CheckDecodeGolden(t, "varint_length", handlers)
+	nettytest.CheckEncodeGolden(t, "varint_length", handlers)
+}
+
+func FuzzVarintLengthFieldCodec(f *testing.F) {
+	codec := func() netty.Handler { return VarintLengthFieldCodec(1024) }
+	fuzz.FuzzDecoder(f, codec, fuzz.WithSeedFiles("testdata/varint_length.bin"), fuzz.WithEncoder(codec))
+}
@@ -0,0 +1,330 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// ConnAdapter defines an io.ReadWriteCloser over the channel, for the libraries that only speak io.Reader / io.Writer.
+type ConnAdapter interface {
+	io.ReadWriteCloser
+	// SetReadDeadline to set the deadline of Read, the zero value means Read will not time out.
+	// The pending Read will be unblocked by the new deadline with os.ErrDeadlineExceeded.
+	SetReadDeadline(t time.Time) error
+}
+
+// ConnAdapterOption defines an option of ConnAdapter
+type ConnAdapterOption func(options *connAdapterOptions)
+
+// connAdapterOptions
+type connAdapterOptions struct {
+	bufferSize   int
+	skipUnknown  bool
+	closeTimeout time.Duration
+}
+
+// WithConnBufferSize to limit the inbound bytes buffered for Read, the read loop of channel will be blocked when the
+// buffer is full, default 64K.
+func WithConnBufferSize(size int) ConnAdapterOption {
+	return func(options *connAdapterOptions) {
+		options.bufferSize = size
+	}
+}
+
+// WithSkipUnknownMessage to skip the inbound messages that are not []byte, default is to fail the Read with an error.
+func WithSkipUnknownMessage() ConnAdapterOption {
+	return func(options *connAdapterOptions) {
+		options.skipUnknown = true
+	}
+}
+
+// WithConnCloseTimeout to limit the time waiting for the written bytes to be flushed by Close, default 5s.
+func WithConnCloseTimeout(timeout time.Duration) ConnAdapterOption {
+	return func(options *connAdapterOptions) {
+		options.closeTimeout = timeout
+	}
+}
+
+// NewConnAdapter create a ConnAdapter over the channel, a terminal inbound handler is added to the end of pipeline.
+//
+// The inbound []byte messages (or the bytes of transport if the pipeline has no decoder) are buffered for Read,
+// Write is mapped to Channel.Write of []byte, and Close is mapped to a graceful Channel.Close that waits for the
+// written bytes to be flushed. Read returns io.EOF (or the error of closing) when the channel goes inactive.
+//
+// The adapter can be created on a serving channel, but the inbound messages read before it is added (including
+// the one in flight) may reach the tail rather than the buffer of Read, create it in ChannelInitializer to see
+// every inbound byte.
+func NewConnAdapter(channel Channel, option ...ConnAdapterOption) ConnAdapter {
+
+	options := connAdapterOptions{bufferSize: 64 << 10, closeTimeout: 5 * time.Second}
+	for i := range option {
+		option[i](&options)
+	}
+
+	a := &connAdapter{
+		channel:  channel,
+		options:  options,
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+		deadline: newReadDeadline(),
+	}
+
+	if !channel.IsActive() {
+		a.err = io.EOF
+	}
+
+	channel.Pipeline().AddLast(connAdapterHandler{a})
+	return a
+}
+
+// connAdapter implement ConnAdapter
+type connAdapter struct {
+	channel  Channel
+	options  connAdapterOptions
+	mutex    sync.Mutex
+	buffer   bytes.Buffer
+	err      error // the error of Read after the buffered bytes are drained
+	readable chan struct{}
+	writable chan struct{}
+	deadline *readDeadline
+	scratch  []byte // the read buffer of transport
+}
+
+// Read to read the buffered inbound bytes, blocks until any bytes are available or the channel is inactive.
+func (a *connAdapter) Read(p []byte) (int, error) {
+
+	if 0 == len(p) {
+		return 0, nil
+	}
+
+	for {
+		a.mutex.Lock()
+		if a.buffer.Len() > 0 {
+			n, _ := a.buffer.Read(p)
+			a.mutex.Unlock()
+			notify(a.writable)
+			return n, nil
+		}
+		err := a.err
+		a.mutex.Unlock()
+
+		if nil != err {
+			return 0, err
+		}
+
+		select {
+		case <-a.readable:
+		case <-a.deadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// Write to write a copy of p to the channel, the writing is blocked while the send queue is full.
+func (a *connAdapter) Write(p []byte) (int, error) {
+	if len(p) > 0 && !a.channel.Write(append([]byte(nil), p...)) {
+		return 0, io.ErrClosedPipe
+	}
+	return len(p), nil
+}
+
+// Close to close the channel after the written bytes are flushed.
+func (a *connAdapter) Close() error {
+	if a.channel.IsActive() {
+		_ = a.channel.CloseGracefully(a.options.closeTimeout)
+	}
+	return nil
+}
+
+// SetReadDeadline to set the deadline of Read
+func (a *connAdapter) SetReadDeadline(t time.Time) error {
+	a.deadline.set(t)
+	return nil
+}
+
+// waitWritable to wait for the free space of buffer, returns false if the Read has failed or the channel is closed.
+func (a *connAdapter) waitWritable() bool {
+
+	for {
+		a.mutex.Lock()
+		free, err := a.options.bufferSize-a.buffer.Len(), a.err
+		a.mutex.Unlock()
+
+		switch {
+		case nil != err:
+			return false
+		case free > 0:
+			return true
+		}
+
+		select {
+		case <-a.writable:
+		case <-a.channel.Context().Done():
+			return false
+		}
+	}
+}
+
+// append the inbound bytes to the buffer
+func (a *connAdapter) append(p []byte) {
+	a.mutex.Lock()
+	a.buffer.Write(p)
+	a.mutex.Unlock()
+	notify(a.readable)
+}
+
+// fail to unblock the Read with the error after the buffered bytes are drained
+func (a *connAdapter) fail(err error) {
+	a.mutex.Lock()
+	if nil == a.err {
+		a.err = err
+	}
+	a.mutex.Unlock()
+	notify(a.readable)
+	notify(a.writable)
+}
+
+// notify to wake up the waiter without blocking
+func notify(signal chan struct{}) {
+	select {
+	case signal <- struct{}{}:
+	default:
+	}
+}
+
+// connAdapterHandler to capture the inbound bytes for ConnAdapter
+type connAdapterHandler struct {
+	adapter *connAdapter
+}
+
+func (h connAdapterHandler) HandleRead(ctx InboundContext, message Message) {
+
+	a := h.adapter
+	switch m := message.(type) {
+	case []byte:
+		if a.waitWritable() {
+			a.append(m)
+		}
+	case transport.Transport:
+		// the pipeline has no decoder, read the bytes from transport directly.
+		if !a.waitWritable() {
+			return
+		}
+		if nil == a.scratch {
+			a.scratch = make([]byte, minInt(a.options.bufferSize, 32<<10))
+		}
+		n, err := m.Read(a.scratch)
+		a.append(a.scratch[:n])
+		if nil != err {
+			// the adapter is the end of pipeline, the end of stream is not an exception.
+			ctx.Close(err)
+		}
+	default:
+		if !a.options.skipUnknown {
+			a.fail(fmt.Errorf("unsupported message type for ConnAdapter: %T", message))
+		} else {
+			LoggerFrom(ctx.Channel().Context()).Debugf("ConnAdapter of channel(%s) skipped the message: %T", ctx.Channel().Name(), message)
+		}
+	}
+}
+
+func (h connAdapterHandler) HandleInactive(ctx InactiveContext, ex Exception) {
+	// the closing by peer is the end of stream.
+	if nil != ex && !errors.Is(ex, io.EOF) {
+		h.adapter.fail(ex)
+	} else {
+		h.adapter.fail(io.EOF)
+	}
+	ctx.HandleInactive(ex)
+}
+
+// readDeadline defines a deadline that can be changed while waiting
+type readDeadline struct {
+	mutex   sync.Mutex
+	timer   *time.Timer
+	expired chan struct{}
+}
+
+func newReadDeadline() *readDeadline {
+	return &readDeadline{expired: make(chan struct{})}
+}
+
+// set to reset the deadline, the zero time means no deadline.
+func (d *readDeadline) set(t time.Time) {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if nil != d.timer && !d.timer.Stop() {
+		// the timer has been fired, the channel is closed or being closed.
+		<-d.expired
+	}
+	d.timer = nil
+
+	closed := isClosed(d.expired)
+	if t.IsZero() {
+		if closed {
+			d.expired = make(chan struct{})
+		}
+		return
+	}
+
+	if dur := time.Until(t); dur > 0 {
+		if closed {
+			d.expired = make(chan struct{})
+		}
+		expired := d.expired
+		d.timer = time.AfterFunc(dur, func() { close(expired) })
+		return
+	}
+
+	if !closed {
+		close(d.expired)
+	}
+}
+
+// wait returns the channel that is closed when the deadline is exceeded
+func (d *readDeadline) wait() chan struct{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.expired
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnAdapterEcho(t *testing.T) {
+
+	// the pipeline without decoder, and the pipeline decodes the bytes to []byte.
+	pipelines := map[string]func() Pipeline{
+		"transport": NewPipelineWith,
+		"bytes": func() Pipeline {
+			return NewPipelineWith().AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+				buffer := make([]byte, 1000)
+				n, err := message.(io.Reader).Read(buffer)
+				if n > 0 {
+					ctx.HandleRead(buffer[:n])
+				}
+				if nil != err {
+					panic(err)
+				}
+			}), ignoreException)
+		},
+	}
+
+	for name, newPipeline := range pipelines {
+		t.Run(name, func(t *testing.T) {
+
+			server, client := tcpPair(t)
+			defer client.Close()
+
+			c := newTransportChannel(1, newPipeline(), &pipeTransport{Conn: server})
+			adapter := NewConnAdapter(c, WithConnBufferSize(4096))
+			c.serveChannel()
+
+			go func() {
+				_, _ = io.Copy(adapter, adapter)
+				_ = adapter.Close()
+			}()
+
+			payload := make([]byte, 1<<20)
+			rand.New(rand.NewSource(1)).Read(payload)
+
+			go func() { _, _ = client.Write(payload) }()
+
+			echoed := make([]byte, len(payload))
+			if _, err := io.ReadFull(client, echoed); nil != err || !bytes.Equal(payload, echoed) {
+				t.Fatal("unexpected echo:", err)
+			}
+
+			// the copying is finished by the end of stream.
+			_ = client.Close()
+			<-c.Context().Done()
+		})
+	}
+}
+
+func TestConnAdapterDeadline(t *testing.T) {
+
+	c, peer := newPipeChannel(1, NewPipelineWith())
+	adapter := NewConnAdapter(c)
+	c.serveChannel()
+	defer c.Close(nil)
+
+	_ = adapter.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := adapter.Read(make([]byte, 8)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the pending Read is unblocked by the new deadline.
+	_ = adapter.SetReadDeadline(time.Time{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := adapter.Read(make([]byte, 8))
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	_ = adapter.SetReadDeadline(time.Now().Add(-time.Second))
+	if err := <-done; !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_ = adapter.SetReadDeadline(time.Time{})
+	go func() { _, _ = peer.Write([]byte("hello")) }()
+	if got := readString(t, adapter, 5); "hello" != got {
+		t.Fatal("unexpected bytes:", got)
+	}
+}
+
+func TestConnAdapterCloseWhileReading(t *testing.T) {
+
+	t.Run("peer", func(t *testing.T) {
+		c, peer := newPipeChannel(1, NewPipelineWith())
+		adapter := NewConnAdapter(c)
+		c.serveChannel()
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := adapter.Read(make([]byte, 8))
+			done <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		_ = peer.Close()
+		if err := <-done; io.EOF != err {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+
+	t.Run("adapter", func(t *testing.T) {
+		c, peer := newPipeChannel(1, NewPipelineWith())
+		adapter := NewConnAdapter(c)
+		c.serveChannel()
+
+		// the bytes written before closing are flushed.
+		flushed := make(chan string, 1)
+		go func() {
+			data, _ := ioutil.ReadAll(peer)
+			flushed <- string(data)
+		}()
+
+		if _, err := adapter.Write([]byte("bye")); nil != err {
+			t.Fatal(err)
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := adapter.Read(make([]byte, 8))
+			done <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		if err := adapter.Close(); nil != err {
+			t.Fatal(err)
+		}
+		if err := <-done; io.EOF != err {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if _, err := adapter.Write([]byte("closed")); io.ErrClosedPipe != err {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if data := <-flushed; "bye" != data {
+			t.Fatal("unexpected bytes:", data)
+		}
+	})
+}
+
+func TestConnAdapterUnknownMessage(t *testing.T) {
+
+	c, _ := newPipeChannel(1, NewPipelineWith())
+	adapter := NewConnAdapter(c)
+
+	c.pipeline.FireChannelRead([]byte("ok"))
+	c.pipeline.FireChannelRead(42)
+	c.pipeline.FireChannelRead([]byte("dropped"))
+
+	// the buffered bytes are read before the error.
+	if got := readString(t, adapter, 2); "ok" != got {
+		t.Fatal("unexpected bytes:", got)
+	}
+	if _, err := adapter.Read(make([]byte, 8)); nil == err || !strings.Contains(err.Error(), "unsupported message type") {
+		t.Fatal("unexpected error:", err)
+	}
+
+	c, _ = newPipeChannel(2, NewPipelineWith())
+	adapter = NewConnAdapter(c, WithSkipUnknownMessage())
+
+	c.pipeline.FireChannelRead(42)
+	c.pipeline.FireChannelRead([]byte("ok"))
+	if got := readString(t, adapter, 2); "ok" != got {
+		t.Fatal("unexpected bytes:", got)
+	}
+}
@@ -16,15 +16,32 @@
 
 package netty
 
-import "runtime/debug"
+import (
+	"errors"
+	"runtime/debug"
+	"sync/atomic"
+	"unsafe"
+)
 
 type (
 	// HandlerContext defines a base handler context
 	HandlerContext interface {
 		Channel() Channel
 		Handler() Handler
+		Name() string
+		// Prev returns the previous context, nil for the head of pipeline.
+		Prev() HandlerContext
+		// Next returns the next context, nil for the tail of pipeline.
+		Next() HandlerContext
+		// Write the message from the outbound handler before this context, the handler itself is skipped.
 		Write(message Message)
+		Retain(message Message)
+		// Trigger the event from the event handler after this context, unlike Pipeline.FireChannelEvent
+		// that starts from the head, the handler itself and the handlers before it are skipped.
 		Trigger(event Event)
+		// FireRead the message from the inbound handler after this context, unlike Pipeline.FireChannelRead
+		// that starts from the head, the handler itself and the handlers before it are skipped.
+		FireRead(message Message)
 		Close(err error)
 		Attachment() Attachment
 		SetAttachment(Attachment)
@@ -52,6 +69,7 @@ type (
 	ExceptionContext interface {
 		HandlerContext
 		HandleException(ex Exception)
+		HandledException(ex Exception)
 	}
 
 	// InactiveContext defines an inactive handler
@@ -67,29 +85,173 @@ type (
 	}
 )
 
+// handlerMask defines the capabilities of the handler
+type handlerMask uint8
+
+const (
+	maskActive handlerMask = 1 << iota
+	maskInbound
+	maskOutbound
+	maskException
+	maskInactive
+	maskEvent
+)
+
+// handlerMaskOf to classify the capabilities of the handler
+func handlerMaskOf(handler Handler) (mask handlerMask) {
+	if _, ok := handler.(ActiveHandler); ok {
+		mask |= maskActive
+	}
+	if _, ok := handler.(InboundHandler); ok {
+		mask |= maskInbound
+	}
+	if _, ok := handler.(OutboundHandler); ok {
+		mask |= maskOutbound
+	}
+	if _, ok := handler.(ExceptionHandler); ok {
+		mask |= maskException
+	}
+	if _, ok := handler.(InactiveHandler); ok {
+		mask |= maskInactive
+	}
+	if _, ok := handler.(EventHandler); ok {
+		mask |= maskEvent
+	}
+	return
+}
+
 // handlerContext impl HandlerContext
+//
+// The links and the binding are accessed atomically, so the pipeline can be mutated while
+// the messages are flowing, the traversals never take a lock.
 type handlerContext struct {
-	pipeline Pipeline
-	handler  Handler
-	prev     *handlerContext
-	next     *handlerContext
+	pipeline *pipeline
+	name     string         // empty for the unnamed handlers
+	binding  unsafe.Pointer // *handlerBinding
+	prev     unsafe.Pointer // *handlerContext
+	next     unsafe.Pointer // *handlerContext
+}
+
+// handlerBinding defines the handler converted once at insertion, so the dispatching needs not type assertions.
+type handlerBinding struct {
+	handler   Handler
+	mask      handlerMask
+	active    ActiveHandler
+	inbound   InboundHandler
+	outbound  OutboundHandler
+	exception ExceptionHandler
+	inactive  InactiveHandler
+	event     EventHandler
+	removed   int32
+}
+
+// bind to convert the handler to the typed handlers
+func (b *handlerBinding) bind(handler Handler) {
+	b.handler = handler
+	b.mask = handlerMaskOf(handler)
+	b.active, _ = handler.(ActiveHandler)
+	b.inbound, _ = handler.(InboundHandler)
+	b.outbound, _ = handler.(OutboundHandler)
+	b.exception, _ = handler.(ExceptionHandler)
+	b.inactive, _ = handler.(InactiveHandler)
+	b.event, _ = handler.(EventHandler)
+}
+
+// handleAdded to notify the handler that it is attached to the pipeline
+func (b *handlerBinding) handleAdded(ctx *handlerContext) {
+	if h, ok := b.handler.(AddedHandler); ok {
+		h.HandleAdded(ctx)
+	}
+}
+
+// handleRemoved to notify the handler that it is detached from the pipeline, only once.
+func (b *handlerBinding) handleRemoved(ctx *handlerContext) {
+	if h, ok := b.handler.(RemovedHandler); ok && atomic.CompareAndSwapInt32(&b.removed, 0, 1) {
+		h.HandleRemoved(ctx)
+	}
+}
+
+// newHandlerContext create a context of handler
+func newHandlerContext(pipeline *pipeline, handler Handler, prev, next *handlerContext) *handlerContext {
+	hc := &handlerContext{
+		pipeline: pipeline,
+		prev:     unsafe.Pointer(prev),
+		next:     unsafe.Pointer(next),
+	}
+	hc.setHandler(handler)
+	return hc
+}
+
+// setHandler to point the context at the handler
+func (hc *handlerContext) setHandler(handler Handler) {
+	b := &handlerBinding{}
+	b.bind(handler)
+	atomic.StorePointer(&hc.binding, unsafe.Pointer(b))
+}
+
+// bound returns the current binding of handler
+func (hc *handlerContext) bound() *handlerBinding {
+	return (*handlerBinding)(atomic.LoadPointer(&hc.binding))
 }
 
 func (hc *handlerContext) prevContext() *handlerContext {
-	return hc.prev
+	return (*handlerContext)(atomic.LoadPointer(&hc.prev))
 }
 
 func (hc *handlerContext) nextContext() *handlerContext {
-	return hc.next
+	return (*handlerContext)(atomic.LoadPointer(&hc.next))
+}
+
+func (hc *handlerContext) setPrev(prev *handlerContext) {
+	atomic.StorePointer(&hc.prev, unsafe.Pointer(prev))
+}
+
+func (hc *handlerContext) setNext(next *handlerContext) {
+	atomic.StorePointer(&hc.next, unsafe.Pointer(next))
+}
+
+// interceptActive to invoke HandleActive of handler through the interceptor of pipeline
+func (hc *handlerContext) interceptActive(b *handlerBinding) {
+	hc.pipeline.interceptor(hc, "HandleActive", func() { b.active.HandleActive(hc) })
+}
+
+// interceptRead to invoke HandleRead of handler through the interceptor of pipeline
+func (hc *handlerContext) interceptRead(b *handlerBinding, message Message) {
+	hc.pipeline.interceptor(hc, "HandleRead", func() { b.inbound.HandleRead(hc, message) })
+}
+
+// interceptWrite to invoke HandleWrite of handler through the interceptor of pipeline
+func (hc *handlerContext) interceptWrite(b *handlerBinding, message Message) {
+	hc.pipeline.interceptor(hc, "HandleWrite", func() { b.outbound.HandleWrite(hc, message) })
+}
+
+// interceptException to invoke HandleException of handler through the interceptor of pipeline
+func (hc *handlerContext) interceptException(b *handlerBinding, ex Exception) {
+	hc.pipeline.interceptor(hc, "HandleException", func() { b.exception.HandleException(hc, ex) })
+}
+
+// interceptInactive to invoke HandleInactive of handler through the interceptor of pipeline
+func (hc *handlerContext) interceptInactive(b *handlerBinding, ex Exception) {
+	hc.pipeline.interceptor(hc, "HandleInactive", func() { b.inactive.HandleInactive(hc, ex) })
+}
+
+// interceptEvent to invoke HandleEvent of handler through the interceptor of pipeline
+func (hc *handlerContext) interceptEvent(b *handlerBinding, event Event) {
+	hc.pipeline.interceptor(hc, "HandleEvent", func() { b.event.HandleEvent(hc, event) })
+}
+
+// recoverException to route the panic to the pipeline, it must be called by defer directly.
+//
+//go:noinline
+func (hc *handlerContext) recoverException() {
+	if err := recover(); nil != err {
+		recoverPanic(hc, err, debug.Stack())
+	}
 }
 
 func (hc *handlerContext) Write(message Message) {
 
-	defer func() {
-		if err := recover(); nil != err {
-			hc.Channel().Pipeline().FireChannelException(AsException(err, debug.Stack()))
-		}
-	}()
+	defer hc.recoverException()
 
 	var next = hc
 
@@ -98,20 +260,24 @@ func (hc *handlerContext) Write(message Message) {
 			break
 		}
 
-		if handler, ok := next.Handler().(OutboundHandler); ok {
-			handler.HandleWrite(next, message)
+		if b := next.bound(); 0 != b.mask&maskOutbound {
+			if nil != hc.pipeline.interceptor {
+				next.interceptWrite(b, message)
+			} else {
+				b.outbound.HandleWrite(next, message)
+			}
 			break
 		}
 	}
 }
 
+func (hc *handlerContext) Retain(message Message) {
+	Retain(message, 1)
+}
+
 func (hc *handlerContext) Trigger(event Event) {
 
-	defer func() {
-		if err := recover(); nil != err {
-			hc.Channel().Pipeline().FireChannelException(AsException(err, debug.Stack()))
-		}
-	}()
+	defer hc.recoverException()
 
 	var next = hc
 
@@ -120,13 +286,24 @@ func (hc *handlerContext) Trigger(event Event) {
 			break
 		}
 
-		if handler, ok := next.Handler().(EventHandler); ok {
-			handler.HandleEvent(next, event)
+		if b := next.bound(); 0 != b.mask&maskEvent {
+			if nil != hc.pipeline.interceptor {
+				next.interceptEvent(b, event)
+			} else {
+				b.event.HandleEvent(next, event)
+			}
 			break
 		}
 	}
 }
 
+func (hc *handlerContext) FireRead(message Message) {
+
+	defer hc.recoverException()
+
+	hc.HandleRead(message)
+}
+
 func (hc *handlerContext) Close(err error) {
 	hc.Channel().Close(err)
 }
@@ -136,7 +313,26 @@ func (hc *handlerContext) Channel() Channel {
 }
 
 func (hc *handlerContext) Handler() Handler {
-	return hc.handler
+	return hc.bound().handler
+}
+
+func (hc *handlerContext) Prev() HandlerContext {
+	if prev := hc.prevContext(); nil != prev {
+		return prev
+	}
+	return nil
+}
+
+func (hc *handlerContext) Next() HandlerContext {
+	if next := hc.nextContext(); nil != next {
+		return next
+	}
+	return nil
+}
+
+// Name returns the name of handler, empty for the unnamed handlers.
+func (hc *handlerContext) Name() string {
+	return hc.name
 }
 
 func (hc *handlerContext) Attachment() Attachment {
@@ -156,8 +352,12 @@ func (hc *handlerContext) HandleActive() {
 			break
 		}
 
-		if handler, ok := next.Handler().(ActiveHandler); ok {
-			handler.HandleActive(next)
+		if b := next.bound(); 0 != b.mask&maskActive {
+			if nil != hc.pipeline.interceptor {
+				next.interceptActive(b)
+			} else {
+				b.active.HandleActive(next)
+			}
 			break
 		}
 	}
@@ -171,8 +371,16 @@ func (hc *handlerContext) HandleRead(message Message) {
 			break
 		}
 
-		if handler, ok := next.Handler().(InboundHandler); ok {
-			handler.HandleRead(next, message)
+		if b := next.bound(); 0 != b.mask&maskInbound {
+			// the messages delivered to the last inbound handler are decoded.
+			if unsafe.Pointer(next) == atomic.LoadPointer(&hc.pipeline.lastInbound) && nil != hc.pipeline.channel {
+				atomic.AddInt64(&hc.pipeline.channel.counters().messagesRead, 1)
+			}
+			if nil != hc.pipeline.interceptor {
+				next.interceptRead(b, message)
+			} else {
+				b.inbound.HandleRead(next, message)
+			}
 			break
 		}
 	}
@@ -186,14 +394,23 @@ func (hc *handlerContext) HandleWrite(message Message) {
 			break
 		}
 
-		if handler, ok := prev.Handler().(OutboundHandler); ok {
-			handler.HandleWrite(prev, message)
+		if b := prev.bound(); 0 != b.mask&maskOutbound {
+			if nil != hc.pipeline.interceptor {
+				prev.interceptWrite(b, message)
+			} else {
+				b.outbound.HandleWrite(prev, message)
+			}
 			break
 		}
 	}
 }
 
 func (hc *handlerContext) HandleException(ex Exception) {
+	// the propagation is stopped by the handled exception, the channel is kept open.
+	if isHandledException(ex) {
+		return
+	}
+
 	var next = hc
 
 	for {
@@ -201,13 +418,24 @@ func (hc *handlerContext) HandleException(ex Exception) {
 			break
 		}
 
-		if handler, ok := next.Handler().(ExceptionHandler); ok {
-			handler.HandleException(next, ex)
+		if b := next.bound(); 0 != b.mask&maskException {
+			if nil != hc.pipeline.interceptor {
+				next.interceptException(b, ex)
+			} else {
+				b.exception.HandleException(next, ex)
+			}
 			break
 		}
 	}
 }
 
+func (hc *handlerContext) HandledException(ex Exception) {
+	var ce *ChannelException
+	if errors.As(ex, &ce) {
+		ce.markHandled()
+	}
+}
+
 func (hc *handlerContext) HandleInactive(ex Exception) {
 	var next = hc
 
@@ -216,8 +444,12 @@ func (hc *handlerContext) HandleInactive(ex Exception) {
 			break
 		}
 
-		if handler, ok := next.Handler().(InactiveHandler); ok {
-			handler.HandleInactive(next, ex)
+		if b := next.bound(); 0 != b.mask&maskInactive {
+			if nil != hc.pipeline.interceptor {
+				next.interceptInactive(b, ex)
+			} else {
+				b.inactive.HandleInactive(next, ex)
+			}
 			break
 		}
 	}
@@ -231,8 +463,12 @@ func (hc *handlerContext) HandleEvent(event Event) {
 			break
 		}
 
-		if handler, ok := next.Handler().(EventHandler); ok {
-			handler.HandleEvent(next, event)
+		if b := next.bound(); 0 != b.mask&maskEvent {
+			if nil != hc.pipeline.interceptor {
+				next.interceptEvent(b, event)
+			} else {
+				b.event.HandleEvent(next, event)
+			}
 			break
 		}
 	}
@@ -17,10 +17,15 @@
 package netty
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // Exception defines an exception
@@ -35,6 +40,15 @@ type Exception interface {
 	PrintStackTrace(writer io.Writer, msg ...string)
 }
 
+// StackFormatter defines an exception that formats the stack trace with options, it is implemented by the
+// exceptions of go-netty, the stack of other exceptions can be formatted by FormatStack(ex.Stack(), option...).
+type StackFormatter interface {
+	// FormatStack format stack trace with options.
+	FormatStack(option ...StackOption) []byte
+	// PrintStackTraceWith dump formatted stack trace to writers.
+	PrintStackTraceWith(writer io.Writer, option []StackOption, msg ...string)
+}
+
 // AsException to wrap error to Exception
 func AsException(e interface{}, stack []byte) Exception {
 
@@ -46,8 +60,20 @@ func AsException(e interface{}, stack []byte) Exception {
 	case error:
 		return exception{error: err, stack: stack}
 	default:
-		return exception{error: fmt.Errorf("%v", e), stack: stack}
+		return &PanicException{value: e, stack: stack}
+	}
+}
+
+// IsFatalException returns true if the channel can not work anymore after the exception,
+// e.g: the peer closed the connection or the transport is broken.
+func IsFatalException(err error) bool {
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
 	}
+
+	var ne net.Error
+	return errors.As(err, &ne) && !ne.Temporary()
 }
 
 // exception impl Exception
@@ -71,8 +97,203 @@ func (e exception) Stack() []byte {
 	return e.stack
 }
 
+// FormatStack to get formatted exception stack trace
+func (e exception) FormatStack(option ...StackOption) []byte {
+	return FormatStack(e.stack, option...)
+}
+
 // PrintStackTrace to write stack trance info to writer
 func (e exception) PrintStackTrace(writer io.Writer, msg ...string) {
+	e.PrintStackTraceWith(writer, nil, msg...)
+}
+
+// PrintStackTraceWith to write formatted stack trance info to writer
+func (e exception) PrintStackTraceWith(writer io.Writer, option []StackOption, msg ...string) {
+	printStackTrace(writer, e.error, e.FormatStack(option...), msg...)
+}
+
+// PanicException defines an exception created by a non-error panic value.
+type PanicException struct {
+	value interface{}
+	stack []byte
+}
+
+// Value to get the original panic value
+func (p *PanicException) Value() interface{} {
+	return p.value
+}
+
+// Unwrap to unwrap inner error, the panic value is not an error.
+func (p *PanicException) Unwrap() error {
+	return nil
+}
+
+// Error to get error message
+func (p *PanicException) Error() string {
+	return fmt.Sprintf("%+v", p.value)
+}
+
+// Stack to get exception stack trace
+func (p *PanicException) Stack() []byte {
+	return p.stack
+}
+
+// FormatStack to get formatted exception stack trace
+func (p *PanicException) FormatStack(option ...StackOption) []byte {
+	return FormatStack(p.stack, option...)
+}
+
+// PrintStackTrace to write stack trance info to writer
+func (p *PanicException) PrintStackTrace(writer io.Writer, msg ...string) {
+	p.PrintStackTraceWith(writer, nil, msg...)
+}
+
+// PrintStackTraceWith to write formatted stack trance info to writer
+func (p *PanicException) PrintStackTraceWith(writer io.Writer, option []StackOption, msg ...string) {
+	printStackTrace(writer, p, p.FormatStack(option...), msg...)
+}
+
+// ChannelException defines an exception routed by the pipeline of channel.
+type ChannelException struct {
+	Exception
+	channelID   int64
+	channelName string
+	server      bool
+	localAddr   string
+	remoteAddr  string
+	pipeline    string
+	time        time.Time
+	handled     int32
+}
+
+// asChannelException to wrap the exception with the metadata of channel, the exception will be wrapped only once.
+func asChannelException(ex Exception, channel Channel) Exception {
+
+	var ce *ChannelException
+	if nil == ex || nil == channel || errors.As(ex, &ce) {
+		return ex
+	}
+
+	return &ChannelException{
+		Exception:   ex,
+		channelID:   channel.ID(),
+		channelName: channel.Name(),
+		server:      channel.IsServer(),
+		localAddr:   channel.LocalAddr(),
+		remoteAddr:  channel.RemoteAddr(),
+		pipeline:    channel.Pipeline().Dump(),
+		time:        ClockFrom(channel.Context()).Now(),
+	}
+}
+
+// Unwrap to unwrap inner exception
+func (c *ChannelException) Unwrap() error {
+	return c.Exception
+}
+
+// ChannelID to get the id of channel
+func (c *ChannelException) ChannelID() int64 {
+	return c.channelID
+}
+
+// ChannelName to get the name of channel
+func (c *ChannelException) ChannelName() string {
+	return c.channelName
+}
+
+// IsServer returns true if the channel is accepted by a listener
+func (c *ChannelException) IsServer() bool {
+	return c.server
+}
+
+// LocalAddr to get the local address of channel
+func (c *ChannelException) LocalAddr() string {
+	return c.localAddr
+}
+
+// RemoteAddr to get the remote address of channel
+func (c *ChannelException) RemoteAddr() string {
+	return c.remoteAddr
+}
+
+// PipelineDump to get the handlers of pipeline when the exception was routed
+func (c *ChannelException) PipelineDump() string {
+	return c.pipeline
+}
+
+// Time to get the time when the exception was routed
+func (c *ChannelException) Time() time.Time {
+	return c.time
+}
+
+// Handled returns true if the exception has been marked as handled by ExceptionContext.HandledException
+func (c *ChannelException) Handled() bool {
+	return 1 == atomic.LoadInt32(&c.handled)
+}
+
+// markHandled to mark the exception as handled
+func (c *ChannelException) markHandled() {
+	atomic.StoreInt32(&c.handled, 1)
+}
+
+// isHandledException returns true if the exception is marked as handled and is not fatal.
+func isHandledException(ex Exception) bool {
+	var ce *ChannelException
+	return errors.As(ex, &ce) && ce.Handled() && !IsFatalException(ex)
+}
+
+// PrintStackTrace to write stack trance info to writer
+func (c *ChannelException) PrintStackTrace(writer io.Writer, msg ...string) {
+	c.PrintStackTraceWith(writer, nil, msg...)
+}
+
+// FormatStack to get formatted exception stack trace
+func (c *ChannelException) FormatStack(option ...StackOption) []byte {
+	return FormatStack(c.Stack(), option...)
+}
+
+// PrintStackTraceWith to write formatted stack trance info to writer
+func (c *ChannelException) PrintStackTraceWith(writer io.Writer, option []StackOption, msg ...string) {
+	printStackTrace(writer, c, c.FormatStack(option...), append(msg, c.channelInfo())...)
+}
+
+// MarshalJSON to marshal the exception and the metadata of channel
+func (c *ChannelException) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ChannelID   int64     `json:"channel_id"`
+		ChannelName string    `json:"channel_name"`
+		Server      bool      `json:"server"`
+		LocalAddr   string    `json:"local_addr"`
+		RemoteAddr  string    `json:"remote_addr"`
+		Pipeline    string    `json:"pipeline"`
+		Time        time.Time `json:"time"`
+		Error       string    `json:"error"`
+		Stack       string    `json:"stack"`
+	}{
+		ChannelID:   c.channelID,
+		ChannelName: c.channelName,
+		Server:      c.server,
+		LocalAddr:   c.localAddr,
+		RemoteAddr:  c.remoteAddr,
+		Pipeline:    c.pipeline,
+		Time:        c.time,
+		Error:       c.Error(),
+		Stack:       string(c.Stack()),
+	})
+}
+
+// channelInfo to format the metadata of channel
+func (c *ChannelException) channelInfo() string {
+	side := "client"
+	if c.server {
+		side = "server"
+	}
+	return fmt.Sprintf("Channel: id=%d, local=%s, remote=%s, time=%s, name=%s, side=%s\nPipeline: %s\n",
+		c.channelID, c.localAddr, c.remoteAddr, c.time.Format(time.RFC3339Nano), c.channelName, side, c.pipeline)
+}
+
+// printStackTrace to write the error chain and stack trace to writer
+func printStackTrace(writer io.Writer, err error, stack []byte, msg ...string) {
 
 	// default: write to stderr.
 	if nil == writer {
@@ -86,12 +307,11 @@ func (e exception) PrintStackTrace(writer io.Writer, msg ...string) {
 	}
 
 	sb.WriteString("Error Traceback:\n")
-	var err = e.error
 	var i int
 	for {
 		i++
 		sb.WriteString(fmt.Sprintf("%T: %s", err, err.Error()))
-		if e, ok := err.(interface{ Unwrap() error }); ok {
+		if e, ok := err.(interface{ Unwrap() error }); ok && nil != e.Unwrap() {
 			sb.WriteString("\n" + strings.Repeat("  ", i))
 			err = e.Unwrap()
 			continue
@@ -100,7 +320,7 @@ func (e exception) PrintStackTrace(writer io.Writer, msg ...string) {
 	}
 
 	sb.WriteString("\n")
-	sb.Write(e.Stack())
+	sb.Write(stack)
 
 	// write stack trace to writer
 	_, _ = io.Copy(writer, strings.NewReader(sb.String()))
@@ -0,0 +1,419 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+var syntheticStack = []byte(`goroutine 7 [running]:
+runtime/debug.Stack()
+	/usr/local/go/src/runtime/debug/stack.go:24 +0x65
+github.com/go-netty/go-netty.(*channel).invokeMethod.func1()
+	/go/src/github.com/go-netty/go-netty/channel.go:222 +0x7a
+panic({0x6dc0a0, 0xc0000a2010})
+	/usr/local/go/src/runtime/panic.go:884 +0x213
+example.com/app/handler.(*Echo).HandleRead(0xc0000b4000, {0x7e1f40, 0xc0000d2000}, {0x6c3d20, 0xc0000a2030})
+	/home/app/handler/echo.go:42 +0x1a5
+github.com/go-netty/go-netty.(*handlerContext).HandleRead(0xc0000b6000, {0x6c3d20, 0xc0000a2030})
+	/go/src/github.com/go-netty/go-netty/context.go:160 +0x8c
+github.com/go-netty/go-netty/codec/frame.(*delimiterCodec).HandleRead(0xc0000b2000, {0x7e1f40, 0xc0000b6000}, {0x7e2000, 0xc0000c0000})
+	/go/src/github.com/go-netty/go-netty/codec/frame/delimiter.go:73 +0x2c5
+example.com/app/server.Serve()
+	/home/app/server/server.go:12 +0x33
+created by github.com/go-netty/go-netty.(*channel).serveChannel in goroutine 1
+	/go/src/github.com/go-netty/go-netty/channel.go:199 +0x8a
+`)
+
+func TestFormatStack(t *testing.T) {
+
+	t.Run("default", func(t *testing.T) {
+		if !bytes.Equal(syntheticStack, FormatStack(syntheticStack)) {
+			t.Fatal("default stack format must keep the raw stack")
+		}
+	})
+
+	t.Run("skip-internal", func(t *testing.T) {
+		want := `goroutine 7 [running]:
+example.com/app/handler.(*Echo).HandleRead()
+	/home/app/handler/echo.go:42 +0x1a5
+example.com/app/server.Serve()
+	/home/app/server/server.go:12 +0x33
+`
+		if got := string(FormatStack(syntheticStack, SkipInternalFrames())); want != got {
+			t.Fatalf("unexpected stack:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("compact-depth", func(t *testing.T) {
+		want := `goroutine 7 [running]:
+runtime/debug.Stack /usr/local/go/src/runtime/debug/stack.go:24
+github.com/go-netty/go-netty.(*channel).invokeMethod.func1 /go/src/github.com/go-netty/go-netty/channel.go:222
+... 6 more frames
+`
+		if got := string(FormatStack(syntheticStack, CompactStack(), MaxStackDepth(2))); want != got {
+			t.Fatalf("unexpected stack:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("highlight-user", func(t *testing.T) {
+		want := `goroutine 7 [running]:
+  runtime/debug.Stack /usr/local/go/src/runtime/debug/stack.go:24
+  github.com/go-netty/go-netty.(*channel).invokeMethod.func1 /go/src/github.com/go-netty/go-netty/channel.go:222
+  panic /usr/local/go/src/runtime/panic.go:884
+> example.com/app/handler.(*Echo).HandleRead /home/app/handler/echo.go:42
+  github.com/go-netty/go-netty.(*handlerContext).HandleRead /go/src/github.com/go-netty/go-netty/context.go:160
+  github.com/go-netty/go-netty/codec/frame.(*delimiterCodec).HandleRead /go/src/github.com/go-netty/go-netty/codec/frame/delimiter.go:73
+  example.com/app/server.Serve /home/app/server/server.go:12
+  created by github.com/go-netty/go-netty.(*channel).serveChannel /go/src/github.com/go-netty/go-netty/channel.go:199
+`
+		if got := string(FormatStack(syntheticStack, CompactStack(), HighlightUserFrame())); want != got {
+			t.Fatalf("unexpected stack:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestPrintStackTraceWith(t *testing.T) {
+
+	ex := AsException(errors.New("boom"), syntheticStack)
+
+	var raw bytes.Buffer
+	ex.PrintStackTrace(&raw, "prefix: ")
+	if !strings.HasPrefix(raw.String(), "prefix: Error Traceback:\n") || !bytes.HasSuffix(raw.Bytes(), syntheticStack) {
+		t.Fatal("unexpected stack trace:", raw.String())
+	}
+
+	var formatted bytes.Buffer
+	ex.(StackFormatter).PrintStackTraceWith(&formatted, []StackOption{SkipInternalFrames(), CompactStack()})
+	if strings.Contains(formatted.String(), "runtime/debug.Stack") || !strings.Contains(formatted.String(), "example.com/app/handler.(*Echo).HandleRead /home/app/handler/echo.go:42\n") {
+		t.Fatal("unexpected stack trace:", formatted.String())
+	}
+
+	// the exceptions implemented outside are formatted by the stack.
+	formatted.Reset()
+	ce := &ChannelException{Exception: plainException{error: errors.New("boom")}}
+	ce.PrintStackTraceWith(&formatted, []StackOption{SkipInternalFrames(), CompactStack()})
+	if strings.Contains(formatted.String(), "runtime/debug.Stack") || !strings.Contains(formatted.String(), "example.com/app/handler.(*Echo).HandleRead /home/app/handler/echo.go:42\n") {
+		t.Fatal("unexpected stack trace:", formatted.String())
+	}
+}
+
+// plainException implements Exception only
+type plainException struct {
+	error
+}
+
+func (e plainException) Unwrap() error {
+	return e.error
+}
+
+func (e plainException) Stack() []byte {
+	return syntheticStack
+}
+
+func (e plainException) PrintStackTrace(writer io.Writer, msg ...string) {
+	_, _ = writer.Write(syntheticStack)
+}
+
+type connectionKicked struct {
+	Reason string
+}
+
+func TestAsException(t *testing.T) {
+
+	var caught Exception
+	p := NewPipelineWith()
+	p.AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		caught = ex
+	}))
+
+	c, _ := newPipeChannel(1, p)
+
+	panicWith := func(v interface{}) Exception {
+		caught = nil
+		c.invokeMethod(func() { panic(v) })
+		return caught
+	}
+
+	t.Run("error", func(t *testing.T) {
+		err := errors.New("closed by peer")
+		if ex := panicWith(err); !errors.Is(ex, err) {
+			t.Fatal("original error is lost:", ex)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		var pe *PanicException
+		if ex := panicWith("read/write idle"); !errors.As(ex, &pe) || "read/write idle" != pe.Value() || "read/write idle" != ex.Error() {
+			t.Fatal("original value is lost:", ex)
+		}
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		var pe *PanicException
+		ex := panicWith(connectionKicked{Reason: "duplicate login"})
+		if !errors.As(ex, &pe) {
+			t.Fatalf("unexpected exception: %T", ex)
+		}
+		if kicked, ok := pe.Value().(connectionKicked); !ok || "duplicate login" != kicked.Reason {
+			t.Fatalf("original value is lost: %#v", pe.Value())
+		}
+		if "{Reason:duplicate login}" != ex.Error() {
+			t.Fatal("unexpected message:", ex.Error())
+		}
+		if 0 == len(ex.Stack()) {
+			t.Fatal("stack trace is lost")
+		}
+	})
+}
+
+func TestChannelException(t *testing.T) {
+
+	var caught []Exception
+	p := NewPipelineWith()
+	p.AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		caught = append(caught, ex)
+		// route the exception through the pipeline again.
+		if 1 == len(caught) {
+			ctx.Channel().Pipeline().FireChannelException(ex)
+		}
+	}))
+
+	c, _ := newPipeChannel(9527, p)
+
+	err := errors.New("malformed frame")
+	c.invokeMethod(func() { panic(err) })
+
+	if 2 != len(caught) {
+		t.Fatal("unexpected exceptions:", len(caught))
+	}
+
+	for _, ex := range caught {
+		ce, ok := ex.(*ChannelException)
+		if !ok {
+			t.Fatalf("unexpected exception: %T", ex)
+		}
+
+		if _, ok := ce.Unwrap().(*ChannelException); ok {
+			t.Fatal("the exception has been wrapped more than once")
+		}
+
+		if !errors.Is(ex, err) {
+			t.Fatal("original error is lost:", ex)
+		}
+
+		if ce.ChannelID() != c.ID() || ce.LocalAddr() != c.LocalAddr() || ce.RemoteAddr() != c.RemoteAddr() || ce.Time().IsZero() {
+			t.Fatal("unexpected channel metadata:", ce.channelInfo())
+		}
+
+		if p.Dump() != ce.PipelineDump() {
+			t.Fatal("unexpected pipeline:", ce.PipelineDump())
+		}
+	}
+
+	var buffer bytes.Buffer
+	caught[0].PrintStackTrace(&buffer)
+	if !strings.Contains(buffer.String(), "Channel: id=9527, local=pipe, remote=pipe") {
+		t.Fatal("channel metadata is missing:", buffer.String())
+	}
+
+	data, jsonErr := json.Marshal(caught[0])
+	if nil != jsonErr || !bytes.Contains(data, []byte(`"channel_id":9527`)) || !bytes.Contains(data, []byte(`"error":"malformed frame"`)) {
+		t.Fatal("unexpected json:", string(data), jsonErr)
+	}
+}
+
+// fixedClock to stop the time at now
+type fixedClock struct {
+	utils.Clock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestChannelExceptionClock(t *testing.T) {
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newContextChannel(ContextWithClock(context.Background(), fixedClock{Clock: utils.RealClock(), now: now}), NewPipelineWith())
+	defer c.Close(nil)
+
+	// the time is read from the clock of channel.
+	ce, ok := asChannelException(AsException(errors.New("boom"), nil), c).(*ChannelException)
+	if !ok || !now.Equal(ce.Time()) {
+		t.Fatal("unexpected time of exception:", ce.Time())
+	}
+}
+
+func TestHandledException(t *testing.T) {
+
+	var received = make(chan string, 3)
+	var handled = make(chan Exception, 3)
+
+	p := NewPipelineWith()
+	p.AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}).
+		AddLast(&textCodec{}).
+		AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+			if "bad" == message {
+				panic(errors.New("invalid frame"))
+			}
+			received <- message.(string)
+		})).
+		AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+			// every exception is recoverable for this handler.
+			ctx.HandledException(ex)
+			handled <- ex
+		}))
+
+	c, peer := newPipeChannel(1, p)
+	c.serveChannel()
+
+	if _, err := peer.Write([]byte("good1$bad$good2$")); nil != err {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"good1", "good2"} {
+		if got := <-received; want != got {
+			t.Fatal("unexpected frame:", got, "want:", want)
+		}
+	}
+
+	if ex := <-handled; !ex.(*ChannelException).Handled() || "invalid frame" != ex.Error() {
+		t.Fatal("unexpected exception:", ex)
+	}
+
+	if !c.IsActive() {
+		t.Fatal("the channel should be kept open after the handled exception")
+	}
+
+	// the peer has been closed, that is fatal exception.
+	_ = peer.Close()
+	if ex := <-handled; !IsFatalException(ex) {
+		t.Fatal("unexpected exception:", ex)
+	}
+
+	<-c.Context().Done()
+	if c.IsActive() {
+		t.Fatal("fatal exception must close the channel")
+	}
+}
+
+func TestHandledExceptionForwarded(t *testing.T) {
+
+	var logs bytes.Buffer
+	ctx := ContextWithLogger(context.Background(), utils.NewWriterLogger(&logs, utils.LogError))
+
+	var forwarded []Exception
+	p := NewPipelineWith()
+	p.AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		panic(message)
+	}), ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		// the handler marks the exception and forwards it anyway.
+		ctx.HandledException(ex)
+		ctx.HandleException(ex)
+	}), ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		forwarded = append(forwarded, ex)
+		ctx.HandleException(ex)
+	}))
+
+	c := newContextChannel(ctx, p)
+	defer c.Close(nil)
+
+	c.invokeMethod(func() { p.FireChannelRead(errors.New("invalid frame")) })
+	if 0 != len(forwarded) || 0 != logs.Len() || !c.IsActive() {
+		t.Fatal("the handled exception should not be propagated:", forwarded, logs.String())
+	}
+
+	// the fatal exceptions can not be suppressed.
+	c.invokeMethod(func() { p.FireChannelRead(io.EOF) })
+	if 1 != len(forwarded) || c.IsActive() {
+		t.Fatal("the fatal exception should close the channel:", forwarded)
+	}
+}
+
+func TestRecoverPolicy(t *testing.T) {
+
+	var logs bytes.Buffer
+	var action RecoverAction
+	var recovered []string
+	bs := NewBootstrap(WithLogger(utils.NewWriterLogger(&logs, utils.LogError)), WithRecoverPolicy(func(ctx HandlerContext, value interface{}, stack []byte) RecoverAction {
+		recovered = append(recovered, fmt.Sprintf("%T:%v", ctx.Handler(), value))
+		if 0 == len(stack) {
+			t.Error("the stack is lost")
+		}
+		return action
+	}))
+	defer bs.Shutdown()
+
+	var caught []Exception
+	p := NewPipelineWith()
+	p.AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		ctx.HandledException(ex)
+		caught = append(caught, ex)
+	}), InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		panic(message)
+	}))
+
+	c := newContextChannel(bs.Context(), p)
+
+	t.Run("exception", func(t *testing.T) {
+		action = RecoverException
+		c.invokeMethod(func() { p.FireChannelRead("decode") })
+		p.ContextAt(1).FireRead("fire")
+		if 2 != len(caught) || !c.IsActive() {
+			t.Fatal("the panics should be routed to the pipeline:", caught)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		action = RecoverPanic
+		defer func() {
+			if p, ok := recover().(propagatedPanic); !ok || "propagate" != p.value {
+				t.Fatal("the panic should be propagated")
+			}
+			if !strings.Contains(logs.String(), "propagate the panic of channel(1: pipe): propagate") {
+				t.Fatal("unexpected logs:", logs.String())
+			}
+		}()
+		c.invokeMethod(func() { p.ContextAt(1).FireRead("propagate") })
+	})
+
+	t.Run("close", func(t *testing.T) {
+		action = RecoverClose
+		c.invokeMethod(func() { p.FireChannelRead("close") })
+		if 2 != len(caught) || c.IsActive() {
+			t.Fatal("the channel should be closed by the panic")
+		}
+	})
+
+	// the panics of channel are recovered with the head, others are recovered with the context.
+	want := "[*netty.headHandler:decode netty.ExceptionHandlerFunc:fire netty.ExceptionHandlerFunc:propagate *netty.headHandler:close]"
+	if fmt.Sprint(recovered) != want {
+		t.Fatal("unexpected panics:", recovered, "want:", want)
+	}
+}
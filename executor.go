@@ -0,0 +1,96 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Executor defines a pool to run the tasks, e.g: a fixed number of goroutines.
+type Executor interface {
+	Submit(task func())
+}
+
+// ExecutorFunc impl Executor
+type ExecutorFunc func(task func())
+
+// Submit to impl Executor
+func (fn ExecutorFunc) Submit(task func()) { fn(task) }
+
+// Offload to run the HandleRead of handler on the executor, so the slow handlers will not stall the read loop,
+// the messages of a channel are still processed in order, and the channels can run in parallel on the executor.
+//
+// The wrapper keeps the queue of a channel, so a wrapper must be created for every pipeline (HandlerFactory for
+// the templates), and it must be placed after the decoders, the raw transport must not leave the read loop.
+// The handlers after the wrapper receive the messages on the executor, the panics raised on the executor are
+// routed to the pipeline of channel.
+func Offload(executor Executor, handler InboundHandler) Handler {
+	return &offloadHandler{executor: executor, handler: handler}
+}
+
+// offloadHandler to queue the inbound messages for the executor
+type offloadHandler struct {
+	executor Executor
+	handler  InboundHandler
+	mutex    sync.Mutex
+	queue    []Message
+	running  bool
+}
+
+func (o *offloadHandler) HandleRead(ctx InboundContext, message Message) {
+
+	o.mutex.Lock()
+	o.queue = append(o.queue, message)
+	running := o.running
+	o.running = true
+	o.mutex.Unlock()
+
+	// only one task of channel is running, so the messages are processed in order.
+	if !running {
+		o.executor.Submit(func() { o.drain(ctx) })
+	}
+}
+
+// drain to process the queued messages until the queue is empty
+func (o *offloadHandler) drain(ctx InboundContext) {
+	for {
+		o.mutex.Lock()
+		if 0 == len(o.queue) {
+			o.running = false
+			o.mutex.Unlock()
+			return
+		}
+
+		message := o.queue[0]
+		o.queue[0] = nil
+		o.queue = o.queue[1:]
+		o.mutex.Unlock()
+
+		o.invoke(ctx, message)
+	}
+}
+
+// invoke to process the message and route the panic to the pipeline
+func (o *offloadHandler) invoke(ctx InboundContext, message Message) {
+	defer func() {
+		if err := recover(); nil != err {
+			recoverPanic(ctx, err, debug.Stack())
+		}
+	}()
+	o.handler.HandleRead(ctx, message)
+}
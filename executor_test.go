@@ -0,0 +1,128 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// workerPool to run the tasks by a fixed number of goroutines
+type workerPool chan func()
+
+func newWorkerPool(workers int) workerPool {
+	pool := make(workerPool, 64)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range pool {
+				task()
+			}
+		}()
+	}
+	return pool
+}
+
+func (w workerPool) Submit(task func()) {
+	w <- task
+}
+
+func TestOffload(t *testing.T) {
+
+	pool := newWorkerPool(4)
+	defer close(pool)
+
+	const messages = 100
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	received := make(map[int64][]int)
+	exceptions := make(chan Exception, 2)
+	blocked := make(chan struct{})
+
+	newPipeline := func() Pipeline {
+		return NewPipelineWith().AddLast(Offload(pool, InboundHandlerFunc(func(ctx InboundContext, message Message) {
+			switch n := message.(int); {
+			case -1 == n:
+				// the slow handler does not stall the read loop.
+				<-blocked
+			case n == messages/2:
+				panic(fmt.Errorf("bad message: %d", n))
+			default:
+				mutex.Lock()
+				received[ctx.Channel().ID()] = append(received[ctx.Channel().ID()], n)
+				mutex.Unlock()
+			}
+			wg.Done()
+		})), ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+			ctx.HandledException(ex)
+			exceptions <- ex
+			wg.Done()
+		}))
+	}
+
+	p1, p2 := newPipeline(), newPipeline()
+	c1, c2 := newContextChannel(context.Background(), p1), newContextChannel(context.Background(), p2)
+	c2.id = 2
+	defer c1.Close(nil)
+	defer c2.Close(nil)
+
+	wg.Add(2 * (messages + 1))
+	p1.FireChannelRead(-1)
+	for i := 0; i < messages; i++ {
+		p1.FireChannelRead(i)
+		p2.FireChannelRead(i)
+	}
+
+	// the other channels are not blocked by the slow handler.
+	deadline := time.After(5 * time.Second)
+	for {
+		mutex.Lock()
+		done := messages-1 == len(received[2])
+		mutex.Unlock()
+		if done {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("the channel is blocked by the other")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	p2.FireChannelRead(-1)
+
+	close(blocked)
+	wg.Wait()
+
+	for id, numbers := range received {
+		if messages-1 != len(numbers) {
+			t.Fatal("the messages are lost:", id, len(numbers))
+		}
+		for i, n := range numbers {
+			if want := i + i/(messages/2); want != n {
+				t.Fatal("unexpected order:", id, n, "want:", want)
+			}
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if ex := <-exceptions; "bad message: 50" != ex.Error() {
+			t.Fatal("unexpected exception:", ex)
+		}
+	}
+}
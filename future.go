@@ -0,0 +1,119 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// WriteFuture defines the result of a message written by WriteWithPromise.
+type WriteFuture interface {
+	// Done returns a chan that is closed after the write is completed.
+	Done() <-chan struct{}
+	// Err returns the error of write after done, nil means the bytes have been written by transport.
+	Err() error
+	// Wait for the write to complete and returns the error.
+	Wait() error
+	// OnComplete to add a callback that is called with the error after the write is completed,
+	// it is called at once if the write has been completed.
+	OnComplete(fn func(err error))
+}
+
+// writeCompleter defines a releaser of the send queue that is notified with the result of write.
+type writeCompleter interface {
+	complete(err error)
+}
+
+// make sure writePromise implements WriteFuture & writeCompleter
+var (
+	_ WriteFuture    = (*writePromise)(nil)
+	_ writeCompleter = (*writePromise)(nil)
+)
+
+// writePromise implements WriteFuture
+type writePromise struct {
+	mutex     sync.Mutex
+	done      chan struct{}
+	err       error
+	completed bool
+	callbacks []func(err error)
+}
+
+func newWritePromise() *writePromise {
+	return &writePromise{done: make(chan struct{})}
+}
+
+func (p *writePromise) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *writePromise) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+func (p *writePromise) Wait() error {
+	<-p.done
+	return p.Err()
+}
+
+func (p *writePromise) OnComplete(fn func(err error)) {
+	p.mutex.Lock()
+	if !p.completed {
+		p.callbacks = append(p.callbacks, fn)
+		p.mutex.Unlock()
+		return
+	}
+	err := p.err
+	p.mutex.Unlock()
+	fn(err)
+}
+
+// complete the promise with the result of write, only the first result is accepted.
+func (p *writePromise) complete(err error) {
+	p.mutex.Lock()
+	if p.completed {
+		p.mutex.Unlock()
+		return
+	}
+	p.err, p.completed = err, true
+	callbacks := p.callbacks
+	p.callbacks = nil
+	p.mutex.Unlock()
+
+	close(p.done)
+	for _, fn := range callbacks {
+		fn(err)
+	}
+}
+
+// Release to complete the promise without error.
+func (p *writePromise) Release() {
+	p.complete(nil)
+}
+
+// completeEntry to complete the releaser of entry with the result of write.
+func completeEntry(releaser utils.Releaser, err error) {
+	if wc, ok := releaser.(writeCompleter); ok {
+		wc.complete(err)
+		return
+	}
+	utils.Release(releaser)
+}
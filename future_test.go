@@ -0,0 +1,104 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteWithPromise(t *testing.T) {
+
+	c, peer := newPipeChannel(1, newDiscardPipeline())
+	c.serveChannel()
+	defer c.Close(nil)
+
+	const count = 10
+
+	var mutex sync.Mutex
+	var completed []int
+	futures := make([]WriteFuture, count)
+	for i := range futures {
+		index := i
+		futures[i] = c.WriteWithPromise([]byte("x"))
+		futures[i].OnComplete(func(err error) {
+			if nil != err {
+				t.Error(index, err)
+			}
+			mutex.Lock()
+			completed = append(completed, index)
+			mutex.Unlock()
+		})
+	}
+
+	if data, err := ioutil.ReadAll(io.LimitReader(peer, count)); nil != err || count != len(data) {
+		t.Fatal(len(data), err)
+	}
+
+	for i, future := range futures {
+		if err := future.Wait(); nil != err {
+			t.Fatal(i, err)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, index := range completed {
+		if i != index {
+			t.Fatal("the futures are completed out of order:", completed)
+		}
+	}
+
+	// the callback is called at once after completed.
+	var called bool
+	futures[0].OnComplete(func(err error) { called = true })
+	if !called {
+		t.Fatal("the callback of completed future should be called at once")
+	}
+}
+
+func TestWriteWithPromiseClosed(t *testing.T) {
+
+	// the peer never reads, so the write loop is blocked by the transport.
+	c, _ := newPipeChannel(1, newDiscardPipeline())
+	c.serveChannel()
+
+	futures := make([]WriteFuture, 5)
+	for i := range futures {
+		futures[i] = c.WriteWithPromise([]byte("x"))
+	}
+
+	c.Close(nil)
+
+	for i, future := range futures {
+		select {
+		case <-future.Done():
+			if nil == future.Err() {
+				t.Fatal(i, "the pending future should be failed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal(i, "the pending future is leaked after closed")
+		}
+	}
+
+	if err := c.WriteWithPromise([]byte("x")).Wait(); ErrChannelClosed != err {
+		t.Fatal("write after close:", err)
+	}
+}
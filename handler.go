@@ -17,12 +17,13 @@
 package netty
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-netty/go-netty/utils"
@@ -53,6 +54,7 @@ type (
 	// ExceptionHandler
 	// InactiveHandler
 	// EventHandler
+	// AddedHandler & RemovedHandler are the optional lifecycle callbacks.
 	Handler interface {
 	}
 
@@ -72,6 +74,12 @@ type (
 	}
 
 	// ExceptionHandler defines an exception handler
+	//
+	// The exception will be propagated to the next ExceptionHandler by ctx.HandleException(ex),
+	// and the tail of the pipeline will close the channel if the exception reached it.
+	// The handler could call ctx.HandledException(ex) to mark the exception as recoverable,
+	// then the propagation will be stopped and the channel will be kept open.
+	// Fatal exceptions (see IsFatalException) can not be suppressed, the channel will be closed anyway.
 	ExceptionHandler interface {
 		HandleException(ctx ExceptionContext, ex Exception)
 	}
@@ -85,9 +93,28 @@ type (
 	EventHandler interface {
 		HandleEvent(ctx EventContext, event Event)
 	}
+
+	// AddedHandler defines an optional callback when the handler is attached to the pipeline
+	//
+	// It is called once after the handler is added, the channel may be not attached to the
+	// pipeline yet if the handler is added by the initializer.
+	AddedHandler interface {
+		HandleAdded(ctx HandlerContext)
+	}
+
+	// RemovedHandler defines an optional callback when the handler is detached from the pipeline
+	//
+	// It is called once after the handler is removed or replaced, or the channel becomes inactive,
+	// the handler should release the owned resources, e.g: stop the timers.
+	RemovedHandler interface {
+		HandleRemoved(ctx HandlerContext)
+	}
 )
 
 // CodecHandler defines an codec handler
+//
+// A codec is registered for both directions by a single context, the dispatching of both
+// directions goes through the handler cached at insertion without type assertions.
 type CodecHandler interface {
 	CodecName() string
 	InboundHandler
@@ -156,26 +183,79 @@ type EventHandlerFunc func(ctx EventContext, event Event)
 // HandleEvent to impl EventHandler
 func (fn EventHandlerFunc) HandleEvent(ctx EventContext, event Event) { fn(ctx, event) }
 
+// Once to wrap a handler that processes exactly one inbound message, e.g: handshake or version negotiation,
+// the wrapper removes itself from the pipeline after the first HandleRead returned, the subsequent messages
+// are forwarded to the next handler directly. The wrapper is kept if the HandleRead panics.
+func Once(handler InboundHandler) Handler {
+	return &onceHandler{handler: handler}
+}
+
+// onceHandler to process the first inbound message only
+type onceHandler struct {
+	handler InboundHandler
+	done    int32
+}
+
+func (o *onceHandler) HandleRead(ctx InboundContext, message Message) {
+
+	// the message was dispatched before the wrapper is removed.
+	if 1 == atomic.LoadInt32(&o.done) {
+		ctx.HandleRead(message)
+		return
+	}
+
+	o.handler.HandleRead(ctx, message)
+
+	// the in-flight traversal goes on through the links of removed context.
+	atomic.StoreInt32(&o.done, 1)
+	ctx.Channel().Pipeline().Remove(o)
+}
+
 // headHandler
 type headHandler struct{}
 
 func (*headHandler) HandleWrite(ctx OutboundContext, message Message) {
 
+	atomic.AddInt64(&ctx.Channel().counters().messagesWritten, 1)
+
+	var data []byte
 	var dataBytes [][]byte
+	var releaser utils.Releaser
 	switch m := message.(type) {
+	case *Buffers:
+		dataBytes, releaser = m.Bytes(), m
 	case []byte:
-		dataBytes = [][]byte{m}
+		data = m
 	case [][]byte:
 		dataBytes = m
 	case io.Reader:
-		data := utils.AssertBytes(ioutil.ReadAll(m))
-		dataBytes = [][]byte{data}
+		data = utils.AssertBytes(ioutil.ReadAll(m))
 	default:
 		panic(fmt.Errorf("unsupported type: %T", m))
 	}
 
-	writeN, err := ctx.Channel().Writev(dataBytes)
-	if totalN := utils.CountOf(dataBytes); totalN != writeN && nil == err {
+	// recycle the message after it is written.
+	if _, ok := message.(Recyclable); ok && nil == releaser {
+		releaser = recycleReleaser{message: message}
+	}
+
+	var totalN, writeN int64
+	var err error
+	if nil != dataBytes {
+		totalN = utils.CountOf(dataBytes)
+		writeN, err = ctx.Channel().writeBuffers(dataBytes, releaser)
+	} else {
+		// a single buffer needs not to allocate [][]byte.
+		totalN = int64(len(data))
+		writeN, err = ctx.Channel().writeBuffer(data, releaser)
+	}
+
+	if nil != err {
+		// the buffers has not been queued.
+		utils.Release(releaser)
+	}
+
+	if totalN != writeN && nil == err {
 		err = fmt.Errorf("short write: %d != %d", totalN, writeN)
 	}
 
@@ -186,13 +266,53 @@ func (*headHandler) HandleWrite(ctx OutboundContext, message Message) {
 
 // default: tailHandler
 // The final closing operation will be provided when the user registered handler is not processing.
-type tailHandler struct{}
+type tailHandler struct {
+	dropped int32
+	ignored int32
+}
+
+func (t *tailHandler) HandleRead(ctx InboundContext, message Message) {
+
+	// nobody consumed the message, release the pooled frames, the transport of channel is never released here.
+	defer Recycle(message)
+	switch message.(type) {
+	case *utils.PooledBuffer, *utils.ByteBuf:
+		defer utils.Release(message)
+	}
+
+	if handler := unhandledMessageFrom(ctx.Channel().Context()); nil != handler {
+		handler(ctx.Channel(), message)
+		return
+	}
+
+	// only the first dropped message of channel is logged.
+	if atomic.CompareAndSwapInt32(&t.dropped, 0, 1) {
+		LoggerFrom(ctx.Channel().Context()).Warnf("An unhandled message(%T) reached at the tail of the pipeline of channel(%s: %s) and was dropped, "+
+			"please check the pipeline configuration, the further dropped messages will not be logged.", message, ctx.Channel().Name(), ctx.Channel().RemoteAddr())
+	}
+}
+
+func (t *tailHandler) HandleEvent(ctx EventContext, event Event) {
+
+	if handler := unhandledMessageFrom(ctx.Channel().Context()); nil != handler {
+		handler(ctx.Channel(), event)
+		return
+	}
+
+	// only the first ignored event of channel is logged.
+	if atomic.CompareAndSwapInt32(&t.ignored, 0, 1) {
+		LoggerFrom(ctx.Channel().Context()).Warnf("An unhandled event(%T) reached at the tail of the pipeline of channel(%s: %s) and was ignored, "+
+			"please check the pipeline configuration, the further ignored events will not be logged.", event, ctx.Channel().Name(), ctx.Channel().RemoteAddr())
+	}
+}
 
 func (*tailHandler) HandleException(ctx ExceptionContext, ex Exception) {
-	ex.PrintStackTrace(os.Stderr, "An HandleException() event was fired, and it reached at the tail of the pipeline. ",
+	var buffer bytes.Buffer
+	ex.PrintStackTrace(&buffer, "An HandleException() event was fired, and it reached at the tail of the pipeline. ",
 		"It usually means the last handler in the pipeline did not handle the exception. ",
-		fmt.Sprintf("We will close the channel(%d: %s), If you don't want to close the channel please add HandleException() to the pipeline.\n", ctx.Channel().ID(), ctx.Channel().RemoteAddr()),
+		fmt.Sprintf("We will close the channel(%s: %s), If you don't want to close the channel please add HandleException() to the pipeline.\n", ctx.Channel().Name(), ctx.Channel().RemoteAddr()),
 	)
+	LoggerFrom(ctx.Channel().Context()).Errorf("%s", buffer.String())
 	ctx.Channel().Close(ex)
 }
 
@@ -202,6 +322,25 @@ type (
 
 	// WriteIdleEvent define a WriteIdleEvent
 	WriteIdleEvent struct{}
+
+	// WritabilityChangedEvent will be fired when the queued bytes of channel cross the watermarks, see WithWriteWatermark,
+	// it is fired out of the write loop, so the handlers can write to the channel in the event.
+	WritabilityChangedEvent struct {
+		Writable bool
+	}
+
+	// DrainEvent will be fired when the bootstrap is shutting down gracefully, see Bootstrap.ShutdownGracefully,
+	// the channel should be closed after the in-flight requests are completed, before the Deadline if it is not zero.
+	DrainEvent struct {
+		Deadline time.Time
+	}
+
+	// QueueSaturationEvent will be fired when the send queue is saturated or recovered, see WithQueueSaturation,
+	// it is fired out of the write loop like WritabilityChangedEvent.
+	QueueSaturationEvent struct {
+		Ratio     float64 // the ratio of the queued entries to the capacity of send queue
+		Saturated bool
+	}
 )
 
 // ReadIdleHandler fire ReadIdleEvent after waiting for a reading timeout
@@ -222,10 +361,12 @@ func WriteIdleHandler(idleTime time.Duration) ChannelOutboundHandler {
 
 // readIdleHandler
 type readIdleHandler struct {
-	mutex        sync.RWMutex
+	mutex        sync.Mutex
 	idleTime     time.Duration
 	lastReadTime time.Time
-	readTimer    *time.Timer
+	cancelTimer  func()
+	timerWheel   *utils.TimerWheel
+	clock        Clock
 	handlerCtx   HandlerContext
 }
 
@@ -235,18 +376,14 @@ func (r *readIdleHandler) withLock(fn func()) {
 	fn()
 }
 
-func (r *readIdleHandler) withReadLock(fn func()) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
-	fn()
-}
-
 func (r *readIdleHandler) HandleActive(ctx ActiveContext) {
 	// cache context.
 	r.withLock(func() {
 		r.handlerCtx = ctx
-		r.lastReadTime = time.Now()
-		r.readTimer = time.AfterFunc(r.idleTime, r.onReadTimeout)
+		r.clock = ClockFrom(ctx.Channel().Context())
+		r.lastReadTime = r.clock.Now()
+		r.timerWheel = TimerWheelFrom(ctx.Channel().Context())
+		r.cancelTimer = r.timerWheel.Schedule(r.idleTime, r.onReadTimeout)
 	})
 	// post the active event.
 	ctx.HandleActive()
@@ -255,13 +392,9 @@ func (r *readIdleHandler) HandleActive(ctx ActiveContext) {
 func (r *readIdleHandler) HandleRead(ctx InboundContext, message Message) {
 	ctx.HandleRead(message)
 
+	// update last read time, the timer will be rescheduled when it expires.
 	r.withLock(func() {
-		// update last read time.
-		r.lastReadTime = time.Now()
-		// reset timer.
-		if r.readTimer != nil {
-			r.readTimer.Reset(r.idleTime)
-		}
+		r.lastReadTime = r.clock.Now()
 	})
 }
 
@@ -269,9 +402,9 @@ func (r *readIdleHandler) HandleInactive(ctx InactiveContext, ex Exception) {
 
 	r.withLock(func() {
 		r.handlerCtx = nil
-		if r.readTimer != nil {
-			r.readTimer.Stop()
-			r.readTimer = nil
+		if r.cancelTimer != nil {
+			r.cancelTimer()
+			r.cancelTimer = nil
 		}
 	})
 
@@ -281,22 +414,28 @@ func (r *readIdleHandler) HandleInactive(ctx InactiveContext, ex Exception) {
 
 func (r *readIdleHandler) onReadTimeout() {
 
-	var expired bool
+	var idle time.Duration
 	var ctx HandlerContext
 
-	r.withReadLock(func() {
+	r.withLock(func() {
 		// check if the idle time expires.
-		expired = time.Since(r.lastReadTime) >= r.idleTime
+		idle = r.clock.Now().Sub(r.lastReadTime)
 		ctx = r.handlerCtx
 	})
 
-	if expired && ctx != nil {
+	if nil == ctx {
+		return
+	}
+
+	next := r.idleTime - idle
+	if next <= 0 {
+		next = r.idleTime
 		// trigger event.
 		func() {
 			// capture exception.
 			defer func() {
 				if err := recover(); nil != err {
-					ctx.Channel().Pipeline().FireChannelException(AsException(err, debug.Stack()))
+					recoverPanic(ctx, err, debug.Stack())
 				}
 			}()
 
@@ -305,20 +444,22 @@ func (r *readIdleHandler) onReadTimeout() {
 		}()
 	}
 
-	// reset timer
-	r.withReadLock(func() {
-		if r.readTimer != nil {
-			r.readTimer.Reset(r.idleTime)
+	// reschedule the timer for the rest of idle time.
+	r.withLock(func() {
+		if r.cancelTimer != nil {
+			r.cancelTimer = r.timerWheel.Schedule(next, r.onReadTimeout)
 		}
 	})
 }
 
 // writeIdleHandler
 type writeIdleHandler struct {
-	mutex         sync.RWMutex
+	mutex         sync.Mutex
 	idleTime      time.Duration
 	lastWriteTime time.Time
-	writeTimer    *time.Timer
+	cancelTimer   func()
+	timerWheel    *utils.TimerWheel
+	clock         Clock
 	handlerCtx    HandlerContext
 }
 
@@ -328,19 +469,15 @@ func (w *writeIdleHandler) withLock(fn func()) {
 	fn()
 }
 
-func (w *writeIdleHandler) withReadLock(fn func()) {
-	w.mutex.RLock()
-	defer w.mutex.RUnlock()
-	fn()
-}
-
 func (w *writeIdleHandler) HandleActive(ctx ActiveContext) {
 
 	// cache context
 	w.withLock(func() {
 		w.handlerCtx = ctx
-		w.lastWriteTime = time.Now()
-		w.writeTimer = time.AfterFunc(w.idleTime, w.onWriteTimeout)
+		w.clock = ClockFrom(ctx.Channel().Context())
+		w.lastWriteTime = w.clock.Now()
+		w.timerWheel = TimerWheelFrom(ctx.Channel().Context())
+		w.cancelTimer = w.timerWheel.Schedule(w.idleTime, w.onWriteTimeout)
 	})
 
 	// post the active event.
@@ -349,13 +486,9 @@ func (w *writeIdleHandler) HandleActive(ctx ActiveContext) {
 
 func (w *writeIdleHandler) HandleWrite(ctx OutboundContext, message Message) {
 
-	// update last write time.
+	// update last write time, the timer will be rescheduled when it expires.
 	w.withLock(func() {
-		w.lastWriteTime = time.Now()
-		// reset timer.
-		if w.writeTimer != nil {
-			w.writeTimer.Reset(w.idleTime)
-		}
+		w.lastWriteTime = w.clock.Now()
 	})
 
 	// post write event.
@@ -368,9 +501,9 @@ func (w *writeIdleHandler) HandleInactive(ctx InactiveContext, ex Exception) {
 		// reset context
 		w.handlerCtx = nil
 		// stop the timer.
-		if w.writeTimer != nil {
-			w.writeTimer.Stop()
-			w.writeTimer = nil
+		if w.cancelTimer != nil {
+			w.cancelTimer()
+			w.cancelTimer = nil
 		}
 	})
 
@@ -380,23 +513,28 @@ func (w *writeIdleHandler) HandleInactive(ctx InactiveContext, ex Exception) {
 
 func (w *writeIdleHandler) onWriteTimeout() {
 
-	var expired bool
+	var idle time.Duration
 	var ctx HandlerContext
 
-	w.withReadLock(func() {
+	w.withLock(func() {
 		// check if the idle time expires.
-		expired = time.Since(w.lastWriteTime) >= w.idleTime
+		idle = w.clock.Now().Sub(w.lastWriteTime)
 		ctx = w.handlerCtx
 	})
 
-	// check if the idle time expires
-	if expired && ctx != nil {
+	if nil == ctx {
+		return
+	}
+
+	next := w.idleTime - idle
+	if next <= 0 {
+		next = w.idleTime
 		// trigger event.
 		func() {
 			// capture exception
 			defer func() {
 				if err := recover(); nil != err {
-					ctx.Channel().Pipeline().FireChannelException(AsException(err, debug.Stack()))
+					recoverPanic(ctx, err, debug.Stack())
 				}
 			}()
 
@@ -405,11 +543,10 @@ func (w *writeIdleHandler) onWriteTimeout() {
 		}()
 	}
 
-	// reset timer.
-	w.withReadLock(func() {
-		if w.writeTimer != nil {
-			w.writeTimer.Reset(w.idleTime)
+	// reschedule the timer for the rest of idle time.
+	w.withLock(func() {
+		if w.cancelTimer != nil {
+			w.cancelTimer = w.timerWheel.Schedule(next, w.onWriteTimeout)
 		}
 	})
-
 }
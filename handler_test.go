@@ -0,0 +1,235 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// newContextChannel create a channel with the context attached to the pipeline without serving it.
+func newContextChannel(ctx context.Context, p Pipeline) *channel {
+	local, _ := net.Pipe()
+	c := newChannelWith(ctx, p, &pipeTransport{Conn: local}, 1, 128, parseChannelOptions(ctx)).(*channel)
+	p.(*pipeline).channel = c
+	return c
+}
+
+func TestUnhandledMessageHandler(t *testing.T) {
+
+	var unhandled []string
+	bs := NewBootstrap(WithUnhandledMessageHandler(func(channel Channel, message Message) {
+		unhandled = append(unhandled, fmt.Sprintf("%d:%v", channel.ID(), message))
+	}))
+	defer bs.Shutdown()
+
+	p := NewPipelineWith()
+	c := newContextChannel(bs.Context(), p)
+
+	p.FireChannelRead("hello")
+	p.FireChannelEvent(ReadIdleEvent{})
+	p.FireChannelRead("world")
+
+	if fmt.Sprint(unhandled) != "[1:hello 1:{} 1:world]" {
+		t.Fatal("unexpected unhandled messages:", unhandled)
+	}
+
+	// the handled messages reach the tail no more.
+	p.AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {}))
+	p.FireChannelRead("consumed")
+	if 3 != len(unhandled) {
+		t.Fatal("unexpected unhandled messages:", unhandled)
+	}
+
+	c.Close(nil)
+}
+
+func TestUnhandledMessageDefault(t *testing.T) {
+
+	var buffer bytes.Buffer
+	ctx := ContextWithLogger(context.Background(), utils.NewWriterLogger(&buffer, utils.LogWarn))
+
+	p := NewPipelineWith()
+	c := newContextChannel(ctx, p)
+	defer c.Close(nil)
+
+	for i := 0; i < 2; i++ {
+		p.FireChannelRead("hello")
+		p.FireChannelEvent(WriteIdleEvent{})
+	}
+
+	// only the first of them are logged.
+	logs := buffer.String()
+	if 1 != strings.Count(logs, "An unhandled message(string) reached at the tail of the pipeline of channel(1: pipe)") ||
+		1 != strings.Count(logs, "An unhandled event(netty.WriteIdleEvent) reached at the tail of the pipeline of channel(1: pipe)") ||
+		2 != strings.Count(logs, "[WARN]") {
+		t.Fatal("unexpected logs:", logs)
+	}
+}
+
+func TestOnce(t *testing.T) {
+
+	var handshakes, received []string
+
+	p := NewPipelineWith()
+	p.AddLast(Once(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		handshakes = append(handshakes, message.(string))
+		// the handshake is forwarded during the removal.
+		ctx.HandleRead("version: " + message.(string))
+	})), InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		received = append(received, message.(string))
+	}))
+
+	c := newContextChannel(context.Background(), p)
+	defer c.Close(nil)
+
+	p.FireChannelRead("v1")
+	p.FireChannelRead("hello")
+
+	if fmt.Sprint(handshakes) != "[v1]" || fmt.Sprint(received) != "[version: v1 hello]" {
+		t.Fatal("unexpected messages:", handshakes, received)
+	}
+
+	if 3 != p.Size() {
+		t.Fatal("the once handler is not removed:", p.Dump())
+	}
+}
+
+func TestOncePanic(t *testing.T) {
+
+	var handshakes int
+
+	p := NewPipelineWith()
+	p.AddLast(Once(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		if handshakes++; 1 == handshakes {
+			panic("invalid handshake")
+		}
+	})), InboundHandlerFunc(func(ctx InboundContext, message Message) {}), ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {
+		ctx.HandledException(ex)
+	}))
+
+	c := newContextChannel(context.Background(), p)
+	defer c.Close(nil)
+
+	// the wrapper is kept until the handshake is done.
+	c.invokeMethod(func() { p.FireChannelRead("bad") })
+	p.FireChannelRead("good")
+	p.FireChannelRead("hello")
+
+	if 2 != handshakes || 4 != p.Size() {
+		t.Fatal("unexpected handshakes:", handshakes, p.Dump())
+	}
+}
+
+// upperCodec to decode the strings to upper case and encode them to lower case
+type upperCodec struct{}
+
+func (upperCodec) CodecName() string { return "upper-codec" }
+
+func (upperCodec) HandleRead(ctx InboundContext, message Message) {
+	ctx.HandleRead(strings.ToUpper(message.(string)))
+}
+
+func (upperCodec) HandleWrite(ctx OutboundContext, message Message) {
+	ctx.HandleWrite(strings.ToLower(message.(string)))
+}
+
+func TestCodecHandler(t *testing.T) {
+
+	var codec CodecHandler = upperCodec{}
+	var read, written []string
+
+	p := NewPipelineWith()
+	p.AddLast(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		written = append(written, message.(string))
+	}), codec, InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		read = append(read, message.(string))
+	}))
+
+	// a single context for both directions.
+	if 5 != p.Size() {
+		t.Fatal("unexpected pipeline:", p.Dump())
+	}
+
+	p.FireChannelRead("Hello")
+	p.FireChannelWrite("World")
+	if fmt.Sprint(read) != "[HELLO]" || fmt.Sprint(written) != "[world]" {
+		t.Fatal("unexpected messages:", read, written)
+	}
+
+	for _, comp := range []func(Handler) bool{
+		func(h Handler) bool { _, ok := h.(CodecHandler); return ok },
+		func(h Handler) bool { _, ok := h.(InboundHandler); _, codec := h.(upperCodec); return ok && codec },
+		func(h Handler) bool { _, ok := h.(OutboundHandler); _, codec := h.(upperCodec); return ok && codec },
+	} {
+		if 2 != p.IndexOf(comp) || 2 != p.LastIndexOf(comp) {
+			t.Fatal("the codec is not found:", p.IndexOf(comp), p.LastIndexOf(comp))
+		}
+	}
+}
+
+func TestTailKeepsTransport(t *testing.T) {
+
+	received := make(chan byte, 3)
+	var forwarded bool
+	bs := NewBootstrap(WithChannel(NewBufferedChannel(128, 1024)), WithChildInitializer(func(channel Channel) {
+		channel.Pipeline().AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+			var b [1]byte
+			if _, err := message.(io.Reader).Read(b[:]); nil != err {
+				panic(err)
+			}
+			received <- b[0]
+
+			// the buffered bytes of transport must not be released by the tail.
+			if !forwarded {
+				forwarded = true
+				ctx.HandleRead(message)
+			}
+		}), ignoreException)
+	}))
+	defer bs.Shutdown()
+
+	local, peer := net.Pipe()
+	defer peer.Close()
+	bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, true)
+
+	if _, err := peer.Write([]byte("abc")); nil != err {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	for len(data) < 3 {
+		select {
+		case b := <-received:
+			data = append(data, b)
+		case <-time.After(time.Second):
+			t.Fatal("the buffered bytes are lost:", string(data))
+		}
+	}
+
+	if "abc" != string(data) {
+		t.Fatal("unexpected bytes:", string(data))
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// HandshakeTimeoutError will be fired to the pipeline and close the accepted channel that received no data
+// within the handshake timeout, see WithHandshakeTimeout.
+type HandshakeTimeoutError struct {
+	// Duration of the handshake timeout
+	Duration time.Duration
+}
+
+// Error to impl error
+func (e *HandshakeTimeoutError) Error() string {
+	return fmt.Sprintf("no data received within the handshake timeout %v", e.Duration)
+}
+
+// Timeout to impl net.Error
+func (e *HandshakeTimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary to impl net.Error
+func (e *HandshakeTimeoutError) Temporary() bool {
+	return false
+}
+
+// watchHandshake to close the channel if no data is read within the timeout, the channels that received data are not affected.
+func (bs *bootstrap) watchHandshake(channel Channel, timeout time.Duration) {
+
+	cancel := ClockFrom(channel.Context()).Schedule(timeout, func() {
+		if !channel.IsActive() || channel.Stats().BytesRead > 0 {
+			return
+		}
+
+		ex := AsException(&HandshakeTimeoutError{Duration: timeout}, nil)
+		func() {
+			// capture exception.
+			defer func() {
+				if err := recover(); nil != err {
+					channel.Close(AsException(err, debug.Stack()))
+				}
+			}()
+			channel.Pipeline().FireChannelException(ex)
+		}()
+		channel.Close(ex)
+	})
+
+	channel.OnClose(func(error) { cancel() })
+}
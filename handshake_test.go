@@ -0,0 +1,79 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandshakeTimeout(t *testing.T) {
+
+	exceptions := make(chan Exception, 1)
+	bs := NewBootstrap(WithHandshakeTimeout(20*time.Millisecond), WithChildInitializer(func(channel Channel) {
+		channel.Pipeline().
+			AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+				_, _ = io.Copy(ioutil.Discard, message.(io.Reader))
+			})).
+			AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) { exceptions <- ex }))
+	}))
+	defer bs.Shutdown()
+
+	// the silent channel is closed with the timeout.
+	local, _ := net.Pipe()
+	silent := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, true)
+
+	var timeout *HandshakeTimeoutError
+	select {
+	case ex := <-exceptions:
+		if !errors.As(ex, &timeout) || 20*time.Millisecond != timeout.Duration {
+			t.Fatal("unexpected exception:", ex)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the handshake timeout should be fired")
+	}
+
+	<-silent.Done()
+	if !errors.As(silent.CloseErr(), &timeout) {
+		t.Fatal("unexpected close error:", silent.CloseErr())
+	}
+
+	// the channel received data is not affected.
+	local, remote := net.Pipe()
+	defer remote.Close()
+	active := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, true)
+	if _, err := remote.Write([]byte("hello")); nil != err {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if !active.IsActive() {
+		t.Fatal("the active channel should not be closed:", active.CloseErr())
+	}
+
+	// the client channels are not watched.
+	local, _ = net.Pipe()
+	client := bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, false)
+	time.Sleep(50 * time.Millisecond)
+	if !client.IsActive() {
+		t.Fatal("the client channel should not be closed:", client.CloseErr())
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// ListenAll create a listener for each url
+func (bs *bootstrap) ListenAll(urls []string, option ...transport.Option) Listener {
+	ls := &listeners{listeners: make([]Listener, 0, len(urls))}
+	for _, url := range urls {
+		ls.urls = append(ls.urls, url)
+		ls.listeners = append(ls.listeners, bs.Listen(url, option...))
+	}
+	return ls
+}
+
+// listeners to run a group of listeners as a single one
+type listeners struct {
+	urls      []string
+	listeners []Listener
+}
+
+// Close all listeners, the first error is returned.
+func (ls *listeners) Close() (err error) {
+	for index, l := range ls.listeners {
+		if e := l.Close(); nil != e && nil == err {
+			err = fmt.Errorf("listener %s: %w", ls.urls[index], e)
+		}
+	}
+	return
+}
+
+// Sync runs all listeners until any of them is done, the others are closed then,
+// the error is wrapped with the url of listener.
+func (ls *listeners) Sync() error {
+
+	if 0 == len(ls.listeners) {
+		return fmt.Errorf("no listener to sync")
+	}
+
+	results := make(chan error, len(ls.listeners))
+	for index, l := range ls.listeners {
+		go func(url string, l Listener) {
+			if err := l.Sync(); nil != err {
+				results <- fmt.Errorf("listener %s: %w", url, err)
+				return
+			}
+			results <- nil
+		}(ls.urls[index], l)
+	}
+
+	err := <-results
+	ls.Close()
+	for i := 1; i < len(ls.listeners); i++ {
+		<-results
+	}
+	return err
+}
+
+// Async nonblock waits for all listeners, fn is invoked once.
+func (ls *listeners) Async(fn func(err error)) {
+	go func() {
+		fn(ls.Sync())
+	}()
+}
+
+// Addr returns the bound address of the first listener
+func (ls *listeners) Addr() net.Addr {
+	if 0 == len(ls.listeners) {
+		return nil
+	}
+	return ls.listeners[0].Addr()
+}
+
+// OnReady to be invoked once all listeners are bound, with the address of the first listener.
+func (ls *listeners) OnReady(fn func(addr net.Addr)) {
+	pending := int32(len(ls.listeners))
+	for _, l := range ls.listeners {
+		l.OnReady(func(net.Addr) {
+			if 0 == atomic.AddInt32(&pending, -1) {
+				fn(ls.Addr())
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestBootstrapListenAll(t *testing.T) {
+
+	bs := NewBootstrap(WithChildInitializer(func(channel Channel) {
+		channel.Pipeline().AddLast(ignoreException)
+	}))
+	defer bs.Shutdown()
+
+	urls := []string{"tcp://127.0.0.1:0", "tcp://localhost:0"}
+	l := bs.ListenAll(urls)
+
+	ready := make(chan net.Addr, 1)
+	l.OnReady(func(addr net.Addr) { ready <- addr })
+
+	closed := make(chan error, 1)
+	l.Async(func(err error) { closed <- err })
+
+	if addr := <-ready; addr != l.Addr() {
+		t.Fatal("unexpected address:", addr)
+	}
+
+	for _, sub := range l.(*listeners).listeners {
+		conn, err := net.Dial("tcp", sub.Addr().String())
+		if nil != err {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	l.Close()
+	if err := <-closed; !errors.Is(err, net.ErrClosed) {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestBootstrapListenAllError(t *testing.T) {
+
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+
+	bs := NewBootstrap()
+	defer bs.Shutdown()
+
+	failed := "tcp://" + occupied.Addr().String()
+	l := bs.ListenAll([]string{"tcp://127.0.0.1:0", failed})
+
+	// the first fatal error wins, and the other listeners are closed.
+	err = l.Sync()
+	if !errors.Is(err, syscall.EADDRINUSE) || !strings.Contains(err.Error(), failed) {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the listener closed before bound never starts accepting.
+	closed := bs.Listen("tcp://127.0.0.1:0")
+	closed.Close()
+	if err = closed.Sync(); !errors.Is(err, net.ErrClosed) {
+		t.Fatal("unexpected error:", err)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+// IndexOf returns the position of the first handler of type T, -1 if not found, the head & tail are skipped,
+// the position is the same as ContextAt, use InsertAt to add handlers after it, which rejects -1.
+// Note -1 equals PositionLast, so AddHandler(IndexOf[T](p), ...) appends the handlers if T is not found.
+func IndexOf[T Handler](p Pipeline) int {
+	return p.IndexOf(isHandlerOf[T])
+}
+
+// LastIndexOf returns the position of the last handler of type T, -1 if not found.
+func LastIndexOf[T Handler](p Pipeline) int {
+	return p.LastIndexOf(isHandlerOf[T])
+}
+
+// Get returns the first handler of type T.
+func Get[T Handler](p Pipeline) (T, bool) {
+	return handlerAt[T](p, IndexOf[T](p))
+}
+
+// GetLast returns the last handler of type T.
+func GetLast[T Handler](p Pipeline) (T, bool) {
+	return handlerAt[T](p, LastIndexOf[T](p))
+}
+
+// isHandlerOf returns true if the handler is of type T, the internal handlers are never matched.
+func isHandlerOf[T Handler](handler Handler) bool {
+	switch handler.(type) {
+	case *headHandler, *tailHandler:
+		return false
+	}
+	_, ok := handler.(T)
+	return ok
+}
+
+// handlerAt to get the handler of type T in position
+func handlerAt[T Handler](p Pipeline, position int) (handler T, ok bool) {
+	if ctx := p.ContextAt(position); nil != ctx {
+		handler, ok = ctx.Handler().(T)
+	}
+	return
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"testing"
+)
+
+func TestIndexOfType(t *testing.T) {
+
+	counter := &counterHandler{}
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(oneHandler{}, counter, threeHandler{}, &threeHandler{}, ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {}))
+
+	// value & pointer types are different.
+	if 1 != IndexOf[oneHandler](pipeline) || -1 != IndexOf[*oneHandler](pipeline) {
+		t.Fatal("unexpected index of value type")
+	}
+	if 3 != IndexOf[threeHandler](pipeline) || 4 != IndexOf[*threeHandler](pipeline) || 4 != LastIndexOf[*threeHandler](pipeline) {
+		t.Fatal("unexpected index of pointer type")
+	}
+	if h, ok := Get[*counterHandler](pipeline); !ok || counter != h {
+		t.Fatal("unexpected handler:", h)
+	}
+
+	// the head & tail are never matched by the interface types.
+	if 3 != IndexOf[OutboundHandler](pipeline) || 4 != LastIndexOf[OutboundHandler](pipeline) {
+		t.Fatal("unexpected index of OutboundHandler")
+	}
+	if 5 != IndexOf[ExceptionHandler](pipeline) || 5 != LastIndexOf[ExceptionHandler](pipeline) {
+		t.Fatal("unexpected index of ExceptionHandler")
+	}
+	if h, ok := GetLast[InboundHandler](pipeline); !ok || counter != h {
+		t.Fatal("unexpected handler:", h)
+	}
+
+	// the index is usable with ContextAt.
+	if _, ok := pipeline.ContextAt(IndexOf[*counterHandler](pipeline)).Handler().(*counterHandler); !ok {
+		t.Fatal("unexpected context")
+	}
+
+	if h, ok := Get[EventHandler](pipeline); ok || nil != h || -1 != IndexOf[EventHandler](pipeline) {
+		t.Fatal("unexpected handler:", h)
+	}
+	if h, ok := GetLast[*fiveHandler](pipeline); ok || nil != h {
+		t.Fatal("unexpected handler:", h)
+	}
+}
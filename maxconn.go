@@ -0,0 +1,117 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ConnectionOverflowPolicy defines the behavior of accepting beyond the limit of WithMaxConnections.
+type ConnectionOverflowPolicy int
+
+const (
+	// ConnectionOverflowClose to accept and close the transport at once, it is the default policy.
+	ConnectionOverflowClose ConnectionOverflowPolicy = iota
+	// ConnectionOverflowPause to stop accepting until an accepted channel is closed, the connections wait in the backlog.
+	ConnectionOverflowPause
+)
+
+// ConnectionStats defines the counters of the accepted channels
+type ConnectionStats struct {
+	Active   int64 `json:"active"`   // the accepted channels that are not closed yet
+	Rejected int64 `json:"rejected"` // the transports closed by the limit of WithMaxConnections
+	Filtered int64 `json:"filtered"` // the transports closed by the filter of WithAcceptFilter
+}
+
+// connectionLimit counts the accepted channels of bootstrap
+type connectionLimit struct {
+	active   int64 // 64-bit aligned for atomic operations
+	rejected int64
+	filtered int64
+	mutex    sync.Mutex
+	released chan struct{} // closed when a slot is released, created by the paused accept loops
+}
+
+// ConnectionStats returns the counters of the accepted channels
+func (bs *bootstrap) ConnectionStats() ConnectionStats {
+	return ConnectionStats{
+		Active:   atomic.LoadInt64(&bs.connections.active),
+		Rejected: atomic.LoadInt64(&bs.connections.rejected),
+		Filtered: atomic.LoadInt64(&bs.connections.filtered),
+	}
+}
+
+// acquireConnection to take a slot for the accepted channel, returns false if the limit is reached.
+func (bs *bootstrap) acquireConnection() bool {
+
+	c := &bs.connections
+	if bs.maxConnections <= 0 {
+		atomic.AddInt64(&c.active, 1)
+		return true
+	}
+
+	for {
+		n := atomic.LoadInt64(&c.active)
+		if n >= bs.maxConnections {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&c.active, n, n+1) {
+			return true
+		}
+	}
+}
+
+// releaseConnection to release the slot of the closed channel, the paused accept loops are woken up.
+func (bs *bootstrap) releaseConnection() {
+
+	c := &bs.connections
+	atomic.AddInt64(&c.active, -1)
+
+	c.mutex.Lock()
+	if nil != c.released {
+		close(c.released)
+		c.released = nil
+	}
+	c.mutex.Unlock()
+}
+
+// waitConnection to wait until a slot may be free, returns false if the listener or bootstrap is closed.
+func (bs *bootstrap) waitConnection(done <-chan struct{}) bool {
+
+	c := &bs.connections
+	c.mutex.Lock()
+	if nil == c.released {
+		c.released = make(chan struct{})
+	}
+	released := c.released
+	c.mutex.Unlock()
+
+	// the slot may be released before the signal is created.
+	if bs.maxConnections <= 0 || atomic.LoadInt64(&c.active) < bs.maxConnections {
+		return true
+	}
+
+	select {
+	case <-released:
+		return true
+	case <-done:
+		return false
+	case <-bs.bootstrapCtx.Done():
+		return false
+	}
+}
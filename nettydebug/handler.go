@@ -0,0 +1,49 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nettydebug provides the http handlers to inspect the bootstrap at runtime.
+package nettydebug
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-netty/go-netty"
+)
+
+// snapshot defines the document rendered by Handler
+type snapshot struct {
+	Count    int                      `json:"count"`
+	Channels []netty.ChannelDebugInfo `json:"channels"`
+}
+
+// Handler returns a http.Handler that renders the DebugSnapshot of bootstrap as JSON, e.g.
+//
+//	mux.Handle("/debug/netty/channels", nettydebug.Handler(bs))
+func Handler(bs netty.Bootstrap) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		channels := bs.DebugSnapshot()
+		if nil == channels {
+			channels = []netty.ChannelDebugInfo{}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(snapshot{Count: len(channels), Channels: channels})
+	})
+}
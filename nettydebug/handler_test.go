@@ -0,0 +1,83 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettydebug
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-netty/go-netty"
+)
+
+func TestHandler(t *testing.T) {
+
+	bs := netty.NewBootstrap(netty.WithChildInitializer(func(netty.Channel) {}), netty.WithClientInitializer(func(netty.Channel) {}))
+	defer bs.Shutdown()
+
+	recorder := httptest.NewRecorder()
+	Handler(bs).ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/netty/channels", nil))
+	if "application/json; charset=utf-8" != recorder.Header().Get("Content-Type") {
+		t.Fatal("unexpected content type:", recorder.Header().Get("Content-Type"))
+	}
+
+	var empty map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &empty); nil != err || 0.0 != empty["count"] || 0 != len(empty["channels"].([]interface{})) {
+		t.Fatal("unexpected snapshot:", recorder.Body.String(), err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		// hold the peer until the listener is closed.
+		if conn, err := ln.Accept(); nil == err {
+			_, _ = conn.Read(make([]byte, 1))
+			_ = conn.Close()
+		}
+	}()
+
+	channel, err := bs.Connect("tcp://"+ln.Addr().String(), nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer channel.Close(nil)
+
+	recorder = httptest.NewRecorder()
+	Handler(bs).ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/netty/channels", nil))
+
+	var document struct {
+		Count    int `json:"count"`
+		Channels []struct {
+			ID       int64  `json:"id"`
+			Listener string `json:"listener"`
+			Age      int64  `json:"age_ns"`
+		} `json:"channels"`
+	}
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &document); nil != err || 1 != document.Count || 1 != len(document.Channels) {
+		t.Fatal("unexpected snapshot:", recorder.Body.String(), err)
+	}
+
+	if channel.ID() != document.Channels[0].ID || "" != document.Channels[0].Listener || document.Channels[0].Age <= 0 {
+		t.Fatal("unexpected channels:", recorder.Body.String())
+	}
+}
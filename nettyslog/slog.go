@@ -0,0 +1,68 @@
+//go:build go1.21
+
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettyslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-netty/go-netty"
+)
+
+// New returns a netty.Logger that logs through the slog.Logger, nil means slog.Default().
+func New(logger *slog.Logger) netty.Logger {
+	if nil == logger {
+		logger = slog.Default()
+	}
+	return slogLogger{logger: logger}
+}
+
+// slogLogger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, format, args)
+}
+
+func (l slogLogger) Infof(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, format, args)
+}
+
+func (l slogLogger) Warnf(format string, args ...interface{}) {
+	l.log(slog.LevelWarn, format, args)
+}
+
+func (l slogLogger) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, format, args)
+}
+
+// With to attach the key-value fields as the attributes of slog
+func (l slogLogger) With(fields ...interface{}) netty.Logger {
+	return slogLogger{logger: l.logger.With(fields...)}
+}
+
+func (l slogLogger) log(level slog.Level, format string, args []interface{}) {
+	// the message is formatted only if the level is enabled.
+	if ctx := context.Background(); l.logger.Enabled(ctx, level) {
+		l.logger.Log(ctx, level, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,54 @@
+//go:build go1.21
+
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettyslog
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+func TestSlogLogger(t *testing.T) {
+
+	var buffer bytes.Buffer
+	logger := New(slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Debugf("hidden")
+	logger.Infof("listening on %s", "tcp://0.0.0.0:9527")
+	utils.LoggerWith(logger, "channel", 1).Warnf("dropped %T", "")
+	logger.Errorf("callback panic")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if 3 != len(lines) {
+		t.Fatal("unexpected lines:", lines)
+	}
+
+	for i, want := range []string{
+		`level=INFO msg="listening on tcp://0.0.0.0:9527"`,
+		`level=WARN msg="dropped string" channel=1`,
+		`level=ERROR msg="callback panic"`,
+	} {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Fatalf("unexpected line: %s, want: %s", lines[i], want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettytest
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-netty/go-netty"
+)
+
+// FakeClock defines a netty.Clock that is advanced manually, the due timers are fired by Advance deterministically.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*fakeTimer
+}
+
+// fakeTimer defines a timer scheduled on FakeClock
+type fakeTimer struct {
+	deadline time.Time
+	seq      uint64 // the timers with the same deadline are fired in the order they are scheduled.
+	fn       func()
+}
+
+// NewFakeClock create a FakeClock starts at the time
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the time of clock
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Schedule to call the fn when the clock is advanced over the duration
+func (c *FakeClock) Schedule(d time.Duration, fn func()) (cancel func()) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	t := &fakeTimer{deadline: c.now.Add(d), seq: c.seq, fn: fn}
+	c.timers = append(c.timers, t)
+
+	return func() { c.remove(t) }
+}
+
+// Len returns the number of pending timers
+func (c *FakeClock) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+// Advance to move the clock forward, the due timers are fired in the order of deadline on the calling goroutine,
+// the clock reads the deadline of timer while it is firing. The timers scheduled by the fired timers are fired
+// as well if they are due.
+func (c *FakeClock) Advance(d time.Duration) {
+
+	c.mutex.Lock()
+	target := c.now.Add(d)
+	c.mutex.Unlock()
+
+	for {
+		t := c.next(target)
+		if nil == t {
+			break
+		}
+		t.fn()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if target.After(c.now) {
+		c.now = target
+	}
+}
+
+// next to pop the earliest timer that is due at the target, and move the clock to its deadline.
+func (c *FakeClock) next(target time.Time) *fakeTimer {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sort.Slice(c.timers, func(i, j int) bool {
+		if c.timers[i].deadline.Equal(c.timers[j].deadline) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+
+	if 0 == len(c.timers) || c.timers[0].deadline.After(target) {
+		return nil
+	}
+
+	t := c.timers[0]
+	c.timers[0] = nil
+	c.timers = c.timers[1:]
+	if t.deadline.After(c.now) {
+		c.now = t.deadline
+	}
+	return t
+}
+
+func (c *FakeClock) remove(t *fakeTimer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := range c.timers {
+		if t == c.timers[i] {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// FixedIDs returns a ChannelIDFactory that generates the ids from start, so the channel ids are same across test runs.
+func FixedIDs(start int64) netty.ChannelIDFactory {
+	id := start - 1
+	return func() int64 {
+		return atomic.AddInt64(&id, 1)
+	}
+}
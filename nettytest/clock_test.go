@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettytest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty"
+)
+
+var epoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock(t *testing.T) {
+
+	clock := NewFakeClock(epoch)
+
+	var fired []string
+	clock.Schedule(2*time.Second, func() { fired = append(fired, "2s") })
+	clock.Schedule(time.Second, func() {
+		fired = append(fired, "1s")
+		// due in the same advance.
+		clock.Schedule(500*time.Millisecond, func() { fired = append(fired, "1.5s@"+clock.Now().Sub(epoch).String()) })
+	})
+	clock.Schedule(time.Second, func() { fired = append(fired, "1s#2") })
+	cancel := clock.Schedule(1500*time.Millisecond, func() { fired = append(fired, "canceled") })
+	cancel()
+
+	clock.Advance(999 * time.Millisecond)
+	if 0 != len(fired) || 3 != clock.Len() {
+		t.Fatal("no timer is due:", fired)
+	}
+
+	clock.Advance(time.Second)
+	if want := []string{"1s", "1s#2", "1.5s@1.5s"}; !reflect.DeepEqual(want, fired) {
+		t.Fatal("unexpected timers:", fired, "want:", want)
+	}
+
+	if 1999*time.Millisecond != clock.Now().Sub(epoch) || 1 != clock.Len() {
+		t.Fatal("unexpected clock:", clock.Now())
+	}
+}
+
+func TestFixedIDs(t *testing.T) {
+	ids := FixedIDs(100)
+	if 100 != ids() || 101 != ids() || 102 != ids() {
+		t.Fatal("unexpected ids")
+	}
+}
+
+func TestReadIdleHandlerClock(t *testing.T) {
+
+	clock := NewFakeClock(epoch)
+	ec := NewEmbeddedChannelContext(netty.ContextWithClock(context.Background(), clock), netty.ReadIdleHandler(time.Second))
+
+	clock.Advance(500 * time.Millisecond)
+	ec.WriteInbound("ping")
+	if "ping" != ec.ReadInbound() {
+		t.Fatal("the message should be passed through")
+	}
+
+	// the idle time is counted from the last read.
+	clock.Advance(999 * time.Millisecond)
+	if event := ec.ReadEvent(); nil != event {
+		t.Fatal("unexpected event:", event)
+	}
+
+	clock.Advance(time.Millisecond)
+	if _, ok := ec.ReadEvent().(netty.ReadIdleEvent); !ok {
+		t.Fatal("the read idle event should be triggered")
+	}
+
+	// the event is triggered for every idle time.
+	clock.Advance(time.Second)
+	if _, ok := ec.ReadEvent().(netty.ReadIdleEvent); !ok {
+		t.Fatal("the read idle event should be triggered again")
+	}
+
+	ec.FinishAndClose()
+	clock.Advance(time.Minute)
+	if event := ec.ReadEvent(); nil != event || 0 != clock.Len() {
+		t.Fatal("the timer should be stopped:", event, clock.Len())
+	}
+}
+
+func TestWriteIdleHandlerClock(t *testing.T) {
+
+	clock := NewFakeClock(epoch)
+	ec := NewEmbeddedChannelContext(netty.ContextWithClock(context.Background(), clock), netty.WriteIdleHandler(2*time.Second))
+
+	clock.Advance(1500 * time.Millisecond)
+	ec.WriteOutbound([]byte("pong"))
+
+	clock.Advance(1999 * time.Millisecond)
+	if event := ec.ReadEvent(); nil != event {
+		t.Fatal("unexpected event:", event)
+	}
+
+	clock.Advance(time.Millisecond)
+	if _, ok := ec.ReadEvent().(netty.WriteIdleEvent); !ok {
+		t.Fatal("the write idle event should be triggered")
+	}
+
+	if ec.ReadOutbound(); ec.FinishAndClose() {
+		t.Fatal("unexpected messages")
+	}
+}
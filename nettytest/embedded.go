@@ -0,0 +1,271 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nettytest provides utilities for testing the handlers without network.
+package nettytest
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/transport"
+)
+
+// EmbeddedChannel defines a channel over an in-memory transport for testing the handlers.
+//
+// The messages written by WriteInbound pass through the real pipeline, the messages reached the tail
+// can be read by ReadInbound. The messages written by WriteOutbound (and Channel.Write) pass through
+// the pipeline in the other direction, the messages reached the head can be read by ReadOutbound.
+// The exceptions reached the tail are captured rather than printed.
+//
+// Every inbound message is dispatched once, the codecs that decode a frame per read should be
+// written with the same reader for every frame, like the read loop of channel.
+//
+// The read messages are owned by the caller and should be recycled by netty.Recycle.
+type EmbeddedChannel struct {
+	netty.Channel
+	pipeline  netty.Pipeline
+	transport *embeddedTransport
+	mutex     sync.Mutex
+	inbound   []netty.Message
+	outbound  []netty.Message
+	events    []netty.Message
+	errs      []netty.Exception
+}
+
+// NewEmbeddedChannel create an active EmbeddedChannel with the handlers
+func NewEmbeddedChannel(handlers ...netty.Handler) *EmbeddedChannel {
+	return NewEmbeddedChannelContext(context.Background(), handlers...)
+}
+
+// NewEmbeddedChannelContext create an active EmbeddedChannel with the context and handlers,
+// e.g. the context created by netty.ContextWithClock to drive the time-based handlers by FakeClock.
+func NewEmbeddedChannelContext(ctx context.Context, handlers ...netty.Handler) *EmbeddedChannel {
+
+	ec := &EmbeddedChannel{pipeline: netty.NewPipelineWith(), transport: newEmbeddedTransport()}
+
+	ec.pipeline.AddFirst(outboundCapture{ec})
+	ec.pipeline.AddLast(handlers...)
+	ec.pipeline.AddLast(inboundCapture{ec})
+
+	pipeline := &embeddedPipeline{Pipeline: ec.pipeline, transport: ec.transport}
+	ec.Channel = netty.NewChannel(128)(1, ctx, pipeline, ec.transport)
+
+	// the active event is fired synchronously.
+	pipeline.ServeChannel(ec.Channel)
+	return ec
+}
+
+// WriteInbound to pass the messages through the inbound pipeline, returns true if any message reached the tail.
+func (ec *EmbeddedChannel) WriteInbound(messages ...netty.Message) bool {
+	for _, message := range messages {
+		ec.invoke(func() { ec.pipeline.FireChannelRead(message) })
+	}
+	return ec.count(&ec.inbound) > 0
+}
+
+// WriteOutbound to pass the messages through the outbound pipeline, returns true if any message reached the head.
+func (ec *EmbeddedChannel) WriteOutbound(messages ...netty.Message) bool {
+	for _, message := range messages {
+		ec.Write(message)
+	}
+	return ec.count(&ec.outbound) > 0
+}
+
+// ReadInbound to pop the first message reached the tail, nil if there is none.
+func (ec *EmbeddedChannel) ReadInbound() netty.Message {
+	return ec.pop(&ec.inbound)
+}
+
+// ReadOutbound to pop the first message reached the head, nil if there is none.
+func (ec *EmbeddedChannel) ReadOutbound() netty.Message {
+	return ec.pop(&ec.outbound)
+}
+
+// ReadEvent to pop the first event reached the tail, nil if there is none.
+func (ec *EmbeddedChannel) ReadEvent() netty.Event {
+	return ec.pop(&ec.events)
+}
+
+// Exceptions returns the exceptions reached the tail
+func (ec *EmbeddedChannel) Exceptions() []netty.Exception {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	return append([]netty.Exception(nil), ec.errs...)
+}
+
+// CheckException to pop the first exception reached the tail, nil if there is none.
+func (ec *EmbeddedChannel) CheckException() netty.Exception {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	if 0 == len(ec.errs) {
+		return nil
+	}
+	ex := ec.errs[0]
+	ec.errs = ec.errs[1:]
+	return ex
+}
+
+// WrittenBytes returns the bytes written to the transport by Channel.Writev
+func (ec *EmbeddedChannel) WrittenBytes() []byte {
+	return ec.transport.written()
+}
+
+// FinishAndClose to close the channel, returns true if any inbound or outbound message is left unread.
+func (ec *EmbeddedChannel) FinishAndClose() bool {
+	// the inactive event is fired synchronously.
+	ec.Close(nil)
+	return ec.count(&ec.inbound) > 0 || ec.count(&ec.outbound) > 0
+}
+
+// invoke to route the panic to the pipeline like the read loop of channel
+func (ec *EmbeddedChannel) invoke(fn func()) {
+	defer func() {
+		if err := recover(); nil != err && ec.IsActive() {
+			ec.pipeline.FireChannelException(netty.AsException(err, debug.Stack()))
+		}
+	}()
+	fn()
+}
+
+func (ec *EmbeddedChannel) push(queue *[]netty.Message, message netty.Message) {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	*queue = append(*queue, message)
+}
+
+func (ec *EmbeddedChannel) pop(queue *[]netty.Message) netty.Message {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	if 0 == len(*queue) {
+		return nil
+	}
+	message := (*queue)[0]
+	(*queue)[0] = nil
+	*queue = (*queue)[1:]
+	return message
+}
+
+func (ec *EmbeddedChannel) count(queue *[]netty.Message) int {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	return len(*queue)
+}
+
+// inboundCapture to capture the messages, events & exceptions before the tail
+type inboundCapture struct {
+	ec *EmbeddedChannel
+}
+
+func (c inboundCapture) HandleRead(ctx netty.InboundContext, message netty.Message) {
+	c.ec.push(&c.ec.inbound, message)
+}
+
+func (c inboundCapture) HandleEvent(ctx netty.EventContext, event netty.Event) {
+	c.ec.push(&c.ec.events, event)
+}
+
+func (c inboundCapture) HandleException(ctx netty.ExceptionContext, ex netty.Exception) {
+	c.ec.mutex.Lock()
+	defer c.ec.mutex.Unlock()
+	c.ec.errs = append(c.ec.errs, ex)
+}
+
+// outboundCapture to capture the messages before the head
+type outboundCapture struct {
+	ec *EmbeddedChannel
+}
+
+func (c outboundCapture) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
+	// the captured message is owned by the reader.
+	c.ec.push(&c.ec.outbound, message)
+}
+
+// embeddedPipeline to park the read loop of channel, the inbound messages are written by WriteInbound.
+type embeddedPipeline struct {
+	netty.Pipeline
+	transport *embeddedTransport
+}
+
+func (p *embeddedPipeline) FireChannelRead(message netty.Message) {
+	if t, ok := message.(transport.Transport); ok && p.transport == t.RawTransport() {
+		<-p.transport.closed
+		return
+	}
+	p.Pipeline.FireChannelRead(message)
+}
+
+// embeddedAddr defines the address of embedded transport
+type embeddedAddr struct{}
+
+func (embeddedAddr) Network() string { return "embedded" }
+func (embeddedAddr) String() string  { return "embedded" }
+
+// embeddedTransport defines the in-memory transport, nothing can be read from it.
+type embeddedTransport struct {
+	mutex   sync.Mutex
+	buffer  bytes.Buffer
+	closed  chan struct{}
+	closing int32
+}
+
+func newEmbeddedTransport() *embeddedTransport {
+	return &embeddedTransport{closed: make(chan struct{})}
+}
+
+func (t *embeddedTransport) Read(b []byte) (int, error) {
+	<-t.closed
+	return 0, net.ErrClosed
+}
+
+func (t *embeddedTransport) Write(b []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.buffer.Write(b)
+}
+
+func (t *embeddedTransport) Writev(buffs transport.Buffers) (int64, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return buffs.Buffers.WriteTo(&t.buffer)
+}
+
+func (t *embeddedTransport) written() []byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]byte(nil), t.buffer.Bytes()...)
+}
+
+func (t *embeddedTransport) Close() error {
+	if atomic.CompareAndSwapInt32(&t.closing, 0, 1) {
+		close(t.closed)
+	}
+	return nil
+}
+
+func (t *embeddedTransport) Flush() error                     { return nil }
+func (t *embeddedTransport) RawTransport() interface{}        { return t }
+func (t *embeddedTransport) LocalAddr() net.Addr              { return embeddedAddr{} }
+func (t *embeddedTransport) RemoteAddr() net.Addr             { return embeddedAddr{} }
+func (t *embeddedTransport) SetDeadline(time.Time) error      { return nil }
+func (t *embeddedTransport) SetReadDeadline(time.Time) error  { return nil }
+func (t *embeddedTransport) SetWriteDeadline(time.Time) error { return nil }
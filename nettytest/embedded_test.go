@@ -0,0 +1,177 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettytest
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty"
+)
+
+// upperCodec to convert string to upper case for inbound, and to lower case for outbound.
+type upperCodec struct{}
+
+func (upperCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
+	switch s := message.(type) {
+	case string:
+		if "panic" == s {
+			panic(errors.New("bad message"))
+		}
+		ctx.HandleRead(strings.ToUpper(s))
+	default:
+		// drop the message.
+	}
+}
+
+func (upperCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
+	ctx.HandleWrite(strings.ToLower(message.(string)))
+}
+
+// pooledMessage defines a refcounted message
+type pooledMessage struct {
+	netty.RefCount
+	recycled bool
+}
+
+func (m *pooledMessage) Recycle() {
+	m.recycled = true
+}
+
+func TestEmbeddedChannel(t *testing.T) {
+
+	var actives, inactives int
+	ec := NewEmbeddedChannel(
+		netty.ActiveHandlerFunc(func(ctx netty.ActiveContext) {
+			actives++
+			ctx.HandleActive()
+		}),
+		netty.InactiveHandlerFunc(func(ctx netty.InactiveContext, ex netty.Exception) {
+			inactives++
+			ctx.HandleInactive(ex)
+		}),
+		upperCodec{},
+	)
+
+	if 1 != actives || !ec.IsActive() {
+		t.Fatal("the channel should be active")
+	}
+
+	if !ec.WriteInbound("hello", 1, "world") {
+		t.Fatal("the messages should reach the tail")
+	}
+
+	for _, want := range []string{"HELLO", "WORLD"} {
+		if message := ec.ReadInbound(); want != message {
+			t.Fatal("unexpected message:", message, "want:", want)
+		}
+	}
+
+	if nil != ec.ReadInbound() {
+		t.Fatal("the dropped message should not reach the tail")
+	}
+
+	if !ec.WriteOutbound("HELLO") || "hello" != ec.ReadOutbound() || nil != ec.ReadOutbound() {
+		t.Fatal("the message should reach the head")
+	}
+
+	ec.SetAttachment("attachment")
+	if "attachment" != ec.Attachment() {
+		t.Fatal("the attachment is lost")
+	}
+
+	ec.Trigger(netty.ReadIdleEvent{})
+	if _, ok := ec.ReadEvent().(netty.ReadIdleEvent); !ok {
+		t.Fatal("the event should reach the tail")
+	}
+
+	ec.WriteInbound("left")
+	if !ec.FinishAndClose() || 1 != inactives || ec.IsActive() {
+		t.Fatal("the unread message should be reported")
+	}
+}
+
+func TestEmbeddedChannelException(t *testing.T) {
+
+	ec := NewEmbeddedChannel(upperCodec{})
+
+	// the exception is captured instead of closing the channel.
+	ec.WriteInbound("panic", "next")
+	if ex := ec.CheckException(); nil == ex || "bad message" != ex.Error() || nil != ec.CheckException() {
+		t.Fatal("unexpected exception:", ex)
+	}
+
+	if "NEXT" != ec.ReadInbound() {
+		t.Fatal("the channel should work after the exception")
+	}
+
+	// the fatal exception closes the channel.
+	ec.Pipeline().FireChannelException(netty.AsException(io.EOF, nil))
+	if ec.IsActive() || 1 != len(ec.Exceptions()) {
+		t.Fatal("the fatal exception should close the channel")
+	}
+
+	if ec.FinishAndClose() {
+		t.Fatal("nothing is left")
+	}
+}
+
+func TestEmbeddedChannelRecycle(t *testing.T) {
+
+	ec := NewEmbeddedChannel()
+	defer ec.FinishAndClose()
+
+	// the outbound message is owned by the reader.
+	message := &pooledMessage{}
+	ec.WriteOutbound(message)
+	if out := ec.ReadOutbound(); message != out || message.recycled || 1 != message.RefCnt() {
+		t.Fatal("the message should be retained")
+	}
+
+	netty.Recycle(message)
+	if !message.recycled {
+		t.Fatal("the message should be recycled")
+	}
+
+	// the inbound message is owned by the reader.
+	message = &pooledMessage{}
+	ec.WriteInbound(message)
+	if message != ec.ReadInbound() || message.recycled {
+		t.Fatal("the message should not be recycled by the tail")
+	}
+}
+
+func TestEmbeddedChannelWritev(t *testing.T) {
+
+	ec := NewEmbeddedChannel()
+	defer ec.FinishAndClose()
+
+	if _, err := ec.Writev([][]byte{[]byte("hello, "), []byte("world")}); nil != err {
+		t.Fatal(err)
+	}
+
+	// the bytes are written by the write loop.
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if "hello, world" == string(ec.WrittenBytes()) {
+			return
+		}
+	}
+	t.Fatal("unexpected bytes:", string(ec.WrittenBytes()))
+}
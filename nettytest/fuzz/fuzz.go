@@ -0,0 +1,306 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fuzz provides the scaffolding to fuzz the decoders by go test -fuzz, e.g:
+//
+//	func FuzzDelimiterCodec(f *testing.F) {
+//		codec := func() netty.Handler { return frame.DelimiterCodec(1024, "\r\n", true) }
+//		fuzz.FuzzDecoder(f, codec, fuzz.WithSeedFiles("testdata/delimiter.bin"), fuzz.WithEncoder(codec))
+//	}
+//
+// The failed inputs found by go test -fuzz are written to testdata/fuzz/<FuzzName>, they are replayed by go test
+// as the regression tests like the seeds, and could be replayed by Check in a regular test as well.
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/nettytest"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// HandlerFunc creates the handler under test, a fresh channel is created for every input.
+type HandlerFunc func() netty.Handler
+
+// Option defines an option of FuzzDecoder
+type Option func(options *options)
+
+// options
+type options struct {
+	seeds     [][]byte
+	seedFiles []string
+	encoder   HandlerFunc
+	maxAlloc  uint64
+	timeout   time.Duration
+}
+
+// WithSeeds to add the inputs to the seed corpus
+func WithSeeds(inputs ...[]byte) Option {
+	return func(options *options) {
+		options.seeds = append(options.seeds, inputs...)
+	}
+}
+
+// WithSeedFiles to add the files matched by the patterns to the seed corpus, e.g: testdata/*.bin
+func WithSeedFiles(patterns ...string) Option {
+	return func(options *options) {
+		options.seedFiles = append(options.seedFiles, patterns...)
+	}
+}
+
+// WithEncoder to check the round-trip of decoded messages: decode -> encode -> decode must be stable.
+func WithEncoder(newEncoder HandlerFunc) Option {
+	return func(options *options) {
+		options.encoder = newEncoder
+	}
+}
+
+// WithMaxAlloc to limit the bytes allocated while decoding an input, default 64M.
+func WithMaxAlloc(bytes uint64) Option {
+	return func(options *options) {
+		options.maxAlloc = bytes
+	}
+}
+
+// WithTimeout to limit the time of decoding an input, default 2s.
+func WithTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.timeout = timeout
+	}
+}
+
+func parseOptions(option ...Option) *options {
+	options := &options{maxAlloc: 64 << 20, timeout: 2 * time.Second}
+	for i := range option {
+		option[i](options)
+	}
+	return options
+}
+
+// FuzzDecoder to fuzz the decoder with the inputs split into the fragments, the fragments are fuzzed as well.
+//
+// The errors raised by the decoder, e.g. panic(err), are the expected rejections of malformed inputs,
+// the input fails on the runtime errors (index out of range, nil dereference ...), the decoder consumes nothing,
+// the allocation exceeds the cap, the decoding is not returned in time or the round-trip is not stable.
+func FuzzDecoder(f *testing.F, newHandler HandlerFunc, option ...Option) {
+	f.Helper()
+
+	options := parseOptions(option...)
+	seeds, err := options.loadSeeds()
+	if nil != err {
+		f.Fatal(err)
+	}
+
+	for _, seed := range seeds {
+		// the whole input, and one byte per read.
+		f.Add(seed, []byte(nil))
+		f.Add(seed, []byte{0})
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte, fragments []byte) {
+		if err := check(newHandler, input, fragments, options); nil != err {
+			t.Fatal(err)
+		}
+	})
+}
+
+// Check the input like FuzzDecoder, e.g. to replay a failed input as the regression test.
+func Check(tb testing.TB, newHandler HandlerFunc, input []byte, fragments []byte, option ...Option) {
+	tb.Helper()
+	if err := check(newHandler, input, fragments, parseOptions(option...)); nil != err {
+		tb.Fatal(err)
+	}
+}
+
+// loadSeeds to read the seed files
+func (o *options) loadSeeds() ([][]byte, error) {
+
+	seeds := append([][]byte(nil), o.seeds...)
+	for _, pattern := range o.seedFiles {
+		files, err := filepath.Glob(pattern)
+		if nil != err {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if nil != err {
+				return nil, err
+			}
+			seeds = append(seeds, data)
+		}
+	}
+
+	return seeds, nil
+}
+
+// fragmentSizes to split n bytes by the fragments cyclically, every byte of fragments is a size in [1, 256].
+func fragmentSizes(n int, fragments []byte) []int {
+
+	if 0 == len(fragments) {
+		return nil
+	}
+
+	var sizes []int
+	for total := 0; total < n; {
+		size := int(fragments[len(sizes)%len(fragments)]) + 1
+		sizes = append(sizes, size)
+		total += size
+	}
+
+	return sizes
+}
+
+func check(newHandler HandlerFunc, input []byte, fragments []byte, options *options) error {
+
+	sizes := fragmentSizes(len(input), fragments)
+
+	var decoded []netty.Message
+	if err := guard(options, func() (err error) {
+		decoded, err = decode(newHandler, input, sizes)
+		return
+	}); nil != err {
+		return fmt.Errorf("decode %d bytes in fragments %v: %w", len(input), sizes, err)
+	}
+
+	if nil == options.encoder || 0 == len(decoded) {
+		return nil
+	}
+
+	var encoded []byte
+	var again []netty.Message
+	if err := guard(options, func() (err error) {
+		if encoded, err = encode(options.encoder, decoded); nil == err {
+			again, err = decode(newHandler, encoded, nil)
+		}
+		return
+	}); nil != err {
+		return fmt.Errorf("round-trip of %d messages decoded in fragments %v: %w", len(decoded), sizes, err)
+	}
+
+	for i := 0; i < len(decoded) || i < len(again); i++ {
+		if i >= len(decoded) || i >= len(again) || !reflect.DeepEqual(decoded[i], again[i]) {
+			return fmt.Errorf("round-trip is not stable at message #%d: decoded %d messages %q, encoded %q, decoded again %d messages %q",
+				i, len(decoded), decoded, encoded, len(again), again)
+		}
+	}
+
+	return nil
+}
+
+// guard to run the fn with the watchdog and the cap of allocation
+func guard(options *options, fn func() error) error {
+
+	before := allocated()
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); nil != err {
+				done <- fmt.Errorf("panic: %v\n%s", err, debug.Stack())
+			}
+		}()
+		done <- fn()
+	}()
+
+	timer := time.NewTimer(options.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if n := allocated() - before; nil == err && n > options.maxAlloc {
+			err = fmt.Errorf("%d bytes allocated, exceeds the cap %d", n, options.maxAlloc)
+		}
+		return err
+	case <-timer.C:
+		return fmt.Errorf("not returned within %v, the decoder may loop infinitely", options.timeout)
+	}
+}
+
+// allocated returns the cumulative bytes allocated in heap
+func allocated() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// decode to dispatch the reader until all bytes are consumed or the decoder raises an error, like the read loop of channel.
+func decode(newHandler HandlerFunc, input []byte, sizes []int) (decoded []netty.Message, err error) {
+
+	ec := nettytest.NewEmbeddedChannel(newHandler())
+	defer ec.FinishAndClose()
+
+	reader := nettytest.NewFragmentReader(input, sizes...)
+	for reader.Len() > 0 {
+
+		remaining := reader.Len()
+		ec.WriteInbound(reader)
+
+		// the decoded message may read lazily from the reader, so it has to be consumed before the next read.
+		for message := ec.ReadInbound(); nil != message; message = ec.ReadInbound() {
+			decoded = append(decoded, nettytest.Normalize(message))
+		}
+
+		if ex := ec.CheckException(); nil != ex {
+			var re runtime.Error
+			if errors.As(ex, &re) {
+				return decoded, fmt.Errorf("%w at stream offset %d\n%s", ex, len(input)-reader.Len(), ex.Stack())
+			}
+			// the malformed input is rejected, the channel will be closed.
+			return decoded, nil
+		}
+
+		if remaining == reader.Len() {
+			return decoded, fmt.Errorf("no bytes consumed at stream offset %d, the read loop will spin", len(input)-remaining)
+		}
+	}
+
+	return decoded, nil
+}
+
+// encode the messages by the encoder
+func encode(newEncoder HandlerFunc, messages []netty.Message) ([]byte, error) {
+
+	ec := nettytest.NewEmbeddedChannel(newEncoder())
+	defer ec.FinishAndClose()
+
+	ec.WriteOutbound(messages...)
+	ec.Flush()
+
+	if ex := ec.CheckException(); nil != ex {
+		return nil, fmt.Errorf("encode: %w", ex)
+	}
+
+	var stream []byte
+	for message := ec.ReadOutbound(); nil != message; message = ec.ReadOutbound() {
+		data, err := utils.ToBytes(nettytest.Normalize(message))
+		if nil != err {
+			return stream, fmt.Errorf("encode: %w", err)
+		}
+		stream = append(stream, data...)
+	}
+
+	return stream, nil
+}
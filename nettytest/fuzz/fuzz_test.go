@@ -0,0 +1,122 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fuzz
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// fixedCodec to decode the 4-bytes frames, the behaviors of bugs are triggered by the first byte of frame.
+type fixedCodec struct {
+	buggy bool
+}
+
+func (c fixedCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
+
+	frame := make([]byte, 4)
+	_, err := io.ReadFull(message.(io.Reader), frame)
+	utils.Assert(err)
+
+	if c.buggy {
+		switch frame[0] {
+		case 'X':
+			// index out of range.
+			_ = frame[frame[1]]
+		case 'L':
+			// longer than the timeout of test, but does not leak the goroutine.
+			time.Sleep(200 * time.Millisecond)
+		case 'M':
+			frame = append(make([]byte, 0, 8<<20), frame...)
+		}
+	}
+
+	ctx.HandleRead(frame)
+}
+
+func (c fixedCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
+	frame := message.([]byte)
+	if c.buggy && 'R' == frame[0] {
+		frame = frame[1:]
+	}
+	ctx.HandleWrite(frame)
+}
+
+// lazyCodec to return without reading the message
+type lazyCodec struct{}
+
+func (lazyCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {}
+
+func TestCheck(t *testing.T) {
+
+	options := parseOptions(WithEncoder(func() netty.Handler { return fixedCodec{} }))
+	for _, fragments := range [][]byte{nil, {0}, {2, 0}, {255}} {
+		if err := check(func() netty.Handler { return fixedCodec{} }, []byte("abcdefghij"), fragments, options); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	buggy := func() netty.Handler { return fixedCodec{buggy: true} }
+
+	var cases = []struct {
+		name    string
+		handler HandlerFunc
+		input   string
+		option  []Option
+		want    string
+	}{
+		{name: "runtime-error", handler: buggy, input: "abcdX\xff..", want: "index out of range"},
+		{name: "infinite-loop", handler: buggy, input: "Labc", option: []Option{WithTimeout(50 * time.Millisecond)}, want: "not returned within 50ms"},
+		{name: "memory", handler: buggy, input: "Mabc", option: []Option{WithMaxAlloc(1 << 20)}, want: "exceeds the cap 1048576"},
+		{name: "round-trip", handler: buggy, input: "abcdRabc", option: []Option{WithEncoder(buggy)}, want: "round-trip is not stable at message #1"},
+		{name: "no-progress", handler: func() netty.Handler { return lazyCodec{} }, input: "abcd", want: "no bytes consumed at stream offset 0"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := check(c.handler, []byte(c.input), []byte{0}, parseOptions(c.option...))
+			if nil == err || !strings.Contains(err.Error(), c.want) {
+				t.Fatal("unexpected error:", err)
+			}
+		})
+	}
+
+	// the rejection of malformed input is expected.
+	if err := check(buggy, []byte("abcdef"), nil, parseOptions()); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestFragmentSizes(t *testing.T) {
+	if sizes := fragmentSizes(10, []byte{0, 2}); !reflect.DeepEqual([]int{1, 3, 1, 3, 1, 3}, sizes) {
+		t.Fatal("unexpected sizes:", sizes)
+	}
+	if sizes := fragmentSizes(10, nil); nil != sizes {
+		t.Fatal("unexpected sizes:", sizes)
+	}
+}
+
+func FuzzFixedCodec(f *testing.F) {
+	codec := func() netty.Handler { return fixedCodec{} }
+	FuzzDecoder(f, codec, WithSeeds([]byte("abcdefgh"), []byte("abc")), WithEncoder(codec))
+}
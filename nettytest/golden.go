@@ -0,0 +1,440 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettytest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// HandlersFunc creates the handlers under test, a fresh pipeline is created for every fragmentation.
+type HandlersFunc func() []netty.Handler
+
+// GoldenOption defines an option of the golden test
+type GoldenOption func(options *goldenOptions)
+
+// goldenOptions
+type goldenOptions struct {
+	maxCuts    int
+	budget     int
+	randomRuns int
+	seed       int64
+	fragments  [][]int
+}
+
+// WithMaxCuts to test all fragmentations with at most n split points, default 2.
+func WithMaxCuts(n int) GoldenOption {
+	return func(options *goldenOptions) {
+		options.maxCuts = n
+	}
+}
+
+// WithSplitBudget to limit the number of exhaustive fragmentations, default 2000.
+func WithSplitBudget(n int) GoldenOption {
+	return func(options *goldenOptions) {
+		options.budget = n
+	}
+}
+
+// WithRandomSplits to test the randomized fragmentations generated by the seed, default 200 runs with seed 1.
+func WithRandomSplits(runs int, seed int64) GoldenOption {
+	return func(options *goldenOptions) {
+		options.randomRuns, options.seed = runs, seed
+	}
+}
+
+// WithFragments to test the given fragmentation only, e.g. to reproduce a reported failure.
+func WithFragments(sizes ...int) GoldenOption {
+	return func(options *goldenOptions) {
+		options.fragments = append(options.fragments, sizes)
+	}
+}
+
+func parseGoldenOptions(option ...GoldenOption) *goldenOptions {
+	options := &goldenOptions{maxCuts: 2, budget: 2000, randomRuns: 200, seed: 1}
+	for i := range option {
+		option[i](options)
+	}
+	return options
+}
+
+// fragmentations to generate the fragmentations of n units: the whole, one unit per fragment,
+// all splits with at most maxCuts split points within the budget, and the randomized splits.
+func (o *goldenOptions) fragmentations(n int) [][]int {
+
+	if len(o.fragments) > 0 {
+		return o.fragments
+	}
+
+	var result = [][]int{{n}}
+	if n <= 1 {
+		return result
+	}
+
+	ones := make([]int, n)
+	for i := range ones {
+		ones[i] = 1
+	}
+	result = append(result, ones)
+
+	// split points are in [1, n-1].
+	var cuts []int
+	var generate func(from, k int) bool
+	generate = func(from, k int) bool {
+		if 0 == k {
+			if len(result) >= o.budget {
+				return false
+			}
+			result = append(result, cutsToSizes(cuts, n))
+			return true
+		}
+		for pos := from; pos <= n-k; pos++ {
+			cuts = append(cuts, pos)
+			ok := generate(pos+1, k-1)
+			cuts = cuts[:len(cuts)-1]
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	for k := 1; k <= o.maxCuts && k < n-1; k++ {
+		if !generate(1, k) {
+			break
+		}
+	}
+
+	random := rand.New(rand.NewSource(o.seed))
+	for run := 0; run < o.randomRuns; run++ {
+		cuts = cuts[:0]
+		probability := random.Float64()
+		for pos := 1; pos < n; pos++ {
+			if random.Float64() < probability {
+				cuts = append(cuts, pos)
+			}
+		}
+		result = append(result, cutsToSizes(cuts, n))
+	}
+
+	return result
+}
+
+func cutsToSizes(cuts []int, n int) []int {
+	sizes := make([]int, 0, len(cuts)+1)
+	var last int
+	for _, cut := range cuts {
+		sizes = append(sizes, cut-last)
+		last = cut
+	}
+	return append(sizes, n-last)
+}
+
+// FragmentReader defines a reader that reads the data in fragments, a read never crosses the boundary of fragment.
+type FragmentReader struct {
+	data  []byte
+	sizes []int
+	left  int
+}
+
+// NewFragmentReader create a FragmentReader with the sizes of fragments, the bytes not covered by the sizes are read at once.
+func NewFragmentReader(data []byte, sizes ...int) *FragmentReader {
+	return &FragmentReader{data: data, sizes: sizes}
+}
+
+// Len returns the number of unread bytes
+func (r *FragmentReader) Len() int {
+	return len(r.data)
+}
+
+func (r *FragmentReader) Read(p []byte) (int, error) {
+
+	if 0 == len(r.data) {
+		return 0, io.EOF
+	}
+
+	for 0 == r.left && len(r.sizes) > 0 {
+		r.left, r.sizes = r.sizes[0], r.sizes[1:]
+	}
+
+	// trailing bytes that are not covered by the sizes.
+	if 0 == r.left {
+		r.left = len(r.data)
+	}
+
+	n := copy(p[:minInt(len(p), r.left)], r.data)
+	r.data, r.left = r.data[n:], r.left-n
+	return n, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Normalize to copy the bytes of the decoded message and recycle it, so it can be compared after the next read.
+// The messages other than io.Reader, []byte, [][]byte and string are kept as it is.
+func Normalize(message netty.Message) netty.Message {
+	switch message.(type) {
+	case io.Reader, []byte, [][]byte, string:
+		data, err := utils.ToBytes(message)
+		utils.Assert(err)
+		data = append([]byte{}, data...)
+		utils.Release(message)
+		netty.Recycle(message)
+		return data
+	}
+	return message
+}
+
+// CheckDecode to decode the input by the handlers with every fragmentation, and compare the messages reached the tail with the wanted.
+func CheckDecode(tb testing.TB, handlers HandlersFunc, input []byte, want []netty.Message, option ...GoldenOption) {
+	tb.Helper()
+	if err := checkDecode(handlers, input, want, parseGoldenOptions(option...)); nil != err {
+		tb.Fatal(err)
+	}
+}
+
+// CheckEncode to encode the messages by the handlers with every variation of the flush boundaries, and compare the bytes reached the head with the wanted.
+// The messages are written for every variation, so they must be reusable, e.g. []byte or string rather than io.Reader.
+func CheckEncode(tb testing.TB, handlers HandlersFunc, messages []netty.Message, want []byte, option ...GoldenOption) {
+	tb.Helper()
+	if err := checkEncode(handlers, messages, want, parseGoldenOptions(option...)); nil != err {
+		tb.Fatal(err)
+	}
+}
+
+func checkDecode(handlers HandlersFunc, input []byte, want []netty.Message, options *goldenOptions) error {
+
+	for i := range want {
+		want[i] = Normalize(want[i])
+	}
+
+	for _, sizes := range options.fragmentations(len(input)) {
+		got, offsets, err := decode(handlers, input, sizes)
+		if nil == err {
+			err = compareMessages(got, want, offsets)
+		}
+		if nil != err {
+			return fmt.Errorf("decode fragmentation %v: %w (reproduce with nettytest.WithFragments)", sizes, err)
+		}
+	}
+
+	return nil
+}
+
+// decode to dispatch the reader until all bytes are consumed, like the read loop of channel.
+func decode(handlers HandlersFunc, input []byte, sizes []int) (got []netty.Message, offsets []int, err error) {
+
+	ec := NewEmbeddedChannel(handlers()...)
+	defer ec.FinishAndClose()
+
+	reader := NewFragmentReader(input, sizes...)
+	for len(reader.data) > 0 {
+
+		remaining := len(reader.data)
+		ec.WriteInbound(reader)
+
+		// the decoded message may read lazily from the reader, so it has to be consumed before the next read.
+		for message := ec.ReadInbound(); nil != message; message = ec.ReadInbound() {
+			got = append(got, Normalize(message))
+			offsets = append(offsets, len(input)-len(reader.data))
+		}
+
+		if ex := ec.CheckException(); nil != ex {
+			return got, offsets, fmt.Errorf("exception after %d messages at stream offset %d: %w", len(got), len(input)-len(reader.data), ex)
+		}
+
+		if remaining == len(reader.data) {
+			return got, offsets, fmt.Errorf("no bytes consumed at stream offset %d", len(input)-remaining)
+		}
+	}
+
+	return got, offsets, nil
+}
+
+func checkEncode(handlers HandlersFunc, messages []netty.Message, want []byte, options *goldenOptions) error {
+
+	for _, sizes := range options.fragmentations(len(messages)) {
+		got, err := encode(handlers, messages, sizes)
+		if nil == err {
+			err = compareBytes("stream", got, want)
+		}
+		if nil != err {
+			return fmt.Errorf("encode flush boundaries %v: %w (reproduce with nettytest.WithFragments)", sizes, err)
+		}
+	}
+
+	return nil
+}
+
+// encode to write the messages in groups, and to collect the bytes reached the head after every group.
+func encode(handlers HandlersFunc, messages []netty.Message, sizes []int) ([]byte, error) {
+
+	ec := NewEmbeddedChannel(handlers()...)
+	defer ec.FinishAndClose()
+
+	var stream []byte
+	for group, written := 0, 0; written < len(messages); group++ {
+
+		size := len(messages) - written
+		if group < len(sizes) && sizes[group] < size {
+			size = sizes[group]
+		}
+
+		ec.WriteOutbound(messages[written : written+size]...)
+		ec.Flush()
+		written += size
+
+		for message := ec.ReadOutbound(); nil != message; message = ec.ReadOutbound() {
+			data, err := utils.ToBytes(Normalize(message))
+			if nil != err {
+				return stream, fmt.Errorf("flush group #%d: %w", group, err)
+			}
+			stream = append(stream, data...)
+		}
+
+		if ex := ec.CheckException(); nil != ex {
+			return stream, fmt.Errorf("exception at flush group #%d after %d bytes: %w", group, len(stream), ex)
+		}
+	}
+
+	return stream, nil
+}
+
+// compareMessages to find the first divergence of the messages
+func compareMessages(got, want []netty.Message, offsets []int) error {
+
+	for i := 0; i < len(got) && i < len(want); i++ {
+
+		gotBytes, ok1 := got[i].([]byte)
+		wantBytes, ok2 := want[i].([]byte)
+
+		var err error
+		switch {
+		case ok1 && ok2:
+			err = compareBytes("message", gotBytes, wantBytes)
+		case !reflect.DeepEqual(got[i], want[i]):
+			err = fmt.Errorf("got %#v, want %#v", got[i], want[i])
+		}
+
+		if nil != err {
+			return fmt.Errorf("message #%d decoded at stream offset %d: %w", i, offsets[i], err)
+		}
+	}
+
+	if len(got) != len(want) {
+		return fmt.Errorf("got %d messages, want %d", len(got), len(want))
+	}
+
+	return nil
+}
+
+// compareBytes to find the first divergent byte
+func compareBytes(what string, got, want []byte) error {
+
+	if bytes.Equal(got, want) {
+		return nil
+	}
+
+	var offset int
+	for offset < len(got) && offset < len(want) && got[offset] == want[offset] {
+		offset++
+	}
+
+	around := func(b []byte) []byte {
+		from := offset - 8
+		if from < 0 {
+			from = 0
+		}
+		return b[from:minInt(len(b), offset+8)]
+	}
+
+	return fmt.Errorf("%s diverges at byte %d (got %d bytes, want %d): got %q, want %q",
+		what, offset, len(got), len(want), around(got), around(want))
+}
+
+// ReadGolden to read the golden file from testdata directory
+func ReadGolden(tb testing.TB, name string) []byte {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if nil != err {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+// ReadGoldenMessages to read the messages from the golden file in testdata directory.
+//
+// Every line is a message written as a double-quoted go string literal, the empty lines and
+// the lines start with # are ignored.
+func ReadGoldenMessages(tb testing.TB, name string) []netty.Message {
+	tb.Helper()
+	messages, err := parseGoldenMessages(ReadGolden(tb, name))
+	if nil != err {
+		tb.Fatalf("%s: %v", name, err)
+	}
+	return messages
+}
+
+func parseGoldenMessages(data []byte) ([]netty.Message, error) {
+
+	var messages []netty.Message
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if "" == text || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		message, err := strconv.Unquote(text)
+		if nil != err {
+			return nil, fmt.Errorf("line %d: malformed message %s: %w", line, text, err)
+		}
+		messages = append(messages, []byte(message))
+	}
+
+	return messages, scanner.Err()
+}
+
+// CheckDecodeGolden to decode testdata/<name>.bin and compare with the messages of testdata/<name>.golden
+func CheckDecodeGolden(tb testing.TB, name string, handlers HandlersFunc, option ...GoldenOption) {
+	tb.Helper()
+	CheckDecode(tb, handlers, ReadGolden(tb, name+".bin"), ReadGoldenMessages(tb, name+".golden"), option...)
+}
+
+// CheckEncodeGolden to encode the messages of testdata/<name>.golden and compare with testdata/<name>.bin
+func CheckEncodeGolden(tb testing.TB, name string, handlers HandlersFunc, option ...GoldenOption) {
+	tb.Helper()
+	CheckEncode(tb, handlers, ReadGoldenMessages(tb, name+".golden"), ReadGolden(tb, name+".bin"), option...)
+}
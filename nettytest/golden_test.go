@@ -0,0 +1,141 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettytest
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// fixedCodec decodes the frames of 4 bytes, the sloppy one assumes that a read returns the whole frame.
+type fixedCodec struct {
+	sloppy bool
+}
+
+func (c fixedCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
+	frame := make([]byte, 4)
+	if c.sloppy {
+		n, err := utils.MustToReader(message).Read(frame)
+		utils.Assert(err)
+		frame = frame[:n]
+	} else if _, err := io.ReadFull(utils.MustToReader(message), frame); nil != err {
+		panic(err)
+	}
+	ctx.HandleRead(frame)
+}
+
+func (c fixedCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
+	frame := utils.MustToBytes(message)
+	if c.sloppy && len(frame) > 4 {
+		frame = frame[:4]
+	}
+	ctx.HandleWrite(frame)
+}
+
+func fixedHandlers(sloppy bool) HandlersFunc {
+	return func() []netty.Handler {
+		return []netty.Handler{fixedCodec{sloppy: sloppy}}
+	}
+}
+
+func TestGoldenFragmentations(t *testing.T) {
+
+	options := parseGoldenOptions(WithMaxCuts(2), WithRandomSplits(10, 7))
+	fragmentations := options.fragmentations(6)
+
+	// whole + one byte per fragment + C(5,1) + C(5,2) + random
+	if 2+5+10+10 != len(fragmentations) {
+		t.Fatal("unexpected fragmentations:", len(fragmentations))
+	}
+
+	for _, sizes := range fragmentations {
+		var n int
+		for _, size := range sizes {
+			if size <= 0 {
+				t.Fatal("empty fragment:", sizes)
+			}
+			n += size
+		}
+		if 6 != n {
+			t.Fatal("unexpected fragmentation:", sizes)
+		}
+	}
+
+	if budget := parseGoldenOptions(WithSplitBudget(5), WithRandomSplits(0, 0)).fragmentations(100); 5 != len(budget) {
+		t.Fatal("the budget is exceeded:", len(budget))
+	}
+
+	if only := parseGoldenOptions(WithFragments(1, 5)).fragmentations(6); 1 != len(only) || 2 != len(only[0]) {
+		t.Fatal("unexpected fragmentations:", only)
+	}
+}
+
+func TestCheckDecode(t *testing.T) {
+
+	input := []byte("abcdefghijkl")
+	want := []netty.Message{"abcd", "efgh", "ijkl"}
+
+	CheckDecode(t, fixedHandlers(false), input, want)
+
+	err := checkDecode(fixedHandlers(true), input, want, parseGoldenOptions())
+	if nil == err || !strings.HasPrefix(err.Error(), "decode fragmentation [") {
+		t.Fatal("the sloppy codec must be detected:", err)
+	}
+
+	// the first failed fragmentation is the byte per fragment.
+	if want := "decode fragmentation [1 1 1 1 1 1 1 1 1 1 1 1]: message #0 decoded at stream offset 1: message diverges at byte 1"; !strings.HasPrefix(err.Error(), want) {
+		t.Fatal("unexpected report:", err)
+	}
+
+	err = checkDecode(fixedHandlers(true), input, want, parseGoldenOptions(WithFragments(4, 3, 5)))
+	if nil == err || !strings.Contains(err.Error(), "message #1 decoded at stream offset 7") {
+		t.Fatal("unexpected report:", err)
+	}
+
+	// the partial frame at the end of stream.
+	err = checkDecode(fixedHandlers(false), input[:10], want, parseGoldenOptions())
+	if nil == err || !strings.Contains(err.Error(), "exception after 2 messages at stream offset 10") {
+		t.Fatal("unexpected report:", err)
+	}
+}
+
+func TestCheckEncode(t *testing.T) {
+
+	messages := []netty.Message{"abcd", []byte("efgh"), "ijkl"}
+	CheckEncode(t, fixedHandlers(false), messages, []byte("abcdefghijkl"))
+
+	err := checkEncode(fixedHandlers(true), []netty.Message{"abcd", "efghi"}, []byte("abcdefghi"), parseGoldenOptions())
+	if nil == err || !strings.HasPrefix(err.Error(), "encode flush boundaries [2]: stream diverges at byte 8 (got 8 bytes, want 9)") {
+		t.Fatal("unexpected report:", err)
+	}
+}
+
+func TestReadGoldenMessages(t *testing.T) {
+
+	messages, err := parseGoldenMessages([]byte("# comment\n\n\"hello\"\n\"\\x00\\x01\\r\\n\"\n"))
+	if nil != err || 2 != len(messages) || "hello" != string(messages[0].([]byte)) || "\x00\x01\r\n" != string(messages[1].([]byte)) {
+		t.Fatal("unexpected messages:", messages, err)
+	}
+
+	if _, err := parseGoldenMessages([]byte("hello\n")); nil == err || !strings.Contains(err.Error(), "line 1") {
+		t.Fatal("unexpected error:", err)
+	}
+}
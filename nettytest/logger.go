@@ -0,0 +1,143 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettytest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// LogEntry defines an entry recorded by LogRecorder
+type LogEntry struct {
+	Level   utils.LogLevel
+	Message string
+	Fields  []interface{} // the key-value fields attached by With
+}
+
+// String returns the entry formatted as: [WARN] message key=value
+func (e LogEntry) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[%s] %s", e.Level, e.Message)
+	for i := 0; i+1 < len(e.Fields); i += 2 {
+		fmt.Fprintf(&builder, " %v=%v", e.Fields[i], e.Fields[i+1])
+	}
+	return builder.String()
+}
+
+// LogRecorder defines a netty.Logger that records the entries for assertions, it is safe for concurrent use.
+type LogRecorder struct {
+	store  *logStore
+	fields []interface{}
+}
+
+// logStore defines the entries shared by the recorders derived by With
+type logStore struct {
+	mutex   sync.Mutex
+	entries []LogEntry
+	notify  chan struct{}
+}
+
+// NewLogRecorder create an empty LogRecorder
+func NewLogRecorder() *LogRecorder {
+	return &LogRecorder{store: &logStore{notify: make(chan struct{})}}
+}
+
+func (r *LogRecorder) Debugf(format string, args ...interface{}) {
+	r.record(utils.LogDebug, format, args)
+}
+
+func (r *LogRecorder) Infof(format string, args ...interface{}) {
+	r.record(utils.LogInfo, format, args)
+}
+
+func (r *LogRecorder) Warnf(format string, args ...interface{}) {
+	r.record(utils.LogWarn, format, args)
+}
+
+func (r *LogRecorder) Errorf(format string, args ...interface{}) {
+	r.record(utils.LogError, format, args)
+}
+
+// With returns a recorder that attaches the fields to the entries recorded in the same LogRecorder
+func (r *LogRecorder) With(fields ...interface{}) netty.Logger {
+	return &LogRecorder{store: r.store, fields: append(append([]interface{}(nil), r.fields...), fields...)}
+}
+
+func (r *LogRecorder) record(level utils.LogLevel, format string, args []interface{}) {
+	s := r.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries = append(s.entries, LogEntry{Level: level, Message: fmt.Sprintf(format, args...), Fields: r.fields})
+	// wakeup the waiters.
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// Entries returns a copy of the recorded entries in order
+func (r *LogRecorder) Entries() []LogEntry {
+	s := r.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]LogEntry(nil), s.entries...)
+}
+
+// Find returns the first entry of the level that contains the substring
+func (r *LogRecorder) Find(level utils.LogLevel, substr string) (LogEntry, bool) {
+	for _, entry := range r.Entries() {
+		if level == entry.Level && strings.Contains(entry.Message, substr) {
+			return entry, true
+		}
+	}
+	return LogEntry{}, false
+}
+
+// Wait for the entry of the level that contains the substring, the entries logged by other goroutines are waited until timeout.
+func (r *LogRecorder) Wait(level utils.LogLevel, substr string, timeout time.Duration) (LogEntry, bool) {
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		r.store.mutex.Lock()
+		notify := r.store.notify
+		r.store.mutex.Unlock()
+
+		if entry, ok := r.Find(level, substr); ok {
+			return entry, true
+		}
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return LogEntry{}, false
+		}
+	}
+}
+
+// Reset to discard the recorded entries
+func (r *LogRecorder) Reset() {
+	s := r.store
+	s.mutex.Lock()
+	s.entries = nil
+	s.mutex.Unlock()
+}
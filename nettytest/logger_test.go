@@ -0,0 +1,186 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nettytest
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/codec/format"
+	"github.com/go-netty/go-netty/codec/frame"
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// temporaryError defines a temporary net.Error, e.g: too many open files.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// pipeTransport defines a transport over net.Pipe
+type pipeTransport struct {
+	net.Conn
+}
+
+func (t *pipeTransport) Writev(buffs transport.Buffers) (int64, error) {
+	return buffs.Buffers.WriteTo(t.Conn)
+}
+
+func (t *pipeTransport) Flush() error              { return nil }
+func (t *pipeTransport) RawTransport() interface{} { return t.Conn }
+
+// scriptedFactory defines a transport factory which acceptor returns the scripted results in order,
+// and blocks until it is closed when the results are exhausted.
+type scriptedFactory struct {
+	results []interface{} // error or transport.Transport
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (f *scriptedFactory) Schemes() transport.Schemes {
+	return transport.Schemes{"tcp"}
+}
+
+func (f *scriptedFactory) Connect(options *transport.Options) (transport.Transport, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *scriptedFactory) Listen(options *transport.Options) (transport.Acceptor, error) {
+	return f, nil
+}
+
+func (f *scriptedFactory) Accept() (transport.Transport, error) {
+	if len(f.results) > 0 {
+		result := f.results[0]
+		f.results = f.results[1:]
+		if err, ok := result.(error); ok {
+			return nil, err
+		}
+		return result.(transport.Transport), nil
+	}
+	<-f.closed
+	return nil, errors.New("use of closed network connection")
+}
+
+func (f *scriptedFactory) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+func TestLogRecorder(t *testing.T) {
+
+	recorder := NewLogRecorder()
+	recorder.Infof("listening on %s", "tcp://0.0.0.0:9527")
+	utils.LoggerWith(recorder, "channel", 1).Warnf("dropped")
+
+	entries := recorder.Entries()
+	if 2 != len(entries) || "[INFO] listening on tcp://0.0.0.0:9527" != entries[0].String() || "[WARN] dropped channel=1" != entries[1].String() {
+		t.Fatal("unexpected entries:", entries)
+	}
+
+	if _, ok := recorder.Find(utils.LogInfo, "dropped"); ok {
+		t.Fatal("the level of entry is ignored")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		recorder.Errorf("callback panic")
+	}()
+
+	if _, ok := recorder.Wait(utils.LogError, "panic", time.Second); !ok {
+		t.Fatal("the entry of other goroutine is lost")
+	}
+
+	recorder.Reset()
+	if 0 != len(recorder.Entries()) {
+		t.Fatal("the entries are not discarded")
+	}
+}
+
+func TestBootstrapLogger(t *testing.T) {
+
+	local, peer := net.Pipe()
+	defer peer.Close()
+
+	factory := &scriptedFactory{
+		results: []interface{}{temporaryError{}, temporaryError{}, &pipeTransport{Conn: local}},
+		closed:  make(chan struct{}),
+	}
+
+	recorder := NewLogRecorder()
+	bs := netty.NewBootstrap(
+		netty.WithLogger(recorder),
+		netty.WithTransport(factory),
+		netty.WithChildInitializer(func(channel netty.Channel) {
+			// nobody handles the decoded strings.
+			channel.Pipeline().AddLast(frame.DelimiterCodec(1024, "$", true), format.TextCodec())
+		}),
+	)
+
+	listened := make(chan error, 1)
+	bs.Listen("tcp://127.0.0.1:9527").Async(func(err error) { listened <- err })
+
+	if _, err := peer.Write([]byte("hello$world$")); nil != err {
+		t.Fatal(err)
+	}
+
+	dropped, ok := recorder.Wait(utils.LogWarn, "unhandled message(string)", 3*time.Second)
+	if !ok {
+		t.Fatal("the unhandled message is not logged:", recorder.Entries())
+	}
+
+	bs.Shutdown()
+	<-listened
+
+	var retries []LogEntry
+	for _, entry := range recorder.Entries() {
+		if utils.LogWarn == entry.Level && strings.HasPrefix(entry.Message, "accept error") {
+			retries = append(retries, entry)
+		}
+	}
+
+	if 2 != len(retries) || "accept error: too many open files, retrying in 5ms" != retries[0].Message || "accept error: too many open files, retrying in 10ms" != retries[1].Message {
+		t.Fatal("unexpected accept retries:", retries)
+	}
+
+	if "[WARN] accept error: too many open files, retrying in 5ms listener=tcp://127.0.0.1:9527" != retries[0].String() {
+		t.Fatal("the url of listener is not attached:", retries[0])
+	}
+
+	// only the first dropped message of channel is logged.
+	var drops int
+	for _, entry := range recorder.Entries() {
+		if entry.Message == dropped.Message {
+			drops++
+		}
+	}
+
+	if 1 != drops {
+		t.Fatal("unexpected dropped messages:", drops)
+	}
+
+	if _, ok := recorder.Find(utils.LogInfo, "the bootstrap has been shut down"); !ok {
+		t.Fatal("the shutdown is not logged:", recorder.Entries())
+	}
+}
@@ -18,9 +18,19 @@ package netty
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
 )
 
 type (
@@ -34,6 +44,20 @@ type (
 	TransportFactory transport.Factory
 	// ChannelIDFactory to create channel id
 	ChannelIDFactory func() int64
+	// ChannelNameFactory to create the name of channel from the channel id, the name is used in the logs & exceptions
+	ChannelNameFactory func(id int64) string
+	// Clock defines the source of time for timers & time-based handlers
+	Clock = utils.Clock
+	// Logger defines the leveled logger of the internal components
+	Logger = utils.Logger
+	// PipelineInterceptor to wrap the handler invocations of pipeline, op is the name of handler method, e.g: HandleRead,
+	// calling next() once continues the invocation, not calling it stops the propagation.
+	PipelineInterceptor func(ctx HandlerContext, op string, next func())
+	// AcceptFilter to check the accepted transport before the channel is created, the transport is closed if
+	// an error is returned, e.g: to reject the banned addresses.
+	AcceptFilter func(t transport.Transport) error
+	// UnhandledMessageHandler to handle the messages & events that reached at the tail of pipeline
+	UnhandledMessageHandler func(channel Channel, message Message)
 
 	// bootstrapOptions
 	bootstrapOptions struct {
@@ -42,9 +66,30 @@ type (
 		clientInitializer ChannelInitializer
 		childInitializer  ChannelInitializer
 		transportFactory  TransportFactory
+		transports        map[string]TransportFactory
 		channelFactory    ChannelFactory
 		pipelineFactory   PipelineFactory
 		channelIDFactory  ChannelIDFactory
+		channelName       ChannelNameFactory
+		timerWheel        *utils.TimerWheel
+		clock             Clock
+		logger            Logger
+		unhandledMessage  UnhandledMessageHandler
+		recoverPolicy     RecoverPolicy
+		interceptor       PipelineInterceptor
+		channelOptions    []ChannelOption
+		noRegistry        bool
+		channelCreated    func(channel Channel)
+		channelActive     func(channel Channel)
+		channelInactive   func(channel Channel, err error)
+		childAttachment   func(t transport.Transport) Attachment
+		childTransport    func(t transport.Transport) transport.Transport
+		acceptFilter      AcceptFilter
+		acceptRejected    func(t transport.Transport, err error)
+		handshakeTimeout  time.Duration
+
+		maxConnections     int64
+		connectionOverflow ConnectionOverflowPolicy
 	}
 )
 
@@ -56,6 +101,47 @@ func SequenceID() ChannelIDFactory {
 	}
 }
 
+// NodeSequenceName to name the channels with the node prefix and the channel id, e.g: node-7:000123
+func NodeSequenceName(node string) ChannelNameFactory {
+	return func(id int64) string {
+		return nodeName(node, fmt.Sprintf("%06d", id))
+	}
+}
+
+// NodeRandomName to name the channels with the node prefix and a random hex, e.g: node-7:9f86d081884c7d65,
+// so the names are unique across the processes without coordination.
+func NodeRandomName(node string) ChannelNameFactory {
+	return func(id int64) string {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); nil != err {
+			// fallback to the sequence if the random source is broken.
+			return nodeName(node, fmt.Sprintf("%06d", id))
+		}
+		return nodeName(node, hex.EncodeToString(b[:]))
+	}
+}
+
+// nodeName to prefix the name with the node if not empty
+func nodeName(node, name string) string {
+	if "" == node {
+		return name
+	}
+	return node + ":" + name
+}
+
+// channelNameKey is the context key of ChannelNameFactory
+type channelNameKey struct{}
+
+// channelNameFrom returns the ChannelNameFactory of context, the decimal channel id by default.
+func channelNameFrom(ctx context.Context) ChannelNameFactory {
+	if factory, ok := ctx.Value(channelNameKey{}).(ChannelNameFactory); ok {
+		return factory
+	}
+	return func(id int64) string {
+		return strconv.FormatInt(id, 10)
+	}
+}
+
 type Option func(options *bootstrapOptions)
 
 // WithContext fork child context with context.WithCancel
@@ -72,6 +158,13 @@ func WithChannelID(channelIDFactory ChannelIDFactory) Option {
 	}
 }
 
+// WithChannelName to set ChannelNameFactory, e.g: NodeSequenceName, NodeRandomName
+func WithChannelName(channelNameFactory ChannelNameFactory) Option {
+	return func(options *bootstrapOptions) {
+		options.channelName = channelNameFactory
+	}
+}
+
 // WithPipeline to set PipelineFactory
 func WithPipeline(pipelineFactory PipelineFactory) Option {
 	return func(options *bootstrapOptions) {
@@ -79,6 +172,14 @@ func WithPipeline(pipelineFactory PipelineFactory) Option {
 	}
 }
 
+// WithPipelineTemplate to stamp out the pipelines from a template, see NewPipelineTemplate.
+// the initializers are optional, they are still called after stamping if set.
+func WithPipelineTemplate(handlers ...Handler) Option {
+	return func(options *bootstrapOptions) {
+		options.pipelineFactory = NewPipelineTemplate(handlers...)
+	}
+}
+
 // WithChannel to set ChannelFactory
 func WithChannel(channelFactory ChannelFactory) Option {
 	return func(options *bootstrapOptions) {
@@ -93,6 +194,23 @@ func WithTransport(transportFactory TransportFactory) Option {
 	}
 }
 
+// WithTransports to register the factories by their schemes, Connect & Listen pick the factory by the scheme of url,
+// the url without scheme or with an unregistered scheme falls back to the factory of WithTransport.
+func WithTransports(factories ...transport.Factory) Option {
+	return func(options *bootstrapOptions) {
+		if nil == options.transports {
+			options.transports = make(map[string]TransportFactory)
+		}
+		for _, factory := range factories {
+			for _, scheme := range factory.Schemes() {
+				registered, ok := options.transports[scheme]
+				utils.AssertIf(ok && registered != factory, "conflicting transport factories of scheme: %s", scheme)
+				options.transports[scheme] = factory
+			}
+		}
+	}
+}
+
 // WithChildInitializer to set server side ChannelInitializer
 func WithChildInitializer(initializer ChannelInitializer) Option {
 	return func(options *bootstrapOptions) {
@@ -100,9 +218,338 @@ func WithChildInitializer(initializer ChannelInitializer) Option {
 	}
 }
 
+// WithChildAttachment to create the attachment of the accepted channels, the attachment is set before the
+// child initializer, so it is visible to the first HandleActive.
+func WithChildAttachment(factory func(t transport.Transport) Attachment) Option {
+	return func(options *bootstrapOptions) {
+		options.childAttachment = factory
+	}
+}
+
+// WithChildTransport to wrap the accepted transports before the channels are created,
+// e.g: limit the bandwidth of every accepted connection by transport.Throttle.
+func WithChildTransport(wrapper func(t transport.Transport) transport.Transport) Option {
+	return func(options *bootstrapOptions) {
+		options.childTransport = wrapper
+	}
+}
+
 // WithClientInitializer to set client side ChannelInitializer
 func WithClientInitializer(initializer ChannelInitializer) Option {
 	return func(options *bootstrapOptions) {
 		options.clientInitializer = initializer
 	}
 }
+
+// WithFlushPolicy to delay the flushing of channels for bigger batches, disabled by default.
+//
+// The pending bytes are flushed when maxDelay elapsed, or maxBytes / maxMessages are reached,
+// zero limit means unlimited. Channel.Flush & Channel.WriteAndFlush will bypass the delay.
+func WithFlushPolicy(maxDelay time.Duration, maxBytes int, maxMessages int) Option {
+	return func(options *bootstrapOptions) {
+		options.channelOptions = append(options.channelOptions, WithChannelFlushPolicy(maxDelay, maxBytes, maxMessages))
+	}
+}
+
+// WithWriteBatching to coalesce the small messages of channels into one Writev for up to maxDelay or maxBytes,
+// it is a shortcut of WithFlushPolicy(maxDelay, maxBytes, 0).
+func WithWriteBatching(maxBytes int, maxDelay time.Duration) Option {
+	return WithFlushPolicy(maxDelay, maxBytes, 0)
+}
+
+// WithWriteQueueSize to set the capacity of send queue of channels, it overrides the capacity of ChannelFactory.
+func WithWriteQueueSize(size int) Option {
+	return func(options *bootstrapOptions) {
+		options.channelOptions = append(options.channelOptions, WithChannelWriteQueueSize(size))
+	}
+}
+
+// WithWriteOverflowPolicy to set the policy of writing to a full send queue of channels, WriteOverflowBlock by default.
+func WithWriteOverflowPolicy(policy WriteOverflowPolicy) Option {
+	return func(options *bootstrapOptions) {
+		options.channelOptions = append(options.channelOptions, WithChannelWriteOverflowPolicy(policy))
+	}
+}
+
+// WithMaxPendingWriteBytes to limit the bytes queued to be written of channels, the overflow policy is applied
+// when the limit is exceeded, zero means unlimited.
+func WithMaxPendingWriteBytes(n int) Option {
+	return func(options *bootstrapOptions) {
+		options.channelOptions = append(options.channelOptions, WithChannelMaxPendingWriteBytes(n))
+	}
+}
+
+// WithQueueSaturation to fire QueueSaturationEvent when the send queue of channels is filled up to the high ratio
+// of capacity, and again when it drops to the low ratio, e.g: WithQueueSaturation(0.8, 0.5), disabled by default.
+func WithQueueSaturation(high, low float64) Option {
+	return func(options *bootstrapOptions) {
+		options.channelOptions = append(options.channelOptions, WithChannelQueueSaturation(high, low))
+	}
+}
+
+// WithWriteWatermark to fire WritabilityChangedEvent when the queued bytes of channels cross the watermarks,
+// the channel becomes unwritable at the high watermark and writable again when dropped to the low watermark.
+func WithWriteWatermark(low, high int) Option {
+	return func(options *bootstrapOptions) {
+		options.channelOptions = append(options.channelOptions, WithChannelWriteWatermark(low, high))
+	}
+}
+
+// WithTimerWheel to share the TimerWheel with channels, the bootstrap will create one if not set.
+func WithTimerWheel(wheel *utils.TimerWheel) Option {
+	return func(options *bootstrapOptions) {
+		options.timerWheel = wheel
+	}
+}
+
+// timerWheelKey is the context key of TimerWheel
+type timerWheelKey struct{}
+
+// TimerWheelFrom to get the TimerWheel of bootstrap from the context of channel,
+// the process-wide TimerWheel will be returned if the context does not carry one.
+func TimerWheelFrom(ctx context.Context) *utils.TimerWheel {
+	if wheel, ok := ctx.Value(timerWheelKey{}).(*utils.TimerWheel); ok {
+		return wheel
+	}
+	return utils.DefaultTimerWheel()
+}
+
+// WithClock to set the Clock of channels and the TimerWheel created by bootstrap, default is the real clock.
+// The TimerWheel set by WithTimerWheel should be driven by the same clock.
+func WithClock(clock Clock) Option {
+	return func(options *bootstrapOptions) {
+		options.clock = clock
+	}
+}
+
+// clockKey is the context key of Clock
+type clockKey struct{}
+
+// ClockFrom to get the Clock of bootstrap from the context of channel,
+// the real clock will be returned if the context does not carry one.
+func ClockFrom(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return utils.RealClock()
+}
+
+// ContextWithClock returns a copy of ctx that carries the clock and a TimerWheel driven by it,
+// the channels created with the context will use them like the channels created by bootstrap.
+func ContextWithClock(ctx context.Context, clock Clock) context.Context {
+	ctx = context.WithValue(ctx, timerWheelKey{}, utils.NewTimerWheel(utils.WithTimerClock(clock)))
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// WithLogger to set the Logger of listeners, channels, handlers and the TimerWheel created by bootstrap,
+// default is the utils.DefaultLogger.
+func WithLogger(logger Logger) Option {
+	return func(options *bootstrapOptions) {
+		options.logger = logger
+	}
+}
+
+// LoggerFrom to get the Logger of bootstrap from the context of channel,
+// the utils.DefaultLogger will be returned if the context does not carry one.
+func LoggerFrom(ctx context.Context) Logger {
+	return utils.LoggerFrom(ctx)
+}
+
+// ContextWithLogger returns a copy of ctx that carries the logger, the channels created with the context
+// will log through it like the channels created by bootstrap, and so do the transports, see utils.LoggerFrom.
+func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return utils.ContextWithLogger(ctx, logger)
+}
+
+// WithUnhandledMessageHandler to handle the messages & events that nobody consumed, the messages are released after handled.
+// default logs a warning for the first unhandled message and the first unhandled event of channel.
+func WithUnhandledMessageHandler(handler UnhandledMessageHandler) Option {
+	return func(options *bootstrapOptions) {
+		options.unhandledMessage = handler
+	}
+}
+
+// WithRecoverPolicy to decide the action of the panics recovered from the handlers, see RecoverPolicy.
+// default routes the panics to Pipeline.FireChannelException.
+func WithRecoverPolicy(policy RecoverPolicy) Option {
+	return func(options *bootstrapOptions) {
+		options.recoverPolicy = policy
+	}
+}
+
+// unhandledMessageKey is the context key of UnhandledMessageHandler
+type unhandledMessageKey struct{}
+
+// unhandledMessageFrom to get the UnhandledMessageHandler of bootstrap from the context of channel.
+func unhandledMessageFrom(ctx context.Context) UnhandledMessageHandler {
+	handler, _ := ctx.Value(unhandledMessageKey{}).(UnhandledMessageHandler)
+	return handler
+}
+
+// WithMaxConnections to limit the accepted channels of the listeners, the policy is applied to the transports
+// accepted beyond the limit, zero means unlimited, see Bootstrap.ConnectionStats.
+func WithMaxConnections(n int, policy ConnectionOverflowPolicy) Option {
+	return func(options *bootstrapOptions) {
+		utils.AssertIf(n < 0, "n must be a non-negative integer")
+		utils.AssertIf(policy < ConnectionOverflowClose || policy > ConnectionOverflowPause, "unknown overflow policy: %d", policy)
+		options.maxConnections, options.connectionOverflow = int64(n), policy
+	}
+}
+
+// WithAcceptFilter to filter the accepted transports of the listeners before the channels are created,
+// the rejected transports are counted by ConnectionStats.Filtered, see WithAcceptRejected.
+func WithAcceptFilter(filter AcceptFilter) Option {
+	return func(options *bootstrapOptions) {
+		options.acceptFilter = filter
+	}
+}
+
+// WithAcceptRejected to be called with the transport rejected by the AcceptFilter and the error of filter,
+// after the transport is closed, the rejections are logged in debug level by default.
+func WithAcceptRejected(fn func(t transport.Transport, err error)) Option {
+	return func(options *bootstrapOptions) {
+		options.acceptRejected = fn
+	}
+}
+
+// WithHandshakeTimeout to close the accepted channels that received no data within the timeout with HandshakeTimeoutError,
+// it is fired to the pipeline before the channel is closed, zero means no timeout.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(options *bootstrapOptions) {
+		utils.AssertIf(timeout < 0, "timeout must be a non-negative duration")
+		options.handshakeTimeout = timeout
+	}
+}
+
+// WithoutChannelRegistry to skip tracking the active channels, so ActiveChannels, RangeChannels, DebugSnapshot
+// and ShutdownGracefully will see no channels.
+func WithoutChannelRegistry() Option {
+	return func(options *bootstrapOptions) {
+		options.noRegistry = true
+	}
+}
+
+// WithChannelCreated to be called with every child & client channel after it is created, before the initializer.
+// The lifecycle hooks are called by the accept loop & Connect, so they should be fast and never block.
+func WithChannelCreated(fn func(channel Channel)) Option {
+	return func(options *bootstrapOptions) {
+		options.channelCreated = fn
+	}
+}
+
+// WithChannelActive to be called with every child & client channel after it is initialized, before it is served.
+func WithChannelActive(fn func(channel Channel)) Option {
+	return func(options *bootstrapOptions) {
+		options.channelActive = fn
+	}
+}
+
+// WithChannelInactive to be called with every created channel and the close error after it is closed,
+// even if the initializer panics.
+func WithChannelInactive(fn func(channel Channel, err error)) Option {
+	return func(options *bootstrapOptions) {
+		options.channelInactive = fn
+	}
+}
+
+// WithPipelineInterceptor to wrap the handler invocations of the pipelines, e.g: to measure the latency of handlers,
+// there is no overhead if it is not set.
+func WithPipelineInterceptor(interceptor PipelineInterceptor) Option {
+	return func(options *bootstrapOptions) {
+		options.interceptor = interceptor
+	}
+}
+
+// pipelineInterceptorKey is the context key of PipelineInterceptor
+type pipelineInterceptorKey struct{}
+
+// pipelineInterceptorFrom to get the PipelineInterceptor of bootstrap from the context of channel.
+func pipelineInterceptorFrom(ctx context.Context) PipelineInterceptor {
+	interceptor, _ := ctx.Value(pipelineInterceptorKey{}).(PipelineInterceptor)
+	return interceptor
+}
+
+// BootstrapErrors defines the problems of the bootstrap options reported by NewBootstrapE
+type BootstrapErrors []error
+
+// Error to impl error
+func (es BootstrapErrors) Error() string {
+	messages := make([]string, 0, len(es))
+	for _, e := range es {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap to get the first error
+func (es BootstrapErrors) Unwrap() error {
+	if 0 == len(es) {
+		return nil
+	}
+	return es[0]
+}
+
+// applyOption to apply the option and convert the assertion panic to error, the channel options appended by
+// the option are checked as well, since they panic at the first channel otherwise.
+func applyOption(options *bootstrapOptions, option Option) (err error) {
+	defer func() {
+		if v := recover(); nil != v {
+			err = asError(v)
+		}
+	}()
+
+	appended := len(options.channelOptions)
+	option(options)
+	for _, channelOption := range options.channelOptions[appended:] {
+		channelOption(&channelOptions{newQueue: newMPSCQueue})
+	}
+	return nil
+}
+
+// asError to convert the recovered value to error
+func asError(v interface{}) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", v)
+}
+
+// bootstrapOptionName to get the name of option function, e.g: netty.WithMaxConnections
+func bootstrapOptionName(option Option) string {
+
+	fn := runtime.FuncForPC(reflect.ValueOf(option).Pointer())
+	if nil == fn {
+		return "unknown"
+	}
+
+	// github.com/go-netty/go-netty.WithMaxConnections.func1
+	name := fn.Name()
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		name = name[index+1:]
+	}
+	if index := strings.Index(name, ".func"); index > 0 {
+		name = name[:index]
+	}
+	return strings.Replace(name, "go-netty.", "netty.", 1)
+}
+
+// validate to check the required components of bootstrap
+func (options *bootstrapOptions) validate() (errs BootstrapErrors) {
+
+	required := []struct {
+		missing bool
+		message string
+	}{
+		{nil == options.channelIDFactory, "the channel id factory must not be nil, see WithChannelID"},
+		{nil == options.pipelineFactory, "the pipeline factory must not be nil, see WithPipeline"},
+		{nil == options.channelFactory, "the channel factory must not be nil, see WithChannel"},
+		{nil == options.transportFactory, "the transport factory must not be nil, see WithTransport"},
+		{nil == options.clock, "the clock must not be nil, see WithClock"},
+	}
+	for _, r := range required {
+		if r.missing {
+			errs = append(errs, errors.New(r.message))
+		}
+	}
+	return
+}
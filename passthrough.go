@@ -0,0 +1,174 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// relayBufferSize the max bytes relayed per read
+const relayBufferSize = 64 << 10
+
+// PassthroughOption defines an option of passthrough mode
+type PassthroughOption func(mode *passthroughMode)
+
+// WithSplice to relay the bytes between the TCP connections without copying to user space,
+// splice(2) will be used on Linux. The bytes are relayed until EOF and the mode can not be exited,
+// and the target channel should not be written by others while splicing.
+func WithSplice() PassthroughOption {
+	return func(mode *passthroughMode) {
+		mode.splice = true
+	}
+}
+
+// passthroughMode defines the target of relaying
+type passthroughMode struct {
+	target Channel
+	splice bool
+}
+
+// releaseFunc defines a function as Releaser
+type releaseFunc func()
+
+func (f releaseFunc) Release() {
+	f()
+}
+
+// PassthroughMode to forward the bytes read from transport to the write queue of target channel directly,
+// both of the pipelines are bypassed. The nil target exits the passthrough mode.
+//
+// The mode takes effect when the current read of pipeline is returned, the bytes read by relaying
+// after exiting will be pushed back to the transport, so none of the bytes will be lost.
+func (c *channel) PassthroughMode(target Channel, option ...PassthroughOption) {
+
+	var mode *passthroughMode
+	if nil != target {
+		mode = &passthroughMode{target: target}
+		for i := range option {
+			option[i](mode)
+		}
+	}
+
+	c.passthrough.Store(mode)
+}
+
+// passthroughMode returns the current passthrough mode, nil if not in passthrough mode.
+func (c *channel) passthroughMode() *passthroughMode {
+	mode, _ := c.passthrough.Load().(*passthroughMode)
+	return mode
+}
+
+// invokeRelay to relay the bytes to the target channel until the passthrough mode is changed
+func (c *channel) invokeRelay(mode *passthroughMode) {
+	defer c.recoverException()
+
+	if mode.splice && c.spliceTo(mode.target) {
+		return
+	}
+
+	for 0 == atomic.LoadInt32(&c.closed) && mode == c.passthroughMode() {
+
+		buffer := utils.NewByteBuf(relayBufferSize)
+		n, err := buffer.WriteFromReader(c.transport, relayBufferSize)
+
+		switch {
+		case 0 == n:
+			buffer.Release()
+		case mode != c.passthroughMode():
+			// the mode has been changed while reading, the bytes belong to the pipeline.
+			if unreader, ok := c.transport.(utils.Unreader); ok {
+				unreader.Unread(buffer.Bytes())
+			}
+			buffer.Release()
+		default:
+			if !c.relayBuffer(mode.target, buffer) {
+				return
+			}
+		}
+
+		if nil != err {
+			panic(err)
+		}
+	}
+}
+
+// relayBuffer to queue the buffer to the target, the channel will be closed if the target has been closed.
+func (c *channel) relayBuffer(target Channel, buffer *utils.ByteBuf) bool {
+	if _, err := target.writeBuffer(buffer.Bytes(), buffer); nil != err {
+		buffer.Release()
+		c.Close(err)
+		return false
+	}
+	return true
+}
+
+// spliceTo to relay the bytes between the connections directly, returns false if the connections are not TCP.
+func (c *channel) spliceTo(target Channel) bool {
+
+	src, ok := c.transport.RawTransport().(*net.TCPConn)
+	if !ok {
+		return false
+	}
+
+	dst, ok := target.Transport().RawTransport().(*net.TCPConn)
+	if !ok {
+		return false
+	}
+
+	// the bytes buffered by transport can not be spliced.
+	for n := transport.Buffered(c.transport); n > 0; n = transport.Buffered(c.transport) {
+		buffer := utils.NewByteBuf(n)
+		if _, err := buffer.WriteFromReader(c.transport, n); nil != err {
+			buffer.Release()
+			panic(err)
+		}
+		if !c.relayBuffer(target, buffer) {
+			return true
+		}
+	}
+
+	// wait for the queued bytes of target to be written.
+	written := make(chan struct{})
+	if _, err := target.writeBuffer(nil, releaseFunc(func() { close(written) })); nil != err {
+		c.Close(err)
+		return true
+	}
+
+	select {
+	case <-written:
+	case <-c.ctx.Done():
+		return true
+	}
+
+	if !target.IsActive() {
+		c.Close(errors.New("broken pipe"))
+		return true
+	}
+
+	if _, err := dst.ReadFrom(src); nil != err {
+		panic(err)
+	}
+
+	// the source has been drained.
+	panic(io.EOF)
+}
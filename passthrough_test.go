@@ -0,0 +1,229 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// tcpPair create a connected pair of TCP connections
+func tcpPair(t testing.TB) (*net.TCPConn, *net.TCPConn) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	server := <-accepted
+	if nil == server {
+		t.Fatal("accept failed")
+	}
+	return server.(*net.TCPConn), client.(*net.TCPConn)
+}
+
+// newHandshakePipeline create a pipeline that reads the 5 bytes handshake and then switches to the passthrough mode
+func newHandshakePipeline(handshakes chan<- string, target func() Channel, option ...PassthroughOption) Pipeline {
+	return NewPipelineWith().AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		handshake := make([]byte, 5)
+		if _, err := io.ReadFull(message.(io.Reader), handshake); nil != err {
+			panic(err)
+		}
+		ctx.Channel().PassthroughMode(target(), option...)
+		handshakes <- string(handshake)
+	}), ignoreException)
+}
+
+// ignoreException to ignore the exceptions, the channel will be closed by fatal exceptions.
+var ignoreException = ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {})
+
+func readString(t *testing.T, r io.Reader, n int) string {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); nil != err {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestChannelPassthrough(t *testing.T) {
+
+	target, targetPeer := newPipeChannel(2, newDiscardPipeline())
+	target.serveChannel()
+	defer target.Close(nil)
+
+	handshakes := make(chan string, 2)
+	c, peer := newPipeChannel(1, newHandshakePipeline(handshakes, func() Channel { return target }))
+	c.serveChannel()
+	defer c.Close(nil)
+
+	go func() { _, _ = peer.Write([]byte("HELLO")) }()
+	if handshake := <-handshakes; "HELLO" != handshake {
+		t.Fatal("unexpected handshake:", handshake)
+	}
+
+	// the bytes are relayed to the peer of target.
+	go func() { _, _ = peer.Write([]byte("relayed")) }()
+	if data := readString(t, targetPeer, 7); "relayed" != data {
+		t.Fatal("unexpected data:", data)
+	}
+
+	// the bytes read by the relaying are returned to the pipeline after exiting.
+	c.PassthroughMode(nil)
+	go func() { _, _ = peer.Write([]byte("AGAIN")) }()
+	if handshake := <-handshakes; "AGAIN" != handshake {
+		t.Fatal("unexpected handshake:", handshake)
+	}
+}
+
+func TestChannelPassthroughTargetClosed(t *testing.T) {
+
+	target, _ := newPipeChannel(2, newDiscardPipeline())
+	target.Close(nil)
+
+	handshakes := make(chan string, 1)
+	c, peer := newPipeChannel(1, newHandshakePipeline(handshakes, func() Channel { return target }))
+	c.serveChannel()
+
+	go func() { _, _ = peer.Write([]byte("HELLOrelayed")) }()
+	<-handshakes
+
+	// the channel can not relay the bytes anymore.
+	<-c.Context().Done()
+}
+
+func TestChannelPassthroughSplice(t *testing.T) {
+
+	targetConn, targetPeer := tcpPair(t)
+	target := newTransportChannel(2, newDiscardPipeline(), &pipeTransport{Conn: targetConn})
+	target.serveChannel()
+	defer target.Close(nil)
+
+	conn, peer := tcpPair(t)
+	handshakes := make(chan string, 1)
+	p := newHandshakePipeline(handshakes, func() Channel { return target }, WithSplice())
+
+	// the bytes after handshake are buffered by the transport.
+	tran := transport.BufferedTransport(&pipeTransport{Conn: conn}, 64)
+	c := newTransportChannel(1, p, tran)
+
+	if _, err := peer.Write([]byte("HELLObuffered")); nil != err {
+		t.Fatal(err)
+	}
+
+	c.serveChannel()
+	if handshake := <-handshakes; "HELLO" != handshake {
+		t.Fatal("unexpected handshake:", handshake)
+	}
+
+	if data := readString(t, targetPeer, 8); "buffered" != data {
+		t.Fatal("unexpected data:", data)
+	}
+
+	if _, err := peer.Write([]byte("spliced")); nil != err {
+		t.Fatal(err)
+	}
+
+	if data := readString(t, targetPeer, 7); "spliced" != data {
+		t.Fatal("unexpected data:", data)
+	}
+
+	// the channel is closed after the source is drained.
+	_ = peer.CloseWrite()
+	<-c.Context().Done()
+}
+
+func BenchmarkProxy(b *testing.B) {
+
+	const streamSize = 64 << 10
+
+	for _, bc := range []struct {
+		name  string
+		proxy func(target Channel) InboundHandler
+	}{
+		{"pipeline", func(target Channel) InboundHandler {
+			return InboundHandlerFunc(func(ctx InboundContext, message Message) {
+				buffer := make([]byte, relayBufferSize)
+				n, err := message.(io.Reader).Read(buffer)
+				if n > 0 {
+					target.Write(buffer[:n])
+				}
+				if nil != err {
+					panic(err)
+				}
+			})
+		}},
+		{"passthrough", func(target Channel) InboundHandler {
+			return InboundHandlerFunc(func(ctx InboundContext, message Message) {
+				ctx.Channel().PassthroughMode(target)
+			})
+		}},
+		{"splice", func(target Channel) InboundHandler {
+			return InboundHandlerFunc(func(ctx InboundContext, message Message) {
+				ctx.Channel().PassthroughMode(target, WithSplice())
+			})
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+
+			targetConn, sink := tcpPair(b)
+			target := newTransportChannel(2, newDiscardPipeline(), &pipeTransport{Conn: targetConn})
+			target.serveChannel()
+			defer target.Close(nil)
+
+			conn, source := tcpPair(b)
+			c := newTransportChannel(1, NewPipelineWith().AddLast(bc.proxy(target), ignoreException), &pipeTransport{Conn: conn})
+			c.serveChannel()
+			defer c.Close(nil)
+
+			received := make(chan int64, 1)
+			go func() {
+				n, _ := io.CopyN(io.Discard, sink, int64(b.N)*streamSize)
+				received <- n
+			}()
+
+			stream := make([]byte, streamSize)
+
+			b.SetBytes(streamSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := source.Write(stream); nil != err {
+					b.Fatal(err)
+				}
+			}
+
+			if n := <-received; int64(b.N)*streamSize != n {
+				b.Fatal("unexpected bytes:", n)
+			}
+		})
+	}
+}
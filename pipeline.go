@@ -18,6 +18,11 @@ package netty
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/go-netty/go-netty/utils"
 )
@@ -31,9 +36,40 @@ type Pipeline interface {
 	// AddLast add a handler to the last.
 	AddLast(handlers ...Handler) Pipeline
 
-	// AddHandler add handlers in position.
+	// AddInboundLast add inbound handlers after the last inbound handler, they process the inbound messages in order.
+	AddInboundLast(handlers ...InboundHandler) Pipeline
+
+	// AddOutboundFirst add outbound handlers to the first, so every writing from the inbound handlers goes through them,
+	// they process the outbound messages in order, and process them after the existing outbound handlers.
+	AddOutboundFirst(handlers ...OutboundHandler) Pipeline
+
+	// AddHandler add handlers after the context in position, the head is 0, PositionLast to add to the last.
 	AddHandler(position int, handlers ...Handler) Pipeline
 
+	// InsertAt insert handlers at the index of user handlers, 0 is the first, UserSize() to add to the last.
+	InsertAt(index int, handlers ...Handler) Pipeline
+
+	// AddLastNamed add a handler with the name to the last.
+	AddLastNamed(name string, handler Handler) Pipeline
+
+	// AddBefore add a handler with the name before the handler of baseName.
+	AddBefore(baseName, name string, handler Handler) Pipeline
+
+	// AddAfter add a handler with the name after the handler of baseName.
+	AddAfter(baseName, name string, handler Handler) Pipeline
+
+	// Remove the first context of handler, returns false if the handler is not found.
+	Remove(handler Handler) bool
+
+	// RemoveAt remove the context in position, returns the removed handler.
+	RemoveAt(position int) Handler
+
+	// Replace the handler in position, returns the old handler.
+	Replace(position int, handler Handler) Handler
+
+	// ReplaceHandler replace the first context of old handler, returns false if the old handler is not found.
+	ReplaceHandler(old, handler Handler) bool
+
 	// IndexOf find fist index of handler.
 	IndexOf(func(Handler) bool) int
 
@@ -43,12 +79,27 @@ type Pipeline interface {
 	// ContextAt get context by position.
 	ContextAt(position int) HandlerContext
 
-	// Size of handler
+	// ContextOf get context by name.
+	ContextOf(name string) HandlerContext
+
+	// Size of handler, including the head & tail.
 	Size() int
 
+	// UserSize of the handlers added by user, excluding the head & tail.
+	UserSize() int
+
+	// Names of the handlers from head to tail, the positions are the same as ContextAt.
+	Names() []string
+
+	// Dump the handlers from head to tail, e.g: *netty.headHandler -> decoder(frame.delimiterCodec) -> *netty.tailHandler
+	Dump() string
+
 	// Channel get channel.
 	Channel() Channel
 
+	// IsServing returns true if the channel is attached and not closed yet, the handlers can not be added after closed.
+	IsServing() bool
+
 	// ServeChannel serve the channel.
 	ServeChannel(channel Channel)
 
@@ -60,6 +111,9 @@ type Pipeline interface {
 	FireChannelEvent(event Event)
 }
 
+// PositionLast to add the handlers to the last by AddHandler, the other negative positions are rejected.
+const PositionLast = -1
+
 // NewPipeline convert to PipelineFactory
 func NewPipeline() PipelineFactory {
 	return NewPipelineWith
@@ -70,18 +124,10 @@ func NewPipelineWith() Pipeline {
 
 	p := &pipeline{}
 
-	p.head = &handlerContext{
-		pipeline: p,
-		handler:  new(headHandler),
-	}
-
-	p.tail = &handlerContext{
-		pipeline: p,
-		handler:  new(tailHandler),
-	}
-
-	p.head.next = p.tail
-	p.tail.prev = p.head
+	p.head = newHandlerContext(p, new(headHandler), nil, nil)
+	p.tail = newHandlerContext(p, new(tailHandler), p.head, nil)
+	p.head.setNext(p.tail)
+	p.updateLastInbound()
 
 	// head + tail
 	p.size = 2
@@ -93,7 +139,40 @@ type pipeline struct {
 	head    *handlerContext
 	tail    *handlerContext
 	channel Channel
+	mutex   sync.Mutex // guards the structural mutation, the traversals are lock-free.
 	size    int
+	pending []func() // the lifecycle callbacks collected under the lock.
+
+	// lastInbound is the last inbound context before the tail or the tail itself, the messages delivered to it are
+	// counted by ChannelStats.MessagesRead, it is updated after the structural mutations.
+	lastInbound unsafe.Pointer // *handlerContext
+
+	// interceptor of the handler invocations, it is set before serving the channel.
+	interceptor PipelineInterceptor
+}
+
+// unlock to release the lock and invoke the pending lifecycle callbacks, so the callbacks can access the pipeline.
+func (p *pipeline) unlock() {
+	p.updateLastInbound()
+	pending := p.pending
+	p.pending = nil
+	p.mutex.Unlock()
+
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+// updateLastInbound to find the last inbound context, it must be called with the mutex locked or before shared.
+func (p *pipeline) updateLastInbound() {
+	last := p.tail
+	for curNode := p.tail.prevContext(); curNode != p.head; curNode = curNode.prevContext() {
+		if 0 != curNode.bound().mask&maskInbound {
+			last = curNode
+			break
+		}
+	}
+	atomic.StorePointer(&p.lastInbound, unsafe.Pointer(last))
 }
 
 // AddFirst to add handlers at head
@@ -101,8 +180,11 @@ func (p *pipeline) AddFirst(handlers ...Handler) Pipeline {
 	// checking handler.
 	checkHandler(handlers...)
 
+	p.mutex.Lock()
+	defer p.unlock()
+
 	for _, h := range handlers {
-		p.addFirst(h)
+		p.insertAfter(p.head, "", h)
 	}
 	return p
 }
@@ -112,8 +194,53 @@ func (p *pipeline) AddLast(handlers ...Handler) Pipeline {
 	// checking handler.
 	checkHandler(handlers...)
 
+	p.mutex.Lock()
+	defer p.unlock()
+
 	for _, h := range handlers {
-		p.addLast(h)
+		p.insertAfter(p.tail.prevContext(), "", h)
+	}
+	return p
+}
+
+// AddInboundLast to add inbound handlers after the last inbound handler
+func (p *pipeline) AddInboundLast(handlers ...InboundHandler) Pipeline {
+
+	// checking handler.
+	for _, h := range handlers {
+		checkHandler(h)
+	}
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	curNode := p.head
+	for ctx := p.head.nextContext(); ctx != p.tail; ctx = ctx.nextContext() {
+		if 0 != ctx.bound().mask&maskInbound {
+			curNode = ctx
+		}
+	}
+
+	for _, h := range handlers {
+		curNode = p.insertAfter(curNode, "", h)
+	}
+	return p
+}
+
+// AddOutboundFirst to add outbound handlers at head, the outbound messages flow from tail to head,
+// so the last one of handlers is placed nearest to the head.
+func (p *pipeline) AddOutboundFirst(handlers ...OutboundHandler) Pipeline {
+
+	// checking handler.
+	for _, h := range handlers {
+		checkHandler(h)
+	}
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	for _, h := range handlers {
+		p.insertAfter(p.head, "", h)
 	}
 	return p
 }
@@ -124,47 +251,290 @@ func (p *pipeline) AddHandler(position int, handlers ...Handler) Pipeline {
 	// checking handler.
 	checkHandler(handlers...)
 
+	p.mutex.Lock()
+	defer p.unlock()
+
 	// checking position.
-	utils.AssertIf(position >= p.size, "invalid position: %d", position)
+	utils.AssertIf(position < PositionLast || position >= p.size, "invalid position: %d", position)
+
+	curNode := p.tail.prevContext()
+	if PositionLast != position && position != p.size-1 {
+		curNode = p.contextAt(position)
+	}
 
-	if -1 == position || position == p.size-1 {
-		return p.AddLast(handlers...)
+	for _, h := range handlers {
+		curNode = p.insertAfter(curNode, "", h)
 	}
 
+	return p
+}
+
+// InsertAt to insert handlers at the index of user handlers, the head & tail are not counted.
+func (p *pipeline) InsertAt(index int, handlers ...Handler) Pipeline {
+
+	// checking handler.
+	checkHandler(handlers...)
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	// checking index.
+	utils.AssertIf(index < 0 || index > p.size-2, "invalid index: %d", index)
+
+	// the previous context of index is the context in position index.
+	curNode := p.contextAt(index)
+	for _, h := range handlers {
+		curNode = p.insertAfter(curNode, "", h)
+	}
+
+	return p
+}
+
+// AddLastNamed to add a handler with the name at tail
+func (p *pipeline) AddLastNamed(name string, handler Handler) Pipeline {
+	// checking handler.
+	checkHandler(handler)
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	p.checkName(name)
+	p.insertAfter(p.tail.prevContext(), name, handler)
+	return p
+}
+
+// AddBefore to insert a handler with the name before the handler of baseName
+func (p *pipeline) AddBefore(baseName, name string, handler Handler) Pipeline {
+	// checking handler.
+	checkHandler(handler)
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	base := p.baseContext(baseName)
+	p.checkName(name)
+
+	p.insertAfter(base.prevContext(), name, handler)
+	return p
+}
+
+// AddAfter to insert a handler with the name after the handler of baseName
+func (p *pipeline) AddAfter(baseName, name string, handler Handler) Pipeline {
+	// checking handler.
+	checkHandler(handler)
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	base := p.baseContext(baseName)
+	p.checkName(name)
+
+	p.insertAfter(base, name, handler)
+	return p
+}
+
+// insertAfter to insert a handler with the name after the context, returns the new context.
+func (p *pipeline) insertAfter(prev *handlerContext, name string, handler Handler) *handlerContext {
+
+	p.checkClosed()
+
+	next := prev.nextContext()
+	ctx := newHandlerContext(p, handler, prev, next)
+	ctx.name = name
+
+	// the context is published after it is fully initialized.
+	next.setPrev(ctx)
+	prev.setNext(ctx)
+	p.size++
+
+	b := ctx.bound()
+	p.pending = append(p.pending, func() { b.handleAdded(ctx) })
+	return ctx
+}
+
+// checkClosed to reject the new handlers after the channel is closed, they would never be activated or removed.
+func (p *pipeline) checkClosed() {
+	utils.AssertIf(nil != p.channel && !p.channel.IsActive(), "the channel of pipeline has been closed")
+}
+
+// baseContext to find the context of baseName
+func (p *pipeline) baseContext(baseName string) *handlerContext {
+	base := p.contextOf(baseName)
+	utils.AssertIf(nil == base, "handler not found: %s", baseName)
+	return base
+}
+
+// checkName to check the name of a new handler
+func (p *pipeline) checkName(name string) {
+	utils.AssertIf("" == name, "handler name must not be empty")
+	utils.AssertIf(nil != p.contextOf(name), "duplicate handler name: %s", name)
+}
+
+// contextOf to find the context by name, the head & tail have no name.
+func (p *pipeline) contextOf(name string) *handlerContext {
+
+	if "" == name {
+		return nil
+	}
+
+	for curNode := p.head.nextContext(); curNode != p.tail; curNode = curNode.nextContext() {
+		if name == curNode.name {
+			return curNode
+		}
+	}
+
+	return nil
+}
+
+// contextAt to access the context by position, the position must be valid.
+func (p *pipeline) contextAt(position int) *handlerContext {
+
 	curNode := p.head
 	for i := 0; i < position; i++ {
-		curNode = curNode.next
+		curNode = curNode.nextContext()
 	}
 
-	for _, h := range handlers {
-		oldNext := curNode.next
-		curNode.next = &handlerContext{
-			pipeline: p,
-			handler:  h,
-			prev:     curNode,
-			next:     oldNext,
+	return curNode
+}
+
+// Remove to remove the first context of handler, the head & tail can not be removed.
+func (p *pipeline) Remove(handler Handler) bool {
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	for curNode := p.head.nextContext(); curNode != p.tail; curNode = curNode.nextContext() {
+		if sameHandler(curNode.Handler(), handler) {
+			p.remove(curNode)
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoveAt to remove the context in position, the head & tail can not be removed.
+func (p *pipeline) RemoveAt(position int) Handler {
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	// checking position.
+	utils.AssertIf(position <= 0 || position >= p.size-1, "invalid position: %d", position)
+
+	curNode := p.contextAt(position)
+	p.remove(curNode)
+	return curNode.Handler()
+}
+
+// remove to unlink the context from pipeline
+func (p *pipeline) remove(ctx *handlerContext) {
+
+	prev, next := ctx.prevContext(), ctx.nextContext()
+	prev.setNext(next)
+	next.setPrev(prev)
+
+	// the links of the removed context are kept, so the in-flight traversals through it can go on.
+	p.size--
+
+	b := ctx.bound()
+	p.pending = append(p.pending, func() { b.handleRemoved(ctx) })
+}
+
+// Replace to replace the handler in position, the head & tail can not be replaced.
+//
+// The context is pointed at the new handler in place, so it is safe to replace the handler
+// while it is processing a message, e.g. a decoder replaces itself for the protocol upgrade.
+func (p *pipeline) Replace(position int, handler Handler) Handler {
+
+	// checking handler.
+	checkHandler(handler)
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	// checking position.
+	utils.AssertIf(position <= 0 || position >= p.size-1, "invalid position: %d", position)
+
+	curNode := p.contextAt(position)
+	old := curNode.Handler()
+	p.setHandler(curNode, handler)
+	return old
+}
+
+// ReplaceHandler to replace the first context of old handler, the head & tail can not be replaced.
+func (p *pipeline) ReplaceHandler(old, handler Handler) bool {
+
+	// checking handler.
+	checkHandler(handler)
+
+	p.mutex.Lock()
+	defer p.unlock()
+
+	for curNode := p.head.nextContext(); curNode != p.tail; curNode = curNode.nextContext() {
+		if sameHandler(curNode.Handler(), old) {
+			p.setHandler(curNode, handler)
+			return true
 		}
+	}
+
+	return false
+}
 
-		oldNext.prev = curNode.next
-		curNode = curNode.next
-		p.size++
+// setHandler to point the context at the new handler, the old one is detached.
+func (p *pipeline) setHandler(ctx *handlerContext, handler Handler) {
+
+	p.checkClosed()
+
+	old := ctx.bound()
+	ctx.setHandler(handler)
+
+	b := ctx.bound()
+	p.pending = append(p.pending, func() {
+		old.handleRemoved(ctx)
+		b.handleAdded(ctx)
+	})
+}
+
+// sameHandler to compare the handlers, the handlers of incomparable types are never the same.
+func sameHandler(a, b Handler) bool {
+	if typ := reflect.TypeOf(a); typ != reflect.TypeOf(b) || !typ.Comparable() {
+		return false
 	}
+	return a == b
+}
 
-	return p
+// contexts to take a snapshot of the contexts, so the callbacks can be called without the lock.
+func (p *pipeline) contexts() []*handlerContext {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	contexts := make([]*handlerContext, 0, p.size)
+	for curNode := p.head; nil != curNode; curNode = curNode.nextContext() {
+		contexts = append(contexts, curNode)
+	}
+	return contexts
+}
+
+// handlers to take a snapshot of the handlers, the comparators are called without the lock.
+func (p *pipeline) handlers() []Handler {
+
+	contexts := p.contexts()
+	handlers := make([]Handler, len(contexts))
+	for i, ctx := range contexts {
+		handlers[i] = ctx.Handler()
+	}
+	return handlers
 }
 
 // IndexOf to find fist index of handler.
 func (p *pipeline) IndexOf(comp func(Handler) bool) int {
 
-	head := p.head
-
-	for i := 0; ; i++ {
-		if comp(head.handler) {
+	for i, h := range p.handlers() {
+		if comp(h) {
 			return i
 		}
-		if head = head.next; head == nil {
-			break
-		}
 	}
 
 	return -1
@@ -173,15 +543,11 @@ func (p *pipeline) IndexOf(comp func(Handler) bool) int {
 // LastIndexOf to find last index of handler.
 func (p *pipeline) LastIndexOf(comp func(Handler) bool) int {
 
-	tail := p.tail
-
-	for i := p.size - 1; ; i-- {
-		if comp(tail.handler) {
+	handlers := p.handlers()
+	for i := len(handlers) - 1; i >= 0; i-- {
+		if comp(handlers[i]) {
 			return i
 		}
-		if tail = tail.prev; tail == nil {
-			break
-		}
 	}
 
 	return -1
@@ -190,51 +556,58 @@ func (p *pipeline) LastIndexOf(comp func(Handler) bool) int {
 // ContextAt to access the context by position
 func (p *pipeline) ContextAt(position int) HandlerContext {
 
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	if -1 == position || position >= p.size {
 		return nil
 	}
 
-	curNode := p.head
-	for i := 0; i < position; i++ {
-		curNode = curNode.next
-	}
+	return p.contextAt(position)
+}
 
-	return curNode
+// ContextOf to access the context by name
+func (p *pipeline) ContextOf(name string) HandlerContext {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if ctx := p.contextOf(name); nil != ctx {
+		return ctx
+	}
+	return nil
 }
 
 // Size of handlers
 func (p *pipeline) Size() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	return p.size
 }
 
-// addFirst to add handlers head
-func (p *pipeline) addFirst(handler Handler) {
-
-	oldNext := p.head.next
-	p.head.next = &handlerContext{
-		pipeline: p,
-		handler:  handler,
-		prev:     p.head,
-		next:     oldNext,
-	}
-
-	oldNext.prev = p.head.next
-	p.size++
+// UserSize of handlers, excluding the head & tail.
+func (p *pipeline) UserSize() int {
+	return p.Size() - 2
 }
 
-// addLast to add handlers tail
-func (p *pipeline) addLast(handler Handler) {
+// Names of the handlers, the named handlers are formatted as name(type).
+func (p *pipeline) Names() []string {
 
-	oldPrev := p.tail.prev
-	p.tail.prev = &handlerContext{
-		pipeline: p,
-		handler:  handler,
-		prev:     oldPrev,
-		next:     p.tail,
+	contexts := p.contexts()
+	names := make([]string, len(contexts))
+	for i, ctx := range contexts {
+		if "" != ctx.name {
+			names[i] = fmt.Sprintf("%s(%T)", ctx.name, ctx.Handler())
+		} else {
+			names[i] = fmt.Sprintf("%T", ctx.Handler())
+		}
 	}
+	return names
+}
 
-	oldPrev.next = p.tail.prev
-	p.size++
+// Dump to format the handlers of pipeline
+func (p *pipeline) Dump() string {
+	return strings.Join(p.Names(), " -> ")
 }
 
 // Channel to get channel of Pipeline
@@ -242,11 +615,17 @@ func (p *pipeline) Channel() Channel {
 	return p.channel
 }
 
+// IsServing returns true if the channel is attached and not closed yet.
+func (p *pipeline) IsServing() bool {
+	return nil != p.channel && p.channel.IsActive()
+}
+
 // ServeChannel serveChannel to serve the channel
 func (p *pipeline) ServeChannel(channel Channel) {
 
 	utils.AssertIf(nil != p.channel, "already attached channel")
 	p.channel = channel
+	p.interceptor = pipelineInterceptorFrom(channel.Context())
 	p.channel.serveChannel()
 }
 
@@ -263,11 +642,23 @@ func (p *pipeline) FireChannelWrite(message Message) {
 }
 
 func (p *pipeline) FireChannelException(ex Exception) {
+
+	ex = asChannelException(ex, p.channel)
 	p.head.HandleException(ex)
+
+	// fatal exceptions can not be suppressed by the handlers.
+	if nil != p.channel && IsFatalException(ex) {
+		p.channel.Close(ex)
+	}
 }
 
 func (p *pipeline) FireChannelInactive(ex Exception) {
 	p.head.HandleInactive(ex)
+
+	// the handlers are detached from the inactive channel.
+	for _, ctx := range p.contexts() {
+		ctx.bound().handleRemoved(ctx)
+	}
 }
 
 func (p *pipeline) FireChannelEvent(event Event) {
@@ -278,14 +669,7 @@ func (p *pipeline) FireChannelEvent(event Event) {
 func checkHandler(handlers ...Handler) {
 
 	for index, h := range handlers {
-		switch h.(type) {
-		case ActiveHandler:
-		case InboundHandler:
-		case OutboundHandler:
-		case ExceptionHandler:
-		case InactiveHandler:
-		case EventHandler:
-		default:
+		if 0 == handlerMaskOf(h) {
 			utils.Assert(fmt.Errorf("unrecognized Handler: %d:%T", index, h))
 		}
 	}
@@ -16,7 +16,18 @@
 
 package netty
 
-import "testing"
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
 
 type oneHandler struct{}
 
@@ -105,3 +116,813 @@ func TestPipeline(t *testing.T) {
 	}
 
 }
+
+func BenchmarkPipelineInbound(b *testing.B) {
+
+	forward := InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		ctx.HandleRead(message)
+	})
+
+	for _, n := range []int{1, 5, 10} {
+		b.Run(fmt.Sprintf("handlers-%d", n), func(b *testing.B) {
+
+			p := NewPipelineWith()
+			for i := 0; i < n; i++ {
+				// the outbound handlers are skipped by the inbound dispatch.
+				p.AddLast(forward, threeHandler{})
+			}
+			newPipeChannel(1, p)
+
+			var message Message = []byte("message")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.FireChannelRead(message)
+			}
+		})
+	}
+}
+
+// discardTransport to discard the written bytes
+type discardTransport struct {
+	*pipeTransport
+}
+
+func (d *discardTransport) Writev(buffs transport.Buffers) (int64, error) {
+	return utils.CountOf(buffs.Buffers), nil
+}
+
+func BenchmarkWritePath(b *testing.B) {
+
+	c := newWritePathChannel()
+	defer c.Close(nil)
+
+	var message Message = []byte("message")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Write(message)
+	}
+}
+
+func BenchmarkSendQueue(b *testing.B) {
+
+	for name, option := range map[string][]ChannelOption{"mpsc": nil, "chan": {WithChanQueue()}} {
+		b.Run(name, func(b *testing.B) {
+
+			c := newWritePathChannel(option...)
+			defer c.Close(nil)
+
+			var message Message = []byte("message")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.Write(message)
+				}
+			})
+		})
+	}
+}
+
+// newWritePathChannel create a serving channel that discards the written bytes
+func newWritePathChannel(option ...ChannelOption) *channel {
+
+	p := NewPipelineWith()
+	for i := 0; i < 5; i++ {
+		p.AddLast(twoHandler{}, OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+			ctx.HandleWrite(message)
+		}))
+	}
+
+	c, _ := newPipeChannel(1, p, option...)
+	// measure the write path without the cost of transport.
+	c.transport = &discardTransport{pipeTransport: &pipeTransport{Conn: c.transport.RawTransport().(net.Conn)}}
+	c.serveChannel()
+	return c
+}
+
+// handshakeHandler to remove itself after the first message
+type handshakeHandler struct {
+	reads int
+}
+
+func (h *handshakeHandler) HandleRead(ctx InboundContext, message Message) {
+	h.reads++
+	ctx.Channel().Pipeline().Remove(h)
+	// the removed context still forwards the message to the next.
+	ctx.HandleRead("authenticated: " + message.(string))
+}
+
+func TestPipelineRemove(t *testing.T) {
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(oneHandler{}, twoHandler{}, threeHandler{})
+
+	if !pipeline.Remove(twoHandler{}) || 4 != pipeline.Size() || pipeline.Remove(twoHandler{}) {
+		t.Fatal("unexpected removing")
+	}
+
+	if dump := pipeline.Dump(); "*netty.headHandler -> netty.oneHandler -> netty.threeHandler -> *netty.tailHandler" != dump {
+		t.Fatal("unexpected pipeline:", dump)
+	}
+
+	// the head & tail can not be removed.
+	if pipeline.Remove(pipeline.ContextAt(0).Handler()) || pipeline.Remove(pipeline.ContextAt(pipeline.Size()-1).Handler()) {
+		t.Fatal("the head & tail should not be removed")
+	}
+
+	for _, position := range []int{-1, 0, pipeline.Size() - 1, pipeline.Size()} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal("invalid position should be rejected:", position)
+				}
+			}()
+			pipeline.RemoveAt(position)
+		}()
+	}
+
+	if _, ok := pipeline.RemoveAt(1).(oneHandler); !ok || 3 != pipeline.Size() {
+		t.Fatal("unexpected removing")
+	}
+
+	// the handlers of incomparable types can be removed by position only.
+	pipeline.AddFirst(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$")})
+	if pipeline.Remove(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$")}) {
+		t.Fatal("the incomparable handler should not be found")
+	}
+
+	if _, ok := pipeline.RemoveAt(1).(delimiterCodec); !ok || 3 != pipeline.Size() {
+		t.Fatal("unexpected removing")
+	}
+}
+
+func TestPipelineRemoveInFlight(t *testing.T) {
+
+	var received []string
+	handshake := &handshakeHandler{}
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(handshake, InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		received = append(received, message.(string))
+	}))
+
+	// the handlers access the pipeline by the channel.
+	newTransportChannel(1, pipeline, &pipeTransport{})
+
+	pipeline.FireChannelRead("login")
+	pipeline.FireChannelRead("hello")
+
+	if 1 != handshake.reads || 3 != pipeline.Size() {
+		t.Fatal("the handshake handler is not removed:", handshake.reads, pipeline.Size())
+	}
+
+	if fmt.Sprint(received) != "[authenticated: login hello]" {
+		t.Fatal("unexpected messages:", received)
+	}
+}
+
+func TestPipelineNamed(t *testing.T) {
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(oneHandler{}).
+		AddLastNamed("decoder", twoHandler{}).
+		AddLastNamed("encoder", threeHandler{}).
+		AddBefore("decoder", "handshake", fourHandler{}).
+		AddAfter("encoder", "business", fiveHandler{})
+
+	// the positions are shifted by the unnamed handler.
+	pipeline.AddFirst(oneHandler{})
+
+	want := "*netty.headHandler -> netty.oneHandler -> netty.oneHandler -> handshake(netty.fourHandler) -> " +
+		"decoder(netty.twoHandler) -> encoder(netty.threeHandler) -> business(netty.fiveHandler) -> *netty.tailHandler"
+	if dump := pipeline.Dump(); want != dump {
+		t.Fatal("unexpected pipeline:", dump)
+	}
+
+	if ctx := pipeline.ContextOf("decoder"); nil == ctx || "decoder" != ctx.Name() || pipeline.ContextAt(4) != ctx {
+		t.Fatal("unexpected context:", ctx)
+	}
+
+	if nil != pipeline.ContextOf("unknown") || nil != pipeline.ContextOf("") || "" != pipeline.ContextAt(1).Name() {
+		t.Fatal("unexpected context")
+	}
+
+	for name, add := range map[string]func(){
+		"duplicate":   func() { pipeline.AddLastNamed("decoder", twoHandler{}) },
+		"empty":       func() { pipeline.AddAfter("decoder", "", twoHandler{}) },
+		"unknown":     func() { pipeline.AddBefore("unknown", "decoder2", twoHandler{}) },
+		"unnamed":     func() { pipeline.AddBefore("", "decoder2", twoHandler{}) },
+		"unsupported": func() { pipeline.AddLastNamed("decoder2", struct{}{}) },
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal("invalid insertion should be rejected:", name)
+				}
+			}()
+			add()
+		}()
+	}
+
+	if 8 != pipeline.Size() {
+		t.Fatal("the rejected handlers are added:", pipeline.Size())
+	}
+
+	// the named handlers can be removed like others.
+	if !pipeline.Remove(twoHandler{}) || nil != pipeline.ContextOf("decoder") {
+		t.Fatal("the named handler is not removed")
+	}
+
+	pipeline.AddAfter("handshake", "decoder", twoHandler{})
+	if pipeline.ContextAt(4) != pipeline.ContextOf("decoder") {
+		t.Fatal("unexpected position")
+	}
+}
+
+// upgradeDecoder to decode the lines until the upgrade line, then replace itself with the fixed decoder.
+type upgradeDecoder struct {
+	fixed Handler
+}
+
+func (u *upgradeDecoder) HandleRead(ctx InboundContext, message Message) {
+
+	var line []byte
+	var b = make([]byte, 1)
+	for {
+		// read byte by byte, the bytes after the line are left for the next handler.
+		_, err := message.(io.Reader).Read(b)
+		utils.Assert(err)
+		if '\n' == b[0] {
+			break
+		}
+		line = append(line, b[0])
+	}
+
+	if "UPGRADE" == string(line) {
+		if !ctx.Channel().Pipeline().ReplaceHandler(u, u.fixed) {
+			panic("upgrade failed")
+		}
+	}
+
+	ctx.HandleRead(string(line))
+}
+
+// fixedDecoder to decode the 4-bytes frames
+type fixedDecoder struct{}
+
+func (fixedDecoder) HandleRead(ctx InboundContext, message Message) {
+	frame := make([]byte, 4)
+	_, err := io.ReadFull(message.(io.Reader), frame)
+	utils.Assert(err)
+	ctx.HandleRead(frame)
+}
+
+func TestPipelineReplace(t *testing.T) {
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(oneHandler{}).AddLastNamed("codec", twoHandler{})
+
+	if _, ok := pipeline.Replace(2, threeHandler{}).(twoHandler); !ok || "codec" != pipeline.ContextAt(2).Name() {
+		t.Fatal("unexpected replacing")
+	}
+
+	if _, ok := pipeline.ContextOf("codec").Handler().(threeHandler); !ok || 4 != pipeline.Size() {
+		t.Fatal("the handler is not replaced")
+	}
+
+	if pipeline.ReplaceHandler(twoHandler{}, fourHandler{}) || !pipeline.ReplaceHandler(threeHandler{}, fourHandler{}) {
+		t.Fatal("unexpected replacing")
+	}
+
+	// the head & tail can not be replaced.
+	for _, position := range []int{-1, 0, pipeline.Size() - 1, pipeline.Size()} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal("invalid position should be rejected:", position)
+				}
+			}()
+			pipeline.Replace(position, fiveHandler{})
+		}()
+	}
+
+	if pipeline.ReplaceHandler(pipeline.ContextAt(0).Handler(), fiveHandler{}) {
+		t.Fatal("the head should not be replaced")
+	}
+}
+
+func TestPipelineReplaceInFlight(t *testing.T) {
+
+	received := make(chan interface{}, 8)
+	pipeline := NewPipelineWith().
+		AddLast(&upgradeDecoder{fixed: fixedDecoder{}}).
+		AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+			received <- message
+		}), ignoreException)
+
+	c, peer := newPipeChannel(1, pipeline)
+	c.serveChannel()
+	defer c.Close(nil)
+
+	go func() { _, _ = peer.Write([]byte("hello\nUPGRADE\nabcdefgh")) }()
+
+	for _, want := range []string{"hello", "UPGRADE", "abcd", "efgh"} {
+		got := <-received
+		if frame, ok := got.([]byte); ok {
+			got = string(frame)
+		}
+		if want != got {
+			t.Fatalf("unexpected message: %v, want: %s", got, want)
+		}
+	}
+
+	if _, ok := pipeline.ContextAt(1).Handler().(fixedDecoder); !ok {
+		t.Fatal("the decoder is not replaced")
+	}
+}
+
+// relayHandler to forward the messages in both directions
+type relayHandler struct{}
+
+func (h *relayHandler) HandleRead(ctx InboundContext, message Message) {
+	ctx.HandleRead(message)
+}
+
+func (h *relayHandler) HandleWrite(ctx OutboundContext, message Message) {
+	ctx.HandleWrite(message)
+}
+
+func TestPipelineConcurrentMutation(t *testing.T) {
+
+	var reads, writes int
+	pipeline := NewPipelineWith()
+	pipeline.AddFirst(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		writes++
+	})).AddLastNamed("sink", InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		reads++
+	}))
+
+	const rounds = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < rounds; i++ {
+			relay := &relayHandler{}
+			switch i % 4 {
+			case 0:
+				pipeline.AddLast(relay)
+			case 1:
+				pipeline.AddHandler(1, relay)
+			case 2:
+				pipeline.AddBefore("sink", fmt.Sprint("relay-", i), relay)
+			default:
+				pipeline.AddFirst(relay)
+			}
+			replaced := &relayHandler{}
+			pipeline.ReplaceHandler(relay, replaced)
+			if 0 == i%2 {
+				pipeline.Remove(replaced)
+			}
+			if 0 == i%100 {
+				_ = pipeline.Dump()
+			}
+		}
+	}()
+
+	for i := 0; i < rounds; i++ {
+		pipeline.FireChannelRead(i)
+		pipeline.FireChannelWrite(i)
+	}
+	<-done
+
+	if rounds != reads || rounds != writes {
+		t.Fatal("the messages are lost:", reads, writes)
+	}
+
+	if 4+rounds/2 != pipeline.Size() {
+		t.Fatal("unexpected size:", pipeline.Size())
+	}
+}
+
+// lifecycleHandler to record the lifecycle callbacks
+type lifecycleHandler struct {
+	name   string
+	events *[]string
+}
+
+func (h *lifecycleHandler) HandleRead(ctx InboundContext, message Message) {
+	ctx.HandleRead(message)
+}
+
+func (h *lifecycleHandler) HandleAdded(ctx HandlerContext) {
+	*h.events = append(*h.events, "added:"+h.name)
+}
+
+func (h *lifecycleHandler) HandleRemoved(ctx HandlerContext) {
+	*h.events = append(*h.events, "removed:"+h.name)
+}
+
+// sizeProbe to record the size of pipeline when it is added
+type sizeProbe struct {
+	events *[]string
+}
+
+func (h *sizeProbe) HandleRead(ctx InboundContext, message Message) {}
+
+func (h *sizeProbe) HandleAdded(ctx HandlerContext) {
+	*h.events = append(*h.events, fmt.Sprint("size:", ctx.(*handlerContext).pipeline.Size()))
+}
+
+func TestPipelineLifecycle(t *testing.T) {
+
+	var events []string
+	handler := func(name string) *lifecycleHandler {
+		return &lifecycleHandler{name: name, events: &events}
+	}
+
+	one, two, three, four := handler("one"), handler("two"), handler("three"), handler("four")
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(one).AddFirst(two).AddHandler(1, three)
+	pipeline.AddLastNamed("idle", InboundHandlerFunc(func(ctx InboundContext, message Message) {}))
+
+	pipeline.Remove(two)
+	pipeline.ReplaceHandler(three, four)
+
+	// the callbacks are free to access the pipeline.
+	pipeline.AddBefore("idle", "five", &lifecycleHandler{name: "five", events: &events})
+	pipeline.AddLast(&sizeProbe{events: &events})
+
+	c, _ := newPipeChannel(1, pipeline)
+	c.Close(nil)
+
+	// the handlers have been detached once.
+	pipeline.Remove(one)
+
+	want := "[added:one added:two added:three removed:two removed:three added:four added:five size:7 removed:four removed:one removed:five]"
+	if got := fmt.Sprint(events); want != got {
+		t.Fatal("unexpected callbacks:", got, "want:", want)
+	}
+}
+
+// stringEncoder to encode the strings by ctx.Write
+type stringEncoder struct {
+	writes int
+}
+
+func (e *stringEncoder) HandleWrite(ctx OutboundContext, message Message) {
+	e.writes++
+	if s, ok := message.(string); ok {
+		ctx.Write([]byte(s))
+		return
+	}
+	ctx.HandleWrite(message)
+}
+
+func TestContextWrite(t *testing.T) {
+
+	var written []Message
+	encoder, laterEncoder := &stringEncoder{}, &stringEncoder{}
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		written = append(written, message)
+	}), encoder, InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		// the write starts from this context, the later encoder is skipped.
+		ctx.Write(message)
+	}), laterEncoder)
+
+	// the channel writes start from the tail.
+	pipeline.FireChannelWrite("hello")
+	if 1 != encoder.writes || 1 != laterEncoder.writes {
+		t.Fatal("the encoder should not be re-entered:", encoder.writes, laterEncoder.writes)
+	}
+
+	pipeline.FireChannelRead("world")
+	if 2 != encoder.writes || 1 != laterEncoder.writes {
+		t.Fatal("unexpected writes:", encoder.writes, laterEncoder.writes)
+	}
+
+	if _, encoded := written[0].([]byte); !encoded || fmt.Sprintf("%s", written) != "[hello world]" {
+		t.Fatalf("unexpected messages: %s", written)
+	}
+}
+
+func TestPipelineNames(t *testing.T) {
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(oneHandler{}).AddLastNamed("decoder", twoHandler{}).AddLast(&threeHandler{})
+
+	names := pipeline.Names()
+	want := []string{"*netty.headHandler", "netty.oneHandler", "decoder(netty.twoHandler)", "*netty.threeHandler", "*netty.tailHandler"}
+	if fmt.Sprint(want) != fmt.Sprint(names) {
+		t.Fatal("unexpected names:", names)
+	}
+
+	// the positions line up with ContextAt.
+	for i := range names {
+		if got := fmt.Sprintf("%T", pipeline.ContextAt(i).Handler()); !strings.Contains(names[i], got) {
+			t.Fatal("unexpected position:", i, names[i], got)
+		}
+	}
+
+	if dump := pipeline.Dump(); strings.Join(want, " -> ") != dump {
+		t.Fatal("unexpected dump:", dump)
+	}
+}
+
+// recordHandler to record the inbound messages & events
+type recordHandler struct {
+	received []Message
+}
+
+func (h *recordHandler) HandleRead(ctx InboundContext, message Message) {
+	h.received = append(h.received, message)
+}
+
+func (h *recordHandler) HandleEvent(ctx EventContext, event Event) {
+	h.received = append(h.received, event)
+}
+
+// synthesizer to synthesize the messages & events from the context
+type synthesizer struct {
+	reads int
+}
+
+func (s *synthesizer) HandleActive(ctx ActiveContext) {
+	ctx.Trigger(ReadIdleEvent{})
+	ctx.FireRead("synthesized")
+}
+
+func (s *synthesizer) HandleRead(ctx InboundContext, message Message) {
+	s.reads++
+	// re-inject the decoded message.
+	ctx.FireRead(string(message.([]byte)))
+}
+
+func TestContextFire(t *testing.T) {
+
+	upstream, downstream, synth := &recordHandler{}, &recordHandler{}, &synthesizer{}
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {}), upstream, synth, downstream)
+
+	pipeline.FireChannelActive()
+	if 0 != len(upstream.received) || fmt.Sprint(downstream.received) != "[{} synthesized]" {
+		t.Fatal("unexpected messages:", upstream.received, downstream.received)
+	}
+
+	// the upstream handlers are skipped, so the synthesizer is never invoked recursively.
+	upstream.received, downstream.received = nil, nil
+	pipeline.ContextAt(2).FireRead([]byte("decoded"))
+	if 1 != synth.reads || 0 != len(upstream.received) || fmt.Sprint(downstream.received) != "[decoded]" {
+		t.Fatal("unexpected messages:", synth.reads, upstream.received, downstream.received)
+	}
+
+	// the pipeline-level methods start from the head.
+	pipeline.FireChannelEvent(WriteIdleEvent{})
+	if 1 != len(upstream.received) {
+		t.Fatal("the event should start from the head:", upstream.received)
+	}
+}
+
+func TestPipelineInsertAt(t *testing.T) {
+
+	pipeline := NewPipelineWith()
+	if 0 != pipeline.UserSize() {
+		t.Fatal("the head & tail should not be counted:", pipeline.UserSize())
+	}
+
+	pipeline.InsertAt(0, threeHandler{}).InsertAt(0, oneHandler{}).InsertAt(1, twoHandler{})
+	pipeline.InsertAt(pipeline.UserSize(), fourHandler{}).AddHandler(PositionLast, fiveHandler{})
+
+	if dump := pipeline.Dump(); "*netty.headHandler -> netty.oneHandler -> netty.twoHandler -> netty.threeHandler -> netty.fourHandler -> netty.fiveHandler -> *netty.tailHandler" != dump {
+		t.Fatal("unexpected pipeline:", dump)
+	}
+
+	if 5 != pipeline.UserSize() || pipeline.Size()-2 != pipeline.UserSize() {
+		t.Fatal("unexpected size:", pipeline.UserSize())
+	}
+
+	// an IndexOf miss must not be added to the last silently.
+	missing := pipeline.IndexOf(func(handler Handler) bool { return false })
+	for _, add := range []func(){
+		func() { pipeline.InsertAt(missing, oneHandler{}) },
+		func() { pipeline.InsertAt(pipeline.UserSize()+1, oneHandler{}) },
+		func() { pipeline.AddHandler(-2, oneHandler{}) },
+		func() { pipeline.AddHandler(pipeline.Size(), oneHandler{}) },
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal("invalid position should be rejected")
+				}
+			}()
+			add()
+		}()
+	}
+
+	if 5 != pipeline.UserSize() {
+		t.Fatal("unexpected size:", pipeline.UserSize())
+	}
+}
+
+// timingHandler to measure the downstream handlers of it
+type timingHandler struct {
+	elapsed map[string]time.Duration
+}
+
+func (h *timingHandler) HandleRead(ctx InboundContext, message Message) {
+	// every downstream inbound handler is invoked directly with its own context.
+	for next := ctx.Next(); nil != next; next = next.Next() {
+		if inbound, ok := next.Handler().(InboundHandler); ok && nil != next.Next() {
+			start := time.Now()
+			inbound.HandleRead(next.(InboundContext), message)
+			h.elapsed[fmt.Sprintf("%T", next.Handler())] += time.Since(start)
+		}
+	}
+}
+
+func TestContextNavigation(t *testing.T) {
+
+	pipeline := NewPipelineWith()
+	pipeline.AddLast(oneHandler{}, twoHandler{}, threeHandler{})
+
+	// walk the pipeline from any context.
+	var forward, backward []string
+	for ctx := pipeline.ContextAt(2); nil != ctx; ctx = ctx.Next() {
+		forward = append(forward, fmt.Sprintf("%T", ctx.Handler()))
+	}
+	for ctx := pipeline.ContextAt(2); nil != ctx; ctx = ctx.Prev() {
+		backward = append(backward, fmt.Sprintf("%T", ctx.Handler()))
+	}
+
+	if fmt.Sprint(forward) != "[netty.twoHandler netty.threeHandler *netty.tailHandler]" ||
+		fmt.Sprint(backward) != "[netty.twoHandler netty.oneHandler *netty.headHandler]" {
+		t.Fatal("unexpected navigation:", forward, backward)
+	}
+
+	// the ends of pipeline are nil interfaces.
+	if nil != pipeline.ContextAt(0).Prev() || nil != pipeline.ContextAt(pipeline.Size()-1).Next() {
+		t.Fatal("unexpected ends")
+	}
+
+	timing := &timingHandler{elapsed: map[string]time.Duration{}}
+	pipeline.AddFirst(timing)
+	pipeline.AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		time.Sleep(time.Millisecond)
+	}))
+	pipeline.FireChannelRead("hello")
+
+	if _, ok := timing.elapsed["netty.twoHandler"]; !ok || timing.elapsed["netty.InboundHandlerFunc"] < time.Millisecond {
+		t.Fatal("unexpected timing:", timing.elapsed)
+	}
+}
+
+func TestPipelineClosed(t *testing.T) {
+
+	var events []string
+	pipeline := NewPipelineWith()
+	if pipeline.IsServing() {
+		t.Fatal("the pipeline without channel is not serving")
+	}
+
+	pipeline.AddLast(&lifecycleHandler{name: "one", events: &events})
+	c, _ := newPipeChannel(1, pipeline)
+	if !pipeline.IsServing() {
+		t.Fatal("the pipeline should be serving")
+	}
+
+	c.Close(nil)
+	if pipeline.IsServing() {
+		t.Fatal("the pipeline of closed channel is not serving")
+	}
+
+	for _, add := range []func(){
+		func() { pipeline.AddLast(&lifecycleHandler{name: "two", events: &events}) },
+		func() { pipeline.AddFirst(&lifecycleHandler{name: "two", events: &events}) },
+		func() { pipeline.AddHandler(1, &lifecycleHandler{name: "two", events: &events}) },
+		func() { pipeline.Replace(1, &lifecycleHandler{name: "two", events: &events}) },
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal("the handler should be rejected after closed")
+				}
+			}()
+			add()
+		}()
+	}
+
+	// the handlers can still be removed.
+	if 3 != pipeline.Size() || 1 != pipeline.UserSize() || nil == pipeline.RemoveAt(1) {
+		t.Fatal("unexpected pipeline:", pipeline.Dump())
+	}
+
+	if fmt.Sprint(events) != "[added:one removed:one]" {
+		t.Fatal("unexpected callbacks:", events)
+	}
+}
+
+func TestPipelineDirectional(t *testing.T) {
+
+	var written []Message
+	pipeline := NewPipelineWith()
+
+	// decoders & business handler are processing in order.
+	pipeline.AddInboundLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		ctx.HandleRead(string(message.([]byte)))
+	}), InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		ctx.HandleRead(strings.ToUpper(message.(string)))
+	})).AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {}))
+
+	// the business handler is added after the existing decoders, and before the exception handler.
+	pipeline.AddInboundLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		ctx.Write(message)
+	}))
+
+	// encoders are processing in order, the outbound sink processes the messages at last.
+	pipeline.AddOutboundFirst(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		ctx.HandleWrite(message.(string) + "$")
+	}), OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		ctx.HandleWrite([]byte(message.(string)))
+	})).AddOutboundFirst(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		written = append(written, message)
+	}))
+
+	pipeline.FireChannelRead([]byte("hello"))
+	pipeline.FireChannelWrite("world")
+
+	if fmt.Sprintf("%q", written) != `["HELLO$" "world$"]` {
+		t.Fatalf("unexpected round trip: %q", written)
+	}
+
+	if _, ok := pipeline.ContextAt(pipeline.Size() - 2).Handler().(ExceptionHandlerFunc); !ok {
+		t.Fatal("the exception handler should be kept at last:", pipeline.Dump())
+	}
+}
+
+func TestPipelineInterceptor(t *testing.T) {
+
+	var mutex sync.Mutex
+	counts := map[string]int{}
+	bs := NewBootstrap(WithPipelineInterceptor(func(ctx HandlerContext, op string, next func()) {
+		mutex.Lock()
+		counts[fmt.Sprintf("%T.%s", ctx.Handler(), op)]++
+		mutex.Unlock()
+
+		// not calling next stops the propagation.
+		if "blocked" != ctx.Name() {
+			next()
+		}
+	}))
+	defer bs.Shutdown()
+
+	events := make(chan Event, 2)
+	received := make(chan string, 2)
+
+	p := NewPipelineWith()
+	p.AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}, &textCodec{}).
+		AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+			received <- message.(string)
+		})).
+		AddLast(EventHandlerFunc(func(ctx EventContext, event Event) {
+			events <- event
+			ctx.HandleEvent(event)
+		})).
+		AddLastNamed("blocked", EventHandlerFunc(func(ctx EventContext, event Event) {
+			t.Error("the event should be blocked by the interceptor")
+		}))
+
+	local, peer := net.Pipe()
+	c := newChannelWith(bs.Context(), p, &pipeTransport{Conn: local}, 1, 128, parseChannelOptions(bs.Context()))
+	p.ServeChannel(c)
+	defer c.Close(nil)
+
+	if _, err := peer.Write([]byte("hello$world$")); nil != err {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"hello", "world"} {
+		if got := <-received; want != got {
+			t.Fatal("unexpected message:", got, "want:", want)
+		}
+	}
+
+	c.Trigger("event")
+	if event := <-events; "event" != event {
+		t.Fatal("unexpected event:", event)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// the event is intercepted by both of the event handlers.
+	if 2 != counts["netty.InboundHandlerFunc.HandleRead"] || 2 != counts["netty.EventHandlerFunc.HandleEvent"] ||
+		0 == counts["netty.delimiterCodec.HandleRead"] || 2 != counts["*netty.textCodec.HandleRead"] {
+		t.Fatal("unexpected counts:", counts)
+	}
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package pool provides a pool of the client channels to the same url over Bootstrap.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// ErrPoolClosed will be returned by Get after the pool is closed.
+var ErrPoolClosed = errors.New("pool closed")
+
+// PoolConfig defines the limits of Pool
+type PoolConfig struct {
+	// Min channels are dialed at once and kept from the idle timeout.
+	Min int
+	// Max channels include the idle, the checked out and the dialing channels, zero means unlimited.
+	Max int
+	// IdleTimeout to close the idle channels above Min, zero means never.
+	IdleTimeout time.Duration
+	// Options of transport for dialing
+	Options []transport.Option
+}
+
+// Pool defines a pool of the client channels, the closed channels are evicted automatically.
+type Pool interface {
+	// Get to check out an idle channel or dial a new one, it waits for a returned channel
+	// if Max is reached until the ctx is done.
+	Get(ctx context.Context) (netty.Channel, error)
+	// Put to check in the channel, the inactive channels are discarded.
+	Put(channel netty.Channel)
+	// Len returns the number of the idle and checked out channels
+	Len() int
+	// Idle returns the number of the idle channels
+	Idle() int
+	// Close to close the idle channels, the checked out channels are closed when they are put back.
+	Close()
+}
+
+// NewPool create a Pool that dials the url by the bootstrap, Min channels are dialed in background.
+func NewPool(bs netty.Bootstrap, url string, config PoolConfig) Pool {
+
+	utils.AssertIf(config.Min < 0 || (config.Max > 0 && config.Max < config.Min), "the limits must be 0 <= min <= max")
+	utils.AssertIf(config.IdleTimeout < 0, "the idle timeout must be a non-negative duration")
+
+	ctx, cancel := context.WithCancel(bs.Context())
+	p := &channelPool{
+		bs:       bs,
+		url:      url,
+		config:   config,
+		ctx:      ctx,
+		cancel:   cancel,
+		channels: make(map[netty.Channel]struct{}),
+	}
+
+	go p.warmUp()
+	if config.IdleTimeout > 0 {
+		go p.reapIdle()
+	}
+	return p
+}
+
+// idleChannel defines a checked in channel
+type idleChannel struct {
+	channel netty.Channel
+	since   time.Time
+}
+
+// implement of Pool
+type channelPool struct {
+	bs       netty.Bootstrap
+	url      string
+	config   PoolConfig
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mutex    sync.Mutex
+	channels map[netty.Channel]struct{}
+	idle     []idleChannel // the last returned is checked out first
+	dialing  int
+	waiters  []chan struct{}
+	closed   bool
+}
+
+func (p *channelPool) Get(ctx context.Context) (netty.Channel, error) {
+
+	for {
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		// the channels closed after checked in are skipped.
+		for n := len(p.idle); n > 0; n = len(p.idle) {
+			channel := p.idle[n-1].channel
+			p.idle[n-1], p.idle = idleChannel{}, p.idle[:n-1]
+			if channel.IsActive() {
+				p.mutex.Unlock()
+				return channel, nil
+			}
+		}
+
+		if p.config.Max <= 0 || len(p.channels)+p.dialing < p.config.Max {
+			p.dialing++
+			p.mutex.Unlock()
+			return p.dial(ctx)
+		}
+
+		waiter := make(chan struct{}, 1)
+		p.waiters = append(p.waiters, waiter)
+		p.mutex.Unlock()
+
+		select {
+		case <-waiter:
+		case <-ctx.Done():
+			p.mutex.Lock()
+			p.removeWaiter(waiter)
+			p.mutex.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// dial to connect a new channel for Get, the dialing slot must be reserved.
+func (p *channelPool) dial(ctx context.Context) (netty.Channel, error) {
+
+	channel, err := p.bs.ConnectContext(ctx, p.url, nil, p.config.Options...)
+
+	p.mutex.Lock()
+	p.dialing--
+	if nil != err {
+		p.notify()
+		p.mutex.Unlock()
+		return nil, err
+	}
+
+	if p.closed {
+		p.mutex.Unlock()
+		channel.Close(ErrPoolClosed)
+		return nil, ErrPoolClosed
+	}
+	p.channels[channel] = struct{}{}
+	p.mutex.Unlock()
+
+	// it is called at once if the channel has been closed.
+	channel.OnClose(func(err error) { p.evict(channel) })
+	return channel, nil
+}
+
+func (p *channelPool) Put(channel netty.Channel) {
+
+	p.mutex.Lock()
+	if _, ok := p.channels[channel]; !ok || !channel.IsActive() {
+		p.mutex.Unlock()
+		return
+	}
+
+	if p.closed {
+		p.mutex.Unlock()
+		channel.Close(ErrPoolClosed)
+		return
+	}
+
+	p.idle = append(p.idle, idleChannel{channel: channel, since: netty.ClockFrom(p.ctx).Now()})
+	p.notify()
+	p.mutex.Unlock()
+}
+
+func (p *channelPool) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.channels)
+}
+
+func (p *channelPool) Idle() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.idle)
+}
+
+func (p *channelPool) Close() {
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	p.cancel()
+
+	idle := p.idle
+	p.idle = nil
+	for _, waiter := range p.waiters {
+		waiter <- struct{}{}
+	}
+	p.waiters = nil
+	p.mutex.Unlock()
+
+	for _, c := range idle {
+		c.channel.Close(ErrPoolClosed)
+	}
+}
+
+// evict to remove the closed channel from pool
+func (p *channelPool) evict(channel netty.Channel) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.channels, channel)
+	for i := range p.idle {
+		if p.idle[i].channel == channel {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			break
+		}
+	}
+	p.notify()
+}
+
+// notify to wake up the first waiter, it must be called with the mutex locked.
+func (p *channelPool) notify() {
+	if len(p.waiters) > 0 {
+		p.waiters[0] <- struct{}{}
+		p.waiters = p.waiters[1:]
+	}
+}
+
+// removeWaiter to remove the waiter that gave up, the notification it received is passed on.
+func (p *channelPool) removeWaiter(waiter chan struct{}) {
+	for i := range p.waiters {
+		if p.waiters[i] == waiter {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return
+		}
+	}
+	p.notify()
+}
+
+// warmUp to dial the Min channels into the idle channels
+func (p *channelPool) warmUp() {
+	for i := 0; i < p.config.Min; i++ {
+
+		p.mutex.Lock()
+		if p.closed || len(p.channels)+p.dialing >= p.config.Min {
+			p.mutex.Unlock()
+			return
+		}
+		p.dialing++
+		p.mutex.Unlock()
+
+		channel, err := p.dial(p.ctx)
+		if nil != err {
+			netty.LoggerFrom(p.ctx).Warnf("failed to warm up the pool of %s: %v", p.url, err)
+			return
+		}
+		p.Put(channel)
+	}
+}
+
+// reapIdle to close the channels that have been idle for the IdleTimeout, Min channels are kept.
+func (p *channelPool) reapIdle() {
+
+	clock := netty.ClockFrom(p.ctx)
+	for {
+		wakeup := make(chan struct{})
+		stop := clock.Schedule(p.config.IdleTimeout/2, func() { close(wakeup) })
+
+		select {
+		case <-p.ctx.Done():
+			stop()
+			return
+		case <-wakeup:
+		}
+
+		var expired []netty.Channel
+		now := clock.Now()
+
+		p.mutex.Lock()
+		// the idle channels are ordered by the time of checked in, the oldest is the first.
+		for len(p.idle) > 0 && len(p.channels)-len(expired) > p.config.Min && now.Sub(p.idle[0].since) >= p.config.IdleTimeout {
+			expired = append(expired, p.idle[0].channel)
+			p.idle = p.idle[1:]
+		}
+		p.mutex.Unlock()
+
+		for _, channel := range expired {
+			channel.Close(nil)
+		}
+	}
+}
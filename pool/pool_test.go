@@ -0,0 +1,148 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/codec/frame"
+)
+
+// newServer to accept the connections into the chan
+func newServer(t *testing.T) (string, chan net.Conn) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	accepted := make(chan net.Conn, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			accepted <- conn
+		}
+	}()
+	return "tcp://" + ln.Addr().String(), accepted
+}
+
+func newBootstrap(t *testing.T) netty.Bootstrap {
+	bs := netty.NewBootstrap(netty.WithClientInitializer(func(channel netty.Channel) {
+		channel.Pipeline().
+			AddLast(frame.DelimiterCodec(1024, "\n", true)).
+			AddLast(netty.ExceptionHandlerFunc(func(ctx netty.ExceptionContext, ex netty.Exception) { ctx.Close(ex) }))
+	}))
+	t.Cleanup(bs.Shutdown)
+	return bs
+}
+
+// eventually to wait for the condition
+func eventually(t *testing.T, cond func() bool, msg ...interface{}) {
+	for deadline := time.Now().Add(3 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal(msg...)
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+
+	url, accepted := newServer(t)
+	p := NewPool(newBootstrap(t), url, PoolConfig{Min: 1, Max: 2})
+	defer p.Close()
+
+	// the Min channels are dialed at once.
+	eventually(t, func() bool { return 1 == p.Idle() }, "the pool should be warmed up")
+	<-accepted
+
+	first, err := p.Get(context.Background())
+	if nil != err || 0 != p.Idle() {
+		t.Fatal("the idle channel should be checked out:", err)
+	}
+
+	second, err := p.Get(context.Background())
+	if nil != err || second == first || 2 != p.Len() {
+		t.Fatal("a new channel should be dialed:", err)
+	}
+	serverConn := <-accepted
+
+	// the Max is reached.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); context.DeadlineExceeded != err {
+		t.Fatal("the Get should be timed out:", err)
+	}
+
+	got := make(chan netty.Channel)
+	go func() {
+		channel, _ := p.Get(context.Background())
+		got <- channel
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	p.Put(first)
+	if channel := <-got; channel != first {
+		t.Fatal("the waiter should get the returned channel")
+	}
+
+	// the closed channels are evicted.
+	serverConn.Close()
+	eventually(t, func() bool { return 1 == p.Len() }, "the closed channel should be evicted")
+	p.Put(second)
+	if 0 != p.Idle() {
+		t.Fatal("the inactive channel should be discarded")
+	}
+
+	p.Close()
+	if _, err := p.Get(context.Background()); ErrPoolClosed != err {
+		t.Fatal("unexpected error:", err)
+	}
+	p.Put(first)
+	if first.IsActive() {
+		t.Fatal("the channel should be closed after the pool is closed")
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+
+	url, _ := newServer(t)
+	p := NewPool(newBootstrap(t), url, PoolConfig{IdleTimeout: 20 * time.Millisecond})
+	defer p.Close()
+
+	channel, err := p.Get(context.Background())
+	if nil != err {
+		t.Fatal(err)
+	}
+	p.Put(channel)
+	if 1 != p.Idle() {
+		t.Fatal("the channel should be idle")
+	}
+
+	eventually(t, func() bool { return 0 == p.Len() }, "the idle channel should be closed")
+	if channel.IsActive() {
+		t.Fatal("the idle channel should be closed")
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync/atomic"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// outboundQueue defines the send queue between the writers and the write loop of channel.
+//
+// put & offer can be called by any goroutine, take & poll can only be called by the write loop,
+// except that the chan based queue can be polled by any goroutine.
+type outboundQueue interface {
+	// put to queue the entry, it blocks until the entry is queued or the done is closed.
+	put(entry outboundEntry, done <-chan struct{}) bool
+	// offer to queue the entry without blocking, returns false if the queue is full.
+	offer(entry outboundEntry) bool
+	// take to dequeue an entry, it blocks until an entry is queued or the done is closed.
+	take(done <-chan struct{}) (outboundEntry, bool)
+	// poll to dequeue an entry without blocking.
+	poll() (outboundEntry, bool)
+	// capacity of queue
+	capacity() int
+	// size returns the number of queued entries, it can be called by any goroutine.
+	size() int
+}
+
+// make sure the queues implement outboundQueue
+var (
+	_ outboundQueue = chanQueue(nil)
+	_ outboundQueue = (*mpscQueue)(nil)
+)
+
+// chanQueue defines the chan based outboundQueue
+type chanQueue chan outboundEntry
+
+func newChanQueue(capacity int) outboundQueue {
+	return chanQueue(make(chan outboundEntry, capacity))
+}
+
+func (q chanQueue) put(entry outboundEntry, done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return false
+	case q <- entry:
+		return true
+	}
+}
+
+func (q chanQueue) offer(entry outboundEntry) bool {
+	select {
+	case q <- entry:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q chanQueue) take(done <-chan struct{}) (outboundEntry, bool) {
+	select {
+	case entry := <-q:
+		return entry, true
+	case <-done:
+		return outboundEntry{}, false
+	}
+}
+
+func (q chanQueue) poll() (outboundEntry, bool) {
+	select {
+	case entry := <-q:
+		return entry, true
+	default:
+		return outboundEntry{}, false
+	}
+}
+
+func (q chanQueue) capacity() int {
+	return cap(q)
+}
+
+func (q chanQueue) size() int {
+	return len(q)
+}
+
+// mpscQueue defines the lock-free outboundQueue, the ring is rounded up to a power of two,
+// so the entries are counted against the limit if it is not the size of ring.
+type mpscQueue struct {
+	queue   *utils.MPSCQueue[outboundEntry]
+	limit   int
+	bounded bool
+	count   int64         // the reserved entries if bounded
+	notFull chan struct{} // the signal of released reservation if bounded
+}
+
+func newMPSCQueue(capacity int) outboundQueue {
+	// the writers are handed over to the write loop one by one without capacity, like an unbuffered chan.
+	if capacity <= 0 {
+		return newChanQueue(0)
+	}
+
+	queue := utils.NewMPSCQueue[outboundEntry](capacity)
+	return &mpscQueue{queue: queue, limit: capacity, bounded: capacity < queue.Cap(), notFull: make(chan struct{}, 1)}
+}
+
+// reserve a slot under the limit
+func (q *mpscQueue) reserve() bool {
+	if atomic.AddInt64(&q.count, 1) > int64(q.limit) {
+		q.release()
+		return false
+	}
+	return true
+}
+
+// release a reserved slot and wake up a blocked producer
+func (q *mpscQueue) release() {
+	atomic.AddInt64(&q.count, -1)
+	select {
+	case q.notFull <- struct{}{}:
+	default:
+	}
+}
+
+func (q *mpscQueue) put(entry outboundEntry, done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return false
+	default:
+	}
+
+	if !q.bounded {
+		return q.queue.Push(entry, done)
+	}
+
+	// the waiting producers are woken up one by one by the released slots.
+	for !q.reserve() {
+		select {
+		case <-q.notFull:
+		case <-done:
+			return false
+		}
+	}
+
+	if !q.queue.Push(entry, done) {
+		q.release()
+		return false
+	}
+	return true
+}
+
+func (q *mpscQueue) offer(entry outboundEntry) bool {
+	if !q.bounded {
+		return q.queue.TryPush(entry)
+	}
+
+	if !q.reserve() {
+		return false
+	}
+	if !q.queue.TryPush(entry) {
+		q.release()
+		return false
+	}
+	return true
+}
+
+func (q *mpscQueue) take(done <-chan struct{}) (outboundEntry, bool) {
+	entry, ok := q.queue.Pop(done)
+	if ok && q.bounded {
+		q.release()
+	}
+	return entry, ok
+}
+
+func (q *mpscQueue) poll() (outboundEntry, bool) {
+	entry, ok := q.queue.TryPop()
+	if ok && q.bounded {
+		q.release()
+	}
+	return entry, ok
+}
+
+func (q *mpscQueue) capacity() int {
+	return q.limit
+}
+
+func (q *mpscQueue) size() int {
+	return q.queue.Len()
+}
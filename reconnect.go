@@ -0,0 +1,306 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// ErrNotConnected will be returned by ReconnectingChannel.Write if the channel is reconnecting without buffer.
+var ErrNotConnected = errors.New("channel is not connected")
+
+// ReconnectingChannel defines a client channel that reconnects after the underlying channel is closed,
+// the client initializer of bootstrap is called for every underlying channel.
+type ReconnectingChannel interface {
+	// Channel returns the current underlying channel, nil if it is reconnecting.
+	Channel() Channel
+	// IsConnected returns true if the underlying channel is active
+	IsConnected() bool
+	// Write the message to the underlying channel, the message is buffered while reconnecting if WithReconnectBuffer is set,
+	// returns ErrNotConnected or ErrWriteQueueFull if it can not be buffered, ErrChannelClosed after closed or gave up.
+	Write(message Message) error
+	// Close to stop reconnecting and close the underlying channel
+	Close()
+	// Done returns a channel that is closed after closed or gave up
+	Done() <-chan struct{}
+}
+
+// ReconnectOption defines an option of ReconnectingChannel
+type ReconnectOption func(options *reconnectOptions)
+
+// reconnectOptions
+type reconnectOptions struct {
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	jitter         float64
+	maxAttempts    int
+	bufferSize     int
+	attachment     Attachment
+	transport      []transport.Option
+	onConnected    func(channel Channel)
+	onDisconnected func(channel Channel, err error)
+	onGaveUp       func(err error)
+}
+
+// WithReconnectBackoff to set the delay before redialing, it is doubled for the consecutive failures up to max,
+// and reduced by a random ratio up to jitter, default is 100ms, 30s and 0.2.
+func WithReconnectBackoff(min, max time.Duration, jitter float64) ReconnectOption {
+	return func(options *reconnectOptions) {
+		utils.AssertIf(min <= 0 || max < min, "the backoff must be 0 < min <= max")
+		utils.AssertIf(jitter < 0 || jitter > 1, "the jitter must be 0 <= jitter <= 1")
+		options.minBackoff, options.maxBackoff, options.jitter = min, max, jitter
+	}
+}
+
+// WithReconnectAttempts to give up after the consecutive failures of dialing, zero means unlimited.
+func WithReconnectAttempts(attempts int) ReconnectOption {
+	return func(options *reconnectOptions) {
+		utils.AssertIf(attempts < 0, "attempts must be a non-negative integer")
+		options.maxAttempts = attempts
+	}
+}
+
+// WithReconnectBuffer to buffer the messages written while reconnecting, they are written after connected.
+func WithReconnectBuffer(size int) ReconnectOption {
+	return func(options *reconnectOptions) {
+		utils.AssertIf(size < 0, "size must be a non-negative integer")
+		options.bufferSize = size
+	}
+}
+
+// WithReconnectAttachment to set the attachment of the underlying channels
+func WithReconnectAttachment(attachment Attachment) ReconnectOption {
+	return func(options *reconnectOptions) {
+		options.attachment = attachment
+	}
+}
+
+// WithReconnectTransport to set the transport options of dialing
+func WithReconnectTransport(option ...transport.Option) ReconnectOption {
+	return func(options *reconnectOptions) {
+		options.transport = option
+	}
+}
+
+// OnReconnectConnected to observe the connected underlying channel
+func OnReconnectConnected(fn func(channel Channel)) ReconnectOption {
+	return func(options *reconnectOptions) {
+		options.onConnected = fn
+	}
+}
+
+// OnReconnectDisconnected to observe the closed underlying channel with the close error
+func OnReconnectDisconnected(fn func(channel Channel, err error)) ReconnectOption {
+	return func(options *reconnectOptions) {
+		options.onDisconnected = fn
+	}
+}
+
+// OnReconnectGaveUp to observe the last error of dialing after the attempts are exhausted
+func OnReconnectGaveUp(fn func(err error)) ReconnectOption {
+	return func(options *reconnectOptions) {
+		options.onGaveUp = fn
+	}
+}
+
+// NewReconnectingChannel create a ReconnectingChannel that dials the url by the bootstrap in background.
+func NewReconnectingChannel(bs Bootstrap, url string, option ...ReconnectOption) ReconnectingChannel {
+
+	options := reconnectOptions{minBackoff: 100 * time.Millisecond, maxBackoff: 30 * time.Second, jitter: 0.2}
+	for i := range option {
+		option[i](&options)
+	}
+
+	ctx, cancel := context.WithCancel(bs.Context())
+	rc := &reconnectingChannel{options: options, bs: bs, url: url, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	go rc.reconnectLoop()
+	return rc
+}
+
+// implement of ReconnectingChannel
+type reconnectingChannel struct {
+	options  reconnectOptions
+	bs       Bootstrap
+	url      string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mutex    sync.Mutex
+	channel  Channel
+	buffered []Message
+	closed   bool
+	done     chan struct{}
+}
+
+func (rc *reconnectingChannel) Channel() Channel {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return rc.channel
+}
+
+func (rc *reconnectingChannel) IsConnected() bool {
+	channel := rc.Channel()
+	return nil != channel && channel.IsActive()
+}
+
+func (rc *reconnectingChannel) Write(message Message) error {
+
+	rc.mutex.Lock()
+	switch channel := rc.channel; {
+	case rc.closed:
+		rc.mutex.Unlock()
+		Recycle(message)
+		return ErrChannelClosed
+	case nil != channel && channel.IsActive():
+		rc.mutex.Unlock()
+		if channel.Write(message) {
+			return nil
+		}
+
+		// the underlying channel is closed before switched, it is reconnecting rather than closed.
+		rc.mutex.Lock()
+		closed := rc.closed
+		rc.mutex.Unlock()
+		if closed {
+			return ErrChannelClosed
+		}
+		return ErrNotConnected
+	case 0 == rc.options.bufferSize:
+		rc.mutex.Unlock()
+		Recycle(message)
+		return ErrNotConnected
+	case len(rc.buffered) >= rc.options.bufferSize:
+		rc.mutex.Unlock()
+		Recycle(message)
+		return ErrWriteQueueFull
+	default:
+		rc.buffered = append(rc.buffered, message)
+		rc.mutex.Unlock()
+		return nil
+	}
+}
+
+func (rc *reconnectingChannel) Close() {
+	rc.cancel()
+	<-rc.done
+}
+
+func (rc *reconnectingChannel) Done() <-chan struct{} {
+	return rc.done
+}
+
+// backoff returns the delay after the previous delay with jitter
+func (rc *reconnectingChannel) backoff(previous time.Duration) (next, jittered time.Duration) {
+	if next = previous * 2; next < rc.options.minBackoff {
+		next = rc.options.minBackoff
+	} else if next > rc.options.maxBackoff {
+		next = rc.options.maxBackoff
+	}
+	return next, next - time.Duration(rand.Float64()*rc.options.jitter*float64(next))
+}
+
+// setChannel to switch the underlying channel, the buffered messages are written before the new writes.
+func (rc *reconnectingChannel) setChannel(channel Channel) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if nil != channel {
+		for i, message := range rc.buffered {
+			channel.Write(message)
+			rc.buffered[i] = nil
+		}
+		rc.buffered = rc.buffered[:0]
+	}
+	rc.channel = channel
+}
+
+// shutdown to reject the writes and discard the buffered messages
+func (rc *reconnectingChannel) shutdown() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rc.closed, rc.channel = true, nil
+	for _, message := range rc.buffered {
+		Recycle(message)
+	}
+	rc.buffered = nil
+	close(rc.done)
+}
+
+// reconnectLoop to dial the url until closed or gave up
+func (rc *reconnectingChannel) reconnectLoop() {
+	defer rc.shutdown()
+
+	clock := ClockFrom(rc.bs.Context())
+	logger := LoggerFrom(rc.bs.Context())
+
+	var attempts int
+	var delay, wait time.Duration
+	for {
+		channel, err := rc.bs.ConnectContext(rc.ctx, rc.url, rc.options.attachment, rc.options.transport...)
+		if nil != err {
+			if nil != rc.ctx.Err() {
+				return
+			}
+
+			if attempts++; rc.options.maxAttempts > 0 && attempts >= rc.options.maxAttempts {
+				logger.Warnf("gave up connecting to %s after %d attempts: %v", rc.url, attempts, err)
+				if nil != rc.options.onGaveUp {
+					rc.options.onGaveUp(err)
+				}
+				return
+			}
+
+			delay, wait = rc.backoff(delay)
+			logger.Debugf("failed to connect to %s: %v, retrying in %v", rc.url, err, wait)
+			if !sleepContext(rc.ctx, clock, wait) {
+				return
+			}
+			continue
+		}
+
+		attempts, delay = 0, 0
+		rc.setChannel(channel)
+		if nil != rc.options.onConnected {
+			rc.options.onConnected(channel)
+		}
+
+		select {
+		case <-channel.Done():
+		case <-rc.ctx.Done():
+			channel.Close(nil)
+			return
+		}
+
+		rc.setChannel(nil)
+		if nil != rc.options.onDisconnected {
+			rc.options.onDisconnected(channel, channel.CloseErr())
+		}
+
+		// the peer may close the connection at once, so the redialing is delayed as well.
+		delay, wait = rc.backoff(delay)
+		if !sleepContext(rc.ctx, clock, wait) {
+			return
+		}
+	}
+}
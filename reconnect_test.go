@@ -0,0 +1,174 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconnectingChannel(t *testing.T) {
+
+	bs := NewBootstrap(WithClientInitializer(func(channel Channel) {
+		channel.Pipeline().
+			AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}).
+			AddLast(textCodec{}).
+			AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) { ctx.Close(ex) }))
+	}))
+	defer bs.Shutdown()
+
+	// reserve a port that refuses the connections until listened.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	connected, disconnected := make(chan Channel, 4), make(chan Channel, 4)
+	rc := NewReconnectingChannel(bs, "tcp://"+address,
+		WithReconnectBackoff(5*time.Millisecond, 20*time.Millisecond, 0.5),
+		WithReconnectBuffer(2),
+		OnReconnectConnected(func(channel Channel) { connected <- channel }),
+		OnReconnectDisconnected(func(channel Channel, err error) { disconnected <- channel }),
+	)
+	defer rc.Close()
+
+	// the messages are buffered while reconnecting.
+	for _, message := range []string{"a", "b"} {
+		if err := rc.Write(message); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if err := rc.Write("c"); ErrWriteQueueFull != err {
+		t.Fatal("the buffer should be full:", err)
+	}
+
+	if ln, err = net.Listen("tcp", address); nil != err {
+		t.Skip("the reserved port is taken:", err)
+	}
+	defer ln.Close()
+
+	accept := func() (net.Conn, *bufio.Reader) {
+		conn, err := ln.Accept()
+		if nil != err {
+			t.Fatal(err)
+		}
+		return conn, bufio.NewReader(conn)
+	}
+
+	conn, reader := accept()
+	first := <-connected
+	if first != rc.Channel() || !rc.IsConnected() {
+		t.Fatal("the underlying channel should be surfaced")
+	}
+	if data, err := reader.ReadString('$'); nil != err || "a$" != data {
+		t.Fatal(data, err)
+	}
+	if data, err := reader.ReadString('$'); nil != err || "b$" != data {
+		t.Fatal(data, err)
+	}
+
+	// redial after the peer closed the connection.
+	conn.Close()
+	if closed := <-disconnected; closed != first {
+		t.Fatal("unexpected disconnected channel")
+	}
+
+	conn, reader = accept()
+	defer conn.Close()
+	if second := <-connected; second == first || !rc.IsConnected() {
+		t.Fatal("a new underlying channel should be connected")
+	}
+	if err := rc.Write("d"); nil != err {
+		t.Fatal(err)
+	}
+	if data, err := reader.ReadString('$'); nil != err || "d$" != data {
+		t.Fatal(data, err)
+	}
+
+	rc.Close()
+	if err := rc.Write("e"); ErrChannelClosed != err || nil != rc.Channel() {
+		t.Fatal("the writes should be rejected after closed:", err)
+	}
+}
+
+func TestReconnectingChannelGaveUp(t *testing.T) {
+
+	bs := NewBootstrap()
+	defer bs.Shutdown()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	gaveUp := make(chan error, 1)
+	rc := NewReconnectingChannel(bs, "tcp://"+address,
+		WithReconnectBackoff(time.Millisecond, time.Millisecond, 0),
+		WithReconnectAttempts(3),
+		OnReconnectGaveUp(func(err error) { gaveUp <- err }),
+	)
+
+	if err := rc.Write("a"); ErrNotConnected != err && ErrChannelClosed != err {
+		t.Fatal("the write should be rejected while reconnecting:", err)
+	}
+
+	select {
+	case err := <-gaveUp:
+		if nil == err {
+			t.Fatal("the last error should be reported")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the reconnecting should be gave up")
+	}
+
+	<-rc.Done()
+	if err := rc.Write("a"); ErrChannelClosed != err {
+		t.Fatal("the writes should be rejected after gave up:", err)
+	}
+}
+
+func TestReconnectingChannelSwitching(t *testing.T) {
+
+	c, _ := newPipeChannel(1, newDiscardPipeline())
+	defer c.Close(nil)
+	rc := &reconnectingChannel{channel: c, done: make(chan struct{})}
+
+	// the underlying channel rejects the write before it is switched.
+	atomic.StoreInt32(&c.closing, 1)
+	if err := rc.Write([]byte("a")); ErrNotConnected != err {
+		t.Fatal("the write should not be rejected as closed:", err)
+	}
+
+	// the closed underlying channel is treated as reconnecting.
+	c.Close(nil)
+	rc.options.bufferSize = 1
+	if err := rc.Write([]byte("b")); nil != err || 1 != len(rc.buffered) {
+		t.Fatal("the message should be buffered:", err)
+	}
+
+	rc.shutdown()
+	if err := rc.Write([]byte("c")); ErrChannelClosed != err {
+		t.Fatal("the writes should be rejected after closed:", err)
+	}
+}
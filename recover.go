@@ -0,0 +1,85 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"context"
+)
+
+// RecoverAction defines how to handle a panic recovered from the handlers
+type RecoverAction int
+
+const (
+	// RecoverException to route AsException(value, stack) to Pipeline.FireChannelException, the default action.
+	RecoverException RecoverAction = iota
+	// RecoverClose to close the channel with the exception of panic.
+	RecoverClose
+	// RecoverPanic to propagate the panic, the process crashes if it is raised by the loops of channel, e.g: fatal in CI.
+	RecoverPanic
+)
+
+// RecoverPolicy to decide the action of a panic recovered from the handlers.
+//
+// The ctx is the context that recovered the panic: the context that called Write / Trigger / FireRead,
+// the context of idle handlers, or the head of pipeline for the panics recovered by the loops of channel.
+type RecoverPolicy func(ctx HandlerContext, value interface{}, stack []byte) RecoverAction
+
+// recoverPolicyKey is the context key of RecoverPolicy
+type recoverPolicyKey struct{}
+
+// recoverPolicyFrom to get the RecoverPolicy of bootstrap from the context of channel.
+func recoverPolicyFrom(ctx context.Context) RecoverPolicy {
+	policy, _ := ctx.Value(recoverPolicyKey{}).(RecoverPolicy)
+	return policy
+}
+
+// propagatedPanic to carry the panic propagated by RecoverPanic through the other recovery points.
+type propagatedPanic struct {
+	value interface{}
+}
+
+// recoverPanic to handle the recovered value with the RecoverPolicy of channel.
+func recoverPanic(ctx HandlerContext, value interface{}, stack []byte) {
+
+	// the propagated panic is never recovered.
+	if _, ok := value.(propagatedPanic); ok {
+		panic(value)
+	}
+
+	channel := ctx.Channel()
+	action := RecoverException
+	if policy := recoverPolicyFrom(channel.Context()); nil != policy {
+		action = policy(ctx, value, stack)
+	}
+
+	switch action {
+	case RecoverPanic:
+		LoggerFrom(channel.Context()).Errorf("propagate the panic of channel(%s: %s): %v\n%s", channel.Name(), channel.RemoteAddr(), value, stack)
+		panic(propagatedPanic{value: value})
+	case RecoverClose:
+		channel.Close(AsException(value, stack))
+	default:
+		channel.Pipeline().FireChannelException(AsException(value, stack))
+	}
+}
+
+// repanic to crash the process with the original value of the panic propagated by RecoverPanic.
+func repanic(value interface{}) {
+	if p, ok := value.(propagatedPanic); ok {
+		panic(p.value)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// Recyclable defines a message that could be recycled after it is processed.
+//
+// Ownership rules of the Recyclable messages:
+//  1. Inbound: forwarding a message by ctx.HandleRead transfers the ownership to the next handler,
+//     the tail of the pipeline recycles the messages that reached it. A handler that consumes
+//     the message without forwarding it must call Recycle(message) when it is done.
+//  2. Outbound: Channel.Write, ctx.Write and ctx.HandleWrite transfer the ownership to the next handler,
+//     the head of the pipeline recycles the messages after they are written to the transport, and the
+//     channel recycles the rejected writes. A handler that consumes the message without forwarding it
+//     (encoded or dropped) must call Recycle(message) when it is done.
+//  3. A handler that holds the message beyond the handler method (async processing or fan-out) must take
+//     an extra reference by ctx.Retain(message) and release it by Recycle(message) later.
+//
+// Retain requires the message to embed RefCount, a message without RefCount is recycled on the first Recycle.
+type Recyclable interface {
+	Recycle()
+}
+
+// refCounted defines a reference counted message, implemented by RefCount.
+type refCounted interface {
+	retain(n int32)
+	release() bool
+	resetRefs()
+}
+
+// RefCount to embed into the Recyclable message for reference counting, the zero value holds one reference.
+type RefCount struct {
+	// the number of extra references, -1 means released.
+	refs int32
+}
+
+// RefCnt returns the number of references
+func (r *RefCount) RefCnt() int32 {
+	return atomic.LoadInt32(&r.refs) + 1
+}
+
+func (r *RefCount) retain(n int32) {
+	if refs := atomic.AddInt32(&r.refs, n); refs-n < 0 {
+		panic(fmt.Errorf("retain a recycled message: refCnt(%d)", refs-n+1))
+	}
+}
+
+func (r *RefCount) release() bool {
+	switch refs := atomic.AddInt32(&r.refs, -1); {
+	case refs < -1:
+		panic(fmt.Errorf("message has been recycled: refCnt(%d)", refs+1))
+	default:
+		return -1 == refs
+	}
+}
+
+func (r *RefCount) resetRefs() {
+	atomic.StoreInt32(&r.refs, 0)
+}
+
+// Retain to take n extra references of the message, the message must embed RefCount.
+func Retain(message Message, n int) {
+	rc, ok := message.(refCounted)
+	if !ok {
+		panic(fmt.Errorf("message %T is not reference counted, please embed netty.RefCount", message))
+	}
+	rc.retain(int32(n))
+}
+
+// Recycle to release a reference of the message, the message will be recycled when the last reference released.
+func Recycle(message Message) {
+	if r, ok := message.(Recyclable); ok {
+		if rc, ok := message.(refCounted); ok && !rc.release() {
+			return
+		}
+		r.Recycle()
+	}
+}
+
+// recycleReleaser to recycle the message after it is written
+type recycleReleaser struct {
+	message Message
+}
+
+func (r recycleReleaser) Release() {
+	Recycle(r.message)
+}
+
+// MessageLeak defines an un-recycled message tracked by MessagePool
+type MessageLeak struct {
+	Message Message
+	Created time.Time
+	Stack   []byte
+}
+
+// MessagePoolOption defines an option of MessagePool
+type MessagePoolOption func(options *messagePoolOptions)
+
+type messagePoolOptions struct {
+	sampleRate uint32
+}
+
+// maxTrackedMessages limits the tracked messages of MessagePool, the further samples are skipped until some are put back.
+const maxTrackedMessages = 1024
+
+// WithLeakDetection to track one of every sampleRate messages with its creation stack, 1 means all messages.
+// It is intended for debugging, the tracked messages are held by the pool until they are put back.
+func WithLeakDetection(sampleRate int) MessagePoolOption {
+	return func(options *messagePoolOptions) {
+		utils.AssertIf(sampleRate < 0, "sampleRate must be a non-negative integer")
+		options.sampleRate = uint32(sampleRate)
+	}
+}
+
+// MessagePool defines a typed pool of messages
+type MessagePool[T any] struct {
+	pool       sync.Pool
+	sampleRate uint32
+	sequence   uint32
+	mutex      sync.Mutex
+	tracking   map[*T]MessageLeak
+}
+
+// NewMessagePool create a typed message pool
+func NewMessagePool[T any](new func() *T, option ...MessagePoolOption) *MessagePool[T] {
+
+	options := &messagePoolOptions{}
+	for i := range option {
+		option[i](options)
+	}
+
+	return &MessagePool[T]{
+		pool:       sync.Pool{New: func() interface{} { return new() }},
+		sampleRate: options.sampleRate,
+		tracking:   make(map[*T]MessageLeak),
+	}
+}
+
+// Get a message from the pool, the message holds one reference.
+func (p *MessagePool[T]) Get() *T {
+
+	m := p.pool.Get().(*T)
+	if rc, ok := interface{}(m).(refCounted); ok {
+		rc.resetRefs()
+	}
+
+	if p.sampleRate > 0 && 0 == atomic.AddUint32(&p.sequence, 1)%p.sampleRate {
+		p.mutex.Lock()
+		if len(p.tracking) < maxTrackedMessages {
+			p.tracking[m] = MessageLeak{Message: m, Created: time.Now(), Stack: debug.Stack()}
+		}
+		p.mutex.Unlock()
+	}
+
+	return m
+}
+
+// Put the message back to the pool, it should be called by the Recycle method of message.
+func (p *MessagePool[T]) Put(m *T) {
+
+	if p.sampleRate > 0 {
+		p.mutex.Lock()
+		delete(p.tracking, m)
+		p.mutex.Unlock()
+	}
+
+	p.pool.Put(m)
+}
+
+// Leaks returns the tracked messages that have not been put back to the pool, up to maxTrackedMessages.
+func (p *MessagePool[T]) Leaks() []MessageLeak {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	leaks := make([]MessageLeak, 0, len(p.tracking))
+	for _, leak := range p.tracking {
+		leaks = append(leaks, leak)
+	}
+	return leaks
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var framePool = NewMessagePool(func() *pooledFrame { return &pooledFrame{} }, WithLeakDetection(1))
+
+type pooledFrame struct {
+	RefCount
+	bytes.Reader
+	recycled chan struct{}
+}
+
+func (f *pooledFrame) Recycle() {
+	f.recycled <- struct{}{}
+	framePool.Put(f)
+}
+
+func newPooledFrame(data string) *pooledFrame {
+	f := framePool.Get()
+	f.Reset([]byte(data))
+	f.recycled = make(chan struct{}, 1)
+	return f
+}
+
+func assertRecycled(t *testing.T, f *pooledFrame, want bool) {
+	t.Helper()
+	select {
+	case <-f.recycled:
+		if !want {
+			t.Fatal("the message should not be recycled")
+		}
+	default:
+		if want {
+			t.Fatal("the message should be recycled, refCnt:", f.RefCnt())
+		}
+	}
+}
+
+func TestRecycleInbound(t *testing.T) {
+
+	var retained *pooledFrame
+	p := NewPipelineWith()
+	p.AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		if nil == retained {
+			// keep the first message for async processing.
+			retained = message.(*pooledFrame)
+			ctx.Retain(message)
+		}
+		ctx.HandleRead(message)
+	}))
+
+	newPipeChannel(1, p)
+
+	first, second := newPooledFrame("first"), newPooledFrame("second")
+	p.FireChannelRead(first)
+	p.FireChannelRead(second)
+
+	// the second message reached the tail.
+	assertRecycled(t, second, true)
+
+	// the first message is held by the handler.
+	assertRecycled(t, first, false)
+	if 1 != first.RefCnt() {
+		t.Fatal("unexpected refCnt:", first.RefCnt())
+	}
+
+	Recycle(retained)
+	assertRecycled(t, first, true)
+}
+
+func TestRecycleFanOut(t *testing.T) {
+
+	var channels []Channel
+	for i := 0; i < 3; i++ {
+		p := NewPipelineWith()
+		p.AddLast(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+			// encode the message to []byte, the encoder recycles the original message.
+			data, _ := ioutil.ReadAll(message.(*pooledFrame))
+			Recycle(message)
+			ctx.HandleWrite(data)
+		}))
+
+		c, peer := newPipeChannel(int64(i), p)
+		c.serveChannel()
+		go ioutil.ReadAll(peer)
+		defer c.Close(nil)
+		channels = append(channels, c)
+	}
+
+	frame := newPooledFrame("broadcast")
+	// every channel takes one reference.
+	Retain(frame, len(channels)-1)
+
+	for i, c := range channels {
+		assertRecycled(t, frame, false)
+		_, _ = frame.Seek(0, 0)
+		if !c.Write(frame) {
+			t.Fatal("write failed:", i)
+		}
+	}
+
+	assertRecycled(t, frame, true)
+}
+
+func TestRecycleOutbound(t *testing.T) {
+
+	p := NewPipelineWith()
+	c, peer := newPipeChannel(1, p)
+	c.serveChannel()
+
+	// the message reached the head will be recycled after it is written.
+	frame := newPooledFrame("hello")
+	if !c.Write(frame) {
+		t.Fatal("write failed")
+	}
+
+	buffer := make([]byte, 5)
+	if _, err := peer.Read(buffer); nil != err || "hello" != string(buffer) {
+		t.Fatal(string(buffer), err)
+	}
+	<-frame.recycled
+
+	// the message written after the channel closed will be dropped.
+	c.Close(nil)
+	dropped := newPooledFrame("dropped")
+	if c.Write(dropped) {
+		t.Fatal("write should be failed after channel closed")
+	}
+	assertRecycled(t, dropped, true)
+}
+
+func TestRecycleForward(t *testing.T) {
+
+	p := NewPipelineWith()
+	p.AddLast(OutboundHandlerFunc(func(ctx OutboundContext, message Message) {
+		// forward the same message from the handler.
+		ctx.Write(message)
+	}))
+
+	c, peer := newPipeChannel(1, p)
+	c.serveChannel()
+	defer c.Close(nil)
+
+	frame := newPooledFrame("hello")
+	if !c.Write(frame) {
+		t.Fatal("write failed")
+	}
+
+	// the pipe is not read yet.
+	assertRecycled(t, frame, false)
+
+	buffer := make([]byte, 5)
+	if _, err := peer.Read(buffer); nil != err || "hello" != string(buffer) {
+		t.Fatal(string(buffer), err)
+	}
+	<-frame.recycled
+
+	// the message is recycled once.
+	if 0 != frame.RefCnt() {
+		t.Fatal("unexpected refCnt:", frame.RefCnt())
+	}
+}
+
+func TestRecycleTwice(t *testing.T) {
+
+	frame := newPooledFrame("twice")
+	Recycle(frame)
+	<-frame.recycled
+
+	defer func() {
+		if err, ok := recover().(error); !ok || !strings.Contains(err.Error(), "has been recycled") {
+			t.Fatal("recycle twice should panic:", err)
+		}
+	}()
+
+	Recycle(frame)
+}
+
+func TestMessagePoolLeaks(t *testing.T) {
+
+	pool := NewMessagePool(func() *pooledFrame { return &pooledFrame{} }, WithLeakDetection(1))
+
+	leaked := pool.Get()
+	pool.Put(pool.Get())
+
+	leaks := pool.Leaks()
+	if 1 != len(leaks) || leaked != leaks[0].Message {
+		t.Fatal("unexpected leaks:", len(leaks))
+	}
+
+	if !bytes.Contains(leaks[0].Stack, []byte("TestMessagePoolLeaks")) || leaks[0].Created.IsZero() {
+		t.Fatal("unexpected creation stack:", string(leaks[0].Stack))
+	}
+}
+
+func TestMessagePoolLeaksLimit(t *testing.T) {
+
+	pool := NewMessagePool(func() *pooledFrame { return &pooledFrame{} }, WithLeakDetection(1))
+
+	// the real leaks must not grow the tracked messages forever.
+	for i := 0; i < maxTrackedMessages+10; i++ {
+		pool.Get()
+	}
+	if n := len(pool.Leaks()); maxTrackedMessages != n {
+		t.Fatal("unexpected leaks:", n)
+	}
+
+	// the samples are tracked again after the messages are put back.
+	pool.Put(pool.Leaks()[0].Message.(*pooledFrame))
+	tracked := pool.Get()
+	if leaks := pool.Leaks(); maxTrackedMessages != len(leaks) || !containsLeak(leaks, tracked) {
+		t.Fatal("the message should be tracked after the others are put back")
+	}
+}
+
+// containsLeak returns true if the message is tracked in the leaks
+func containsLeak(leaks []MessageLeak, m Message) bool {
+	for _, leak := range leaks {
+		if m == leak.Message {
+			return true
+		}
+	}
+	return false
+}
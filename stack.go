@@ -0,0 +1,209 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// modulePath of go-netty, used to recognize internal stack frames.
+var modulePath = reflect.TypeOf(exception{}).PkgPath()
+
+// StackOption defines an option to format the stack trace.
+type StackOption func(options *stackOptions)
+
+// stackOptions
+type stackOptions struct {
+	skipInternal  bool
+	maxDepth      int
+	compact       bool
+	highlightUser bool
+}
+
+// SkipInternalFrames to skip the frames of go-netty and go runtime.
+func SkipInternalFrames() StackOption {
+	return func(options *stackOptions) {
+		options.skipInternal = true
+	}
+}
+
+// MaxStackDepth to limit the number of frames, zero means unlimited.
+func MaxStackDepth(depth int) StackOption {
+	return func(options *stackOptions) {
+		options.maxDepth = depth
+	}
+}
+
+// CompactStack to write every frame in a single line: function file:line
+func CompactStack() StackOption {
+	return func(options *stackOptions) {
+		options.compact = true
+	}
+}
+
+// HighlightUserFrame to mark the first frame outside of go-netty and go runtime.
+func HighlightUserFrame() StackOption {
+	return func(options *stackOptions) {
+		options.highlightUser = true
+	}
+}
+
+// stackFrame defines a frame parsed from the stack trace.
+type stackFrame struct {
+	function string // e.g: github.com/go-netty/go-netty.(*channel).readLoop
+	file     string // e.g: /go/src/github.com/go-netty/go-netty/channel.go:251
+	offset   string // e.g: +0x1a5
+}
+
+// packagePath of the frame function.
+func (f stackFrame) packagePath() string {
+	function := strings.TrimPrefix(f.function, "created by ")
+	// github.com/go-netty/go-netty/codec/frame.(*lengthFieldCodec).HandleRead
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// isInternal returns true if the frame belongs to go-netty or go runtime.
+func (f stackFrame) isInternal() bool {
+	switch pkg := f.packagePath(); {
+	case "panic" == pkg:
+		return true
+	case "runtime" == pkg || strings.HasPrefix(pkg, "runtime/"):
+		return true
+	case modulePath == pkg || strings.HasPrefix(pkg, modulePath+"/"):
+		return true
+	}
+	return false
+}
+
+// parseStack to parse the stack trace that created by runtime/debug.Stack()
+func parseStack(stack []byte) (header string, frames []stackFrame) {
+
+	lines := strings.Split(strings.TrimRight(string(stack), "\n"), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "goroutine ") {
+		header, lines = lines[0], lines[1:]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if "" == strings.TrimSpace(line) || strings.HasPrefix(line, "\t") {
+			continue
+		}
+
+		// strip arguments: main.handler(0xc000010000, {0x1, 0x2})
+		frame := stackFrame{function: line}
+		if strings.HasSuffix(line, ")") && !strings.HasPrefix(line, "created by ") {
+			if index := strings.LastIndex(line, "("); index > 0 {
+				frame.function = line[:index]
+			}
+		}
+
+		// strip goroutine id: created by main.main in goroutine 1
+		if index := strings.Index(frame.function, " in goroutine "); index > 0 {
+			frame.function = frame.function[:index]
+		}
+
+		// location: \t/path/to/file.go:123 +0x1a
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\t") {
+			i++
+			location := strings.TrimSpace(lines[i])
+			if index := strings.LastIndex(location, " +0x"); index > 0 {
+				frame.file, frame.offset = location[:index], location[index+1:]
+			} else {
+				frame.file = location
+			}
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return
+}
+
+// FormatStack to format the stack trace with options, the raw stack will be returned if no option is passed.
+func FormatStack(stack []byte, option ...StackOption) []byte {
+
+	if 0 == len(option) {
+		return stack
+	}
+
+	options := &stackOptions{}
+	for i := range option {
+		option[i](options)
+	}
+
+	header, frames := parseStack(stack)
+
+	var buffer bytes.Buffer
+	if "" != header {
+		buffer.WriteString(header)
+		buffer.WriteString("\n")
+	}
+
+	var highlighted bool
+	var depth, skipped int
+	for _, frame := range frames {
+
+		internal := frame.isInternal()
+		if internal && options.skipInternal {
+			continue
+		}
+
+		if options.maxDepth > 0 && depth >= options.maxDepth {
+			skipped++
+			continue
+		}
+		depth++
+
+		var prefix string
+		if options.highlightUser {
+			prefix = "  "
+			if !internal && !highlighted {
+				prefix, highlighted = "> ", true
+			}
+		}
+
+		if options.compact {
+			fmt.Fprintf(&buffer, "%s%s %s\n", prefix, frame.function, frame.file)
+			continue
+		}
+
+		var call = frame.function
+		if !strings.HasPrefix(call, "created by ") {
+			call += "()"
+		}
+
+		fmt.Fprintf(&buffer, "%s%s\n\t%s", prefix, call, frame.file)
+		if "" != frame.offset {
+			buffer.WriteString(" ")
+			buffer.WriteString(frame.offset)
+		}
+		buffer.WriteString("\n")
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(&buffer, "... %d more frames\n", skipped)
+	}
+
+	return buffer.Bytes()
+}
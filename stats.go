@@ -0,0 +1,190 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"context"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// ChannelStats defines the counters of channel
+type ChannelStats struct {
+	BytesRead       int64     `json:"bytes_read"`       // the bytes read from transport
+	BytesWritten    int64     `json:"bytes_written"`    // the bytes written to transport
+	Reads           int64     `json:"reads"`            // the reads dispatched to the pipeline
+	Writes          int64     `json:"writes"`           // the combined writes to transport
+	MessagesRead    int64     `json:"messages_read"`    // the messages delivered to the last inbound handler of pipeline
+	MessagesWritten int64     `json:"messages_written"` // the messages reached the head of pipeline to be written
+	ActiveSince     time.Time `json:"active_since"`     // the time that the channel is served
+	LastActivity    time.Time `json:"last_activity"`    // the time of the last read or write of transport
+	PendingBytes    int64     `json:"pending_bytes"`    // the bytes queued to be written
+}
+
+// channelStats defines the atomic counters of channel
+type channelStats struct {
+	bytesRead       int64
+	bytesWritten    int64
+	reads           int64
+	writes          int64
+	messagesRead    int64
+	messagesWritten int64
+	activeSince     int64 // unix nano
+	lastActivity    int64 // unix nano
+}
+
+func (s *channelStats) load() ChannelStats {
+	return ChannelStats{
+		BytesRead:       atomic.LoadInt64(&s.bytesRead),
+		BytesWritten:    atomic.LoadInt64(&s.bytesWritten),
+		Reads:           atomic.LoadInt64(&s.reads),
+		Writes:          atomic.LoadInt64(&s.writes),
+		MessagesRead:    atomic.LoadInt64(&s.messagesRead),
+		MessagesWritten: atomic.LoadInt64(&s.messagesWritten),
+		ActiveSince:     unixTime(atomic.LoadInt64(&s.activeSince)),
+		LastActivity:    unixTime(atomic.LoadInt64(&s.lastActivity)),
+	}
+}
+
+// unixTime returns the time of unix nano, zero time for zero.
+func unixTime(nano int64) time.Time {
+	if 0 == nano {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// statsTransport to count the bytes read from the wrapped transport
+type statsTransport struct {
+	transport.Transport
+	channel *channel
+}
+
+func (t *statsTransport) Read(b []byte) (int, error) {
+	n, err := t.Transport.Read(b)
+	if n > 0 {
+		atomic.AddInt64(&t.channel.stats.bytesRead, int64(n))
+		t.channel.touch()
+	}
+	return n, err
+}
+
+// Buffered returns the bytes buffered by the wrapped transport
+func (t *statsTransport) Buffered() int {
+	return transport.Buffered(t.Transport)
+}
+
+// Release to release the wrapped transport
+func (t *statsTransport) Release() {
+	utils.Release(t.Transport)
+}
+
+// ChannelDebugInfo defines the runtime information of a channel
+type ChannelDebugInfo struct {
+	ID            int64         `json:"id"`
+	Name          string        `json:"name"`
+	LocalAddr     string        `json:"local_addr"`
+	RemoteAddr    string        `json:"remote_addr"`
+	Listener      string        `json:"listener,omitempty"` // the url of listener that accepted the channel
+	Pipeline      string        `json:"pipeline"`
+	Stats         ChannelStats  `json:"stats"`
+	QueueDepth    int           `json:"queue_depth"` // the number of entries waiting in the write queue
+	QueueCapacity int           `json:"queue_capacity"`
+	Created       time.Time     `json:"created"`
+	Age           time.Duration `json:"age_ns"`
+}
+
+// listenerKey is the context key of the listener url
+type listenerKey struct{}
+
+// listenerURL returns the url of listener that accepted the channel, empty for the client channels.
+func listenerURL(ctx context.Context) string {
+	url, _ := ctx.Value(listenerKey{}).(string)
+	return url
+}
+
+// Stats returns the counters of channel
+func (c *channel) Stats() ChannelStats {
+	stats := c.stats.load()
+	stats.PendingBytes = atomic.LoadInt64(&c.writability.queuedBytes)
+	return stats
+}
+
+// counters returns the atomic counters of channel
+func (c *channel) counters() *channelStats {
+	return &c.stats
+}
+
+// touch to record the activity of transport
+func (c *channel) touch() {
+	atomic.StoreInt64(&c.stats.lastActivity, ClockFrom(c.ctx).Now().UnixNano())
+}
+
+// debugInfo returns the runtime information of channel without blocking the read & write loops.
+func (c *channel) debugInfo() ChannelDebugInfo {
+	return ChannelDebugInfo{
+		ID:            c.id,
+		Name:          c.name,
+		LocalAddr:     c.LocalAddr(),
+		RemoteAddr:    c.RemoteAddr(),
+		Listener:      listenerURL(c.ctx),
+		Pipeline:      c.pipeline.Dump(),
+		Stats:         c.Stats(),
+		QueueDepth:    c.sendQueue.size(),
+		QueueCapacity: c.sendQueue.capacity(),
+		Created:       c.created,
+		Age:           ClockFrom(c.ctx).Now().Sub(c.created),
+	}
+}
+
+// profileLabels returns the context with the pprof labels of channel, the goroutines of channel are labeled by it.
+func (c *channel) profileLabels() context.Context {
+	labels := []string{"netty.channel", strconv.FormatInt(c.id, 10), "netty.remote", c.RemoteAddr()}
+	if url := listenerURL(c.ctx); "" != url {
+		labels = append(labels, "netty.listener", url)
+	}
+	return pprof.WithLabels(c.ctx, pprof.Labels(labels...))
+}
+
+// goWithLabels to run the fn in a new goroutine with the pprof labels
+func goWithLabels(ctx context.Context, fn func()) {
+	go func() {
+		pprof.SetGoroutineLabels(ctx)
+		fn()
+	}()
+}
+
+// DebugSnapshot returns the runtime information of the active channels in the order of id
+func (bs *bootstrap) DebugSnapshot() []ChannelDebugInfo {
+
+	var infos []ChannelDebugInfo
+	for _, channel := range bs.activeChannels() {
+		infos = append(infos, channel.debugInfo())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+
+	return infos
+}
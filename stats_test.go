@@ -0,0 +1,241 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/transport/tcp"
+)
+
+func TestBootstrapDebugSnapshot(t *testing.T) {
+
+	received := make(chan string, 2)
+	bs := NewBootstrap(
+		WithChannel(NewBufferedChannel(16, 1024)),
+		WithTransport(tcp.New()),
+		WithChildInitializer(func(channel Channel) {
+			channel.Pipeline().
+				AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}).
+				AddLast(&textCodec{}).
+				AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) { ctx.Write(message) })).
+				AddLast(ignoreException)
+		}),
+		WithClientInitializer(func(channel Channel) {
+			channel.Pipeline().
+				AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}).
+				AddLast(&textCodec{}).
+				AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) { received <- message.(string) })).
+				AddLast(ignoreException)
+		}),
+	)
+	defer bs.Shutdown()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// serve the accepted connections like the listener of bootstrap does.
+	url := "tcp://" + ln.Addr().String()
+	listenerCtx := context.WithValue(bs.Context(), listenerKey{}, url)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+			bs.(*bootstrap).serveTransport(listenerCtx, &connTransport{Conn: conn}, nil, true)
+		}
+	}()
+
+	var clients []Channel
+	for i := 0; i < 2; i++ {
+		client, err := bs.Connect(url, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+		defer client.Close(nil)
+		clients = append(clients, client)
+
+		client.Write("hello")
+		if got := <-received; "hello" != got {
+			t.Fatal("unexpected echo:", got)
+		}
+	}
+
+	// the counters of the server side are updated after the echo is written.
+	completed := func(infos []ChannelDebugInfo) bool {
+		for _, info := range infos {
+			if 1 != info.Stats.Writes || 6 != info.Stats.BytesWritten {
+				return false
+			}
+		}
+		return true
+	}
+
+	var infos []ChannelDebugInfo
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if infos = bs.DebugSnapshot(); 4 == len(infos) && completed(infos) {
+			break
+		}
+	}
+
+	if 4 != len(infos) {
+		t.Fatal("unexpected channels:", len(infos))
+	}
+
+	for i, info := range infos {
+
+		if i > 0 && info.ID <= infos[i-1].ID {
+			t.Fatal("the channels are not ordered by id:", infos[i-1].ID, info.ID)
+		}
+
+		client := clients[0].ID() == info.ID || clients[1].ID() == info.ID
+		if client && "" != info.Listener || !client && url != info.Listener {
+			t.Fatal("unexpected listener:", info.ID, info.Listener)
+		}
+
+		if "" == info.LocalAddr || "" == info.RemoteAddr || !strings.Contains(info.Pipeline, "delimiterCodec") {
+			t.Fatalf("unexpected channel info: %+v", info)
+		}
+
+		// hello$
+		if stats := info.Stats; 6 != stats.BytesRead || 6 != stats.BytesWritten || stats.Reads < 1 || 1 != stats.Writes {
+			t.Fatalf("unexpected stats of channel %d: %+v", info.ID, stats)
+		}
+
+		if 0 != info.QueueDepth || 16 != info.QueueCapacity || info.Created.IsZero() || info.Age <= 0 {
+			t.Fatalf("unexpected channel info: %+v", info)
+		}
+	}
+
+	// the closed channels are removed from the snapshot.
+	clients[0].Close(nil)
+	<-clients[0].Context().Done()
+	for _, info := range bs.DebugSnapshot() {
+		if clients[0].ID() == info.ID {
+			t.Fatal("the closed channel is still in snapshot")
+		}
+	}
+}
+
+func TestChannelProfileLabels(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), listenerKey{}, "tcp://127.0.0.1:9527")
+	server, client := tcpPair(t)
+	defer client.Close()
+
+	p := NewPipelineWith().AddLast(ignoreException)
+	c := newChannelWith(ctx, p, &pipeTransport{Conn: server}, 9527, 128, parseChannelOptions(ctx)).(*channel)
+	p.(*pipeline).channel = c
+	c.serveChannel()
+	defer c.Close(nil)
+
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); nil != err {
+		t.Fatal(err)
+	}
+
+	for _, label := range []string{`"netty.channel":"9527"`, `"netty.listener":"tcp://127.0.0.1:9527"`, `"netty.remote":` + strconv.Quote(c.RemoteAddr())} {
+		if !strings.Contains(profile.String(), label) {
+			t.Fatal("the goroutines of channel are not labeled:", label)
+		}
+	}
+}
+
+func TestChannelStatsUnhandled(t *testing.T) {
+
+	// the messages reaching the tail are counted if there is no inbound handler.
+	p := NewPipelineWith()
+	c, _ := newPipeChannel(1, p)
+	defer c.Close(nil)
+
+	for i := 0; i < 3; i++ {
+		p.FireChannelRead("unhandled")
+	}
+	if stats := c.Stats(); 3 != stats.MessagesRead {
+		t.Fatal("unexpected stats:", stats)
+	}
+
+	// the last inbound handler is updated after the mutations.
+	p.AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {}), ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {}))
+	p.FireChannelRead("handled")
+	if stats := c.Stats(); 4 != stats.MessagesRead {
+		t.Fatal("unexpected stats:", stats)
+	}
+}
+
+func TestChannelStats(t *testing.T) {
+
+	received := make(chan string, 3)
+	p := NewPipelineWith().AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}, textCodec{}).
+		AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+			received <- message.(string)
+		})).
+		// the trailing handler that is not inbound does not hide the last inbound handler.
+		AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {}))
+
+	c, peer := newPipeChannel(1, p)
+	if stats := c.Stats(); !stats.ActiveSince.IsZero() || !stats.LastActivity.IsZero() {
+		t.Fatal("the channel is not served:", stats)
+	}
+
+	c.serveChannel()
+	defer c.Close(nil)
+
+	served := c.Stats()
+	if served.ActiveSince.IsZero() || served.LastActivity.Before(served.ActiveSince) {
+		t.Fatal("unexpected stats:", served)
+	}
+
+	if _, err := peer.Write([]byte("a$b$c$")); nil != err {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		<-received
+	}
+
+	c.Write("hello")
+	c.Write("world")
+	buffer := make([]byte, 12)
+	if _, err := io.ReadFull(peer, buffer); nil != err || "hello$world$" != string(buffer) {
+		t.Fatal(string(buffer), err)
+	}
+
+	// the write loop counts the bytes after written.
+	var stats ChannelStats
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if stats = c.Stats(); 12 == stats.BytesWritten {
+			break
+		}
+	}
+
+	if 6 != stats.BytesRead || 12 != stats.BytesWritten || 3 != stats.MessagesRead || 2 != stats.MessagesWritten ||
+		stats.LastActivity.Before(served.LastActivity) {
+		t.Fatal("unexpected stats:", stats)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// TakeoverHTTP to hijack the connection of http request, and serve it by the child initializer of bootstrap.
+//
+// Nothing is written to the connection by TakeoverHTTP, the response of upgrading (e.g. 101 Switching Protocols)
+// should be written before it by w.WriteHeader, or written to the returned channel by Channel.Writev.
+// The bytes that have been read by net/http are replayed as the first inbound bytes, and the deadlines set by
+// http.Server are cleared. The connection is no longer tracked by http.Server, it is closed with the channel.
+func TakeoverHTTP(w http.ResponseWriter, r *http.Request, bs Bootstrap, attachment Attachment) (Channel, error) {
+
+	b, ok := bs.(*bootstrap)
+	if !ok {
+		return nil, fmt.Errorf("unsupported bootstrap: %T", bs)
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("the ResponseWriter does not support hijacking")
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if nil != err {
+		return nil, err
+	}
+
+	// the response written by the handler may be buffered.
+	if err = buffered.Flush(); nil == err {
+		err = conn.SetDeadline(time.Time{})
+	}
+
+	if nil != err {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	t := transport.PushbackTransport(&connTransport{Conn: conn})
+
+	// replay the bytes read ahead by net/http.
+	if n := buffered.Reader.Buffered(); n > 0 {
+		peeked, _ := buffered.Reader.Peek(n)
+		t.(utils.Unreader).Unread(append([]byte(nil), peeked...))
+	}
+
+	return b.serveTransport(b.bootstrapCtx, t, attachment, true), nil
+}
+
+// connTransport to wrap a net.Conn as transport
+type connTransport struct {
+	net.Conn
+}
+
+func (t *connTransport) Writev(buffs transport.Buffers) (int64, error) {
+	return buffs.Buffers.WriteTo(t.Conn)
+}
+
+func (t *connTransport) Flush() error {
+	return nil
+}
+
+func (t *connTransport) RawTransport() interface{} {
+	return t.Conn
+}
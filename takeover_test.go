@@ -0,0 +1,134 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTakeoverServer to upgrade the requests to the pipeline that echoes the frames with a prefix.
+func newTakeoverServer(t *testing.T) (*httptest.Server, chan Channel) {
+
+	bs := NewBootstrap(WithChildInitializer(func(channel Channel) {
+		channel.Pipeline().
+			AddLast(delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true}).
+			AddLast(textCodec{}).
+			AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+				ctx.Write(ctx.Channel().Attachment().(string) + message.(string))
+			})).
+			AddLast(ignoreException)
+	}))
+	t.Cleanup(bs.Shutdown)
+
+	channels := make(chan Channel, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if "netty" != r.Header.Get("Upgrade") {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "netty")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+
+		channel, err := TakeoverHTTP(w, r, bs, "echo:")
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		channels <- channel
+	}))
+
+	// the deadlines set by http.Server must be cleared.
+	server.Config.ReadTimeout = 50 * time.Millisecond
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return server, channels
+}
+
+func TestTakeoverHTTP(t *testing.T) {
+
+	server, channels := newTakeoverServer(t)
+
+	request, _ := http.NewRequest("GET", server.URL+"/upgrade", nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "netty")
+
+	response, err := server.Client().Do(request)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if http.StatusSwitchingProtocols != response.StatusCode {
+		t.Fatal("unexpected status:", response.Status)
+	}
+
+	channel := <-channels
+	conn := response.Body.(io.ReadWriteCloser)
+
+	// exchange the frames after the deadlines of http.Server.
+	time.Sleep(100 * time.Millisecond)
+	for _, frame := range []string{"hello", "world"} {
+		if _, err := conn.Write([]byte(frame + "$")); nil != err {
+			t.Fatal(err)
+		}
+		if got := readString(t, conn, len("echo:"+frame+"$")); "echo:"+frame+"$" != got {
+			t.Fatal("unexpected frame:", got)
+		}
+	}
+
+	// the connection is closed with the channel.
+	channel.Close(nil)
+	if _, err := conn.Read(make([]byte, 1)); io.EOF != err {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestTakeoverHTTPReplay(t *testing.T) {
+
+	server, _ := newTakeoverServer(t)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the frames are sent with the request, they have been read ahead by net/http.
+	if _, err := conn.Write([]byte("GET /upgrade HTTP/1.1\r\nHost: netty\r\nConnection: Upgrade\r\nUpgrade: netty\r\n\r\nearly$bird$")); nil != err {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if nil != err || http.StatusSwitchingProtocols != response.StatusCode {
+		t.Fatal("unexpected response:", response, err)
+	}
+
+	if got := readString(t, reader, len("echo:early$echo:bird$")); "echo:early$echo:bird$" != got {
+		t.Fatal("unexpected frames:", got)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"unsafe"
+)
+
+// HandlerFactory to create a stateful handler for every pipeline of the template.
+type HandlerFactory func() Handler
+
+// NewPipelineTemplate to build a template of pipeline once, the pipelines are stamped out from it without
+// running the initializer, the contexts of a pipeline are allocated at once.
+//
+// The handlers are shared by all pipelines of the template, so they must be stateless,
+// the stateful handlers should be passed as HandlerFactory (or func() Handler) which is called for every pipeline.
+func NewPipelineTemplate(handlers ...Handler) PipelineFactory {
+
+	t := &pipelineTemplate{
+		bindings:  make([]handlerBinding, len(handlers)),
+		factories: make([]HandlerFactory, len(handlers)),
+	}
+
+	for i, h := range handlers {
+		switch factory := h.(type) {
+		case HandlerFactory:
+			t.factories[i] = factory
+		case func() Handler:
+			t.factories[i] = factory
+		default:
+			// checking handler.
+			checkHandler(h)
+			t.bindings[i].bind(h)
+		}
+	}
+
+	return t.newPipeline
+}
+
+// pipelineTemplate defines the shared handlers are converted once, and the factories of stateful handlers.
+type pipelineTemplate struct {
+	bindings  []handlerBinding
+	factories []HandlerFactory
+}
+
+// newPipeline to stamp out a pipeline from the template
+func (t *pipelineTemplate) newPipeline() Pipeline {
+
+	// head + handlers + tail
+	size := len(t.bindings) + 2
+	contexts := make([]handlerContext, size)
+	bindings := make([]handlerBinding, size)
+
+	bindings[0].bind(new(headHandler))
+	bindings[size-1].bind(new(tailHandler))
+	for i := range t.bindings {
+		if factory := t.factories[i]; nil != factory {
+			handler := factory()
+			checkHandler(handler)
+			bindings[i+1].bind(handler)
+		} else {
+			bindings[i+1] = t.bindings[i]
+		}
+	}
+
+	p := &pipeline{head: &contexts[0], tail: &contexts[size-1], size: size}
+	for i := range contexts {
+		contexts[i].pipeline = p
+		contexts[i].binding = unsafe.Pointer(&bindings[i])
+		if i > 0 {
+			contexts[i].prev = unsafe.Pointer(&contexts[i-1])
+		}
+		if i < size-1 {
+			contexts[i].next = unsafe.Pointer(&contexts[i+1])
+		}
+	}
+
+	// the pipeline is not shared yet, the callbacks are invoked without the lock.
+	for i := 1; i < size-1; i++ {
+		bindings[i].handleAdded(&contexts[i])
+	}
+	return p
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+// counterHandler defines a stateful handler
+type counterHandler struct {
+	reads int
+}
+
+func (h *counterHandler) HandleRead(ctx InboundContext, message Message) {
+	h.reads++
+	ctx.HandleRead(message)
+}
+
+func TestPipelineTemplate(t *testing.T) {
+
+	var events []string
+	factory := NewPipelineTemplate(
+		oneHandler{},
+		HandlerFactory(func() Handler { return &counterHandler{} }),
+		func() Handler { return &lifecycleHandler{name: "stateful", events: &events} },
+		threeHandler{},
+	)
+
+	want := NewPipelineWith().AddLast(oneHandler{}, &counterHandler{}, &lifecycleHandler{events: new([]string)}, threeHandler{}).Dump()
+
+	p1, p2 := factory(), factory()
+	if want != p1.Dump() || want != p2.Dump() {
+		t.Fatal("unexpected pipeline:", p1.Dump(), "want:", want)
+	}
+
+	// the stateful handlers are created for every pipeline.
+	if p1.ContextAt(2).Handler() == p2.ContextAt(2).Handler() || fmt.Sprint(events) != "[added:stateful added:stateful]" {
+		t.Fatal("the stateful handlers are shared:", events)
+	}
+
+	var received []Message
+	p1.AddLast(InboundHandlerFunc(func(ctx InboundContext, message Message) {
+		received = append(received, message)
+	}))
+	p1.FireChannelRead("hello")
+
+	if 1 != p1.ContextAt(2).Handler().(*counterHandler).reads || 0 != p2.ContextAt(2).Handler().(*counterHandler).reads {
+		t.Fatal("unexpected states of the handlers")
+	}
+
+	// the stamped pipelines are mutable.
+	if fmt.Sprint(received) != "[hello]" || !p1.Remove(p1.ContextAt(3).Handler()) || 6 != p1.Size() || 6 != p2.Size() {
+		t.Fatal("unexpected pipeline:", received, p1.Dump(), p2.Dump())
+	}
+
+	// the invalid handlers are rejected by the template.
+	defer func() {
+		if nil == recover() {
+			t.Fatal("the invalid handler should be rejected")
+		}
+	}()
+	NewPipelineTemplate("invalid")
+}
+
+func TestBootstrapPipelineTemplate(t *testing.T) {
+
+	bs := NewBootstrap(WithPipelineTemplate(
+		delimiterCodec{maxFrameLength: 1024, delimiter: []byte("$"), stripDelimiter: true},
+		textCodec{},
+		InboundHandlerFunc(func(ctx InboundContext, message Message) {
+			ctx.Write(message.(string) + "$")
+		}),
+	))
+	defer bs.Shutdown()
+
+	local, peer := net.Pipe()
+	defer peer.Close()
+
+	// the pipeline is stamped out without the initializer.
+	bs.(*bootstrap).serveTransport(bs.Context(), &pipeTransport{Conn: local}, nil, true)
+
+	if _, err := peer.Write([]byte("hello$")); nil != err {
+		t.Fatal(err)
+	}
+
+	if echo := readString(t, peer, 6); "hello$" != echo {
+		t.Fatal("unexpected echo:", echo)
+	}
+}
+
+func BenchmarkPipelineSetup(b *testing.B) {
+
+	handlers := func() []Handler {
+		return []Handler{oneHandler{}, twoHandler{}, &counterHandler{}, threeHandler{}, fourHandler{}, fiveHandler{}}
+	}
+
+	b.Run("initializer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			NewPipelineWith().AddLast(handlers()...)
+		}
+	})
+
+	b.Run("template", func(b *testing.B) {
+		factory := NewPipelineTemplate(oneHandler{}, twoHandler{}, HandlerFactory(func() Handler { return &counterHandler{} }), threeHandler{}, fourHandler{}, fiveHandler{})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			factory()
+		}
+	})
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// AcceptPolicy defines the handling of the accept errors of listener.
+type AcceptPolicy struct {
+	// Retryable to classify the accept errors, the temporary or timeout net.Error is retryable by default.
+	Retryable func(err error) bool
+	// MinBackoff of the retry delay, it is doubled for the consecutive errors up to MaxBackoff, 5ms & 1s by default.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnError to observe the retryable errors before waiting for the delay, they are logged as warnings by default.
+	OnError func(err error, delay time.Duration)
+}
+
+// Backoff returns the delay after the previous delay
+func (p AcceptPolicy) Backoff(previous time.Duration) time.Duration {
+	if previous *= 2; previous < p.MinBackoff {
+		return p.MinBackoff
+	} else if previous > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return previous
+}
+
+// TemporaryError returns true if the error is a temporary or timeout net.Error, e.g: too many open files.
+func TemporaryError(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && (ne.Temporary() || ne.Timeout())
+}
+
+var acceptPolicyKey = struct{ key string }{"go-netty-transport-accept-policy"}
+
+// WithAcceptPolicy to set the policy of accept errors, the zero fields are filled with the defaults.
+func WithAcceptPolicy(policy AcceptPolicy) Option {
+	return func(options *Options) error {
+		if policy.MinBackoff < 0 || (policy.MaxBackoff > 0 && policy.MaxBackoff < policy.MinBackoff) {
+			return errors.New("the backoff must be 0 <= min <= max")
+		}
+		options.Context = context.WithValue(options.Context, acceptPolicyKey, policy)
+		return nil
+	}
+}
+
+// AcceptPolicyFrom to unwrap the accept policy with the defaults
+func AcceptPolicyFrom(ctx context.Context) AcceptPolicy {
+	policy, _ := ctx.Value(acceptPolicyKey).(AcceptPolicy)
+	if nil == policy.Retryable {
+		policy.Retryable = TemporaryError
+	}
+	if 0 == policy.MinBackoff {
+		policy.MinBackoff = 5 * time.Millisecond
+	}
+	if 0 == policy.MaxBackoff {
+		policy.MaxBackoff = time.Second
+	}
+	if policy.MaxBackoff < policy.MinBackoff {
+		policy.MaxBackoff = policy.MinBackoff
+	}
+	return policy
+}
+
+// ConcurrentAcceptor defines an Acceptor that is safe to Accept by multiple goroutines, see WithAcceptorCount.
+type ConcurrentAcceptor interface {
+	Acceptor
+	// AcceptConcurrently marks the Acceptor as safe for concurrent use
+	AcceptConcurrently()
+}
+
+var acceptorCountKey = struct{ key string }{"go-netty-transport-acceptor-count"}
+
+// WithAcceptorCount to run n accept loops for the listener if the Acceptor is a ConcurrentAcceptor, default is 1.
+func WithAcceptorCount(n int) Option {
+	return func(options *Options) error {
+		if n <= 0 {
+			return errors.New("the acceptor count must be a positive integer")
+		}
+		options.Context = context.WithValue(options.Context, acceptorCountKey, n)
+		return nil
+	}
+}
+
+// AcceptorCountFrom to unwrap the count of accept loops, the acceptor that is not a ConcurrentAcceptor has a single loop.
+func AcceptorCountFrom(ctx context.Context, acceptor Acceptor) int {
+	if n, ok := ctx.Value(acceptorCountKey).(int); ok && n > 1 {
+		if _, ok = acceptor.(ConcurrentAcceptor); ok {
+			return n
+		}
+	}
+	return 1
+}
+
+// AddrAcceptor defines an Acceptor that knows the local address it is bound to.
+type AddrAcceptor interface {
+	Acceptor
+	// Addr returns the bound address, e.g: the actual port when listen on port 0
+	Addr() net.Addr
+}
+
+// AcceptorAddr to unwrap the bound address of acceptor, nil if the acceptor is not an AddrAcceptor.
+func AcceptorAddr(acceptor Acceptor) net.Addr {
+	if a, ok := acceptor.(AddrAcceptor); ok {
+		return a.Addr()
+	}
+	return nil
+}
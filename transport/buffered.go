@@ -16,9 +16,9 @@
 
 package transport
 
-import "bufio"
+import "github.com/go-netty/go-netty/utils"
 
-// BufferedTransport for optimize system calls with bufio.Reader
+// BufferedTransport for optimize system calls with a read buffer rented from the buffer pool
 func BufferedTransport(transport Transport, sizeRead int) Transport {
 	switch transport.(type) {
 	case *bufferedTransport:
@@ -26,16 +26,115 @@ func BufferedTransport(transport Transport, sizeRead int) Transport {
 	default:
 		return &bufferedTransport{
 			Transport: transport,
-			reader:    bufio.NewReaderSize(transport, sizeRead),
+			buffer:    utils.GetBytes(sizeRead),
 		}
 	}
 }
 
 type bufferedTransport struct {
 	Transport
-	reader *bufio.Reader
+	buffer []byte
+	r, w   int
+	err    error
 }
 
 func (bt *bufferedTransport) Read(b []byte) (int, error) {
-	return bt.reader.Read(b)
+
+	if 0 == len(b) {
+		return 0, nil
+	}
+
+	if bt.r == bt.w {
+		if nil != bt.err {
+			return 0, bt.readErr()
+		}
+
+		// large read, read directly into b to avoid copy.
+		if len(b) >= len(bt.buffer) {
+			return bt.Transport.Read(b)
+		}
+
+		// one read only.
+		bt.r, bt.w = 0, 0
+		n, err := bt.Transport.Read(bt.buffer)
+		bt.w, bt.err = n, err
+		if 0 == n {
+			return 0, bt.readErr()
+		}
+	}
+
+	n := copy(b, bt.buffer[bt.r:bt.w])
+	bt.r += n
+	return n, nil
+}
+
+func (bt *bufferedTransport) ReadByte() (byte, error) {
+
+	for bt.r == bt.w {
+		if nil != bt.err {
+			return 0, bt.readErr()
+		}
+		bt.r, bt.w = 0, 0
+		bt.w, bt.err = bt.Transport.Read(bt.buffer)
+	}
+
+	c := bt.buffer[bt.r]
+	bt.r++
+	return c, nil
+}
+
+func (bt *bufferedTransport) readErr() error {
+	err := bt.err
+	bt.err = nil
+	return err
+}
+
+// Unread to push back the bytes, they will be read before the buffered bytes.
+func (bt *bufferedTransport) Unread(p []byte) {
+
+	if 0 == len(p) {
+		return
+	}
+
+	// enough space before the unread bytes.
+	if bt.r >= len(p) {
+		bt.r -= len(p)
+		copy(bt.buffer[bt.r:], p)
+		return
+	}
+
+	n := len(p) + bt.w - bt.r
+	if n > len(bt.buffer) {
+		// grow the buffer to hold the unread bytes.
+		buffer := utils.GetBytes(n)
+		copy(buffer[len(p):], bt.buffer[bt.r:bt.w])
+		utils.PutBytes(bt.buffer)
+		bt.buffer = buffer
+	} else {
+		copy(bt.buffer[len(p):], bt.buffer[bt.r:bt.w])
+	}
+
+	copy(bt.buffer, p)
+	bt.r, bt.w = 0, n
+}
+
+// Buffered returns the number of the bytes that can be read without reading the wrapped transport
+func (bt *bufferedTransport) Buffered() int {
+	return bt.w - bt.r + Buffered(bt.Transport)
+}
+
+// Release to return the read buffer to the pool, must be called after the last read.
+func (bt *bufferedTransport) Release() {
+	if nil != bt.buffer {
+		utils.PutBytes(bt.buffer)
+		bt.buffer, bt.r, bt.w = nil, 0, 0
+	}
+}
+
+// Buffered returns the number of the bytes has been read from connection but not consumed by the transport
+func Buffered(transport Transport) int {
+	if b, ok := transport.(interface{ Buffered() int }); ok {
+		return b.Buffered()
+	}
+	return 0
 }
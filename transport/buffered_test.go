@@ -0,0 +1,113 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+type pipeTransport struct {
+	net.Conn
+}
+
+func (p *pipeTransport) Writev(buffs Buffers) (int64, error) {
+	return buffs.Buffers.WriteTo(p.Conn)
+}
+
+func (p *pipeTransport) Flush() error {
+	return nil
+}
+
+func (p *pipeTransport) RawTransport() interface{} {
+	return p.Conn
+}
+
+func TestBufferedTransport(t *testing.T) {
+
+	local, peer := net.Pipe()
+	defer local.Close()
+
+	payload := bytes.Repeat([]byte("GO-NETTY"), 1024)
+	go func() {
+		for i := 0; i < len(payload); i += 100 {
+			end := i + 100
+			if end > len(payload) {
+				end = len(payload)
+			}
+			_, _ = peer.Write(payload[i:end])
+		}
+		_ = peer.Close()
+	}()
+
+	transport := BufferedTransport(&pipeTransport{Conn: local}, 1024)
+	if BufferedTransport(transport, 1024) != transport {
+		t.Fatal("buffered transport should not be wrapped twice")
+	}
+
+	first, err := transport.(io.ByteReader).ReadByte()
+	if nil != err || payload[0] != first {
+		t.Fatal(first, err)
+	}
+
+	readBytes, err := ioutil.ReadAll(transport)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(payload[1:], readBytes) {
+		t.Fatal("unexpected bytes")
+	}
+
+	transport.(interface{ Release() }).Release()
+}
+
+func TestBufferedTransportUnread(t *testing.T) {
+
+	local, peer := net.Pipe()
+	defer local.Close()
+
+	go func() {
+		_, _ = peer.Write([]byte("0123456789"))
+		_ = peer.Close()
+	}()
+
+	transport := BufferedTransport(&pipeTransport{Conn: local}, 16)
+	unreader := transport.(interface{ Unread([]byte) })
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(transport, head); nil != err {
+		t.Fatal(err)
+	}
+
+	// fits in the space before the buffered bytes.
+	unreader.Unread(head[2:])
+	// moves the buffered bytes.
+	unreader.Unread([]byte("abc"))
+	// grows the buffer.
+	unreader.Unread(bytes.Repeat([]byte("x"), 2048))
+
+	data, err := ioutil.ReadAll(transport)
+	if nil != err || string(bytes.Repeat([]byte("x"), 2048))+"abc23456789" != string(data) {
+		t.Fatal(string(data), err)
+	}
+
+	transport.(interface{ Release() }).Release()
+}
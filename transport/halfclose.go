@@ -0,0 +1,36 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import "errors"
+
+// ErrHalfCloseUnsupported will be returned when the transport can not shut down the writing side only.
+var ErrHalfCloseUnsupported = errors.New("the transport does not support half-close")
+
+// CloseWrite to shut down the writing side of transport, e.g: send FIN of tcp.
+//
+// The transport or its raw transport needs to implement CloseWrite() error like *net.TCPConn & *net.UnixConn,
+// ErrHalfCloseUnsupported is returned otherwise.
+func CloseWrite(transport Transport) error {
+	if cw, ok := transport.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	if cw, ok := transport.RawTransport().(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return ErrHalfCloseUnsupported
+}
@@ -18,9 +18,12 @@ package transport
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
+	"reflect"
+	"runtime"
 	"strings"
 
 	"github.com/go-netty/go-netty/utils"
@@ -39,27 +42,128 @@ type Options struct {
 	Context context.Context
 }
 
-// AddressWithoutHost convert host:port to :port
+// HostPort to split the host and port of address
+func (lo *Options) HostPort() (host, port string, err error) {
+	if nil == lo.Address {
+		return "", "", errors.New("missing address")
+	}
+	if host, port, err = net.SplitHostPort(lo.Address.Host); nil != err {
+		return "", "", fmt.Errorf("invalid address %q: %w", lo.Address.Host, err)
+	}
+	return host, port, nil
+}
+
+// ListenAddress convert host:port to :port, the error-returning variant of AddressWithoutHost.
+func (lo *Options) ListenAddress() (string, error) {
+	_, port, err := lo.HostPort()
+	if nil != err {
+		return "", err
+	}
+	return net.JoinHostPort("", port), nil
+}
+
+// AddressWithoutHost convert host:port to :port, it panics if the address is malformed.
+//
+// Deprecated: the address comes from user input, use ListenAddress instead.
 func (lo *Options) AddressWithoutHost() string {
-	_, port, err := net.SplitHostPort(lo.Address.Host)
+	address, err := lo.ListenAddress()
 	utils.Assert(err)
-	return net.JoinHostPort("", port)
+	return address
 }
 
-// Apply options
+// Apply options, all of the options will be applied and the errors will be collected as OptionErrors.
 func (lo *Options) Apply(options ...Option) error {
-	for _, option := range options {
+	var errs OptionErrors
+	for index, option := range options {
 		if err := option(lo); nil != err {
-			return err
+			errs = append(errs, &OptionError{Index: index, Name: optionName(option), Err: err})
 		}
 	}
-	return nil
+	return errs.asError()
 }
 
-// ParseOptions parse options
+// ParseOptions parse options, the address error will be reported as an OptionError with index -1.
 func ParseOptions(ctx context.Context, url string, options ...Option) (*Options, error) {
+
 	option := &Options{Context: ctx}
-	return option, option.Apply(append([]Option{withAddress(url)}, options...)...)
+
+	var errs OptionErrors
+	if err := withAddress(url)(option); nil != err {
+		errs = append(errs, &OptionError{Index: -1, Name: "address", Err: err})
+	}
+
+	if err := option.Apply(options...); nil != err {
+		errs = append(errs, err.(OptionErrors)...)
+	}
+
+	return option, errs.asError()
+}
+
+// OptionError defines an error raised by applying an option
+type OptionError struct {
+	// Index of the option, -1 means the address
+	Index int
+	// Name of the option function, e.g: tcp.WithOptions
+	Name string
+	// Err the original error
+	Err error
+}
+
+// Error to impl error
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("option[%d] %s: %v", e.Index, e.Name, e.Err)
+}
+
+// Unwrap to get the original error
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// OptionErrors defines the errors of options
+type OptionErrors []*OptionError
+
+// Error to impl error
+func (es OptionErrors) Error() string {
+	messages := make([]string, 0, len(es))
+	for _, e := range es {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap to get the first error
+func (es OptionErrors) Unwrap() error {
+	if 0 == len(es) {
+		return nil
+	}
+	return es[0]
+}
+
+// asError returns nil if no error
+func (es OptionErrors) asError() error {
+	if 0 == len(es) {
+		return nil
+	}
+	return es
+}
+
+// optionName returns the name of the function that created the option, e.g: tcp.WithOptions
+func optionName(option Option) string {
+
+	fn := runtime.FuncForPC(reflect.ValueOf(option).Pointer())
+	if nil == fn {
+		return "unknown"
+	}
+
+	// github.com/go-netty/go-netty/transport/tcp.WithOptions.func1
+	name := fn.Name()
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		name = name[index+1:]
+	}
+	if index := strings.Index(name, ".func"); index > 0 {
+		name = name[:index]
+	}
+	return name
 }
 
 // withAddress for server listener or client dialer
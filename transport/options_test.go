@@ -0,0 +1,115 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func withFailure(err error) Option {
+	return func(options *Options) error {
+		return err
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+
+	options, err := ParseOptions(context.Background(), "tcp://127.0.0.1:9527")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if address, err := options.ListenAddress(); nil != err || ":9527" != address {
+		t.Fatal(address, err)
+	}
+
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	_, err = ParseOptions(context.Background(), "tcp://[::1", WithContext(context.Background()), withFailure(errFirst), withFailure(errSecond))
+
+	var errs OptionErrors
+	if !errors.As(err, &errs) || 3 != len(errs) {
+		t.Fatal("unexpected errors:", err)
+	}
+
+	if -1 != errs[0].Index || "address" != errs[0].Name {
+		t.Fatal("unexpected address error:", errs[0])
+	}
+
+	if 1 != errs[1].Index || "transport.withFailure" != errs[1].Name || !errors.Is(errs[1], errFirst) {
+		t.Fatal("unexpected option error:", errs[1])
+	}
+
+	if 2 != errs[2].Index || !errors.Is(errs[2], errSecond) {
+		t.Fatal("unexpected option error:", errs[2])
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+
+	for _, address := range []string{"127.0.0.1", "tcp://localhost", "tcp:///path"} {
+		options, err := ParseOptions(context.Background(), address)
+		if nil != err {
+			t.Fatal(address, err)
+		}
+
+		if _, err := options.ListenAddress(); nil == err {
+			t.Fatal("malformed address should be rejected:", address)
+		}
+	}
+
+	if _, err := (&Options{}).ListenAddress(); nil == err {
+		t.Fatal("missing address should be rejected")
+	}
+}
+
+func TestAcceptPolicy(t *testing.T) {
+
+	policy := AcceptPolicyFrom(context.Background())
+	if 5*time.Millisecond != policy.MinBackoff || time.Second != policy.MaxBackoff {
+		t.Fatal("unexpected defaults:", policy.MinBackoff, policy.MaxBackoff)
+	}
+
+	var delays []time.Duration
+	for delay, i := time.Duration(0), 0; i < 10; i++ {
+		delay = policy.Backoff(delay)
+		delays = append(delays, delay)
+	}
+	if 5*time.Millisecond != delays[0] || 10*time.Millisecond != delays[1] || time.Second != delays[9] {
+		t.Fatal("unexpected delays:", delays)
+	}
+
+	if !policy.Retryable(&net.OpError{Op: "accept", Err: syscall.EMFILE}) || policy.Retryable(net.ErrClosed) {
+		t.Fatal("the temporary errors should be retryable only")
+	}
+
+	options, err := ParseOptions(context.Background(), "tcp://127.0.0.1:9527", WithAcceptPolicy(AcceptPolicy{MinBackoff: time.Millisecond}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if policy := AcceptPolicyFrom(options.Context); time.Millisecond != policy.MinBackoff || time.Second != policy.MaxBackoff {
+		t.Fatal("unexpected policy:", policy.MinBackoff, policy.MaxBackoff)
+	}
+
+	if _, err := ParseOptions(context.Background(), "tcp://127.0.0.1:9527", WithAcceptPolicy(AcceptPolicy{MinBackoff: time.Second, MaxBackoff: time.Millisecond})); nil == err {
+		t.Fatal("the invalid backoff should be rejected")
+	}
+}
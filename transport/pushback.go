@@ -0,0 +1,51 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import "github.com/go-netty/go-netty/utils"
+
+// PushbackTransport to wrap the transport with a push-back buffer, so the bytes peeked
+// by the handshake code will be read by the pipeline. see utils.Peek
+func PushbackTransport(transport Transport) Transport {
+	if _, ok := transport.(utils.Unreader); ok {
+		return transport
+	}
+	return &pushbackTransport{Transport: transport, reader: utils.PushbackReader{Reader: transport}}
+}
+
+type pushbackTransport struct {
+	Transport
+	reader utils.PushbackReader
+}
+
+func (pt *pushbackTransport) Read(b []byte) (int, error) {
+	return pt.reader.Read(b)
+}
+
+func (pt *pushbackTransport) Unread(p []byte) {
+	pt.reader.Unread(p)
+}
+
+// Buffered returns the number of the pushed back bytes and the bytes buffered by the wrapped transport
+func (pt *pushbackTransport) Buffered() int {
+	return pt.reader.Buffered() + Buffered(pt.Transport)
+}
+
+// Release to release the wrapped transport
+func (pt *pushbackTransport) Release() {
+	utils.Release(pt.Transport)
+}
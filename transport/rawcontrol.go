@@ -0,0 +1,55 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ErrSyscallUnsupported will be returned when the transport does not expose the raw connection.
+var ErrSyscallUnsupported = errors.New("the transport does not support syscall")
+
+// SyscallTransport defines a transport that exposes the raw connection, e.g: *net.TCPConn of tcp transport.
+type SyscallTransport interface {
+	SyscallConn() (syscall.RawConn, error)
+}
+
+// RawControl to invoke fn with the file descriptor of transport, e.g: to set SO_MARK or TCP_CONGESTION.
+//
+// The transport or its raw transport needs to implement SyscallTransport, ErrSyscallUnsupported is returned otherwise,
+// the fd must not be used after fn returns.
+func RawControl(transport Transport, fn func(fd uintptr) error) error {
+
+	sc, ok := transport.(SyscallTransport)
+	if !ok {
+		if sc, ok = transport.RawTransport().(SyscallTransport); !ok {
+			return ErrSyscallUnsupported
+		}
+	}
+
+	raw, err := sc.SyscallConn()
+	if nil != err {
+		return err
+	}
+
+	var fnErr error
+	if err = raw.Control(func(fd uintptr) { fnErr = fn(fd) }); nil != err {
+		return err
+	}
+	return fnErr
+}
@@ -17,9 +17,17 @@
 package tcp
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
 )
 
 // New tcp factory
@@ -39,15 +47,48 @@ func (f *tcpFactory) Connect(options *transport.Options) (transport.Transport, e
 		return nil, err
 	}
 
-	tcpOptions := FromContext(options.Context, DefaultOption)
+	if _, _, err := options.HostPort(); nil != err {
+		return nil, err
+	}
 
-	var d = net.Dialer{Timeout: tcpOptions.Timeout}
-	conn, err := d.DialContext(options.Context, options.Address.Scheme, options.Address.Host)
+	tcpOptions, err := optionsOf(options)
 	if nil != err {
 		return nil, err
 	}
 
-	return (&tcpTransport{TCPConn: conn.(*net.TCPConn)}).applyOptions(tcpOptions, true)
+	network := options.Address.Scheme
+	if "" != tcpOptions.Network {
+		if !f.Schemes().Valid(tcpOptions.Network) {
+			return nil, fmt.Errorf("invalid network %q, available: %v", tcpOptions.Network, f.Schemes())
+		}
+		network = tcpOptions.Network
+	}
+
+	var d = net.Dialer{Timeout: tcpOptions.Timeout, Control: tcpOptions.Control, Resolver: tcpOptions.Resolver}
+	if "" != tcpOptions.LocalAddr {
+		if d.LocalAddr, err = localAddress(network, tcpOptions.LocalAddr); nil != err {
+			return nil, err
+		}
+	}
+
+	address := options.Address.Host
+	if tcpOptions.ResolveTimeout > 0 {
+		if address, err = resolveAddress(options.Context, tcpOptions, network, address); nil != err {
+			return nil, err
+		}
+	}
+
+	conn, err := dialRetry(options.Context, &d, tcpOptions, network, address)
+	if nil != err {
+		return nil, err
+	}
+
+	t, err := (&tcpTransport{TCPConn: conn.(*net.TCPConn), logger: utils.LoggerFrom(options.Context)}).applyOptions(tcpOptions, true)
+	if nil != err {
+		conn.Close()
+		return nil, err
+	}
+	return t, nil
 }
 
 func (f *tcpFactory) Listen(options *transport.Options) (transport.Acceptor, error) {
@@ -56,33 +97,181 @@ func (f *tcpFactory) Listen(options *transport.Options) (transport.Acceptor, err
 		return nil, err
 	}
 
-	l, err := net.Listen(options.Address.Scheme, options.AddressWithoutHost())
+	tcpOptions, err := optionsOf(options)
+	if nil != err {
+		return nil, err
+	}
+
+	address, err := listenAddress(options, tcpOptions)
 	if nil != err {
 		return nil, err
 	}
 
-	return &tcpAcceptor{listener: l.(*net.TCPListener), options: FromContext(options.Context, DefaultOption)}, nil
+	lc := net.ListenConfig{Control: listenControl(tcpOptions)}
+	l, err := lc.Listen(options.Context, options.Address.Scheme, address)
+	if nil != err {
+		return nil, err
+	}
+
+	acceptor := &tcpAcceptor{listener: l.(*net.TCPListener), options: tcpOptions, logger: utils.LoggerFrom(options.Context), done: make(chan struct{})}
+	go acceptor.closeOnDone(options.Context)
+	return acceptor, nil
+}
+
+// dialRetry to dial with the retries of the refused or timed out connections, it stops if the ctx is done.
+func dialRetry(ctx context.Context, d *net.Dialer, tcpOptions *Options, network, address string) (net.Conn, error) {
+
+	backoff := tcpOptions.DialRetryBackoff
+	for attempt := 1; ; attempt++ {
+		conn, err := d.DialContext(ctx, network, address)
+		if nil == err || attempt > tcpOptions.DialRetries || !retryable(err) {
+			if nil != err && attempt > 1 {
+				err = fmt.Errorf("dial failed after %d attempts: %w", attempt, err)
+			}
+			return conn, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("dial canceled after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// retryable returns true if the dialing is refused or timed out
+func retryable(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// resolveAddress to look up the host of address within the ResolveTimeout, the first address of the family is used.
+func resolveAddress(ctx context.Context, tcpOptions *Options, network, address string) (string, error) {
+
+	host, port, err := net.SplitHostPort(address)
+	if nil != err || nil != net.ParseIP(host) {
+		return address, err
+	}
+
+	resolver := tcpOptions.Resolver
+	if nil == resolver {
+		resolver = net.DefaultResolver
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tcpOptions.ResolveTimeout)
+	defer cancel()
+
+	ips, err := resolver.LookupIP(ctx, "ip"+strings.TrimPrefix(network, "tcp"), host)
+	if nil != err {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// localAddress to resolve the source address of dialer, the port is optional.
+func localAddress(network, address string) (*net.TCPAddr, error) {
+	if _, _, err := net.SplitHostPort(address); nil != err {
+		address = net.JoinHostPort(address, "0")
+	}
+	addr, err := net.ResolveTCPAddr(network, address)
+	if nil != err {
+		return nil, fmt.Errorf("invalid local address %q: %w", address, err)
+	}
+	return addr, nil
+}
+
+// listenAddress to bind the host of url, the empty host or 0.0.0.0 listens on all interfaces.
+func listenAddress(options *transport.Options, tcpOptions *Options) (string, error) {
+
+	host, _, err := options.HostPort()
+	if nil != err {
+		return "", err
+	}
+
+	if "" == host || "0.0.0.0" == host || tcpOptions.ListenAnyHost {
+		return options.ListenAddress()
+	}
+	return options.Address.Host, nil
+}
+
+// listenControl to set the socket options of listener before binding
+func listenControl(options *Options) func(network, address string, c syscall.RawConn) error {
+
+	if !options.ReuseAddr && !options.ReusePort {
+		return options.Control
+	}
+
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if e := c.Control(func(fd uintptr) { err = setReuse(fd, options.ReuseAddr, options.ReusePort) }); nil != e {
+			return e
+		}
+		if nil != err {
+			return err
+		}
+		if nil != options.Control {
+			return options.Control(network, address, c)
+		}
+		return nil
+	}
 }
 
 type tcpAcceptor struct {
-	listener *net.TCPListener
-	options  *Options
+	listener  *net.TCPListener
+	options   *Options
+	closeOnce sync.Once
+	closeErr  error
+	logger    utils.Logger
+	done      chan struct{}
+}
+
+// closeOnDone to interrupt the blocked Accept by closing the listener when the ctx is done.
+func (t *tcpAcceptor) closeOnDone(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		t.Close()
+	case <-t.done:
+	}
 }
 
 func (t *tcpAcceptor) Accept() (transport.Transport, error) {
 
-	conn, err := t.listener.AcceptTCP()
-	if nil != err {
-		return nil, err
+	for {
+		conn, err := t.listener.AcceptTCP()
+		if nil != err {
+			return nil, err
+		}
+
+		// the connection that failed to apply the options is dropped, the listener keeps accepting.
+		accepted, err := (&tcpTransport{TCPConn: conn, logger: t.logger}).applyOptions(t.options, false)
+		if nil != err {
+			t.logger.Warnf("tcp: drop the connection from %s: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			continue
+		}
+		return accepted, nil
 	}
+}
 
-	return (&tcpTransport{TCPConn: conn}).applyOptions(t.options, false)
+// AcceptConcurrently to impl transport.ConcurrentAcceptor, the TCPListener is safe for concurrent use.
+func (t *tcpAcceptor) AcceptConcurrently() {}
+
+// Addr returns the bound address of listener
+func (t *tcpAcceptor) Addr() net.Addr {
+	return t.listener.Addr()
 }
 
+// Close the listener, it is safe to close while the accept loops are running.
 func (t *tcpAcceptor) Close() error {
-	if t.listener != nil {
-		defer func() { t.listener = nil }()
-		return t.listener.Close()
-	}
-	return nil
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.closeErr = t.listener.Close()
+	})
+	return t.closeErr
 }
@@ -0,0 +1,326 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// listen to create an acceptor with the tcp options
+func listen(t *testing.T, url string, tcpOptions *Options) *net.TCPAddr {
+
+	options, err := transport.ParseOptions(context.Background(), url, WithOptions(tcpOptions))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	acceptor, err := New().Listen(options)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { acceptor.Close() })
+
+	return transport.AcceptorAddr(acceptor).(*net.TCPAddr)
+}
+
+// externalIP to find an ipv4 address that is not loopback
+func externalIP() net.IP {
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ip, ok := addr.(*net.IPNet); ok && !ip.IP.IsLoopback() && nil != ip.IP.To4() {
+			return ip.IP
+		}
+	}
+	return nil
+}
+
+func TestListenHost(t *testing.T) {
+
+	addr := listen(t, "tcp://127.0.0.1:0", DefaultOption)
+	if !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatal("the host should be respected:", addr)
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	ip := externalIP()
+	if nil == ip {
+		t.Skip("no external interface")
+	}
+
+	// the external interfaces should not accept.
+	external := &net.TCPAddr{IP: ip, Port: addr.Port}
+	if conn, err = net.DialTimeout("tcp", external.String(), time.Second); nil == err {
+		conn.Close()
+		t.Fatal("the external interface should not accept:", external)
+	}
+}
+
+func TestListenAnyHost(t *testing.T) {
+
+	for _, url := range []string{"tcp://0.0.0.0:0", "tcp://:0"} {
+		if addr := listen(t, url, DefaultOption); !addr.IP.IsUnspecified() {
+			t.Fatal("unexpected address:", url, addr)
+		}
+	}
+
+	// the legacy behavior to strip the host.
+	options := *DefaultOption
+	options.ListenAnyHost = true
+	if addr := listen(t, "tcp://127.0.0.1:0", &options); !addr.IP.IsUnspecified() {
+		t.Fatal("the host should be stripped:", addr)
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+
+	var controlled int
+	options := *DefaultOption
+	options.ReuseAddr, options.ReusePort = true, true
+	options.Control = func(network, address string, c syscall.RawConn) error {
+		controlled++
+		return nil
+	}
+
+	url := "tcp://127.0.0.1:0"
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+	default:
+		parsed, _ := transport.ParseOptions(context.Background(), url, WithOptions(&options))
+		if _, err := New().Listen(parsed); nil == err {
+			t.Fatal("SO_REUSEPORT should be unsupported")
+		}
+		return
+	}
+
+	// the listeners with SO_REUSEPORT can bind the same port.
+	addr := listen(t, url, &options)
+	if again := listen(t, addr.String(), &options); again.Port != addr.Port {
+		t.Fatal("unexpected address:", again)
+	}
+	if 2 != controlled {
+		t.Fatal("the control should be called for each listener:", controlled)
+	}
+}
+
+func TestConnectLocalAddr(t *testing.T) {
+
+	addr := listen(t, "tcp://127.0.0.1:0", DefaultOption)
+
+	options := *DefaultOption
+	options.LocalAddr = "127.0.0.1"
+	parsed, _ := transport.ParseOptions(context.Background(), "tcp://"+addr.String(), WithOptions(&options))
+	conn, err := New().Connect(parsed)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if local := conn.LocalAddr().(*net.TCPAddr); !local.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatal("unexpected local address:", local)
+	}
+
+	options.LocalAddr = "no-such-host.invalid"
+	parsed, _ = transport.ParseOptions(context.Background(), "tcp://"+addr.String(), WithOptions(&options))
+	if _, err = New().Connect(parsed); nil == err || !strings.Contains(err.Error(), "invalid local address") {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+// fakeResolver to answer the A queries with the ip, the other queries have no answer.
+func fakeResolver(ip net.IP) *net.Resolver {
+	return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		local, remote := net.Pipe()
+		go func() {
+			defer remote.Close()
+
+			// the messages are prefixed with the length on stream connections.
+			var size [2]byte
+			if _, err := io.ReadFull(remote, size[:]); nil != err {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(size[:]))
+			if _, err := io.ReadFull(remote, query); nil != err {
+				return
+			}
+
+			// the question: name, type and class.
+			end := 12
+			for query[end] != 0 {
+				end += int(query[end]) + 1
+			}
+			end += 5
+
+			var answers uint16
+			var answer []byte
+			if 1 == binary.BigEndian.Uint16(query[end-4:]) {
+				answers = 1
+				answer = append([]byte{0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4}, ip.To4()...)
+			}
+
+			response := append([]byte{query[0], query[1], 0x81, 0x80, 0, 1, byte(answers >> 8), byte(answers), 0, 0, 0, 0}, query[12:end]...)
+			response = append(response, answer...)
+			binary.BigEndian.PutUint16(size[:], uint16(len(response)))
+			remote.Write(append(size[:], response...))
+		}()
+		return local, nil
+	}}
+}
+
+func TestConnectResolver(t *testing.T) {
+
+	addr := listen(t, "tcp://127.0.0.1:0", DefaultOption)
+	url := fmt.Sprintf("tcp://fake.test:%d", addr.Port)
+
+	connect := func(options Options) error {
+		parsed, _ := transport.ParseOptions(context.Background(), url, WithOptions(&options))
+		conn, err := New().Connect(parsed)
+		if nil == err {
+			conn.Close()
+		}
+		return err
+	}
+
+	options := *DefaultOption
+	options.Resolver = fakeResolver(net.IPv4(127, 0, 0, 1))
+	if err := connect(options); nil != err {
+		t.Fatal(err)
+	}
+
+	options.ResolveTimeout = time.Second
+	if err := connect(options); nil != err {
+		t.Fatal(err)
+	}
+
+	// the host has no ipv6 address.
+	options.Network = "tcp6"
+	if err := connect(options); nil == err {
+		t.Fatal("the ipv6 address should not be found")
+	}
+
+	options.Network = "udp"
+	if err := connect(options); nil == err || !strings.Contains(err.Error(), "invalid network") {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the lookup is limited by ResolveTimeout instead of Timeout.
+	options.Network, options.ResolveTimeout = "", 50*time.Millisecond
+	options.Resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	start := time.Now()
+	if err := connect(options); nil == err || time.Since(start) > options.Timeout/2 {
+		t.Fatal("the lookup should be timed out:", err, time.Since(start))
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+
+	// the port is refused after the listener is closed.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	connect := func(ctx context.Context, options Options) (transport.Transport, error) {
+		parsed, _ := transport.ParseOptions(ctx, "tcp://"+address, WithOptions(&options))
+		return New().Connect(parsed)
+	}
+
+	options := *DefaultOption
+	if _, err = connect(context.Background(), options); !errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), "attempts") {
+		t.Fatal("unexpected error without retry:", err)
+	}
+
+	options.DialRetries, options.DialRetryBackoff = 2, time.Millisecond
+	if _, err = connect(context.Background(), options); !errors.Is(err, syscall.ECONNREFUSED) || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatal("unexpected error with retries:", err)
+	}
+
+	// the retrying stops when the context is canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	options.DialRetries, options.DialRetryBackoff = 100, 10*time.Millisecond
+	start := time.Now()
+	if _, err = connect(ctx, options); nil == err || time.Since(start) > time.Second {
+		t.Fatal("the retrying should be canceled:", err, time.Since(start))
+	}
+
+	// the restarting server is connected by the retries.
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		if l, err := net.Listen("tcp", address); nil == err {
+			t.Cleanup(func() { l.Close() })
+		}
+	}()
+	options.DialRetries, options.DialRetryBackoff = 10, 5*time.Millisecond
+	conn, err := connect(context.Background(), options)
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestAcceptContextDone(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	options, err := transport.ParseOptions(ctx, "tcp://127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	acceptor, err := New().Listen(options)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer acceptor.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := acceptor.Accept()
+		result <- err
+	}()
+
+	cancel()
+	select {
+	case err = <-result:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatal("unexpected error:", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the accept should be interrupted by the ctx")
+	}
+}
@@ -18,6 +18,12 @@ package tcp
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/go-netty/go-netty/transport"
@@ -33,29 +39,289 @@ var DefaultOption = &Options{
 }
 
 // Options fot tcp transport
+//
+// Timeout, DialRetries, LocalAddr, Network, Resolver & ResolveTimeout are dial-only, ListenAnyHost, ReuseAddr & ReusePort
+// are listen-only, the others are applied to both the dialed and accepted connections unless overridden by Client & Server.
 type Options struct {
+	// Timeout of dialing
 	Timeout         time.Duration `json:"timeout"`
 	KeepAlive       bool          `json:"keep-alive,string"`
 	KeepAlivePeriod time.Duration `json:"keep-alive-period"`
 	Linger          int           `json:"linger,string"`
 	NoDelay         bool          `json:"nodelay,string"`
 	SockBuf         int           `json:"sockbuf,string"`
+	// ReadBuf & WriteBuf to set the receive & send buffer of socket separately, they override SockBuf if greater than zero.
+	ReadBuf  int `json:"readbuf,string"`
+	WriteBuf int `json:"writebuf,string"`
+	// ListenAnyHost to listen on all interfaces ignoring the host of url, e.g: tcp://example.com:9527 listens on :9527,
+	// by default only the empty host or 0.0.0.0 listens on all interfaces.
+	ListenAnyHost bool `json:"listen-any-host,string"`
+	// ReuseAddr & ReusePort to set SO_REUSEADDR & SO_REUSEPORT of the listener, e.g: to run multiple processes on one port,
+	// SO_REUSEPORT is unsupported on the platforms other than linux, darwin & bsd.
+	ReuseAddr bool `json:"reuseaddr,string"`
+	ReusePort bool `json:"reuseport,string"`
+	// UserTimeout to set TCP_USER_TIMEOUT, the connection fails if the written data is not acknowledged within the timeout,
+	// so a stalled peer is detected even if the connection is not idle, it is only supported on linux and ignored elsewhere.
+	UserTimeout time.Duration `json:"user-timeout"`
+	// TOS to set IP_TOS of ipv4 or IPV6_TCLASS of ipv6 connections for QoS, e.g: DSCP EF is 46 << 2, zero means unset,
+	// it is only supported on linux, darwin & bsd.
+	TOS int `json:"tos,string"`
+	// WriteBufferedSize to buffer the small writes until Flush, the payloads not smaller than WriteBypassSize are written
+	// directly, WriteBypassSize is WriteBufferedSize by default, zero means writing to the socket directly.
+	WriteBufferedSize int `json:"write-buffered-size,string"`
+	WriteBypassSize   int `json:"write-bypass-size,string"`
+	// DialRetries to retry the refused or timed out dialing with the backoff, which is doubled after each retry,
+	// zero means no retry.
+	DialRetries      int           `json:"dial-retries,string"`
+	DialRetryBackoff time.Duration `json:"dial-retry-backoff"`
+	// LocalAddr to bind the source address of the dialed connections, e.g: 10.0.0.2 or 10.0.0.2:0
+	LocalAddr string `json:"local-addr"`
+	// Network to force the address family of the dialed connections regardless of the scheme of url: tcp4 or tcp6
+	Network string `json:"network"`
+	// Resolver to look up the host of the dialed connections, the default resolver is used if nil.
+	Resolver *net.Resolver `json:"-"`
+	// ResolveTimeout to limit the lookup separately from the connect timeout, zero means the lookup is part of Timeout.
+	ResolveTimeout time.Duration `json:"resolve-timeout"`
+	// IgnoreSockOptErrors to log the failures of setting the socket options as warnings instead of failing the connection.
+	IgnoreSockOptErrors bool `json:"ignore-sockopt-errors,string"`
+	// Client & Server to override the options of the dialed & accepted connections respectively, nil means inherit,
+	// the fields of override are merged over the shared options one by one, see SideOptions.
+	Client *SideOptions `json:"client,omitempty"`
+	Server *SideOptions `json:"server,omitempty"`
+	// Control to set the socket options of the listener & dialer before binding or connecting, it runs after ReuseAddr & ReusePort.
+	Control func(network, address string, c syscall.RawConn) error `json:"-"`
 }
 
 var contextKey = struct{ key string }{"go-netty-transport-tcp-options"}
 
-// WithOptions to wrap the tcp options
+// WithOptions to wrap the tcp options, the invalid options are reported by transport.ParseOptions, see Options.Validate.
 func WithOptions(option *Options) transport.Option {
 	return func(options *transport.Options) error {
+		if nil == option {
+			return fmt.Errorf("nil tcp options")
+		}
+		if err := option.Validate(); nil != err {
+			return err
+		}
 		options.Context = context.WithValue(options.Context, contextKey, option)
 		return nil
 	}
 }
 
-// FromContext to unwrap the tcp options
+// FromContext to unwrap the tcp options of WithOptions, it is kept for compatibility, see OptionsOf.
 func FromContext(ctx context.Context, def *Options) *Options {
 	if v, ok := ctx.Value(contextKey).(*Options); ok {
 		return v
 	}
 	return def
 }
+
+// OptionsOf to get the tcp options of WithOptions from the transport options, def is returned if absent.
+func OptionsOf(options *transport.Options, def *Options) *Options {
+	return FromContext(options.Context, def)
+}
+
+// Validate to reject the nonsensical options, e.g: negative buffers, KeepAlivePeriod without KeepAlive.
+func (o *Options) Validate() error {
+
+	for _, v := range []struct {
+		invalid bool
+		message string
+	}{
+		{o.Timeout < 0, "negative Timeout"},
+		{o.SockBuf < 0, "negative SockBuf"},
+		{o.ReadBuf < 0, "negative ReadBuf"},
+		{o.WriteBuf < 0, "negative WriteBuf"},
+		{o.KeepAlivePeriod < 0, "negative KeepAlivePeriod"},
+		{!o.KeepAlive && o.KeepAlivePeriod > 0, "KeepAlivePeriod without KeepAlive"},
+		{o.UserTimeout < 0, "negative UserTimeout"},
+		{o.TOS < 0 || o.TOS > 0xff, "TOS out of range [0, 255]"},
+		{o.WriteBufferedSize < 0, "negative WriteBufferedSize"},
+		{o.WriteBypassSize < 0, "negative WriteBypassSize"},
+		{o.DialRetries < 0, "negative DialRetries"},
+		{o.DialRetryBackoff < 0, "negative DialRetryBackoff"},
+		{o.ResolveTimeout < 0, "negative ResolveTimeout"},
+	} {
+		if v.invalid {
+			return fmt.Errorf("invalid tcp options: %s", v.message)
+		}
+	}
+
+	// the sides are validated after merged over the shared options.
+	if nil != o.Client {
+		if err := o.sideOf(true).Validate(); nil != err {
+			return fmt.Errorf("Client: %w", err)
+		}
+	}
+	if nil != o.Server {
+		if err := o.sideOf(false).Validate(); nil != err {
+			return fmt.Errorf("Server: %w", err)
+		}
+	}
+	return nil
+}
+
+// SideOptions defines the overrides of the per-connection options for the dialed or accepted connections,
+// the nil fields inherit the shared options, e.g: Server: &SideOptions{Linger: Value(0)} keeps NoDelay & keepalive.
+type SideOptions struct {
+	KeepAlive           *bool          `json:"keep-alive,omitempty"`
+	KeepAlivePeriod     *time.Duration `json:"keep-alive-period,omitempty"`
+	Linger              *int           `json:"linger,omitempty"`
+	NoDelay             *bool          `json:"nodelay,omitempty"`
+	SockBuf             *int           `json:"sockbuf,omitempty"`
+	ReadBuf             *int           `json:"readbuf,omitempty"`
+	WriteBuf            *int           `json:"writebuf,omitempty"`
+	UserTimeout         *time.Duration `json:"user-timeout,omitempty"`
+	TOS                 *int           `json:"tos,omitempty"`
+	WriteBufferedSize   *int           `json:"write-buffered-size,omitempty"`
+	WriteBypassSize     *int           `json:"write-bypass-size,omitempty"`
+	IgnoreSockOptErrors *bool          `json:"ignore-sockopt-errors,omitempty"`
+}
+
+// Value returns the pointer of v to set the fields of SideOptions
+func Value[T any](v T) *T {
+	return &v
+}
+
+// mergeOver to override the fields of options that are set
+func (s *SideOptions) mergeOver(o *Options) {
+	for _, f := range []struct {
+		set   bool
+		apply func()
+	}{
+		{nil != s.KeepAlive, func() { o.KeepAlive = *s.KeepAlive }},
+		{nil != s.KeepAlivePeriod, func() { o.KeepAlivePeriod = *s.KeepAlivePeriod }},
+		{nil != s.Linger, func() { o.Linger = *s.Linger }},
+		{nil != s.NoDelay, func() { o.NoDelay = *s.NoDelay }},
+		{nil != s.SockBuf, func() { o.SockBuf = *s.SockBuf }},
+		{nil != s.ReadBuf, func() { o.ReadBuf = *s.ReadBuf }},
+		{nil != s.WriteBuf, func() { o.WriteBuf = *s.WriteBuf }},
+		{nil != s.UserTimeout, func() { o.UserTimeout = *s.UserTimeout }},
+		{nil != s.TOS, func() { o.TOS = *s.TOS }},
+		{nil != s.WriteBufferedSize, func() { o.WriteBufferedSize = *s.WriteBufferedSize }},
+		{nil != s.WriteBypassSize, func() { o.WriteBypassSize = *s.WriteBypassSize }},
+		{nil != s.IgnoreSockOptErrors, func() { o.IgnoreSockOptErrors = *s.IgnoreSockOptErrors }},
+	} {
+		if f.set {
+			f.apply()
+		}
+	}
+}
+
+// sideOf returns the options of the dialed or accepted connections, the override is merged over a copy of options.
+func (o *Options) sideOf(client bool) *Options {
+	override := o.Server
+	if client {
+		override = o.Client
+	}
+	if nil == override {
+		return o
+	}
+
+	merged := *o
+	merged.Client, merged.Server = nil, nil
+	override.mergeOver(&merged)
+	return &merged
+}
+
+// readBuffer returns the size of receive buffer, zero means the default of kernel.
+func (o *Options) readBuffer() int {
+	if o.ReadBuf > 0 {
+		return o.ReadBuf
+	}
+	return o.SockBuf
+}
+
+// writeBuffer returns the size of send buffer, zero means the default of kernel.
+func (o *Options) writeBuffer() int {
+	if o.WriteBuf > 0 {
+		return o.WriteBuf
+	}
+	return o.SockBuf
+}
+
+// FromURL to overlay the query parameters of url on the default options, e.g: tcp://0.0.0.0:9527?nodelay=false&sockbuf=262144,
+// the parameters are: timeout, keepalive, keepalive-period, linger, nodelay, sockbuf, readbuf, writebuf, local-addr,
+// network, resolve-timeout, user-timeout, tos, dial-retries, dial-retry-backoff, write-buffered-size, write-bypass-size,
+// reuseaddr, reuseport, the unknown parameters are rejected.
+func FromURL(u *url.URL, def *Options) (*Options, error) {
+
+	query := u.Query()
+	if 0 == len(query) {
+		return def, nil
+	}
+
+	options := *def
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := query.Get(key)
+
+		var err error
+		switch key {
+		case "timeout":
+			options.Timeout, err = time.ParseDuration(value)
+		case "keepalive":
+			options.KeepAlive, err = strconv.ParseBool(value)
+		case "keepalive-period":
+			options.KeepAlivePeriod, err = time.ParseDuration(value)
+		case "linger":
+			options.Linger, err = strconv.Atoi(value)
+		case "nodelay":
+			options.NoDelay, err = strconv.ParseBool(value)
+		case "sockbuf":
+			options.SockBuf, err = strconv.Atoi(value)
+		case "readbuf":
+			options.ReadBuf, err = strconv.Atoi(value)
+		case "writebuf":
+			options.WriteBuf, err = strconv.Atoi(value)
+		case "user-timeout":
+			options.UserTimeout, err = time.ParseDuration(value)
+		case "tos":
+			options.TOS, err = strconv.Atoi(value)
+		case "dial-retries":
+			options.DialRetries, err = strconv.Atoi(value)
+		case "dial-retry-backoff":
+			options.DialRetryBackoff, err = time.ParseDuration(value)
+		case "write-buffered-size":
+			options.WriteBufferedSize, err = strconv.Atoi(value)
+		case "write-bypass-size":
+			options.WriteBypassSize, err = strconv.Atoi(value)
+		case "network":
+			options.Network = value
+		case "resolve-timeout":
+			options.ResolveTimeout, err = time.ParseDuration(value)
+		case "local-addr":
+			options.LocalAddr = value
+		case "reuseaddr":
+			options.ReuseAddr, err = strconv.ParseBool(value)
+		case "reuseport":
+			options.ReusePort, err = strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf("unknown tcp parameter: %s", key)
+		}
+
+		if nil != err {
+			return nil, fmt.Errorf("invalid tcp parameter %s=%q: %w", key, value, err)
+		}
+	}
+
+	// keepalive=false disables the keepalive period of default options.
+	if !options.KeepAlive && !query.Has("keepalive-period") {
+		options.KeepAlivePeriod = 0
+	}
+
+	if err := options.Validate(); nil != err {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// optionsOf to get the tcp options of transport, the parameters of url are overlaid on the options of context.
+func optionsOf(options *transport.Options) (*Options, error) {
+	return FromURL(options.Address, OptionsOf(options, DefaultOption))
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+func TestFromURL(t *testing.T) {
+
+	u, _ := url.Parse("tcp://0.0.0.0:9527?nodelay=false&keepalive=false&linger=3&sockbuf=262144&timeout=2s&reuseport=true")
+	options, err := FromURL(u, DefaultOption)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	expected := Options{Timeout: 2 * time.Second, Linger: 3, SockBuf: 262144, ReusePort: true}
+	if !reflect.DeepEqual(expected, *options) {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+	if !DefaultOption.NoDelay {
+		t.Fatal("the default options should not be modified")
+	}
+
+	for _, address := range []string{"tcp://0.0.0.0:9527?nodelay=no", "tcp://0.0.0.0:9527?sockbuf=1k", "tcp://0.0.0.0:9527?unknown=1",
+		"tcp://0.0.0.0:9527?keepalive=false&keepalive-period=30s", "tcp://0.0.0.0:9527?sockbuf=-1"} {
+		u, _ = url.Parse(address)
+		if _, err = FromURL(u, DefaultOption); nil == err {
+			t.Fatal("the invalid parameters should be rejected:", address)
+		}
+	}
+}
+
+func TestOptionsOf(t *testing.T) {
+
+	options, _ := transport.ParseOptions(context.Background(), "tcp://0.0.0.0:9527?sockbuf=1024")
+	if tcpOptions, err := optionsOf(options); nil != err || 1024 != tcpOptions.SockBuf {
+		t.Fatal("the parameters of url should be applied:", tcpOptions, err)
+	}
+
+	// the parameters of url are overlaid on the options of context.
+	explicit := &Options{SockBuf: 4096, NoDelay: true, Linger: 3}
+	options, _ = transport.ParseOptions(context.Background(), "tcp://0.0.0.0:9527?sockbuf=1024", WithOptions(explicit))
+	if tcpOptions, err := optionsOf(options); nil != err || 1024 != tcpOptions.SockBuf || !tcpOptions.NoDelay || 3 != tcpOptions.Linger {
+		t.Fatalf("the parameters of url should be overlaid: %+v, %v", tcpOptions, err)
+	}
+	if 4096 != explicit.SockBuf {
+		t.Fatal("the options of context should not be modified")
+	}
+
+	// the options of context are used as is without parameters.
+	options, _ = transport.ParseOptions(context.Background(), "tcp://0.0.0.0:9527", WithOptions(explicit))
+	if tcpOptions, err := optionsOf(options); nil != err || explicit != tcpOptions {
+		t.Fatal("the options of context should be used:", tcpOptions, err)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+
+	if err := DefaultOption.Validate(); nil != err {
+		t.Fatal("the default options should be valid:", err)
+	}
+
+	for _, options := range []*Options{
+		{SockBuf: -1},
+		{KeepAlivePeriod: time.Minute},
+		{KeepAlive: true, TOS: 256},
+		{Server: &SideOptions{ReadBuf: Value(-1)}},
+		{KeepAlive: true, Client: &SideOptions{KeepAlive: Value(false), KeepAlivePeriod: Value(time.Minute)}},
+		nil,
+	} {
+		_, err := transport.ParseOptions(context.Background(), "tcp://0.0.0.0:9527", WithOptions(options))
+		var optionErr *transport.OptionError
+		if !errors.As(err, &optionErr) || "tcp.WithOptions" != optionErr.Name {
+			t.Fatalf("the invalid options should be rejected: %+v, error: %v", options, err)
+		}
+	}
+
+	explicit := &Options{KeepAlive: true, KeepAlivePeriod: time.Minute}
+	options, err := transport.ParseOptions(context.Background(), "tcp://0.0.0.0:9527", WithOptions(explicit))
+	if nil != err || explicit != OptionsOf(options, DefaultOption) {
+		t.Fatal("the valid options should be stored:", err)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// setReuse to report SO_REUSEPORT as unsupported, SO_REUSEADDR is left to the defaults of go.
+func setReuse(fd uintptr, reuseAddr, reusePort bool) error {
+	if reusePort {
+		return fmt.Errorf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
+
+// setTOS to report IP_TOS as unsupported
+func setTOS(conn *net.TCPConn, tos int) error {
+	return fmt.Errorf("IP_TOS is not supported on %s", runtime.GOOS)
+}
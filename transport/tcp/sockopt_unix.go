@@ -0,0 +1,60 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// setReuse to set SO_REUSEADDR & SO_REUSEPORT of the socket
+func setReuse(fd uintptr, reuseAddr, reusePort bool) error {
+	if reuseAddr {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); nil != err {
+			return fmt.Errorf("set SO_REUSEADDR: %w", err)
+		}
+	}
+	if reusePort {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1); nil != err {
+			return fmt.Errorf("set SO_REUSEPORT: %w", err)
+		}
+	}
+	return nil
+}
+
+// setTOS to set IP_TOS of ipv4 or IPV6_TCLASS of ipv6 connection
+func setTOS(conn *net.TCPConn, tos int) error {
+
+	raw, err := conn.SyscallConn()
+	if nil != err {
+		return err
+	}
+
+	level, opt := syscall.IPPROTO_IP, syscall.IP_TOS
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok && nil == addr.IP.To4() {
+		level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS
+	}
+
+	var sockErr error
+	if err = raw.Control(func(fd uintptr) { sockErr = syscall.SetsockoptInt(int(fd), level, opt, tos) }); nil != err {
+		return err
+	}
+	return sockErr
+}
@@ -17,52 +17,149 @@
 package tcp
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"net"
 
 	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
 )
 
+// tcpTransport implements transport.SyscallTransport by the embedded *net.TCPConn
 type tcpTransport struct {
 	*net.TCPConn
+	writer *bufio.Writer // the buffered writer if WriteBufferedSize is set, the writes are not concurrent safe then.
+	bypass int           // the payloads not smaller than bypass are written to the conn directly
+	logger utils.Logger  // the logger of transport options, utils.DefaultLogger if nil
+}
+
+func (t *tcpTransport) Write(p []byte) (int, error) {
+	if nil == t.writer {
+		return t.TCPConn.Write(p)
+	}
+	return t.writer.Write(p)
+}
+
+func (t *tcpTransport) ReadFrom(r io.Reader) (int64, error) {
+	// the buffered bytes are written first to keep the order.
+	if err := t.Flush(); nil != err {
+		return 0, err
+	}
+	return t.TCPConn.ReadFrom(r)
 }
 
 func (t *tcpTransport) Writev(buffs transport.Buffers) (int64, error) {
-	return buffs.Buffers.WriteTo(t.TCPConn)
+
+	// net.Buffers splits the buffers into the chunks of IOV_MAX, and retries the partial writes.
+	if nil == t.writer {
+		return buffs.Buffers.WriteTo(t.TCPConn)
+	}
+
+	var total int64
+	for _, b := range buffs.Buffers {
+		// the large payloads bypass the buffer to avoid copying.
+		if len(b) >= t.bypass {
+			if err := t.writer.Flush(); nil != err {
+				return total, err
+			}
+			n, err := t.TCPConn.Write(b)
+			if total += int64(n); nil != err {
+				return total, err
+			}
+			continue
+		}
+
+		n, err := t.writer.Write(b)
+		if total += int64(n); nil != err {
+			return total, err
+		}
+	}
+	return total, nil
 }
 
 func (t *tcpTransport) Flush() error {
-	return nil
+	if nil == t.writer {
+		return nil
+	}
+	return t.writer.Flush()
 }
 
 func (t *tcpTransport) RawTransport() interface{} {
 	return t.TCPConn
 }
 
+// log returns the logger of transport
+func (t *tcpTransport) log() utils.Logger {
+	if nil == t.logger {
+		return utils.DefaultLogger()
+	}
+	return t.logger
+}
+
+// applyOptions to set the socket options of the side, the failures are logged as warnings if IgnoreSockOptErrors is set.
 func (t *tcpTransport) applyOptions(tcpOptions *Options, client bool) (*tcpTransport, error) {
 
-	if err := t.SetKeepAlive(tcpOptions.KeepAlive); nil != err {
-		return t, err
+	tcpOptions = tcpOptions.sideOf(client)
+
+	check := func(option string, err error) error {
+		if nil == err {
+			return nil
+		}
+		if tcpOptions.IgnoreSockOptErrors {
+			t.log().Warnf("tcp: failed to set %s of %s: %v", option, t.RemoteAddr(), err)
+			return nil
+		}
+		return fmt.Errorf("set %s: %w", option, err)
+	}
+
+	if err := check("keepalive", t.SetKeepAlive(tcpOptions.KeepAlive)); nil != err {
+		return nil, err
+	}
+
+	// the zero period means the default of kernel.
+	if tcpOptions.KeepAlive && tcpOptions.KeepAlivePeriod > 0 {
+		if err := check("keepalive period", t.SetKeepAlivePeriod(tcpOptions.KeepAlivePeriod)); nil != err {
+			return nil, err
+		}
 	}
 
-	if err := t.SetKeepAlivePeriod(tcpOptions.KeepAlivePeriod); nil != err {
-		return t, err
+	if tcpOptions.UserTimeout > 0 {
+		if err := check("user timeout", setUserTimeout(t.TCPConn, tcpOptions.UserTimeout, t.log())); nil != err {
+			return nil, err
+		}
+	}
+
+	if tcpOptions.TOS > 0 {
+		if err := check("tos", setTOS(t.TCPConn, tcpOptions.TOS)); nil != err {
+			return nil, err
+		}
 	}
 
-	if err := t.SetLinger(tcpOptions.Linger); nil != err {
-		return t, err
+	if err := check("linger", t.SetLinger(tcpOptions.Linger)); nil != err {
+		return nil, err
 	}
 
-	if err := t.SetNoDelay(tcpOptions.NoDelay); nil != err {
-		return t, err
+	if err := check("nodelay", t.SetNoDelay(tcpOptions.NoDelay)); nil != err {
+		return nil, err
 	}
 
-	if tcpOptions.SockBuf > 0 {
-		if err := t.SetReadBuffer(tcpOptions.SockBuf); nil != err {
-			return t, err
+	if size := tcpOptions.readBuffer(); size > 0 {
+		if err := check("read buffer", t.SetReadBuffer(size)); nil != err {
+			return nil, err
 		}
+	}
+
+	if size := tcpOptions.writeBuffer(); size > 0 {
+		if err := check("write buffer", t.SetWriteBuffer(size)); nil != err {
+			return nil, err
+		}
+	}
 
-		if err := t.SetWriteBuffer(tcpOptions.SockBuf); nil != err {
-			return t, err
+	if tcpOptions.WriteBufferedSize > 0 {
+		t.writer = bufio.NewWriterSize(t.TCPConn, tcpOptions.WriteBufferedSize)
+		if t.bypass = tcpOptions.WriteBypassSize; t.bypass <= 0 {
+			t.bypass = tcpOptions.WriteBufferedSize
 		}
 	}
 
@@ -0,0 +1,143 @@
+//go:build linux
+
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// sockopt to get the socket option of connection
+func sockopt(t *testing.T, transport *tcpTransport, level, opt int) int {
+
+	raw, err := transport.SyscallConn()
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	var value int
+	if err = raw.Control(func(fd uintptr) { value, err = syscall.GetsockoptInt(int(fd), level, opt) }); nil != err {
+		t.Fatal(err)
+	}
+	if nil != err {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestApplyBuffers(t *testing.T) {
+
+	// the kernel doubles the sizes for the bookkeeping overhead.
+	options := Options{Linger: -1, SockBuf: 32 << 10, ReadBuf: 128 << 10}
+	transport, err := (&tcpTransport{TCPConn: dial(t)}).applyOptions(&options, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	read, write := sockopt(t, transport, syscall.SOL_SOCKET, syscall.SO_RCVBUF), sockopt(t, transport, syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	if read <= write || 2*options.SockBuf != write {
+		t.Fatal("unexpected buffer sizes:", read, write)
+	}
+}
+
+func TestApplyUserTimeout(t *testing.T) {
+
+	options := Options{Linger: -1, UserTimeout: 5 * time.Second}
+	transport, err := (&tcpTransport{TCPConn: dial(t)}).applyOptions(&options, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if timeout := sockopt(t, transport, syscall.IPPROTO_TCP, tcpUserTimeout); 5000 != timeout {
+		t.Fatal("unexpected user timeout:", timeout)
+	}
+}
+
+func TestApplyTOS(t *testing.T) {
+
+	options := Options{Linger: -1, TOS: 46 << 2}
+	transport, err := (&tcpTransport{TCPConn: dial(t)}).applyOptions(&options, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if tos := sockopt(t, transport, syscall.IPPROTO_IP, syscall.IP_TOS); options.TOS != tos {
+		t.Fatal("unexpected tos:", tos)
+	}
+}
+
+func TestRawControl(t *testing.T) {
+
+	conn := &tcpTransport{TCPConn: dial(t)}
+	for _, tran := range []transport.Transport{conn, transport.BufferedTransport(conn, 1024)} {
+		var soType int
+		err := transport.RawControl(tran, func(fd uintptr) (err error) {
+			soType, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TYPE)
+			return
+		})
+		if nil != err || syscall.SOCK_STREAM != soType {
+			t.Fatal("unexpected socket type:", soType, err)
+		}
+	}
+}
+
+func TestApplySideOptions(t *testing.T) {
+
+	client, server := pair(t)
+	options := Options{Linger: -1, NoDelay: true, SockBuf: 65536, Server: &SideOptions{Linger: Value(0)}}
+
+	dialed, err := (&tcpTransport{TCPConn: client}).applyOptions(&options, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+	accepted, err := (&tcpTransport{TCPConn: server}).applyOptions(&options, false)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// the partial override keeps the other shared options.
+	if 1 != sockopt(t, dialed, syscall.IPPROTO_TCP, syscall.TCP_NODELAY) || 1 != sockopt(t, accepted, syscall.IPPROTO_TCP, syscall.TCP_NODELAY) {
+		t.Fatal("the nodelay option should be inherited")
+	}
+	if sockopt(t, accepted, syscall.SOL_SOCKET, syscall.SO_RCVBUF) < 65536 {
+		t.Fatal("the buffer option should be inherited")
+	}
+	if 0 != sockopt(t, dialed, syscall.SOL_SOCKET, syscall.SO_LINGER) || 1 != sockopt(t, accepted, syscall.SOL_SOCKET, syscall.SO_LINGER) {
+		t.Fatal("the linger should be overridden for the server only")
+	}
+
+	// the override is applied to its side only.
+	client, server = pair(t)
+	options.Client = &SideOptions{NoDelay: Value(false)}
+	if dialed, err = (&tcpTransport{TCPConn: client}).applyOptions(&options, true); nil != err {
+		t.Fatal(err)
+	}
+	if accepted, err = (&tcpTransport{TCPConn: server}).applyOptions(&options, false); nil != err {
+		t.Fatal(err)
+	}
+	if 0 != sockopt(t, dialed, syscall.IPPROTO_TCP, syscall.TCP_NODELAY) || 1 != sockopt(t, accepted, syscall.IPPROTO_TCP, syscall.TCP_NODELAY) {
+		t.Fatal("unexpected nodelay options")
+	}
+	if !options.NoDelay {
+		t.Fatal("the shared options should not be modified")
+	}
+}
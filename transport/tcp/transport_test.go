@@ -0,0 +1,212 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// dial to connect a tcp connection to the local listener
+func dial(t *testing.T) *net.TCPConn {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn.(*net.TCPConn)
+}
+
+func TestApplyOptions(t *testing.T) {
+
+	for _, options := range []Options{
+		{KeepAlive: false, KeepAlivePeriod: time.Minute, Linger: -1},
+		{KeepAlive: true, KeepAlivePeriod: 0, Linger: -1},
+		*DefaultOption,
+	} {
+		if _, err := (&tcpTransport{TCPConn: dial(t)}).applyOptions(&options, true); nil != err {
+			t.Fatalf("unexpected error: %v, options: %+v", err, options)
+		}
+	}
+
+	// the socket options of closed connection can not be set.
+	conn := dial(t)
+	conn.Close()
+	if _, err := (&tcpTransport{TCPConn: conn}).applyOptions(DefaultOption, true); nil == err {
+		t.Fatal("the error of socket options should be returned")
+	}
+
+	// the ignored errors are logged through the logger of transport.
+	var buffer strings.Builder
+	options := *DefaultOption
+	options.IgnoreSockOptErrors = true
+	if _, err := (&tcpTransport{TCPConn: conn, logger: utils.NewWriterLogger(&buffer, utils.LogWarn)}).applyOptions(&options, true); nil != err {
+		t.Fatal("the error of socket options should be ignored:", err)
+	}
+	if !strings.Contains(buffer.String(), "tcp: failed to set keepalive") {
+		t.Fatal("the ignored error should be logged:", buffer.String())
+	}
+}
+
+func TestTransportLogger(t *testing.T) {
+
+	logger := utils.NopLogger()
+	options, err := transport.ParseOptions(utils.ContextWithLogger(context.Background(), logger), "tcp://127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	acceptor, err := New().Listen(options)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer acceptor.Close()
+
+	// the dialed & accepted connections log through the logger of context.
+	options.Address.Host = transport.AcceptorAddr(acceptor).String()
+	dialed, err := New().Connect(options)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer dialed.Close()
+
+	accepted, err := acceptor.Accept()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer accepted.Close()
+
+	if logger != dialed.(*tcpTransport).logger || logger != accepted.(*tcpTransport).logger {
+		t.Fatal("the logger of context should be used")
+	}
+}
+
+// pair to connect a tcp connection and accept it
+func pair(t *testing.T) (client, server *net.TCPConn) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	peer := <-accepted
+	if nil == peer {
+		t.Fatal("failed to accept")
+	}
+	t.Cleanup(func() { conn.Close(); peer.Close() })
+	return conn.(*net.TCPConn), peer.(*net.TCPConn)
+}
+
+func TestBufferedWrite(t *testing.T) {
+
+	client, server := pair(t)
+	options := Options{Linger: -1, WriteBufferedSize: 64, WriteBypassSize: 32}
+	tran, err := (&tcpTransport{TCPConn: client}).applyOptions(&options, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// read the bytes that arrive in a short time.
+	received := func() string {
+		buffer := make([]byte, 1024)
+		server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, _ := io.ReadAtLeast(server, buffer, len(buffer))
+		return string(buffer[:n])
+	}
+
+	if _, err = tran.Writev(transport.Buffers{Buffers: net.Buffers{[]byte("head"), []byte("-body")}}); nil != err {
+		t.Fatal(err)
+	}
+	if got := received(); "" != got {
+		t.Fatal("the small writes should be buffered:", got)
+	}
+
+	if err = tran.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	if got := received(); "head-body" != got {
+		t.Fatal("unexpected bytes:", got)
+	}
+
+	// the large payload bypasses the buffer, the buffered bytes are written before it.
+	large := strings.Repeat("x", 32)
+	if _, err = tran.Writev(transport.Buffers{Buffers: net.Buffers{[]byte("head"), []byte(large)}}); nil != err {
+		t.Fatal(err)
+	}
+	if got := received(); "head"+large != got {
+		t.Fatal("unexpected bytes:", got)
+	}
+}
+
+func TestWritevManyBuffers(t *testing.T) {
+
+	client, server := pair(t)
+	tran, err := (&tcpTransport{TCPConn: client}).applyOptions(DefaultOption, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// more buffers than IOV_MAX of linux.
+	var expected bytes.Buffer
+	buffers := make(net.Buffers, 0, 5000)
+	for i := 0; i < cap(buffers); i++ {
+		b := []byte(strconv.Itoa(i) + ",")
+		buffers = append(buffers, b)
+		expected.Write(b)
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(io.LimitReader(server, int64(expected.Len())))
+		received <- data
+	}()
+
+	n, err := tran.Writev(transport.Buffers{Buffers: buffers})
+	if nil != err || int64(expected.Len()) != n {
+		t.Fatal("unexpected written bytes:", n, err)
+	}
+	if data := <-received; !bytes.Equal(expected.Bytes(), data) {
+		t.Fatal("unexpected received bytes:", len(data))
+	}
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// tcpUserTimeout is TCP_USER_TIMEOUT of linux, it is missing in the syscall package of some platforms, e.g: linux/amd64.
+const tcpUserTimeout = 0x12
+
+// setUserTimeout to set TCP_USER_TIMEOUT of the connection in milliseconds, the logger is used by the other platforms.
+func setUserTimeout(conn *net.TCPConn, timeout time.Duration, _ utils.Logger) error {
+
+	raw, err := conn.SyscallConn()
+	if nil != err {
+		return err
+	}
+
+	var sockErr error
+	if err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpUserTimeout, int(timeout/time.Millisecond))
+	}); nil != err {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,38 @@
+//go:build !linux
+
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcp
+
+import (
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+var userTimeoutOnce sync.Once
+
+// setUserTimeout to warn once that TCP_USER_TIMEOUT is only supported on linux
+func setUserTimeout(conn *net.TCPConn, timeout time.Duration, logger utils.Logger) error {
+	userTimeoutOnce.Do(func() {
+		logger.Warnf("tcp: UserTimeout is not supported on %s, it is ignored", runtime.GOOS)
+	})
+	return nil
+}
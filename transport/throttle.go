@@ -0,0 +1,207 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// Throttle to limit the bandwidth of transport in bytes per second, zero means unlimited,
+// the reads are shortened and the writes are split to the burst of limiter, which is a tenth of the rate.
+func Throttle(transport Transport, readBytesPerSec, writeBytesPerSec int) Transport {
+	utils.AssertIf(readBytesPerSec < 0 || writeBytesPerSec < 0, "the bandwidth must be a non-negative integer")
+	if 0 == readBytesPerSec && 0 == writeBytesPerSec {
+		return transport
+	}
+
+	done := make(chan struct{})
+	return &throttleTransport{
+		Transport: transport,
+		reader:    newTokenBucket(readBytesPerSec, done),
+		writer:    newTokenBucket(writeBytesPerSec, done),
+		done:      done,
+	}
+}
+
+type throttleTransport struct {
+	Transport
+	reader    *tokenBucket
+	writer    *tokenBucket
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (t *throttleTransport) Read(b []byte) (int, error) {
+	if nil == t.reader {
+		return t.Transport.Read(b)
+	}
+
+	if len(b) > t.reader.burst {
+		b = b[:t.reader.burst]
+	}
+
+	// the buffer is paid before read, the tokens of the unread bytes are refunded.
+	if err := t.reader.wait(len(b)); nil != err {
+		return 0, err
+	}
+	n, err := t.Transport.Read(b)
+	t.reader.refund(len(b) - n)
+	return n, err
+}
+
+func (t *throttleTransport) Write(b []byte) (int, error) {
+	if nil == t.writer {
+		return t.Transport.Write(b)
+	}
+
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > t.writer.burst {
+			chunk = chunk[:t.writer.burst]
+		}
+		if err := t.writer.wait(len(chunk)); nil != err {
+			return written, err
+		}
+		n, err := t.Transport.Write(chunk)
+		written += n
+		if nil != err {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (t *throttleTransport) Writev(buffs Buffers) (int64, error) {
+	if nil == t.writer {
+		return t.Transport.Writev(buffs)
+	}
+
+	var size int
+	for _, b := range buffs.Buffers {
+		size += len(b)
+	}
+
+	// the small batch is written by writev at once, the large one is split.
+	if size <= t.writer.burst {
+		if err := t.writer.wait(size); nil != err {
+			return 0, err
+		}
+		return t.Transport.Writev(buffs)
+	}
+
+	var written int64
+	for _, b := range buffs.Buffers {
+		n, err := t.Write(b)
+		written += int64(n)
+		if nil != err {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Close to interrupt the throttled reads & writes and close the wrapped transport
+func (t *throttleTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return t.Transport.Close()
+}
+
+// Buffered returns the bytes buffered by the wrapped transport
+func (t *throttleTransport) Buffered() int {
+	return Buffered(t.Transport)
+}
+
+// Release to release the wrapped transport
+func (t *throttleTransport) Release() {
+	utils.Release(t.Transport)
+}
+
+// tokenBucket to meter the bytes, the tokens can be overdrawn and the debt is paid by waiting.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64 // tokens per second
+	burst  int
+	tokens float64
+	last   time.Time
+	done   chan struct{}
+}
+
+// newTokenBucket to create an empty bucket, nil for unlimited.
+func newTokenBucket(bytesPerSec int, done chan struct{}) *tokenBucket {
+	if 0 == bytesPerSec {
+		return nil
+	}
+
+	burst := bytesPerSec / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: float64(bytesPerSec), burst: burst, last: time.Now(), done: done}
+}
+
+// take n tokens and returns the time to wait for the debt
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// refund n tokens taken but not used
+func (b *tokenBucket) refund(n int) {
+	b.mutex.Lock()
+	b.tokens += float64(n)
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.mutex.Unlock()
+}
+
+// wait to take n tokens and sleep until the debt is paid, net.ErrClosed is returned if the transport is closed.
+func (b *tokenBucket) wait(n int) error {
+	delay := b.take(n)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-b.done:
+		return net.ErrClosed
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// measure the rate of transferring n bytes in bytes per second
+func measure(t *testing.T, n int, transfer func() error) float64 {
+	start := time.Now()
+	if err := transfer(); nil != err {
+		t.Fatal(err)
+	}
+	return float64(n) / time.Since(start).Seconds()
+}
+
+// within to check the rate is within 10% of the limit
+func within(t *testing.T, name string, rate float64, limit int) {
+	if rate < 0.9*float64(limit) || rate > 1.1*float64(limit) {
+		t.Fatalf("the %s rate %.0f B/s is not within 10%% of %d B/s", name, rate, limit)
+	}
+}
+
+func TestThrottleWrite(t *testing.T) {
+
+	const limit, size = 200 << 10, 100 << 10
+	local, peer := net.Pipe()
+	defer peer.Close()
+	go func() { _, _ = io.Copy(ioutil.Discard, peer) }()
+
+	throttled := Throttle(&pipeTransport{Conn: local}, 0, limit)
+	defer throttled.Close()
+
+	within(t, "write", measure(t, size, func() error {
+		_, err := throttled.Write(make([]byte, size))
+		return err
+	}), limit)
+
+	// the large batch of writev is split as the writes.
+	within(t, "writev", measure(t, size, func() error {
+		_, err := throttled.Writev(Buffers{Buffers: net.Buffers{make([]byte, size/2), make([]byte, size/2)}, Indexes: []int{2}})
+		return err
+	}), limit)
+}
+
+func TestThrottleRead(t *testing.T) {
+
+	const limit, size = 200 << 10, 100 << 10
+	local, peer := net.Pipe()
+	defer peer.Close()
+	go func() { _, _ = peer.Write(make([]byte, size)) }()
+
+	throttled := Throttle(&pipeTransport{Conn: local}, limit, 0)
+	defer throttled.Close()
+
+	within(t, "read", measure(t, size, func() error {
+		_, err := io.ReadFull(throttled, make([]byte, size))
+		return err
+	}), limit)
+}
+
+func TestThrottleClose(t *testing.T) {
+
+	local, peer := net.Pipe()
+	defer peer.Close()
+	go func() { _, _ = io.Copy(ioutil.Discard, peer) }()
+
+	// the write waiting for the tokens is interrupted by closing.
+	throttled := Throttle(&pipeTransport{Conn: local}, 0, 10)
+	result := make(chan error, 1)
+	go func() {
+		_, err := throttled.Write(make([]byte, 100))
+		result <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	throttled.Close()
+	select {
+	case err := <-result:
+		if net.ErrClosed != err {
+			t.Fatal("unexpected error:", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the throttled write should be interrupted")
+	}
+
+	if raw := Throttle(&pipeTransport{Conn: local}, 0, 10).RawTransport(); local != raw {
+		t.Fatal("the raw transport should be passed through:", raw)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/transport/tcp"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// New tls factory over the tcp transport, e.g: NewBootstrap(WithTransports(tcp.New(), tls.New())).
+//
+// The handshake is completed by Connect & Accept, so the channels go active with the handshaked connections,
+// the failures are returned by Connect, the handshakes of the accepted connections run concurrently, so a slow
+// client does not block the listener, and the failed ones are dropped, see Options.OnHandshakeError.
+func New() transport.Factory {
+	return &tlsFactory{tcp: tcp.New()}
+}
+
+type tlsFactory struct {
+	tcp transport.Factory
+}
+
+func (*tlsFactory) Schemes() transport.Schemes {
+	return transport.Schemes{"tls", "tls4", "tls6"}
+}
+
+// tcpOptions returns a copy of options with the url of tcp scheme
+func (f *tlsFactory) tcpOptions(options *transport.Options) (*transport.Options, error) {
+	if err := f.Schemes().FixedURL(options.Address); nil != err {
+		return nil, err
+	}
+
+	address := *options.Address
+	address.Scheme = "tcp" + address.Scheme[len("tls"):]
+
+	tcpOptions := *options
+	tcpOptions.Address = &address
+	return &tcpOptions, nil
+}
+
+func (f *tlsFactory) Connect(options *transport.Options) (transport.Transport, error) {
+
+	tcpOptions, err := f.tcpOptions(options)
+	if nil != err {
+		return nil, err
+	}
+
+	host, _, err := options.HostPort()
+	if nil != err {
+		return nil, err
+	}
+
+	raw, err := f.tcp.Connect(tcpOptions)
+	if nil != err {
+		return nil, err
+	}
+
+	tlsOptions := FromContext(options.Context, DefaultOption)
+	conn := tls.Client(raw, tlsOptions.clientConfig(host))
+
+	ctx, cancel := tlsOptions.handshakeContext(options.Context)
+	defer cancel()
+	if err = conn.HandshakeContext(ctx); nil != err {
+		raw.Close()
+		return nil, err
+	}
+	return &tlsTransport{Conn: conn, raw: raw}, nil
+}
+
+func (f *tlsFactory) Listen(options *transport.Options) (transport.Acceptor, error) {
+
+	tcpOptions, err := f.tcpOptions(options)
+	if nil != err {
+		return nil, err
+	}
+
+	tlsOptions := FromContext(options.Context, DefaultOption)
+	config, err := tlsOptions.serverConfig()
+	if nil != err {
+		return nil, err
+	}
+
+	acceptor, err := f.tcp.Listen(tcpOptions)
+	if nil != err {
+		return nil, err
+	}
+
+	onError := tlsOptions.OnHandshakeError
+	if nil == onError {
+		logger := utils.LoggerFrom(options.Context)
+		onError = func(err *HandshakeError) { logger.Debugf("%v", err) }
+	}
+
+	t := &tlsAcceptor{
+		acceptor: acceptor,
+		config:   config,
+		options:  tlsOptions,
+		onError:  onError,
+		ctx:      options.Context,
+		results:  make(chan acceptResult),
+		done:     make(chan struct{}),
+	}
+	go t.acceptLoop(transport.AcceptPolicyFrom(options.Context))
+	return t, nil
+}
+
+// acceptResult defines the result of handshake
+type acceptResult struct {
+	transport transport.Transport
+	err       error
+}
+
+type tlsAcceptor struct {
+	acceptor  transport.Acceptor
+	config    *tls.Config
+	options   *Options
+	onError   func(err *HandshakeError)
+	ctx       context.Context
+	results   chan acceptResult
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// acceptLoop to accept the tcp connections and handshake them concurrently, the temporary errors are
+// forwarded to Accept and retried with the backoff of policy.
+func (t *tlsAcceptor) acceptLoop(policy transport.AcceptPolicy) {
+
+	var retryDelay time.Duration
+	for {
+		raw, err := t.acceptor.Accept()
+		if nil != err {
+			if !t.deliver(acceptResult{err: err}) || !policy.Retryable(err) {
+				return
+			}
+
+			retryDelay = policy.Backoff(retryDelay)
+			timer := time.NewTimer(retryDelay)
+			select {
+			case <-timer.C:
+				continue
+			case <-t.done:
+				timer.Stop()
+				return
+			}
+		}
+		retryDelay = 0
+
+		go t.handshake(raw)
+	}
+}
+
+// handshake to complete the handshake of the accepted connection
+func (t *tlsAcceptor) handshake(raw transport.Transport) {
+
+	conn := tls.Server(raw, t.config)
+
+	ctx, cancel := t.options.handshakeContext(t.ctx)
+	defer cancel()
+	// the failure belongs to the peer, so it is not an error of Accept, which would back off the listener.
+	if err := conn.HandshakeContext(ctx); nil != err {
+		raw.Close()
+		t.onError(&HandshakeError{RemoteAddr: raw.RemoteAddr(), Err: err})
+		return
+	}
+
+	t.deliver(acceptResult{transport: &tlsTransport{Conn: conn, raw: raw}})
+}
+
+// deliver the result to Accept, the transport is closed if the acceptor has been closed.
+func (t *tlsAcceptor) deliver(result acceptResult) bool {
+	select {
+	case t.results <- result:
+		return true
+	case <-t.done:
+		if nil != result.transport {
+			result.transport.Close()
+		}
+		return false
+	}
+}
+
+func (t *tlsAcceptor) Accept() (transport.Transport, error) {
+	select {
+	case result := <-t.results:
+		return result.transport, result.err
+	case <-t.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// AcceptConcurrently to impl transport.ConcurrentAcceptor, the results of handshakes are received from a channel.
+func (t *tlsAcceptor) AcceptConcurrently() {}
+
+// Addr returns the bound address of listener
+func (t *tlsAcceptor) Addr() net.Addr {
+	return transport.AcceptorAddr(t.acceptor)
+}
+
+// Close the listener, the connections in handshake are closed after the handshake.
+func (t *tlsAcceptor) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.closeErr = t.acceptor.Close()
+	})
+	return t.closeErr
+}
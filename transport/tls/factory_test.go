@@ -0,0 +1,298 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/transport/tcp"
+)
+
+// certificate to create a self-signed certificate of the name
+func certificate(t *testing.T, name string) (tls.Certificate, *x509.CertPool) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// listen to create a tls acceptor with the options
+func listen(t *testing.T, tlsOptions *Options) (transport.Acceptor, string) {
+
+	options, err := transport.ParseOptions(context.Background(), "tls://127.0.0.1:0", WithOptions(tlsOptions))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	acceptor, err := New().Listen(options)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { acceptor.Close() })
+	return acceptor, "tls://" + transport.AcceptorAddr(acceptor).String()
+}
+
+// connect to the tls address with the options
+func connect(url string, tlsOptions *Options) (transport.Transport, error) {
+	options, err := transport.ParseOptions(context.Background(), url, WithOptions(tlsOptions))
+	if nil != err {
+		return nil, err
+	}
+	return New().Connect(options)
+}
+
+func TestTLSTransport(t *testing.T) {
+
+	cert, pool := certificate(t, "example.test")
+	acceptor, url := listen(t, &Options{Config: &tls.Config{Certificates: []tls.Certificate{cert}}, HandshakeTimeout: time.Second})
+
+	// the host of url is overridden by ServerName to verify the certificate.
+	client, err := connect(url, &Options{Config: &tls.Config{RootCAs: pool}, ServerName: "example.test"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	server, err := acceptor.Accept()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// the channels go active with the handshaked connections.
+	for _, side := range []transport.Transport{client, server} {
+		if state, ok := ConnectionState(side); !ok || !state.HandshakeComplete {
+			t.Fatal("the handshake should be completed:", ok)
+		}
+		if _, ok := side.RawTransport().(*tls.Conn); !ok {
+			t.Fatalf("unexpected raw transport: %T", side.RawTransport())
+		}
+	}
+
+	if _, err = client.Writev(transport.Buffers{Buffers: net.Buffers{[]byte("hello, "), []byte("tls")}, Indexes: []int{2}}); nil != err {
+		t.Fatal(err)
+	}
+	if err = client.Flush(); nil != err {
+		t.Fatal(err)
+	}
+
+	buffer := make([]byte, len("hello, tls"))
+	if _, err = io.ReadFull(server, buffer); nil != err || "hello, tls" != string(buffer) {
+		t.Fatal("unexpected message:", string(buffer), err)
+	}
+}
+
+func TestHandshakeFailure(t *testing.T) {
+
+	cert, _ := certificate(t, "example.test")
+	failures := make(chan *HandshakeError, 4)
+	acceptor, url := listen(t, &Options{
+		Config:           &tls.Config{Certificates: []tls.Certificate{cert}},
+		HandshakeTimeout: time.Second,
+		OnHandshakeError: func(err *HandshakeError) { failures <- err },
+	})
+
+	// the certificate of unknown authority fails the Connect.
+	if _, err := connect(url, &Options{ServerName: "example.test"}); nil == err {
+		t.Fatal("the handshake should fail")
+	}
+
+	// the failure is observed by the callback instead of Accept.
+	if err := <-failures; !errors.As(err, new(*HandshakeError)) || nil == err.RemoteAddr {
+		t.Fatal("unexpected error:", err)
+	}
+
+	client, err := connect(url, &Options{InsecureSkipVerify: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	server, err := acceptor.Accept()
+	if nil != err {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	// the closed acceptor stops accepting.
+	acceptor.Close()
+	if _, err = acceptor.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestBootstrapHandshakeFailure(t *testing.T) {
+
+	cert, _ := certificate(t, "example.test")
+	active := make(chan struct{}, 1)
+	bs := netty.NewBootstrap(
+		netty.WithTransports(tcp.New(), New()),
+		netty.WithChildInitializer(func(channel netty.Channel) {
+			channel.Pipeline().AddLast(netty.ActiveHandlerFunc(func(ctx netty.ActiveContext) {
+				active <- struct{}{}
+				ctx.HandleActive()
+			}))
+		}),
+	)
+	defer bs.Shutdown()
+
+	failures := make(chan *HandshakeError, 8)
+	listener := bs.Listen("tls://127.0.0.1:0", WithOptions(&Options{
+		Config:           &tls.Config{Certificates: []tls.Certificate{cert}},
+		OnHandshakeError: func(err *HandshakeError) { failures <- err },
+	}))
+	ready := make(chan net.Addr, 1)
+	listener.OnReady(func(addr net.Addr) { ready <- addr })
+	listener.Async(func(error) {})
+	addr := (<-ready).String()
+
+	// the plain tcp clients fail the handshakes, e.g: the health checks.
+	for i := 0; i < 8; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if nil != err {
+			t.Fatal(err)
+		}
+		_, _ = conn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+		conn.Close()
+		<-failures
+	}
+
+	// the failures do not back off the listener, so the next client is accepted at once.
+	start := time.Now()
+	channel, err := bs.Connect("tls://"+addr, nil, WithOptions(&Options{InsecureSkipVerify: true}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer channel.Close(nil)
+
+	<-active
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatal("the accepting is delayed by the failed handshakes:", elapsed)
+	}
+}
+
+func TestListenRequiresCertificates(t *testing.T) {
+
+	options, err := transport.ParseOptions(context.Background(), "tls://127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err = New().Listen(options); nil == err {
+		t.Fatal("the listener without certificates should be rejected")
+	}
+}
+
+// pipeTransport defines a transport that is not tls
+type pipeTransport struct {
+	net.Conn
+}
+
+func (p *pipeTransport) Writev(buffs transport.Buffers) (int64, error) {
+	return buffs.Buffers.WriteTo(p.Conn)
+}
+
+func (p *pipeTransport) Flush() error {
+	return nil
+}
+
+func (p *pipeTransport) RawTransport() interface{} {
+	return p.Conn
+}
+
+func TestConnectionStateUnsupported(t *testing.T) {
+
+	local, peer := net.Pipe()
+	defer peer.Close()
+	defer local.Close()
+
+	if _, ok := ConnectionState(&pipeTransport{Conn: local}); ok {
+		t.Fatal("the pipe transport has no tls connection state")
+	}
+}
+
+func TestBootstrapTLS(t *testing.T) {
+
+	cert, pool := certificate(t, "example.test")
+	states := make(chan tls.ConnectionState, 1)
+	bs := netty.NewBootstrap(
+		netty.WithTransports(tcp.New(), New()),
+		netty.WithChildInitializer(func(channel netty.Channel) {
+			channel.Pipeline().AddLast(netty.ActiveHandlerFunc(func(ctx netty.ActiveContext) {
+				state, _ := ConnectionState(ctx.Channel().Transport())
+				states <- state
+				ctx.HandleActive()
+			}))
+		}),
+	)
+	defer bs.Shutdown()
+
+	listener := bs.Listen("tls://127.0.0.1:0", WithOptions(&Options{Config: &tls.Config{Certificates: []tls.Certificate{cert}}}))
+	ready := make(chan net.Addr, 1)
+	listener.OnReady(func(addr net.Addr) { ready <- addr })
+	listener.Async(func(error) {})
+
+	channel, err := bs.Connect("tls://"+(<-ready).String(), nil, WithOptions(&Options{Config: &tls.Config{RootCAs: pool}, ServerName: "example.test"}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer channel.Close(nil)
+
+	if state := <-states; !state.HandshakeComplete || "example.test" != state.ServerName {
+		t.Fatal("the accepted channel should be active with the handshaked connection:", state.ServerName)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// DefaultOption default tls options
+var DefaultOption = &Options{
+	HandshakeTimeout: time.Second * 10,
+}
+
+// Options for tls transport, the options of the underlying tcp transport are set by tcp.WithOptions.
+type Options struct {
+	// Config of tls, the certificates or GetCertificate are required by the listeners, e.g: ClientAuth to verify the clients.
+	Config *tls.Config `json:"-"`
+	// ServerName to override the name of server verified by the dialed connections, the host of url by default.
+	ServerName string `json:"server-name"`
+	// InsecureSkipVerify to skip verifying the certificates of server, for tests only.
+	InsecureSkipVerify bool `json:"insecure-skip-verify,string"`
+	// HandshakeTimeout to limit the handshake of the dialed & accepted connections, zero means no timeout.
+	HandshakeTimeout time.Duration `json:"handshake-timeout"`
+	// OnHandshakeError to observe the failed handshakes of the accepted connections, which are closed and never
+	// returned by Accept, they are logged in debug level by default.
+	OnHandshakeError func(err *HandshakeError) `json:"-"`
+}
+
+var contextKey = struct{ key string }{"go-netty-transport-tls-options"}
+
+// WithOptions to wrap the tls options
+func WithOptions(option *Options) transport.Option {
+	return func(options *transport.Options) error {
+		if nil == option {
+			return fmt.Errorf("nil tls options")
+		}
+		if option.HandshakeTimeout < 0 {
+			return fmt.Errorf("invalid tls options: negative HandshakeTimeout")
+		}
+		options.Context = context.WithValue(options.Context, contextKey, option)
+		return nil
+	}
+}
+
+// FromContext to unwrap the tls options
+func FromContext(ctx context.Context, def *Options) *Options {
+	if v, ok := ctx.Value(contextKey).(*Options); ok {
+		return v
+	}
+	return def
+}
+
+// clientConfig returns the config of the dialed connections to the host
+func (o *Options) clientConfig(host string) *tls.Config {
+	config := &tls.Config{}
+	if nil != o.Config {
+		config = o.Config.Clone()
+	}
+
+	switch {
+	case "" != o.ServerName:
+		config.ServerName = o.ServerName
+	case "" == config.ServerName:
+		config.ServerName = host
+	}
+
+	if o.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	return config
+}
+
+// serverConfig returns the config of the accepted connections
+func (o *Options) serverConfig() (*tls.Config, error) {
+	if nil == o.Config || (0 == len(o.Config.Certificates) && nil == o.Config.GetCertificate && nil == o.Config.GetConfigForClient) {
+		return nil, fmt.Errorf("the tls listener requires the certificates of Options.Config")
+	}
+	return o.Config, nil
+}
+
+// handshakeContext returns the context to limit the handshake
+func (o *Options) handshakeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.HandshakeTimeout > 0 {
+		return context.WithTimeout(ctx, o.HandshakeTimeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tls
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// tlsTransport implements transport.Transport by the embedded *tls.Conn over the tcp transport
+type tlsTransport struct {
+	*tls.Conn
+	raw transport.Transport
+}
+
+func (t *tlsTransport) Writev(buffs transport.Buffers) (int64, error) {
+
+	var size int
+	for _, b := range buffs.Buffers {
+		size += len(b)
+	}
+
+	// the small buffers are merged into one write, so they are sealed in one record instead of one for each.
+	if size > 64<<10 {
+		return buffs.Buffers.WriteTo(t.Conn)
+	}
+
+	merged := utils.GetBytes(size)[:0]
+	defer utils.PutBytes(merged)
+	for _, b := range buffs.Buffers {
+		merged = append(merged, b...)
+	}
+
+	n, err := t.Conn.Write(merged)
+	return int64(n), err
+}
+
+// Flush to flush the buffered writes of the tcp transport, see tcp.Options.WriteBufferedSize.
+func (t *tlsTransport) Flush() error {
+	return t.raw.Flush()
+}
+
+// RawTransport returns the *tls.Conn
+func (t *tlsTransport) RawTransport() interface{} {
+	return t.Conn
+}
+
+// ConnectionState to get the state of tls connection, e.g: ctx.Channel().Transport() in the handlers,
+// false is returned if the transport is not a tls transport.
+func ConnectionState(t transport.Transport) (tls.ConnectionState, bool) {
+	if conn, ok := t.RawTransport().(*tls.Conn); ok {
+		return conn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// HandshakeError defines the failure of handshake of the accepted connection, see Options.OnHandshakeError.
+type HandshakeError struct {
+	// RemoteAddr of the connection
+	RemoteAddr net.Addr
+	// Err the original error
+	Err error
+}
+
+// Error to impl error
+func (e *HandshakeError) Error() string {
+	return "tls handshake with " + e.RemoteAddr.String() + " failed: " + e.Err.Error()
+}
+
+// Unwrap to get the original error
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
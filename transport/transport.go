@@ -60,6 +60,8 @@ type BuffersWriter interface {
 
 // Transport defines a transport
 type Transport interface {
+	// net.Conn declares the deadlines, e.g: SetReadDeadline & SetWriteDeadline, so no type assertion of RawTransport
+	// is needed for the timeouts. The transport that can not support them should return an error instead of ignoring.
 	net.Conn
 
 	// BuffersWriter for optimized syscall
@@ -97,6 +99,8 @@ type Schemes []string
 // FixedURL to fix scheme
 func (ss Schemes) FixedURL(u *url.URL) error {
 	switch {
+	case nil == u:
+		return fmt.Errorf("missing url, available: %v", ss)
 	case "" == u.Scheme:
 		u.Scheme = ss[0]
 	case !ss.Valid(u.Scheme):
@@ -17,8 +17,12 @@
 package transport
 
 import (
+	"errors"
+	"net"
 	"net/url"
+	"os"
 	"testing"
+	"time"
 )
 
 func TestSchemes(t *testing.T) {
@@ -51,3 +55,35 @@ func TestSchemes(t *testing.T) {
 	}
 
 }
+
+func TestRawControlUnsupported(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	if err := RawControl(&pipeTransport{Conn: local}, func(fd uintptr) error { return nil }); ErrSyscallUnsupported != err {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestTransportDeadline(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	// the deadlines are delegated by the wrappers of transport.
+	for _, tran := range []Transport{&pipeTransport{Conn: local}, BufferedTransport(&pipeTransport{Conn: local}, 64)} {
+		if err := tran.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); nil != err {
+			t.Fatal(err)
+		}
+		if _, err := tran.Read(make([]byte, 8)); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatal("unexpected read error:", err)
+		}
+
+		if err := tran.SetWriteDeadline(time.Now().Add(10 * time.Millisecond)); nil != err {
+			t.Fatal(err)
+		}
+		if _, err := tran.Write([]byte("blocked")); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatal("unexpected write error:", err)
+		}
+		tran.SetDeadline(time.Time{})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package unix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// New unix factory, the path of socket file is carried by the url, e.g: unix:///var/run/app.sock
+func New() transport.Factory {
+	return new(unixFactory)
+}
+
+type unixFactory struct{}
+
+func (*unixFactory) Schemes() transport.Schemes {
+	return transport.Schemes{"unix", "unixpacket"}
+}
+
+func (f *unixFactory) Connect(options *transport.Options) (transport.Transport, error) {
+
+	if err := f.Schemes().FixedURL(options.Address); nil != err {
+		return nil, err
+	}
+
+	path, err := socketPath(options)
+	if nil != err {
+		return nil, err
+	}
+
+	unixOptions := FromContext(options.Context, DefaultOption)
+	d := net.Dialer{Timeout: unixOptions.Timeout}
+	conn, err := d.DialContext(options.Context, options.Address.Scheme, path)
+	if nil != err {
+		return nil, err
+	}
+	return &unixTransport{UnixConn: conn.(*net.UnixConn)}, nil
+}
+
+func (f *unixFactory) Listen(options *transport.Options) (transport.Acceptor, error) {
+
+	if err := f.Schemes().FixedURL(options.Address); nil != err {
+		return nil, err
+	}
+
+	path, err := socketPath(options)
+	if nil != err {
+		return nil, err
+	}
+
+	network := options.Address.Scheme
+	unixOptions := FromContext(options.Context, DefaultOption)
+	if !unixOptions.KeepStale {
+		if err = removeStale(network, path); nil != err {
+			return nil, err
+		}
+	}
+
+	l, err := net.ListenUnix(network, &net.UnixAddr{Name: path, Net: network})
+	if nil != err {
+		return nil, err
+	}
+
+	// the socket file is removed by the acceptor only if it is still the one created by the listener.
+	l.SetUnlinkOnClose(false)
+	acceptor := &unixAcceptor{listener: l, path: path, done: make(chan struct{})}
+	if acceptor.file, err = os.Lstat(path); nil != err {
+		l.Close()
+		return nil, err
+	}
+
+	if 0 != unixOptions.FileMode {
+		if err = os.Chmod(path, unixOptions.FileMode); nil != err {
+			acceptor.Close()
+			return nil, err
+		}
+	}
+
+	go acceptor.closeOnDone(options.Context)
+	return acceptor, nil
+}
+
+// socketPath returns the path of socket file, e.g: unix:///var/run/app.sock or unix://run/app.sock for the relative path.
+func socketPath(options *transport.Options) (string, error) {
+	path := options.Address.Host + options.Address.Path
+	if "" == path || "/" == path {
+		return "", fmt.Errorf("missing the path of socket file: %s", options.Address)
+	}
+	return path, nil
+}
+
+// removeStale to remove the socket file that nobody is listening on, the other files are left to fail the listening.
+func removeStale(network, path string) error {
+
+	info, err := os.Lstat(path)
+	if nil != err || 0 == info.Mode()&os.ModeSocket {
+		return nil
+	}
+
+	conn, err := net.DialTimeout(network, path, time.Second)
+	if nil == err {
+		conn.Close()
+		return fmt.Errorf("the socket file %s is in use", path)
+	}
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	if err = os.Remove(path); nil != err && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+type unixAcceptor struct {
+	listener  *net.UnixListener
+	path      string
+	file      os.FileInfo
+	closeOnce sync.Once
+	closeErr  error
+	done      chan struct{}
+}
+
+// closeOnDone to interrupt the blocked Accept by closing the listener when the ctx is done.
+func (u *unixAcceptor) closeOnDone(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		u.Close()
+	case <-u.done:
+	}
+}
+
+func (u *unixAcceptor) Accept() (transport.Transport, error) {
+	conn, err := u.listener.AcceptUnix()
+	if nil != err {
+		return nil, err
+	}
+	return &unixTransport{UnixConn: conn}, nil
+}
+
+// AcceptConcurrently to impl transport.ConcurrentAcceptor, the UnixListener is safe for concurrent use.
+func (u *unixAcceptor) AcceptConcurrently() {}
+
+// Addr returns the bound address of listener
+func (u *unixAcceptor) Addr() net.Addr {
+	return u.listener.Addr()
+}
+
+// Close the listener and remove the socket file, the file replaced by others is kept.
+func (u *unixAcceptor) Close() error {
+	u.closeOnce.Do(func() {
+		close(u.done)
+		u.closeErr = u.listener.Close()
+		if info, err := os.Lstat(u.path); nil == err && os.SameFile(u.file, info) {
+			_ = os.Remove(u.path)
+		}
+	})
+	return u.closeErr
+}
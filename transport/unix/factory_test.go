@@ -0,0 +1,149 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package unix
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// parse the options of url
+func parse(t *testing.T, url string, option ...transport.Option) *transport.Options {
+	options, err := transport.ParseOptions(context.Background(), url, option...)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return options
+}
+
+func TestUnixTransport(t *testing.T) {
+
+	for _, scheme := range New().Schemes() {
+		path := filepath.Join(t.TempDir(), "app.sock")
+		acceptor, err := New().Listen(parse(t, scheme+"://"+path, WithOptions(&Options{FileMode: 0600})))
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		if info, err := os.Stat(path); nil != err || 0600 != info.Mode().Perm() {
+			t.Fatal("the file mode should be changed:", info, err)
+		}
+
+		client, err := New().Connect(parse(t, scheme+"://"+path))
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		server, err := acceptor.Accept()
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		if _, err = client.Writev(transport.Buffers{Buffers: net.Buffers{[]byte("hello, "), []byte("unix")}, Indexes: []int{2}}); nil != err {
+			t.Fatal(err)
+		}
+
+		buffer := make([]byte, 16)
+		if n, err := io.ReadAtLeast(server, buffer, len("hello, unix")); nil != err || "hello, unix" != string(buffer[:n]) {
+			t.Fatal("unexpected message:", string(buffer[:n]), err)
+		}
+
+		client.Close()
+		server.Close()
+		acceptor.Close()
+
+		// the socket file created by the acceptor is removed.
+		if _, err = os.Lstat(path); !os.IsNotExist(err) {
+			t.Fatal("the socket file should be removed:", err)
+		}
+	}
+}
+
+func TestListenStale(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// leave a stale socket file that nobody is listening on.
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	l.SetUnlinkOnClose(false)
+	l.Close()
+
+	if _, err = New().Listen(parse(t, "unix://"+path, WithOptions(&Options{KeepStale: true}))); nil == err {
+		t.Fatal("the stale socket file should be kept")
+	}
+
+	acceptor, err := New().Listen(parse(t, "unix://"+path))
+	if nil != err {
+		t.Fatal("the stale socket file should be removed:", err)
+	}
+	defer acceptor.Close()
+
+	// the socket file in use is not removed.
+	if _, err = New().Listen(parse(t, "unix://"+path)); nil == err {
+		t.Fatal("the socket file in use should not be removed")
+	}
+	if _, err = New().Connect(parse(t, "unix://"+path)); nil != err {
+		t.Fatal("the listener should be still available:", err)
+	}
+}
+
+func TestCloseKeepsForeignFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	acceptor, err := New().Listen(parse(t, "unix://"+path))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// the socket file is replaced by another one.
+	if err = os.Remove(path); nil != err {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, []byte("foreign"), 0600); nil != err {
+		t.Fatal(err)
+	}
+
+	acceptor.Close()
+	if data, err := os.ReadFile(path); nil != err || "foreign" != string(data) {
+		t.Fatal("the file not created by the acceptor should be kept:", err)
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+
+	for url, expected := range map[string]string{
+		"unix:///var/run/app.sock": "/var/run/app.sock",
+		"unix://run/app.sock":      "run/app.sock",
+	} {
+		if path, err := socketPath(parse(t, url)); nil != err || expected != path {
+			t.Fatal("unexpected path:", path, err)
+		}
+	}
+
+	if _, err := New().Listen(parse(t, "unix://")); nil == err {
+		t.Fatal("the empty path should be rejected")
+	}
+}
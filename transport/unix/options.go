@@ -0,0 +1,65 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package unix
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// DefaultOption default unix options
+var DefaultOption = &Options{
+	Timeout: time.Second * 5,
+}
+
+// Options for unix transport
+type Options struct {
+	// Timeout of dialing
+	Timeout time.Duration `json:"timeout"`
+	// FileMode to change the permissions of the socket file after listening, zero means the umask of process.
+	FileMode os.FileMode `json:"file-mode"`
+	// KeepStale to fail the listening if the socket file exists, by default the socket file is removed if nothing is listening.
+	KeepStale bool `json:"keep-stale,string"`
+}
+
+var contextKey = struct{ key string }{"go-netty-transport-unix-options"}
+
+// WithOptions to wrap the unix options
+func WithOptions(option *Options) transport.Option {
+	return func(options *transport.Options) error {
+		if nil == option {
+			return fmt.Errorf("nil unix options")
+		}
+		if option.Timeout < 0 {
+			return fmt.Errorf("invalid unix options: negative Timeout")
+		}
+		options.Context = context.WithValue(options.Context, contextKey, option)
+		return nil
+	}
+}
+
+// FromContext to unwrap the unix options
+func FromContext(ctx context.Context, def *Options) *Options {
+	if v, ok := ctx.Value(contextKey).(*Options); ok {
+		return v
+	}
+	return def
+}
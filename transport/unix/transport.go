@@ -0,0 +1,41 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package unix
+
+import (
+	"net"
+
+	"github.com/go-netty/go-netty/transport"
+)
+
+// unixTransport implements transport.SyscallTransport by the embedded *net.UnixConn
+type unixTransport struct {
+	*net.UnixConn
+}
+
+func (t *unixTransport) Writev(buffs transport.Buffers) (int64, error) {
+	// net.Buffers writes by writev, a unixpacket message is not split unless it exceeds IOV_MAX.
+	return buffs.Buffers.WriteTo(t.UnixConn)
+}
+
+func (t *unixTransport) Flush() error {
+	return nil
+}
+
+func (t *unixTransport) RawTransport() interface{} {
+	return t.UnixConn
+}
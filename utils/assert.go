@@ -18,6 +18,10 @@ package utils
 
 import "fmt"
 
+// The Assert helpers panic, so they are only used for programming errors,
+// e.g: invalid arguments of constructors or broken invariants of the codecs.
+// Errors caused by user input or configuration (addresses, options) must be returned.
+
 // Assert if nil != err
 func Assert(err error, msg ...interface{}) {
 	if nil != err {
@@ -29,7 +33,7 @@ func Assert(err error, msg ...interface{}) {
 	}
 }
 
-// AssertIf exp
+// AssertIf exp, the arguments will be boxed even if exp is false, so avoid it on hot paths.
 func AssertIf(exp bool, msg string, args ...interface{}) {
 	if exp {
 		panic(fmt.Errorf(msg, args...))
@@ -0,0 +1,458 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// ErrByteBufFixed will be raised when writing more than the writable bytes of a slice.
+var ErrByteBufFixed = errors.New("the capacity of slice is fixed")
+
+// ErrVarintOverflow will be raised when reading a varint that overflows a 64-bit integer.
+var ErrVarintOverflow = errors.New("varint overflows a 64-bit integer")
+
+// byteBufStorage defines the backing array shared by the ByteBuf and its slices.
+type byteBufStorage struct {
+	buf    []byte
+	refs   int32
+	pooled bool
+}
+
+func (s *byteBufStorage) retain() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+func (s *byteBufStorage) release() {
+	if 0 == atomic.AddInt32(&s.refs, -1) && s.pooled {
+		PutBytes(s.buf)
+	}
+}
+
+// ByteBuf defines a buffer with independent reader and writer indexes.
+//
+//	+-------------------+------------------+------------------+
+//	| discardable bytes |  readable bytes  |  writable bytes  |
+//	+-------------------+------------------+------------------+
+//	0      <=     readerIndex   <=   writerIndex    <=    capacity
+//
+// The views returned by Bytes, Peek and ReadBytes alias the backing array, they are valid until
+// the buffer grows, compacts or is released. The slices share the backing array with explicit
+// retain semantics: every slice holds a reference of the backing array and must be released.
+type ByteBuf struct {
+	storage *byteBufStorage
+	owned   byteBufStorage // the initial storage allocated with the ByteBuf
+	buf     []byte
+	r, w    int
+	mark    int
+	fixed   bool
+	refs    int32
+}
+
+// NewByteBuf rent a empty ByteBuf with the capacity from the buffer pool.
+func NewByteBuf(capacity int) *ByteBuf {
+	p := GetBytes(capacity)
+	p = p[:cap(p)]
+	b := &ByteBuf{owned: byteBufStorage{buf: p, refs: 1, pooled: true}, buf: p, refs: 1}
+	b.storage = &b.owned
+	return b
+}
+
+// WrapByteBuf wrap the bytes as the readable bytes of ByteBuf, the bytes will not be returned to the pool.
+func WrapByteBuf(p []byte) *ByteBuf {
+	p = p[:len(p):len(p)]
+	b := &ByteBuf{owned: byteBufStorage{buf: p, refs: 1}, buf: p, w: len(p), refs: 1}
+	b.storage = &b.owned
+	return b
+}
+
+// checkReleased to detect use-after-release in debug mode
+func (b *ByteBuf) checkReleased() {
+	if atomic.LoadInt32(&b.refs) <= 0 && poolDebug() {
+		panic(ErrBufferReleased)
+	}
+}
+
+// Capacity returns the number of bytes the buffer can hold without growing
+func (b *ByteBuf) Capacity() int {
+	return len(b.buf)
+}
+
+// ReaderIndex returns the index of the next read
+func (b *ByteBuf) ReaderIndex() int {
+	return b.r
+}
+
+// WriterIndex returns the index of the next write
+func (b *ByteBuf) WriterIndex() int {
+	return b.w
+}
+
+// SetReaderIndex to set the reader index, 0 <= index <= writerIndex
+func (b *ByteBuf) SetReaderIndex(index int) error {
+	if index < 0 || index > b.w {
+		return io.ErrUnexpectedEOF
+	}
+	b.r = index
+	return nil
+}
+
+// SetWriterIndex to set the writer index, readerIndex <= index <= capacity
+func (b *ByteBuf) SetWriterIndex(index int) error {
+	if index < b.r || index > len(b.buf) {
+		return io.ErrShortBuffer
+	}
+	b.w = index
+	return nil
+}
+
+// ReadableBytes returns the number of readable bytes
+func (b *ByteBuf) ReadableBytes() int {
+	return b.w - b.r
+}
+
+// WritableBytes returns the number of bytes can be written without growing
+func (b *ByteBuf) WritableBytes() int {
+	return len(b.buf) - b.w
+}
+
+// Bytes returns a view of the readable bytes without moving the reader index
+func (b *ByteBuf) Bytes() []byte {
+	b.checkReleased()
+	return b.buf[b.r:b.w]
+}
+
+// Reset to clear the reader and writer indexes
+func (b *ByteBuf) Reset() {
+	b.r, b.w, b.mark = 0, 0, 0
+}
+
+// EnsureWritable to grow the buffer if the writable bytes less than n, the slices can not grow.
+func (b *ByteBuf) EnsureWritable(n int) {
+
+	b.checkReleased()
+
+	if b.WritableBytes() >= n {
+		return
+	}
+
+	if b.fixed {
+		panic(ErrByteBufFixed)
+	}
+
+	newCap := 2 * len(b.buf)
+	if newCap < b.w+n {
+		newCap = b.w + n
+	}
+
+	grown := GetBytes(newCap)
+	grown = grown[:cap(grown)]
+	copy(grown, b.buf[:b.w])
+
+	// the slices still hold the old storage.
+	b.storage.release()
+	b.storage = &byteBufStorage{buf: grown, refs: 1, pooled: true}
+	b.buf = grown
+}
+
+// DiscardReadBytes to move the readable bytes to the beginning of the buffer
+func (b *ByteBuf) DiscardReadBytes() {
+
+	b.checkReleased()
+
+	if 0 == b.r {
+		return
+	}
+
+	copy(b.buf, b.buf[b.r:b.w])
+	b.w -= b.r
+	if b.mark -= b.r; b.mark < 0 {
+		b.mark = 0
+	}
+	b.r = 0
+}
+
+// Write to append bytes and move the writer index
+func (b *ByteBuf) Write(p []byte) (int, error) {
+	b.EnsureWritable(len(p))
+	n := copy(b.buf[b.w:], p)
+	b.w += n
+	return n, nil
+}
+
+// WriteString to append string and move the writer index
+func (b *ByteBuf) WriteString(s string) (int, error) {
+	b.EnsureWritable(len(s))
+	n := copy(b.buf[b.w:], s)
+	b.w += n
+	return n, nil
+}
+
+// WriteByte to append a byte and move the writer index
+func (b *ByteBuf) WriteByte(c byte) error {
+	b.EnsureWritable(1)
+	b.buf[b.w] = c
+	b.w++
+	return nil
+}
+
+// Grow to move the writer index by n and returns the view of the written region
+func (b *ByteBuf) Grow(n int) []byte {
+	b.EnsureWritable(n)
+	b.w += n
+	return b.buf[b.w-n : b.w]
+}
+
+// WriteUint16BE to append an uint16 in big endian
+func (b *ByteBuf) WriteUint16BE(v uint16) {
+	binary.BigEndian.PutUint16(b.Grow(2), v)
+}
+
+// WriteUint16LE to append an uint16 in little endian
+func (b *ByteBuf) WriteUint16LE(v uint16) {
+	binary.LittleEndian.PutUint16(b.Grow(2), v)
+}
+
+// WriteUint32BE to append an uint32 in big endian
+func (b *ByteBuf) WriteUint32BE(v uint32) {
+	binary.BigEndian.PutUint32(b.Grow(4), v)
+}
+
+// WriteUint32LE to append an uint32 in little endian
+func (b *ByteBuf) WriteUint32LE(v uint32) {
+	binary.LittleEndian.PutUint32(b.Grow(4), v)
+}
+
+// WriteUint64BE to append an uint64 in big endian
+func (b *ByteBuf) WriteUint64BE(v uint64) {
+	binary.BigEndian.PutUint64(b.Grow(8), v)
+}
+
+// WriteUint64LE to append an uint64 in little endian
+func (b *ByteBuf) WriteUint64LE(v uint64) {
+	binary.LittleEndian.PutUint64(b.Grow(8), v)
+}
+
+// WriteUvarint to append an unsigned varint
+func (b *ByteBuf) WriteUvarint(v uint64) {
+	b.EnsureWritable(binary.MaxVarintLen64)
+	b.w += binary.PutUvarint(b.buf[b.w:], v)
+}
+
+// WriteFromReader to read at most n bytes from reader with one call of Read
+func (b *ByteBuf) WriteFromReader(reader io.Reader, n int) (int, error) {
+	b.EnsureWritable(n)
+	n, err := reader.Read(b.buf[b.w : b.w+n])
+	b.w += n
+	return n, err
+}
+
+// Read to consume the readable bytes, io.EOF will be returned if the buffer is empty.
+func (b *ByteBuf) Read(p []byte) (int, error) {
+
+	b.checkReleased()
+
+	if b.r == b.w {
+		if 0 == len(p) {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, b.buf[b.r:b.w])
+	b.r += n
+	return n, nil
+}
+
+// ReadByte to consume a byte
+func (b *ByteBuf) ReadByte() (byte, error) {
+
+	b.checkReleased()
+
+	if b.r == b.w {
+		return 0, io.EOF
+	}
+
+	c := b.buf[b.r]
+	b.r++
+	return c, nil
+}
+
+// Peek returns a view of the next n readable bytes without moving the reader index
+func (b *ByteBuf) Peek(n int) ([]byte, error) {
+	b.checkReleased()
+	if n < 0 || n > b.w-b.r {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b.buf[b.r : b.r+n], nil
+}
+
+// ReadBytes returns a view of the next n readable bytes and moves the reader index, the bytes are not copied.
+func (b *ByteBuf) ReadBytes(n int) ([]byte, error) {
+	p, err := b.Peek(n)
+	if nil == err {
+		b.r += n
+	}
+	return p, err
+}
+
+// Skip to discard the next n readable bytes
+func (b *ByteBuf) Skip(n int) error {
+	if n < 0 || n > b.w-b.r {
+		return io.ErrUnexpectedEOF
+	}
+	b.r += n
+	return nil
+}
+
+// ReadUint16BE to consume an uint16 in big endian
+func (b *ByteBuf) ReadUint16BE() (uint16, error) {
+	p, err := b.ReadBytes(2)
+	if nil != err {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(p), nil
+}
+
+// ReadUint16LE to consume an uint16 in little endian
+func (b *ByteBuf) ReadUint16LE() (uint16, error) {
+	p, err := b.ReadBytes(2)
+	if nil != err {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(p), nil
+}
+
+// ReadUint32BE to consume an uint32 in big endian
+func (b *ByteBuf) ReadUint32BE() (uint32, error) {
+	p, err := b.ReadBytes(4)
+	if nil != err {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(p), nil
+}
+
+// ReadUint32LE to consume an uint32 in little endian
+func (b *ByteBuf) ReadUint32LE() (uint32, error) {
+	p, err := b.ReadBytes(4)
+	if nil != err {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(p), nil
+}
+
+// ReadUint64BE to consume an uint64 in big endian
+func (b *ByteBuf) ReadUint64BE() (uint64, error) {
+	p, err := b.ReadBytes(8)
+	if nil != err {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(p), nil
+}
+
+// ReadUint64LE to consume an uint64 in little endian
+func (b *ByteBuf) ReadUint64LE() (uint64, error) {
+	p, err := b.ReadBytes(8)
+	if nil != err {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(p), nil
+}
+
+// ReadUvarint to consume an unsigned varint, the reader index is unchanged if the varint is incomplete.
+func (b *ByteBuf) ReadUvarint() (uint64, error) {
+	b.checkReleased()
+	v, n := binary.Uvarint(b.buf[b.r:b.w])
+	switch {
+	case 0 == n:
+		return 0, io.ErrUnexpectedEOF
+	case n < 0:
+		return 0, ErrVarintOverflow
+	}
+	b.r += n
+	return v, nil
+}
+
+// MarkReader to mark the current reader index
+func (b *ByteBuf) MarkReader() {
+	b.mark = b.r
+}
+
+// ResetReader to move the reader index to the marked index
+func (b *ByteBuf) ResetReader() {
+	b.r = b.mark
+}
+
+// Slice returns a fixed capacity ByteBuf that shares the bytes of [index, index+length),
+// the slice retains the backing array and must be released.
+func (b *ByteBuf) Slice(index, length int) (*ByteBuf, error) {
+
+	b.checkReleased()
+
+	if index < 0 || length < 0 || index+length > len(b.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b.storage.retain()
+	window := b.buf[index : index+length : index+length]
+	return &ByteBuf{storage: b.storage, buf: window, w: length, fixed: true, refs: 1}, nil
+}
+
+// ReadSlice returns a slice of the next n readable bytes and moves the reader index, the slice must be released.
+func (b *ByteBuf) ReadSlice(n int) (*ByteBuf, error) {
+	if n < 0 || n > b.w-b.r {
+		return nil, io.ErrUnexpectedEOF
+	}
+	s, err := b.Slice(b.r, n)
+	if nil == err {
+		b.r += n
+	}
+	return s, err
+}
+
+// RefCnt returns the number of references of the ByteBuf
+func (b *ByteBuf) RefCnt() int32 {
+	return atomic.LoadInt32(&b.refs)
+}
+
+// Retain to take an extra reference, every reference must be released.
+func (b *ByteBuf) Retain() *ByteBuf {
+	if atomic.AddInt32(&b.refs, 1) <= 1 {
+		panic(ErrBufferReleased)
+	}
+	return b
+}
+
+// Release a reference, the backing array returns to the pool when the buffer and all of its slices are released.
+func (b *ByteBuf) Release() {
+
+	switch refs := atomic.AddInt32(&b.refs, -1); {
+	case refs > 0:
+		return
+	case refs < 0:
+		if poolDebug() {
+			panic(errors.New("buffer has been released twice"))
+		}
+		return
+	}
+
+	b.storage.release()
+	b.storage, b.buf, b.r, b.w, b.mark = nil, nil, 0, 0, 0
+}
@@ -0,0 +1,396 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestByteBufIndexes(t *testing.T) {
+
+	b := NewByteBuf(16)
+	defer b.Release()
+
+	if 0 != b.ReaderIndex() || 0 != b.WriterIndex() || 1<<10 != b.Capacity() || 1<<10 != b.WritableBytes() {
+		t.Fatal("unexpected indexes:", b.ReaderIndex(), b.WriterIndex(), b.Capacity())
+	}
+
+	_, _ = b.WriteString("go-netty")
+	if 0 != b.ReaderIndex() || 8 != b.WriterIndex() || 8 != b.ReadableBytes() || 1<<10-8 != b.WritableBytes() {
+		t.Fatal("unexpected indexes:", b.ReaderIndex(), b.WriterIndex())
+	}
+
+	if err := b.Skip(3); nil != err || 3 != b.ReaderIndex() || "netty" != string(b.Bytes()) {
+		t.Fatal("unexpected skip:", b.ReaderIndex(), err)
+	}
+
+	if err := b.Skip(6); !errors.Is(err, io.ErrUnexpectedEOF) || 3 != b.ReaderIndex() {
+		t.Fatal("skip over the writer index:", b.ReaderIndex(), err)
+	}
+
+	if err := b.SetReaderIndex(9); nil == err {
+		t.Fatal("reader index must not exceed the writer index")
+	}
+
+	if err := b.SetWriterIndex(2); nil == err {
+		t.Fatal("writer index must not be less than the reader index")
+	}
+
+	if err := b.SetWriterIndex(b.Capacity() + 1); nil == err {
+		t.Fatal("writer index must not exceed the capacity")
+	}
+
+	b.MarkReader()
+	if p, err := b.ReadBytes(5); nil != err || "netty" != string(p) || 0 != b.ReadableBytes() {
+		t.Fatal("unexpected bytes:", string(p), err)
+	}
+
+	if _, err := b.ReadByte(); !errors.Is(err, io.EOF) {
+		t.Fatal("read an empty buffer:", err)
+	}
+
+	b.ResetReader()
+	if 3 != b.ReaderIndex() || "netty" != string(b.Bytes()) {
+		t.Fatal("unexpected reset:", b.ReaderIndex())
+	}
+
+	b.Reset()
+	if 0 != b.ReaderIndex() || 0 != b.WriterIndex() {
+		t.Fatal("unexpected reset:", b.ReaderIndex(), b.WriterIndex())
+	}
+}
+
+func TestByteBufAccessors(t *testing.T) {
+
+	b := NewByteBuf(0)
+	defer b.Release()
+
+	b.WriteUint16BE(0x0102)
+	b.WriteUint16LE(0x0102)
+	b.WriteUint32BE(0x01020304)
+	b.WriteUint32LE(0x01020304)
+	b.WriteUint64BE(math.MaxUint64 - 1)
+	b.WriteUint64LE(0x0102030405060708)
+	b.WriteUvarint(300)
+	b.WriteUvarint(math.MaxUint64)
+	_ = b.WriteByte('$')
+
+	want := []byte{1, 2, 2, 1, 1, 2, 3, 4, 4, 3, 2, 1}
+	if p, _ := b.Peek(len(want)); !bytes.Equal(want, p) {
+		t.Fatal("unexpected bytes:", p)
+	}
+
+	if v, err := b.ReadUint16BE(); nil != err || 0x0102 != v {
+		t.Fatal(v, err)
+	}
+	if v, err := b.ReadUint16LE(); nil != err || 0x0102 != v {
+		t.Fatal(v, err)
+	}
+	if v, err := b.ReadUint32BE(); nil != err || 0x01020304 != v {
+		t.Fatal(v, err)
+	}
+	if v, err := b.ReadUint32LE(); nil != err || 0x01020304 != v {
+		t.Fatal(v, err)
+	}
+	if v, err := b.ReadUint64BE(); nil != err || math.MaxUint64-1 != v {
+		t.Fatal(v, err)
+	}
+	if v, err := b.ReadUint64LE(); nil != err || 0x0102030405060708 != v {
+		t.Fatal(v, err)
+	}
+	if v, err := b.ReadUvarint(); nil != err || 300 != v {
+		t.Fatal(v, err)
+	}
+	if v, err := b.ReadUvarint(); nil != err || math.MaxUint64 != v {
+		t.Fatal(v, err)
+	}
+	if c, err := b.ReadByte(); nil != err || '$' != c {
+		t.Fatal(c, err)
+	}
+
+	// incomplete values keep the reader index.
+	b.Reset()
+	_ = b.WriteByte(0x80)
+	if _, err := b.ReadUvarint(); !errors.Is(err, io.ErrUnexpectedEOF) || 0 != b.ReaderIndex() {
+		t.Fatal("incomplete varint:", err, b.ReaderIndex())
+	}
+	if _, err := b.ReadUint16BE(); !errors.Is(err, io.ErrUnexpectedEOF) || 0 != b.ReaderIndex() {
+		t.Fatal("incomplete uint16:", err, b.ReaderIndex())
+	}
+
+	b.Reset()
+	_, _ = b.Write(bytes.Repeat([]byte{0xff}, binary.MaxVarintLen64+1))
+	if _, err := b.ReadUvarint(); !errors.Is(err, ErrVarintOverflow) {
+		t.Fatal("overflow varint:", err)
+	}
+}
+
+func TestByteBufGrowth(t *testing.T) {
+
+	SetBufferPoolDebug(true)
+	defer SetBufferPoolDebug(false)
+
+	balance := BufferPoolBalance()
+
+	b := NewByteBuf(16)
+	_, _ = b.WriteString("header")
+	_ = b.Skip(2)
+
+	data := bytes.Repeat([]byte("GO-NETTY"), 1024)
+	_, _ = b.Write(data)
+
+	if b.Capacity() < 6+len(data) || 2 != b.ReaderIndex() || 6+len(data) != b.WriterIndex() {
+		t.Fatal("unexpected growth:", b.Capacity(), b.ReaderIndex(), b.WriterIndex())
+	}
+
+	if !bytes.Equal(append([]byte("ader"), data...), b.Bytes()) {
+		t.Fatal("the content is lost after growth")
+	}
+
+	// the grown storage is rented from the pool, the old one has been returned.
+	if balance+1 != BufferPoolBalance() {
+		t.Fatal("unexpected balance:", BufferPoolBalance()-balance)
+	}
+
+	b.Release()
+	if balance != BufferPoolBalance() {
+		t.Fatal("unexpected balance:", BufferPoolBalance()-balance)
+	}
+
+	wrapped := WrapByteBuf([]byte("wrapped"))
+	if 7 != wrapped.Capacity() || "wrapped" != string(wrapped.Bytes()) {
+		t.Fatal("unexpected wrapped buffer:", wrapped.Capacity())
+	}
+
+	_ = wrapped.WriteByte('!')
+	if "wrapped!" != string(wrapped.Bytes()) || balance+1 != BufferPoolBalance() {
+		t.Fatal("unexpected wrapped buffer:", string(wrapped.Bytes()))
+	}
+	wrapped.Release()
+
+	if balance != BufferPoolBalance() {
+		t.Fatal("unexpected balance:", BufferPoolBalance()-balance)
+	}
+}
+
+func TestByteBufDiscardReadBytes(t *testing.T) {
+
+	b := NewByteBuf(16)
+	defer b.Release()
+
+	_, _ = b.WriteString("0123456789")
+	_ = b.Skip(4)
+	b.MarkReader()
+	_ = b.Skip(2)
+	b.DiscardReadBytes()
+
+	if 0 != b.ReaderIndex() || 4 != b.WriterIndex() || "6789" != string(b.Bytes()) {
+		t.Fatal("unexpected compaction:", b.ReaderIndex(), b.WriterIndex(), string(b.Bytes()))
+	}
+
+	// the marked index before the reader index is clamped to zero.
+	b.ResetReader()
+	if 0 != b.ReaderIndex() {
+		t.Fatal("unexpected mark:", b.ReaderIndex())
+	}
+
+	// nothing to discard.
+	b.DiscardReadBytes()
+	if "6789" != string(b.Bytes()) {
+		t.Fatal("unexpected compaction:", string(b.Bytes()))
+	}
+}
+
+func TestByteBufViews(t *testing.T) {
+
+	b := NewByteBuf(16)
+	_, _ = b.WriteString("hello go-netty")
+
+	// the views alias the backing array.
+	view, _ := b.Peek(5)
+	view[0] = 'H'
+	if p, _ := b.ReadBytes(5); "Hello" != string(p) {
+		t.Fatal("the mutation of view is invisible:", string(p))
+	}
+
+	_ = b.Skip(1)
+	slice, err := b.ReadSlice(2)
+	if nil != err || "go" != string(slice.Bytes()) || 0 != slice.ReaderIndex() || 2 != slice.Capacity() {
+		t.Fatal("unexpected slice:", err)
+	}
+
+	if 8 != b.ReaderIndex() || "-netty" != string(b.Bytes()) {
+		t.Fatal("unexpected reader index:", b.ReaderIndex())
+	}
+
+	// the slice shares the backing array in both directions.
+	slice.Bytes()[0] = 'G'
+	b.buf[7] = 'O'
+	if "GO" != string(slice.Bytes()) {
+		t.Fatal("the mutation of slice is invisible:", string(slice.Bytes()))
+	}
+
+	// the slice can be rewritten but can not grow.
+	slice.Reset()
+	_, _ = slice.WriteString("Go")
+	if "Hello Go-netty" != string(b.buf[:b.WriterIndex()]) {
+		t.Fatal("the write of slice is invisible:", string(b.buf[:b.WriterIndex()]))
+	}
+
+	func() {
+		defer func() {
+			if err := recover(); ErrByteBufFixed != err {
+				t.Fatal("the slice should not grow:", err)
+			}
+		}()
+		_ = slice.WriteByte('!')
+	}()
+
+	if _, err := b.Slice(b.Capacity(), 1); nil == err {
+		t.Fatal("slice out of range")
+	}
+
+	if _, err := b.ReadSlice(b.ReadableBytes() + 1); nil == err {
+		t.Fatal("slice out of readable bytes")
+	}
+
+	b.Release()
+	slice.Release()
+}
+
+func TestByteBufRetain(t *testing.T) {
+
+	SetBufferPoolDebug(true)
+	defer SetBufferPoolDebug(false)
+
+	balance := BufferPoolBalance()
+
+	b := NewByteBuf(16)
+	_, _ = b.WriteString("shared")
+	slice, _ := b.Slice(0, 3)
+
+	// the buffer grows, the slice still hold the old backing array.
+	b.EnsureWritable(b.Capacity() * 2)
+	if balance+2 != BufferPoolBalance() {
+		t.Fatal("unexpected balance:", BufferPoolBalance()-balance)
+	}
+
+	b.Bytes()[0] = 'S'
+	if "sha" != string(slice.Bytes()) {
+		t.Fatal("the slice should not see the writes after growth:", string(slice.Bytes()))
+	}
+
+	slice.Retain()
+	slice.Release()
+	if balance+2 != BufferPoolBalance() || 1 != slice.RefCnt() {
+		t.Fatal("the retained slice should not be returned:", slice.RefCnt())
+	}
+
+	slice.Release()
+	if balance+1 != BufferPoolBalance() {
+		t.Fatal("unexpected balance:", BufferPoolBalance()-balance)
+	}
+
+	b.Release()
+	if balance != BufferPoolBalance() {
+		t.Fatal("unexpected balance:", BufferPoolBalance()-balance)
+	}
+
+	func() {
+		defer func() {
+			if nil == recover() {
+				t.Fatal("double release should panic in debug mode")
+			}
+		}()
+		b.Release()
+	}()
+
+	func() {
+		defer func() {
+			if err := recover(); ErrBufferReleased != err {
+				t.Fatal("use after release should panic in debug mode:", err)
+			}
+		}()
+		b.Bytes()
+	}()
+}
+
+func TestByteBufReader(t *testing.T) {
+
+	b := NewByteBuf(16)
+	defer b.Release()
+
+	reader := bytes.NewReader([]byte("stream data"))
+	if n, err := b.WriteFromReader(reader, 6); nil != err || 6 != n {
+		t.Fatal(n, err)
+	}
+
+	if data, err := ioutil.ReadAll(b); nil != err || "stream" != string(data) {
+		t.Fatal(string(data), err)
+	}
+
+	if n, err := b.Read(nil); nil != err || 0 != n {
+		t.Fatal(n, err)
+	}
+}
+
+func BenchmarkByteBuf(b *testing.B) {
+
+	payload := bytes.Repeat([]byte("go-netty"), 16)
+
+	buf := NewByteBuf(256)
+	defer buf.Release()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		buf.WriteUint16BE(1)
+		buf.WriteUvarint(uint64(len(payload)))
+		_, _ = buf.Write(payload)
+
+		_, _ = buf.ReadUint16BE()
+		n, _ := buf.ReadUvarint()
+		_, _ = buf.ReadBytes(int(n))
+	}
+}
+
+func BenchmarkByteSlice(b *testing.B) {
+
+	payload := bytes.Repeat([]byte("go-netty"), 16)
+
+	buf := make([]byte, 0, 256)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = append(buf[:0], 0, 1)
+		var head [binary.MaxVarintLen64]byte
+		buf = append(buf, head[:binary.PutUvarint(head[:], uint64(len(payload)))]...)
+		buf = append(buf, payload...)
+
+		offset := 2
+		_ = binary.BigEndian.Uint16(buf)
+		n, size := binary.Uvarint(buf[offset:])
+		offset += size
+		_ = buf[offset : offset+int(n)]
+	}
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import "time"
+
+// Clock defines the source of time, the timers could be driven by a fake clock in tests.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// Schedule to call the fn after the duration, the returned cancel func prevents the fn from being called.
+	Schedule(d time.Duration, fn func()) (cancel func())
+}
+
+// RealClock returns the Clock of system time
+func RealClock() Clock {
+	return realClock{}
+}
+
+// realClock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Schedule(d time.Duration, fn func()) func() {
+	t := time.AfterFunc(d, fn)
+	return func() { t.Stop() }
+}
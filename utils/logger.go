@@ -0,0 +1,180 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Logger defines the leveled logger of the internal components, e.g: accept retries, dropped messages.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// FieldLogger defines a Logger that attaches the key-value fields to the entries, it is optional for Logger.
+type FieldLogger interface {
+	Logger
+	// With returns a Logger that attaches the key-value fields, e.g: With("channel", 1, "remote", "127.0.0.1:9527")
+	With(fields ...interface{}) Logger
+}
+
+// LoggerWith returns a Logger that attaches the key-value fields if the logger implements FieldLogger, or the logger itself.
+func LoggerWith(logger Logger, fields ...interface{}) Logger {
+	if fl, ok := logger.(FieldLogger); ok && len(fields) > 0 {
+		return fl.With(fields...)
+	}
+	return logger
+}
+
+// LogLevel defines the level of log entries
+type LogLevel int32
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarn:
+		return "WARN"
+	case LogError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int32(l))
+}
+
+// NewWriterLogger create a Logger that writes the entries not lower than the level to writer, e.g:
+//
+//	2019/06/01 12:00:00 go-netty: [WARN] accept error: too many open files, retrying in 5ms listener=tcp://0.0.0.0:9527
+func NewWriterLogger(writer io.Writer, level LogLevel) Logger {
+	return &writerLogger{logger: log.New(writer, "", log.LstdFlags), level: level}
+}
+
+// writerLogger
+type writerLogger struct {
+	logger *log.Logger
+	level  LogLevel
+	fields string
+}
+
+func (w *writerLogger) Debugf(format string, args ...interface{}) {
+	w.output(LogDebug, format, args)
+}
+
+func (w *writerLogger) Infof(format string, args ...interface{}) {
+	w.output(LogInfo, format, args)
+}
+
+func (w *writerLogger) Warnf(format string, args ...interface{}) {
+	w.output(LogWarn, format, args)
+}
+
+func (w *writerLogger) Errorf(format string, args ...interface{}) {
+	w.output(LogError, format, args)
+}
+
+func (w *writerLogger) With(fields ...interface{}) Logger {
+	var builder strings.Builder
+	builder.WriteString(w.fields)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 < len(fields) {
+			fmt.Fprintf(&builder, " %v=%v", fields[i], fields[i+1])
+		} else {
+			fmt.Fprintf(&builder, " %v", fields[i])
+		}
+	}
+	return &writerLogger{logger: w.logger, level: w.level, fields: builder.String()}
+}
+
+func (w *writerLogger) output(level LogLevel, format string, args []interface{}) {
+	if level >= w.level {
+		_ = w.logger.Output(3, "go-netty: ["+level.String()+"] "+fmt.Sprintf(format, args...)+w.fields)
+	}
+}
+
+// NopLogger returns a Logger that discards all entries
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+// nopLogger
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+// loggerHolder to store the different implementations in atomic.Value
+type loggerHolder struct {
+	Logger
+}
+
+// defaultLogger is the package-level logger
+var defaultLogger atomic.Value
+
+func init() {
+	defaultLogger.Store(loggerHolder{NewWriterLogger(os.Stderr, LogWarn)})
+}
+
+// DefaultLogger returns the package-level logger, default is writing the warnings & errors to stderr.
+func DefaultLogger() Logger {
+	return defaultLogger.Load().(loggerHolder).Logger
+}
+
+// loggerKey is the context key of Logger
+type loggerKey struct{}
+
+// LoggerFrom to get the Logger carried by the context, e.g: the transports read the Logger of bootstrap
+// from transport.Options.Context, the DefaultLogger will be returned if the context does not carry one.
+func LoggerFrom(ctx context.Context) Logger {
+	if nil != ctx {
+		if logger, ok := ctx.Value(loggerKey{}).(Logger); ok {
+			return logger
+		}
+	}
+	return DefaultLogger()
+}
+
+// ContextWithLogger returns a copy of ctx that carries the logger
+func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// SetDefaultLogger to replace the package-level logger, nil means NopLogger.
+func SetDefaultLogger(logger Logger) {
+	if nil == logger {
+		logger = NopLogger()
+	}
+	defaultLogger.Store(loggerHolder{logger})
+}
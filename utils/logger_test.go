@@ -0,0 +1,101 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer defines a bytes.Buffer that is safe for concurrent use
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func TestWriterLogger(t *testing.T) {
+
+	var buffer syncBuffer
+	logger := NewWriterLogger(&buffer, LogInfo)
+	logger.Debugf("hidden")
+	logger.Infof("listening on %s", "tcp://0.0.0.0:9527")
+	LoggerWith(logger, "channel", 1, "remote").Warnf("dropped")
+	LoggerWith(NopLogger(), "channel", 1).Errorf("discarded")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if 2 != len(lines) {
+		t.Fatal("unexpected lines:", lines)
+	}
+
+	if !strings.HasSuffix(lines[0], " go-netty: [INFO] listening on tcp://0.0.0.0:9527") || !strings.HasSuffix(lines[1], " go-netty: [WARN] dropped channel=1 remote") {
+		t.Fatal("unexpected lines:", lines)
+	}
+}
+
+func TestDefaultLogger(t *testing.T) {
+
+	defer SetDefaultLogger(DefaultLogger())
+
+	var buffer syncBuffer
+	SetDefaultLogger(NewWriterLogger(&buffer, LogError))
+
+	// the wheel without logger logs through the default logger.
+	w := NewTimerWheel(WithTimerTick(time.Millisecond))
+	defer w.Stop()
+
+	w.Schedule(time.Millisecond, func() { panic("boom") })
+	for deadline := time.Now().Add(time.Second); !strings.Contains(buffer.String(), "TimerWheel: callback panic: boom") && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !strings.Contains(buffer.String(), "go-netty: [ERROR] TimerWheel: callback panic: boom") {
+		t.Fatal("the panic is not logged:", buffer.String())
+	}
+
+	SetDefaultLogger(nil)
+	if _, ok := DefaultLogger().(nopLogger); !ok {
+		t.Fatal("nil should be replaced by NopLogger")
+	}
+}
+
+func TestLoggerFrom(t *testing.T) {
+
+	if DefaultLogger() != LoggerFrom(context.Background()) {
+		t.Fatal("the default logger should be returned")
+	}
+
+	logger := NopLogger()
+	if logger != LoggerFrom(ContextWithLogger(context.Background(), logger)) {
+		t.Fatal("the logger of context should be returned")
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"sync/atomic"
+)
+
+// mpscCell defines a slot of the ring, seq is the sequence that the cell expected.
+type mpscCell[T any] struct {
+	seq   uint64
+	value T
+}
+
+// MPSCQueue defines a bounded lock-free multi-producer single-consumer queue.
+//
+// Any goroutine can push, but only one goroutine can pop at the same time.
+// The consumer will be parked if the queue is empty, and the producers of Push
+// will be parked if the queue is full.
+type MPSCQueue[T any] struct {
+	_     [64]byte
+	tail  uint64 // the next position to push, shared by producers
+	_     [56]byte
+	head  uint64 // the next position to pop, owned by the consumer
+	_     [56]byte
+	mask  uint64
+	cells []mpscCell[T]
+
+	sleeping int32 // the consumer is parked
+	wakeup   chan struct{}
+	waiters  int32 // the number of parked producers
+	notFull  chan struct{}
+}
+
+// NewMPSCQueue create a queue with the capacity rounded up to the power of 2, at least 2.
+func NewMPSCQueue[T any](capacity int) *MPSCQueue[T] {
+
+	// the sequence of filled & consumed cell can not be distinguished with a single cell.
+	size := 2
+	for size < capacity {
+		size <<= 1
+	}
+
+	q := &MPSCQueue[T]{
+		mask:    uint64(size - 1),
+		cells:   make([]mpscCell[T], size),
+		wakeup:  make(chan struct{}, 1),
+		notFull: make(chan struct{}, 1),
+	}
+
+	for i := range q.cells {
+		q.cells[i].seq = uint64(i)
+	}
+	return q
+}
+
+// Cap returns the capacity of queue
+func (q *MPSCQueue[T]) Cap() int {
+	return len(q.cells)
+}
+
+// Len returns the approximate number of the queued values
+func (q *MPSCQueue[T]) Len() int {
+	n := int64(atomic.LoadUint64(&q.tail) - atomic.LoadUint64(&q.head))
+	if n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+// TryPush to push the value without blocking, returns false if the queue is full.
+func (q *MPSCQueue[T]) TryPush(value T) bool {
+
+	pos := atomic.LoadUint64(&q.tail)
+	for {
+		cell := &q.cells[pos&q.mask]
+		switch dif := int64(atomic.LoadUint64(&cell.seq) - pos); {
+		case 0 == dif:
+			if atomic.CompareAndSwapUint64(&q.tail, pos, pos+1) {
+				cell.value = value
+				atomic.StoreUint64(&cell.seq, pos+1)
+				q.unparkConsumer()
+				return true
+			}
+			pos = atomic.LoadUint64(&q.tail)
+		case dif < 0:
+			// the cell has not been consumed.
+			return false
+		default:
+			// the cell has been taken by other producer.
+			pos = atomic.LoadUint64(&q.tail)
+		}
+	}
+}
+
+// Push to push the value, it blocks until the value is queued or the done is closed.
+func (q *MPSCQueue[T]) Push(value T, done <-chan struct{}) bool {
+
+	if q.TryPush(value) {
+		return true
+	}
+
+	// register as a waiter before retrying, so the consumer can not miss it.
+	atomic.AddInt32(&q.waiters, 1)
+	defer atomic.AddInt32(&q.waiters, -1)
+
+	for {
+		if q.TryPush(value) {
+			// pass the notification to the other waiters.
+			q.unparkProducer()
+			return true
+		}
+
+		select {
+		case <-q.notFull:
+		case <-done:
+			return false
+		}
+	}
+}
+
+// TryPop to pop a value without blocking, returns false if the queue is empty.
+func (q *MPSCQueue[T]) TryPop() (value T, ok bool) {
+
+	pos := q.head
+	cell := &q.cells[pos&q.mask]
+	if int64(atomic.LoadUint64(&cell.seq)-(pos+1)) < 0 {
+		// empty or the producer is writing the cell.
+		return value, false
+	}
+
+	var zero T
+	value, cell.value = cell.value, zero
+	atomic.StoreUint64(&cell.seq, pos+q.mask+1)
+	atomic.StoreUint64(&q.head, pos+1)
+
+	q.unparkProducer()
+	return value, true
+}
+
+// Pop to pop a value, the consumer will be parked until a value is pushed or the done is closed.
+func (q *MPSCQueue[T]) Pop(done <-chan struct{}) (value T, ok bool) {
+
+	for {
+		if value, ok = q.TryPop(); ok {
+			return
+		}
+
+		atomic.StoreInt32(&q.sleeping, 1)
+
+		// the value may be pushed before parking.
+		if value, ok = q.TryPop(); ok {
+			atomic.StoreInt32(&q.sleeping, 0)
+			return
+		}
+
+		select {
+		case <-q.wakeup:
+		case <-done:
+			atomic.StoreInt32(&q.sleeping, 0)
+			return value, false
+		}
+	}
+}
+
+// PopBatch to pop at most max values without blocking, the values will be appended to dst.
+func (q *MPSCQueue[T]) PopBatch(dst []T, max int) []T {
+	for i := 0; i < max; i++ {
+		value, ok := q.TryPop()
+		if !ok {
+			break
+		}
+		dst = append(dst, value)
+	}
+	return dst
+}
+
+func (q *MPSCQueue[T]) unparkConsumer() {
+	if 1 == atomic.LoadInt32(&q.sleeping) && atomic.CompareAndSwapInt32(&q.sleeping, 1, 0) {
+		select {
+		case q.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (q *MPSCQueue[T]) unparkProducer() {
+	if atomic.LoadInt32(&q.waiters) > 0 {
+		select {
+		case q.notFull <- struct{}{}:
+		default:
+		}
+	}
+}
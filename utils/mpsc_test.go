@@ -0,0 +1,177 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMPSCQueue(t *testing.T) {
+
+	q := NewMPSCQueue[int](3)
+	if 4 != q.Cap() {
+		t.Fatal("capacity must be rounded up to the power of 2:", q.Cap())
+	}
+
+	for i := 0; i < 4; i++ {
+		if !q.TryPush(i) {
+			t.Fatal("push failed:", i)
+		}
+	}
+
+	if q.TryPush(4) || 4 != q.Len() {
+		t.Fatal("the queue should be full:", q.Len())
+	}
+
+	if v, ok := q.TryPop(); !ok || 0 != v {
+		t.Fatal("unexpected value:", v, ok)
+	}
+
+	if batch := q.PopBatch(nil, 2); 2 != len(batch) || 1 != batch[0] || 2 != batch[1] {
+		t.Fatal("unexpected batch:", batch)
+	}
+
+	if batch := q.PopBatch(nil, 8); 1 != len(batch) || 3 != batch[0] {
+		t.Fatal("unexpected batch:", batch)
+	}
+
+	if _, ok := q.TryPop(); ok || 0 != q.Len() {
+		t.Fatal("the queue should be empty")
+	}
+}
+
+func TestMPSCQueueParking(t *testing.T) {
+
+	q := NewMPSCQueue[int](2)
+	done := make(chan struct{})
+
+	// the consumer is parked on the empty queue.
+	popped := make(chan int)
+	go func() {
+		v, _ := q.Pop(done)
+		popped <- v
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.TryPush(1)
+	if v := <-popped; 1 != v {
+		t.Fatal("unexpected value:", v)
+	}
+
+	// the producer is parked on the full queue.
+	q.TryPush(2)
+	q.TryPush(2)
+	pushed := make(chan bool)
+	go func() { pushed <- q.Push(3, done) }()
+
+	time.Sleep(10 * time.Millisecond)
+	if v, _ := q.TryPop(); 2 != v || !<-pushed || 2 != q.Len() {
+		t.Fatal("unexpected value:", v)
+	}
+
+	// the parked producer & consumer will be released by done.
+	go func() { pushed <- q.Push(4, done) }()
+	close(done)
+	if <-pushed {
+		t.Fatal("push should be canceled")
+	}
+
+	if batch := q.PopBatch(nil, 2); 2 != len(batch) || 3 != batch[1] {
+		t.Fatal("unexpected batch:", batch)
+	}
+
+	if _, ok := q.Pop(done); ok {
+		t.Fatal("pop should be canceled")
+	}
+}
+
+func TestMPSCQueueStress(t *testing.T) {
+
+	const producers = 64
+	const count = 2000
+
+	q := NewMPSCQueue[[2]int](16)
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if 0 == p%2 {
+					q.Push([2]int{p, i}, done)
+					continue
+				}
+				for !q.TryPush([2]int{p, i}) {
+					time.Sleep(time.Microsecond)
+				}
+			}
+		}(p)
+	}
+
+	// the values of every producer must be popped in order.
+	var next [producers]int
+	var batch [][2]int
+	for received := 0; received < producers*count; {
+		v, _ := q.Pop(done)
+		batch = q.PopBatch(append(batch[:0], v), 8)
+		for _, v := range batch {
+			if next[v[0]] != v[1] {
+				t.Fatal("out of order:", v, next[v[0]])
+			}
+			next[v[0]]++
+		}
+		received += len(batch)
+	}
+
+	wg.Wait()
+	if _, ok := q.TryPop(); ok {
+		t.Fatal("unexpected value")
+	}
+}
+
+func benchmarkQueue(b *testing.B, push func(int), pop func()) {
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < b.N; i++ {
+			pop()
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			push(1)
+		}
+	})
+	<-done
+}
+
+func BenchmarkMPSCQueue(b *testing.B) {
+	q := NewMPSCQueue[int](128)
+	benchmarkQueue(b, func(v int) { q.Push(v, nil) }, func() { q.Pop(nil) })
+}
+
+func BenchmarkChanQueue(b *testing.B) {
+	q := make(chan int, 128)
+	benchmarkQueue(b, func(v int) { q <- v }, func() { <-q })
+}
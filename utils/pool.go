@@ -0,0 +1,233 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// size classes of the buffer pool: 1K, 4K, 16K, 64K
+var bufferSizes = [...]int{1 << 10, 4 << 10, 16 << 10, 64 << 10}
+
+var (
+	// bytesPools for raw []byte
+	bytesPools [len(bufferSizes)]sync.Pool
+	// bufferPools for *PooledBuffer
+	bufferPools [len(bufferSizes)]sync.Pool
+	// bufferPoolDebug to detect double-release and use-after-release
+	bufferPoolDebug int32
+	// bufferPoolBalance count of rented buffers that have not been released
+	bufferPoolBalance int64
+)
+
+// ErrBufferReleased will be raised when access a released buffer in debug mode.
+var ErrBufferReleased = errors.New("buffer has been released")
+
+// Releaser defines a message that holds the pooled resources.
+//
+// Ownership protocol: the handler that consumes the message (converts it to another message
+// without passing it to the next handler) must release it, the tail of the pipeline releases
+// the messages that nobody consumed. A message must not be accessed after it is released.
+type Releaser interface {
+	Release()
+}
+
+// Release to release the message if it implements Releaser
+func Release(message interface{}) {
+	if r, ok := message.(Releaser); ok {
+		r.Release()
+	}
+}
+
+// SetBufferPoolDebug to enable the debug mode of buffer pool, double-release and use-after-release will panic.
+func SetBufferPoolDebug(enable bool) {
+	if enable {
+		atomic.StoreInt32(&bufferPoolDebug, 1)
+	} else {
+		atomic.StoreInt32(&bufferPoolDebug, 0)
+	}
+}
+
+// BufferPoolBalance returns the number of rented buffers that have not been released, only tracked in debug mode.
+func BufferPoolBalance() int64 {
+	return atomic.LoadInt64(&bufferPoolBalance)
+}
+
+func poolDebug() bool {
+	return 1 == atomic.LoadInt32(&bufferPoolDebug)
+}
+
+// sizeClass to find the index of the smallest size class that fits the size, -1 if too large.
+func sizeClass(size int) int {
+	for index, n := range bufferSizes {
+		if size <= n {
+			return index
+		}
+	}
+	return -1
+}
+
+// GetBytes rent a []byte with len(size) from the pool, the size greater than 64K will not be pooled.
+func GetBytes(size int) []byte {
+
+	class := sizeClass(size)
+	if -1 == class {
+		return make([]byte, size)
+	}
+
+	if poolDebug() {
+		atomic.AddInt64(&bufferPoolBalance, 1)
+	}
+
+	if b, ok := bytesPools[class].Get().(*[]byte); ok {
+		return (*b)[:size]
+	}
+
+	return make([]byte, size, bufferSizes[class])
+}
+
+// PutBytes return the []byte rented from GetBytes to the pool.
+func PutBytes(b []byte) {
+
+	class := sizeClass(cap(b))
+	if -1 == class || cap(b) != bufferSizes[class] {
+		return
+	}
+
+	if poolDebug() {
+		atomic.AddInt64(&bufferPoolBalance, -1)
+	}
+
+	b = b[:0]
+	bytesPools[class].Put(&b)
+}
+
+// PooledBuffer defines a growable buffer rented from the pool.
+type PooledBuffer struct {
+	buf      []byte
+	off      int
+	released int32
+}
+
+// NewPooledBuffer rent a empty buffer with the capacity from the pool.
+func NewPooledBuffer(capacity int) *PooledBuffer {
+
+	if poolDebug() {
+		// never reuse the buffer object in debug mode, so the stale reference can be detected.
+		return &PooledBuffer{buf: GetBytes(capacity)[:0]}
+	}
+
+	if class := sizeClass(capacity); -1 != class {
+		if pb, ok := bufferPools[class].Get().(*PooledBuffer); ok {
+			pb.released = 0
+			return pb
+		}
+	}
+
+	return &PooledBuffer{buf: GetBytes(capacity)[:0]}
+}
+
+// checkReleased to detect use-after-release in debug mode
+func (b *PooledBuffer) checkReleased() {
+	if 1 == atomic.LoadInt32(&b.released) && poolDebug() {
+		panic(ErrBufferReleased)
+	}
+}
+
+// Bytes returns the unread bytes, the slice is valid until the buffer is released.
+func (b *PooledBuffer) Bytes() []byte {
+	b.checkReleased()
+	return b.buf[b.off:]
+}
+
+// Len returns the number of unread bytes
+func (b *PooledBuffer) Len() int {
+	b.checkReleased()
+	return len(b.buf) - b.off
+}
+
+// Truncate discards all but the first n unread bytes
+func (b *PooledBuffer) Truncate(n int) {
+	b.checkReleased()
+	b.buf = b.buf[:b.off+n]
+}
+
+// Write to append bytes to buffer, the buffer will grow with the size classes.
+func (b *PooledBuffer) Write(p []byte) (int, error) {
+
+	b.checkReleased()
+
+	if len(b.buf)+len(p) > cap(b.buf) {
+		// the capacity is doubled at least, the buffers above the pooled classes are not rounded up.
+		newCap := 2 * cap(b.buf)
+		if newCap < len(b.buf)+len(p) {
+			newCap = len(b.buf) + len(p)
+		}
+		grown := GetBytes(newCap)[:len(b.buf)]
+		copy(grown, b.buf)
+		PutBytes(b.buf)
+		b.buf = grown
+	}
+
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// Read to consume bytes from buffer
+func (b *PooledBuffer) Read(p []byte) (int, error) {
+
+	b.checkReleased()
+
+	if b.off >= len(b.buf) {
+		if 0 == len(p) {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, b.buf[b.off:])
+	b.off += n
+	return n, nil
+}
+
+// Release to return the buffer to the pool, the buffer must not be accessed anymore.
+func (b *PooledBuffer) Release() {
+
+	if !atomic.CompareAndSwapInt32(&b.released, 0, 1) {
+		if poolDebug() {
+			panic(errors.New("buffer has been released twice"))
+		}
+		return
+	}
+
+	if poolDebug() {
+		PutBytes(b.buf)
+		b.buf, b.off = nil, 0
+		return
+	}
+
+	class := sizeClass(cap(b.buf))
+	if -1 == class || cap(b.buf) != bufferSizes[class] {
+		return
+	}
+
+	b.buf, b.off = b.buf[:0], 0
+	bufferPools[class].Put(b)
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetBytes(t *testing.T) {
+
+	var cases = []struct {
+		size     int
+		capacity int
+	}{
+		{size: 0, capacity: 1 << 10},
+		{size: 1000, capacity: 1 << 10},
+		{size: 1025, capacity: 4 << 10},
+		{size: 16 << 10, capacity: 16 << 10},
+		{size: 64 << 10, capacity: 64 << 10},
+		{size: 64<<10 + 1, capacity: 64<<10 + 1},
+	}
+
+	for _, c := range cases {
+		b := GetBytes(c.size)
+		if len(b) != c.size || cap(b) != c.capacity {
+			t.Fatalf("unexpected buffer: len(%d) cap(%d), want: len(%d) cap(%d)", len(b), cap(b), c.size, c.capacity)
+		}
+		PutBytes(b)
+	}
+}
+
+func TestPooledBuffer(t *testing.T) {
+
+	buffer := NewPooledBuffer(16)
+	data := bytes.Repeat([]byte("GO-NETTY"), 1024)
+
+	// grow from 1K to 16K
+	for i := 0; i < len(data); i += 8 {
+		if n, err := buffer.Write(data[i : i+8]); nil != err || 8 != n {
+			t.Fatal(n, err)
+		}
+	}
+
+	if !bytes.Equal(data, buffer.Bytes()) || len(data) != buffer.Len() {
+		t.Fatal("unexpected bytes")
+	}
+
+	buffer.Truncate(len(data) - 8)
+	if readBytes := MustToBytes(buffer); !bytes.Equal(data[:len(data)-8], readBytes) {
+		t.Fatal("unexpected bytes")
+	}
+
+	if 0 != buffer.Len() {
+		t.Fatal("unexpected length:", buffer.Len())
+	}
+
+	buffer.Release()
+}
+
+func TestPooledBufferGrowth(t *testing.T) {
+
+	buffer := NewPooledBuffer(16)
+	defer buffer.Release()
+
+	// the buffers above the pooled classes are grown geometrically too.
+	var grown int
+	for i := 0; i < 1<<20; i++ {
+		previous := cap(buffer.buf)
+		_, _ = buffer.Write([]byte{'x'})
+		if cap(buffer.buf) != previous {
+			grown++
+		}
+	}
+
+	if 1<<20 != buffer.Len() || grown > 20 {
+		t.Fatal("unexpected growth:", buffer.Len(), grown)
+	}
+}
+
+func TestPooledBufferDebug(t *testing.T) {
+
+	SetBufferPoolDebug(true)
+	defer SetBufferPoolDebug(false)
+
+	recoverOf := func(fn func()) (err interface{}) {
+		defer func() { err = recover() }()
+		fn()
+		return
+	}
+
+	buffer := NewPooledBuffer(16)
+	_, _ = buffer.Write([]byte("GO-NETTY"))
+	buffer.Release()
+
+	if nil == recoverOf(buffer.Release) {
+		t.Fatal("double-release should panic")
+	}
+
+	if err := recoverOf(func() { buffer.Bytes() }); ErrBufferReleased != err {
+		t.Fatal("use-after-release should panic:", err)
+	}
+
+	if err := recoverOf(func() { _, _ = buffer.Read(make([]byte, 8)) }); ErrBufferReleased != err {
+		t.Fatal("read-after-release should panic:", err)
+	}
+}
+
+func TestBufferPoolBalance(t *testing.T) {
+
+	SetBufferPoolDebug(true)
+	defer SetBufferPoolDebug(false)
+
+	var balance = BufferPoolBalance()
+	var payload = []byte("GO-NETTY")
+
+	for i := 0; i < 1000000; i++ {
+		buffer := NewPooledBuffer(16)
+		_, _ = buffer.Write(payload)
+		Release(buffer)
+	}
+
+	if n := BufferPoolBalance(); balance != n {
+		t.Fatalf("unbalanced pool: %d != %d", n, balance)
+	}
+}
+
+func BenchmarkMakeBuffer(b *testing.B) {
+	var payload = make([]byte, 512)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buffer := make([]byte, 0, 16)
+		buffer = append(buffer, payload...)
+		_ = buffer
+	}
+}
+
+func BenchmarkPooledBuffer(b *testing.B) {
+	var payload = make([]byte, 512)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buffer := NewPooledBuffer(16)
+		_, _ = buffer.Write(payload)
+		buffer.Release()
+	}
+}
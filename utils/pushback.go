@@ -0,0 +1,151 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnreadUnsupported will be returned when peeking a reader that can not push back bytes.
+var ErrUnreadUnsupported = errors.New("the reader does not support push-back, see transport.PushbackTransport")
+
+// DeadlineReader defines a reader with read deadline, e.g: transport.Transport
+type DeadlineReader interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+// Unreader defines a reader that can push back the bytes, the pushed back bytes will be read first.
+type Unreader interface {
+	Unread(p []byte)
+}
+
+// PushbackReader defines a reader with a push-back buffer, it is not safe for concurrent use.
+type PushbackReader struct {
+	Reader  io.Reader
+	pending []byte
+}
+
+// NewPushbackReader create a PushbackReader
+func NewPushbackReader(reader io.Reader) *PushbackReader {
+	return &PushbackReader{Reader: reader}
+}
+
+// Read to drain the pushed back bytes before reading the underlying reader
+func (r *PushbackReader) Read(p []byte) (int, error) {
+
+	if 0 == len(r.pending) {
+		return r.Reader.Read(p)
+	}
+
+	n := copy(p, r.pending)
+	if r.pending = r.pending[n:]; 0 == len(r.pending) {
+		r.pending = nil
+	}
+	return n, nil
+}
+
+// Unread to push back the bytes, they will be read before the bytes pushed back previously.
+func (r *PushbackReader) Unread(p []byte) {
+	if len(p) > 0 {
+		pending := make([]byte, 0, len(p)+len(r.pending))
+		r.pending = append(append(pending, p...), r.pending...)
+	}
+}
+
+// Buffered returns the number of the pushed back bytes
+func (r *PushbackReader) Buffered() int {
+	return len(r.pending)
+}
+
+// ReadFullWithDeadline to read exactly len(buf) bytes within the duration, the read deadline will be cleared after reading.
+// The bytes that have been read will be pushed back if the read failed and the reader implements Unreader.
+func ReadFullWithDeadline(reader DeadlineReader, buf []byte, d time.Duration) error {
+
+	if err := reader.SetReadDeadline(time.Now().Add(d)); nil != err {
+		return err
+	}
+
+	n, err := io.ReadFull(reader, buf)
+
+	if resetErr := reader.SetReadDeadline(time.Time{}); nil == err {
+		err = resetErr
+	}
+
+	if nil != err && n > 0 {
+		if unreader, ok := reader.(Unreader); ok {
+			unreader.Unread(buf[:n])
+		}
+	}
+
+	return err
+}
+
+// Peek returns the next n bytes without consuming them, the reader must implement Unreader.
+func Peek(reader DeadlineReader, n int, d time.Duration) ([]byte, error) {
+
+	unreader, ok := reader.(Unreader)
+	if !ok {
+		return nil, ErrUnreadUnsupported
+	}
+
+	buf := make([]byte, n)
+	if err := ReadFullWithDeadline(reader, buf, d); nil != err {
+		return nil, err
+	}
+
+	unreader.Unread(buf)
+	return buf, nil
+}
+
+// ReadUvarint to read an unsigned varint of at most max bytes, returns the value and the number of bytes read.
+// io.EOF will be returned if no byte is read, io.ErrUnexpectedEOF if the varint is truncated.
+func ReadUvarint(reader io.Reader, max int) (uint64, int, error) {
+
+	if max <= 0 || max > binary.MaxVarintLen64 {
+		max = binary.MaxVarintLen64
+	}
+
+	byteReader := NewByteReader(reader)
+
+	var x uint64
+	var s uint
+	for i := 0; i < max; i++ {
+		b, err := byteReader.ReadByte()
+		if nil != err {
+			if i > 0 && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return x, i, err
+		}
+
+		if b < 0x80 {
+			if binary.MaxVarintLen64-1 == i && b > 1 {
+				return x, i + 1, ErrVarintOverflow
+			}
+			return x | uint64(b)<<s, i + 1, nil
+		}
+
+		x |= uint64(b&0x7f) << s
+		s += 7
+	}
+
+	return x, max, ErrVarintOverflow
+}
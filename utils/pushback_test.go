@@ -0,0 +1,145 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// pushbackConn for testing
+type pushbackConn struct {
+	net.Conn
+	reader *PushbackReader
+}
+
+func (c *pushbackConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *pushbackConn) Unread(p []byte) {
+	c.reader.Unread(p)
+}
+
+func newPushbackConn() (*pushbackConn, net.Conn) {
+	local, peer := net.Pipe()
+	return &pushbackConn{Conn: local, reader: NewPushbackReader(local)}, peer
+}
+
+func TestPushbackReader(t *testing.T) {
+
+	reader := NewPushbackReader(bytes.NewReader([]byte("-netty")))
+	reader.Unread([]byte("go"))
+	reader.Unread([]byte("++"))
+
+	if 4 != reader.Buffered() {
+		t.Fatal("unexpected buffered:", reader.Buffered())
+	}
+
+	if data, err := ioutil.ReadAll(reader); nil != err || "++go-netty" != string(data) {
+		t.Fatal(string(data), err)
+	}
+}
+
+func TestReadFullWithDeadline(t *testing.T) {
+
+	conn, peer := newPushbackConn()
+	defer conn.Close()
+
+	go func() { _, _ = peer.Write([]byte("abc")) }()
+
+	// the deadline expired in the middle of reading.
+	buf := make([]byte, 8)
+	if err := ReadFullWithDeadline(conn, buf, 100*time.Millisecond); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the bytes have been read are pushed back.
+	if 3 != conn.reader.Buffered() {
+		t.Fatal("unexpected buffered:", conn.reader.Buffered())
+	}
+
+	// the deadline has been cleared.
+	go func() { time.Sleep(150 * time.Millisecond); _, _ = peer.Write([]byte("defgh")) }()
+	if _, err := io.ReadFull(conn, buf); nil != err || "abcdefgh" != string(buf) {
+		t.Fatal(string(buf), err)
+	}
+}
+
+func TestPeek(t *testing.T) {
+
+	conn, peer := newPushbackConn()
+	defer conn.Close()
+
+	go func() { _, _ = peer.Write([]byte("PROXY TCP4")) }()
+
+	for i := 0; i < 2; i++ {
+		if p, err := Peek(conn, 5, time.Second); nil != err || "PROXY" != string(p) {
+			t.Fatal(string(p), err)
+		}
+	}
+
+	buf := make([]byte, 10)
+	if _, err := io.ReadFull(conn, buf); nil != err || "PROXY TCP4" != string(buf) {
+		t.Fatal(string(buf), err)
+	}
+
+	if _, err := Peek(conn.Conn, 5, time.Second); ErrUnreadUnsupported != err {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestReadUvarint(t *testing.T) {
+
+	for _, v := range []uint64{0, 1, 127, 128, 16383, 16384, math.MaxUint32, math.MaxUint64} {
+		encoded := make([]byte, binary.MaxVarintLen64)
+		encoded = encoded[:binary.PutUvarint(encoded, v)]
+
+		x, n, err := ReadUvarint(bytes.NewReader(encoded), 0)
+		if nil != err || v != x || len(encoded) != n {
+			t.Fatal("unexpected varint:", v, x, n, err)
+		}
+
+		// the varint must not exceed max bytes.
+		if len(encoded) > 1 {
+			if _, n, err := ReadUvarint(bytes.NewReader(encoded), len(encoded)-1); !errors.Is(err, ErrVarintOverflow) || len(encoded)-1 != n {
+				t.Fatal("unexpected error:", v, n, err)
+			}
+
+			if _, n, err := ReadUvarint(bytes.NewReader(encoded[:len(encoded)-1]), 0); !errors.Is(err, io.ErrUnexpectedEOF) || len(encoded)-1 != n {
+				t.Fatal("unexpected truncated error:", v, n, err)
+			}
+		}
+	}
+
+	if _, n, err := ReadUvarint(bytes.NewReader(nil), 0); !errors.Is(err, io.EOF) || 0 != n {
+		t.Fatal("unexpected error:", n, err)
+	}
+
+	overflow := append(bytes.Repeat([]byte{0xff}, binary.MaxVarintLen64-1), 0x02)
+	if _, _, err := ReadUvarint(bytes.NewReader(overflow), 0); !errors.Is(err, ErrVarintOverflow) {
+		t.Fatal("unexpected error:", err)
+	}
+}
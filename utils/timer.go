@@ -0,0 +1,363 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// TimerWheelOption defines an option of TimerWheel
+type TimerWheelOption func(options *timerWheelOptions)
+
+// timerWheelOptions
+type timerWheelOptions struct {
+	tick     time.Duration
+	slots    int
+	executor func(task func())
+	onPanic  func(err interface{}, stack []byte)
+	clock    Clock
+	logger   Logger
+}
+
+// WithTimerTick to set the precision of timers, default is 10ms.
+func WithTimerTick(tick time.Duration) TimerWheelOption {
+	return func(options *timerWheelOptions) {
+		options.tick = tick
+	}
+}
+
+// WithTimerSlots to set the number of slots per rotation, default is 512.
+func WithTimerSlots(slots int) TimerWheelOption {
+	return func(options *timerWheelOptions) {
+		options.slots = slots
+	}
+}
+
+// WithTimerExecutor to run the expired callbacks, default is running them on the driving goroutine.
+func WithTimerExecutor(executor func(task func())) TimerWheelOption {
+	return func(options *timerWheelOptions) {
+		options.executor = executor
+	}
+}
+
+// WithTimerPanicHandler to handle the panic of callbacks, default is logging it by the logger of wheel.
+func WithTimerPanicHandler(onPanic func(err interface{}, stack []byte)) TimerWheelOption {
+	return func(options *timerWheelOptions) {
+		options.onPanic = onPanic
+	}
+}
+
+// WithTimerClock to drive the wheel by the clock, default is the real clock.
+func WithTimerClock(clock Clock) TimerWheelOption {
+	return func(options *timerWheelOptions) {
+		options.clock = clock
+	}
+}
+
+// WithTimerLogger to set the logger of wheel, default is the DefaultLogger.
+func WithTimerLogger(logger Logger) TimerWheelOption {
+	return func(options *timerWheelOptions) {
+		options.logger = logger
+	}
+}
+
+// wheelTimer defines a timer linked in the slot
+type wheelTimer struct {
+	prev, next *wheelTimer
+	slot       *wheelSlot
+	target     uint64 // the tick to expire
+	fn         func()
+}
+
+// wheelSlot defines the linked list of timers
+type wheelSlot struct {
+	head *wheelTimer
+}
+
+func (s *wheelSlot) add(t *wheelTimer) {
+	t.slot, t.prev, t.next = s, nil, s.head
+	if nil != s.head {
+		s.head.prev = t
+	}
+	s.head = t
+}
+
+func (s *wheelSlot) remove(t *wheelTimer) {
+	if nil != t.prev {
+		t.prev.next = t.next
+	} else {
+		s.head = t.next
+	}
+	if nil != t.next {
+		t.next.prev = t.prev
+	}
+	t.slot, t.prev, t.next = nil, nil, nil
+}
+
+// TimerWheel defines a hashed timer wheel, that drives massive timers by a single goroutine.
+//
+// Schedule & cancel are O(1), the timers expire with the precision of tick.
+// The driving goroutine starts on demand, and exits when no timer is pending.
+// The wheel driven by a custom clock is ticked by the timers of the clock instead of a goroutine.
+type TimerWheel struct {
+	options timerWheelOptions
+	clock   Clock
+	mutex   sync.Mutex
+	slots   []wheelSlot
+	start   time.Time // the time of tick zero
+	ticks   uint64    // the ticks has been processed
+	pending int
+	running bool
+	stopped bool
+	stop    chan struct{}
+	cancel  func() // cancel the next tick of custom clock
+}
+
+// NewTimerWheel create a TimerWheel with options
+func NewTimerWheel(option ...TimerWheelOption) *TimerWheel {
+
+	options := timerWheelOptions{
+		tick:  10 * time.Millisecond,
+		slots: 512,
+		clock: RealClock(),
+	}
+
+	for i := range option {
+		option[i](&options)
+	}
+
+	AssertIf(options.tick <= 0, "tick must be greater than zero")
+	AssertIf(options.slots <= 0, "slots must be greater than zero")
+	AssertIf(nil == options.clock, "clock must not be nil")
+
+	return &TimerWheel{
+		options: options,
+		clock:   options.clock,
+		slots:   make([]wheelSlot, options.slots),
+		start:   options.clock.Now(),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Schedule to call the fn after the duration, the returned cancel func prevents the fn from being called.
+func (w *TimerWheel) Schedule(d time.Duration, fn func()) (cancel func()) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stopped {
+		return func() {}
+	}
+
+	if !w.running {
+		// the ticks are not advanced while idle.
+		w.start = w.clock.Now().Add(-time.Duration(w.ticks) * w.options.tick)
+	}
+
+	// the first tick at or after the deadline.
+	target := uint64((w.clock.Now().Sub(w.start) + d + w.options.tick - 1) / w.options.tick)
+	if target <= w.ticks {
+		target = w.ticks + 1
+	}
+
+	t := &wheelTimer{target: target, fn: fn}
+	w.slots[target%uint64(len(w.slots))].add(t)
+	w.pending++
+
+	if !w.running {
+		w.running = true
+		if _, ok := w.clock.(realClock); ok {
+			go w.run()
+		} else {
+			w.cancel = w.clock.Schedule(w.options.tick, w.step)
+		}
+	}
+
+	return func() { w.remove(t) }
+}
+
+// Len returns the number of pending timers
+func (w *TimerWheel) Len() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.pending
+}
+
+// Stop the wheel, the pending timers will be dropped.
+func (w *TimerWheel) Stop() {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.stopped {
+		w.stopped = true
+		close(w.stop)
+		if nil != w.cancel {
+			w.cancel()
+			w.cancel = nil
+		}
+		for i := range w.slots {
+			for t := w.slots[i].head; nil != t; t = w.slots[i].head {
+				w.slots[i].remove(t)
+			}
+		}
+		w.pending = 0
+	}
+}
+
+func (w *TimerWheel) remove(t *wheelTimer) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// expired or canceled.
+	if nil != t.slot {
+		t.slot.remove(t)
+		w.pending--
+	}
+}
+
+// run to drive the wheel until no timer is pending
+func (w *TimerWheel) run() {
+
+	ticker := time.NewTicker(w.options.tick)
+	defer ticker.Stop()
+
+	var expired []*wheelTimer
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+
+		var more bool
+		if expired, more = w.advance(expired[:0]); len(expired) > 0 {
+			for i, t := range expired {
+				w.execute(t.fn)
+				expired[i] = nil
+			}
+		}
+
+		if !more {
+			return
+		}
+	}
+}
+
+// step to tick the wheel driven by a custom clock, the next tick is scheduled if any timer is pending.
+func (w *TimerWheel) step() {
+
+	expired, more := w.advance(nil)
+	for _, t := range expired {
+		w.execute(t.fn)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// the wheel is still running, nobody else schedules the next tick.
+	if more && !w.stopped {
+		w.cancel = w.clock.Schedule(w.options.tick, w.step)
+	}
+}
+
+// advance to collect the expired timers, returns false if the driving goroutine should exit.
+func (w *TimerWheel) advance(expired []*wheelTimer) ([]*wheelTimer, bool) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := uint64(w.clock.Now().Sub(w.start) / w.options.tick)
+
+	// every slot is visited at most once, even if the ticks fall behind.
+	last := now
+	if n := uint64(len(w.slots)); last-w.ticks > n {
+		last = w.ticks + n
+	}
+
+	for tick := w.ticks + 1; tick <= last; tick++ {
+		slot := &w.slots[tick%uint64(len(w.slots))]
+		for t := slot.head; nil != t; {
+			next := t.next
+			// the timers of next rotations will be kept.
+			if t.target <= now {
+				slot.remove(t)
+				expired = append(expired, t)
+			}
+			t = next
+		}
+	}
+
+	if now > w.ticks {
+		w.ticks = now
+	}
+
+	w.pending -= len(expired)
+	if 0 == w.pending || w.stopped {
+		w.running = false
+		return expired, false
+	}
+
+	return expired, true
+}
+
+// logger returns the logger of wheel
+func (w *TimerWheel) logger() Logger {
+	if nil != w.options.logger {
+		return w.options.logger
+	}
+	return DefaultLogger()
+}
+
+// execute to run the callback with panic isolation
+func (w *TimerWheel) execute(fn func()) {
+
+	task := func() {
+		defer func() {
+			if err := recover(); nil != err {
+				if nil != w.options.onPanic {
+					w.options.onPanic(err, debug.Stack())
+				} else {
+					w.logger().Errorf("TimerWheel: callback panic: %v\n%s", err, debug.Stack())
+				}
+			}
+		}()
+		fn()
+	}
+
+	if nil != w.options.executor {
+		w.options.executor(task)
+	} else {
+		task()
+	}
+}
+
+var (
+	defaultTimerWheel     *TimerWheel
+	defaultTimerWheelOnce sync.Once
+)
+
+// DefaultTimerWheel returns the process-wide TimerWheel with default options
+func DefaultTimerWheel() *TimerWheel {
+	defaultTimerWheelOnce.Do(func() {
+		defaultTimerWheel = NewTimerWheel()
+	})
+	return defaultTimerWheel
+}
@@ -0,0 +1,261 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimerWheel(t *testing.T) {
+
+	w := NewTimerWheel(WithTimerTick(time.Millisecond), WithTimerSlots(8))
+	defer w.Stop()
+
+	// the long delays span multiple rotations of wheel.
+	var fired = make(chan time.Duration, 3)
+	var begin = time.Now()
+	for _, d := range []time.Duration{30 * time.Millisecond, 3 * time.Millisecond, 17 * time.Millisecond} {
+		d := d
+		w.Schedule(d, func() {
+			if elapsed := time.Since(begin); elapsed < d {
+				t.Error("the timer expired too early:", elapsed, d)
+			}
+			fired <- d
+		})
+	}
+
+	for _, want := range []time.Duration{3 * time.Millisecond, 17 * time.Millisecond, 30 * time.Millisecond} {
+		if d := <-fired; want != d {
+			t.Fatal("unexpected timer:", d, "want:", want)
+		}
+	}
+
+	if 0 != w.Len() {
+		t.Fatal("unexpected pending timers:", w.Len())
+	}
+
+	// the wheel is restarted after idle.
+	time.Sleep(5 * time.Millisecond)
+	begin = time.Now()
+	w.Schedule(10*time.Millisecond, func() { fired <- time.Since(begin) })
+	if elapsed := <-fired; elapsed < 10*time.Millisecond {
+		t.Fatal("the timer expired too early:", elapsed)
+	}
+}
+
+func TestTimerWheelCancel(t *testing.T) {
+
+	w := NewTimerWheel(WithTimerTick(time.Millisecond), WithTimerSlots(16))
+	defer w.Stop()
+
+	const count = 1000
+
+	var fired [count]int32
+	var cancels [count]func()
+	for i := 0; i < count; i++ {
+		i := i
+		cancels[i] = w.Schedule(200*time.Millisecond, func() { atomic.AddInt32(&fired[i], 1) })
+	}
+
+	// cancel the even timers concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < count; i += 2 {
+		wg.Add(1)
+		go func(cancel func()) {
+			defer wg.Done()
+			cancel()
+			cancel()
+		}(cancels[i])
+	}
+	wg.Wait()
+
+	for deadline := time.Now().Add(2 * time.Second); w.Len() > 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for i := range fired {
+		if want := int32(i % 2); want != atomic.LoadInt32(&fired[i]) {
+			t.Fatal("unexpected timer:", i, atomic.LoadInt32(&fired[i]))
+		}
+	}
+}
+
+func TestTimerWheelCancelRace(t *testing.T) {
+
+	w := NewTimerWheel(WithTimerTick(time.Millisecond))
+	defer w.Stop()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				var fired int32
+				cancel := w.Schedule(time.Duration(i%3)*time.Millisecond, func() {
+					if 1 != atomic.AddInt32(&fired, 1) {
+						t.Error("the timer expired twice")
+					}
+				})
+				// cancel while the timer may be expiring.
+				if 0 == (g+i)%2 {
+					time.Sleep(time.Duration(i%2) * time.Millisecond)
+					cancel()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestTimerWheelExecutor(t *testing.T) {
+
+	var executed int32
+	var panics = make(chan interface{}, 1)
+	w := NewTimerWheel(
+		WithTimerTick(time.Millisecond),
+		WithTimerExecutor(func(task func()) {
+			atomic.AddInt32(&executed, 1)
+			go task()
+		}),
+		WithTimerPanicHandler(func(err interface{}, stack []byte) {
+			panics <- err
+		}),
+	)
+	defer w.Stop()
+
+	w.Schedule(time.Millisecond, func() { panic("boom") })
+	if err := <-panics; "boom" != err {
+		t.Fatal("unexpected panic:", err)
+	}
+
+	// the wheel still works after the panic.
+	fired := make(chan struct{})
+	w.Schedule(time.Millisecond, func() { close(fired) })
+	<-fired
+
+	if 2 != atomic.LoadInt32(&executed) {
+		t.Fatal("the callbacks must be run by executor")
+	}
+}
+
+func TestTimerWheelStop(t *testing.T) {
+
+	w := NewTimerWheel(WithTimerTick(time.Millisecond))
+	w.Schedule(5*time.Millisecond, func() { t.Error("the timer must be dropped") })
+	w.Stop()
+	w.Stop()
+
+	w.Schedule(time.Millisecond, func() { t.Error("the wheel has been stopped") })()
+	time.Sleep(10 * time.Millisecond)
+
+	if 0 != w.Len() {
+		t.Fatal("unexpected pending timers:", w.Len())
+	}
+}
+
+// manualClock to tick the wheel manually, only one tick is scheduled at a time.
+type manualClock struct {
+	now  time.Time
+	tick func()
+}
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+func (c *manualClock) Schedule(d time.Duration, fn func()) func() {
+	c.tick = fn
+	return func() { c.tick = nil }
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	if tick := c.tick; nil != tick {
+		c.tick = nil
+		tick()
+	}
+}
+
+func TestTimerWheelClock(t *testing.T) {
+
+	clock := &manualClock{now: time.Unix(0, 0)}
+	w := NewTimerWheel(WithTimerTick(time.Second), WithTimerSlots(4), WithTimerClock(clock))
+
+	var fired []int
+	w.Schedule(3*time.Second, func() { fired = append(fired, 3) })
+	w.Schedule(time.Second, func() { fired = append(fired, 1) })
+	w.Schedule(6*time.Second, func() { fired = append(fired, 6) })
+
+	for i := 0; i < 5; i++ {
+		clock.advance(time.Second)
+	}
+
+	if 2 != len(fired) || 1 != fired[0] || 3 != fired[1] || 1 != w.Len() {
+		t.Fatal("unexpected timers:", fired, w.Len())
+	}
+
+	// the ticking stops with the wheel.
+	w.Stop()
+	if nil != clock.tick {
+		t.Fatal("the next tick should be canceled")
+	}
+}
+
+const concurrentTimers = 100000
+
+func BenchmarkTimerWheel(b *testing.B) {
+
+	w := NewTimerWheel()
+	defer w.Stop()
+
+	var cancels = make([]func(), concurrentTimers)
+	for i := range cancels {
+		cancels[i] = w.Schedule(time.Hour, func() {})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// keep the number of concurrent timers.
+		index := i % concurrentTimers
+		cancels[index]()
+		cancels[index] = w.Schedule(time.Hour, func() {})
+	}
+}
+
+func BenchmarkTimerAfterFunc(b *testing.B) {
+
+	var timers = make([]*time.Timer, concurrentTimers)
+	for i := range timers {
+		timers[i] = time.AfterFunc(time.Hour, func() {})
+	}
+	defer func() {
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index := i % concurrentTimers
+		timers[index].Stop()
+		timers[index] = time.AfterFunc(time.Hour, func() {})
+	}
+}
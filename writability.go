@@ -0,0 +1,245 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-netty/go-netty/utils"
+)
+
+// writeWatermark defines the watermarks of the queued bytes of channel.
+type writeWatermark struct {
+	low  int64
+	high int64
+}
+
+// enabled returns true if the writability of channel is tracked
+func (w writeWatermark) enabled() bool {
+	return w.high > 0
+}
+
+// WithChannelWriteWatermark to mark the channel unwritable if the queued bytes reach the high watermark,
+// and writable again if they drop to the low watermark, WritabilityChangedEvent will be fired on changes.
+func WithChannelWriteWatermark(low, high int) ChannelOption {
+	return func(options *channelOptions) {
+		utils.AssertIf(low < 0 || high <= low, "the watermarks must be 0 <= low < high")
+		options.watermark = writeWatermark{low: int64(low), high: int64(high)}
+	}
+}
+
+// queueSaturation defines the ratios of the queued entries to the capacity of send queue.
+type queueSaturation struct {
+	high float64
+	low  float64
+}
+
+// enabled returns true if the saturation of send queue is tracked
+func (s queueSaturation) enabled() bool {
+	return s.high > 0
+}
+
+// WithChannelQueueSaturation to fire QueueSaturationEvent when the send queue is filled up to the high ratio
+// of capacity, e.g: 0.8, and fire it again when the send queue drops to the low ratio, zero high to disable.
+func WithChannelQueueSaturation(high, low float64) ChannelOption {
+	return func(options *channelOptions) {
+		utils.AssertIf(0 != high && (low < 0 || high <= low || high > 1), "the ratios must be 0 <= low < high <= 1")
+		options.saturation = queueSaturation{high: high, low: low}
+	}
+}
+
+// writability tracks the queued bytes and the writable state of channel
+type writability struct {
+	queuedBytes int64 // 64-bit aligned for atomic operations
+	unwritable  int32
+	saturated   int32
+	mutex       sync.Mutex
+	draining    bool
+	pending     []Event
+	released    chan struct{} // closed when the bytes are released, created by the blocked writers
+}
+
+// IsWritable returns false if the queued bytes have reached the high watermark
+func (c *channel) IsWritable() bool {
+	return 0 == atomic.LoadInt32(&c.writability.unwritable)
+}
+
+// reserveWritability to count the bytes before queued, returns false if the max pending bytes would be exceeded.
+func (c *channel) reserveWritability(size int64) bool {
+
+	if c.maxPending <= 0 {
+		c.updateWritability(size)
+		return true
+	}
+
+	w := &c.writability
+	for {
+		n := atomic.LoadInt64(&w.queuedBytes)
+		if n > 0 && n+size > c.maxPending {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&w.queuedBytes, n, n+size) {
+			c.changeWritability(size, n+size)
+			return true
+		}
+	}
+}
+
+// waitWritability to wait until the pending bytes are released, returns false if the channel is closed.
+func (c *channel) waitWritability(size int64) bool {
+
+	w := &c.writability
+	w.mutex.Lock()
+	if nil == w.released {
+		w.released = make(chan struct{})
+	}
+	released := w.released
+	w.mutex.Unlock()
+
+	// the bytes may be released before the signal is created.
+	if n := atomic.LoadInt64(&w.queuedBytes); 0 == n || n+size <= c.maxPending {
+		return true
+	}
+
+	select {
+	case <-released:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// updateWritability to count the queued bytes and deliver the changes of writability in order.
+func (c *channel) updateWritability(delta int64) {
+
+	if 0 == delta {
+		return
+	}
+
+	w := &c.writability
+	n := atomic.AddInt64(&w.queuedBytes, delta)
+
+	// wakeup the writers blocked by the max pending bytes.
+	if delta < 0 && c.maxPending > 0 {
+		w.mutex.Lock()
+		if nil != w.released {
+			close(w.released)
+			w.released = nil
+		}
+		w.mutex.Unlock()
+	}
+
+	c.changeWritability(delta, n)
+}
+
+// changeWritability to update the writable state with the count of queued bytes.
+func (c *channel) changeWritability(delta, n int64) {
+
+	if !c.watermark.enabled() {
+		return
+	}
+
+	w := &c.writability
+
+	// the state can not be changed by this update.
+	if unwritable := 1 == atomic.LoadInt32(&w.unwritable); (delta > 0 && (unwritable || n < c.watermark.high)) ||
+		(delta < 0 && (!unwritable || n > c.watermark.low)) {
+		return
+	}
+
+	w.mutex.Lock()
+	n = atomic.LoadInt64(&w.queuedBytes)
+	switch unwritable := 1 == w.unwritable; {
+	case !unwritable && n >= c.watermark.high:
+		atomic.StoreInt32(&w.unwritable, 1)
+		w.pending = append(w.pending, WritabilityChangedEvent{Writable: false})
+	case unwritable && n <= c.watermark.low:
+		atomic.StoreInt32(&w.unwritable, 0)
+		w.pending = append(w.pending, WritabilityChangedEvent{Writable: true})
+	}
+
+	c.deliverWritability()
+}
+
+// updateSaturation to check the entries of send queue and deliver the changes of saturation in order.
+func (c *channel) updateSaturation() {
+
+	capacity := c.sendQueue.capacity()
+	if !c.saturation.enabled() || capacity <= 0 {
+		return
+	}
+
+	w := &c.writability
+	ratio := float64(c.sendQueue.size()) / float64(capacity)
+
+	// the state can not be changed by this update.
+	if saturated := 1 == atomic.LoadInt32(&w.saturated); (!saturated && ratio < c.saturation.high) ||
+		(saturated && ratio > c.saturation.low) {
+		return
+	}
+
+	w.mutex.Lock()
+	ratio = float64(c.sendQueue.size()) / float64(capacity)
+	switch saturated := 1 == w.saturated; {
+	case !saturated && ratio >= c.saturation.high:
+		atomic.StoreInt32(&w.saturated, 1)
+		w.pending = append(w.pending, QueueSaturationEvent{Ratio: ratio, Saturated: true})
+	case saturated && ratio <= c.saturation.low:
+		atomic.StoreInt32(&w.saturated, 0)
+		w.pending = append(w.pending, QueueSaturationEvent{Ratio: ratio, Saturated: false})
+	}
+
+	c.deliverWritability()
+}
+
+// deliverWritability to fire the pending events in order, it must be called with the mutex locked.
+func (c *channel) deliverWritability() {
+
+	w := &c.writability
+
+	// the changes are delivered by the goroutine that is draining, include the handlers that write in the event.
+	if w.draining || 0 == len(w.pending) {
+		w.mutex.Unlock()
+		return
+	}
+
+	// the changes may be made by the write loop, so the handlers are invoked out of it to write in the event.
+	w.draining = true
+	w.mutex.Unlock()
+	go c.drainWritability()
+}
+
+// drainWritability to fire the pending events until there are no more changes.
+func (c *channel) drainWritability() {
+
+	w := &c.writability
+	w.mutex.Lock()
+	for len(w.pending) > 0 {
+		event := w.pending[0]
+		w.pending = w.pending[1:]
+		w.mutex.Unlock()
+
+		if c.IsActive() {
+			c.Trigger(event)
+		}
+
+		w.mutex.Lock()
+	}
+	w.draining = false
+	w.mutex.Unlock()
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright 2019 the go-netty project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netty
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChannelWritability(t *testing.T) {
+
+	bs := NewBootstrap(WithWriteWatermark(4, 8))
+	defer bs.Shutdown()
+
+	events := make(chan bool, 16)
+	p := newDiscardPipeline().AddLast(EventHandlerFunc(func(ctx EventContext, event Event) {
+		if e, ok := event.(WritabilityChangedEvent); ok {
+			events <- e.Writable
+		}
+	}))
+
+	local, peer := net.Pipe()
+	c := NewChannel(128)(1, bs.Context(), p, &pipeTransport{Conn: local}).(*channel)
+	p.(*pipeline).channel = c
+	defer c.Close(nil)
+
+	if !c.IsWritable() {
+		t.Fatal("the channel should be writable")
+	}
+
+	// the write loop is not served, so the bytes are queued.
+	c.Write([]byte("abcd"))
+	if !c.IsWritable() || 0 != len(events) {
+		t.Fatal("the channel should be writable under the high watermark")
+	}
+
+	// the event is edge-triggered.
+	c.Write([]byte("efgh"))
+	c.Write([]byte("ijkl"))
+	if c.IsWritable() || false != <-events || 0 != len(events) {
+		t.Fatal("the channel should be unwritable at the high watermark")
+	}
+
+	c.serveChannel()
+	if data, err := ioutil.ReadAll(io.LimitReader(peer, 12)); nil != err || "abcdefghijkl" != string(data) {
+		t.Fatal(string(data), err)
+	}
+
+	select {
+	case writable := <-events:
+		if !writable || !c.IsWritable() {
+			t.Fatal("the channel should be writable after the bytes are written")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel should be writable after the bytes are written")
+	}
+}
+
+func TestChannelWritabilityWrite(t *testing.T) {
+
+	bs := NewBootstrap(WithWriteWatermark(4, 8), WithMaxPendingWriteBytes(8))
+	defer bs.Shutdown()
+
+	flushed := make(chan struct{})
+	written := make(chan bool, 1)
+	p := newDiscardPipeline().AddLast(EventHandlerFunc(func(ctx EventContext, event Event) {
+		// the handler waits for the write loop, it is blocked forever if the event is fired by the write loop.
+		if e, ok := event.(WritabilityChangedEvent); ok && e.Writable {
+			ok = ctx.Channel().Write([]byte("mnop"))
+			select {
+			case <-flushed:
+			case <-time.After(time.Second):
+				ok = false
+			}
+			written <- ok
+		}
+	}))
+
+	local, peer := net.Pipe()
+	c := NewChannel(128)(1, bs.Context(), p, &pipeTransport{Conn: local}).(*channel)
+	p.(*pipeline).channel = c
+	defer c.Close(nil)
+
+	c.Write([]byte("abcd"))
+	c.Write([]byte("efgh"))
+	if c.IsWritable() {
+		t.Fatal("the channel should be unwritable at the high watermark")
+	}
+
+	c.serveChannel()
+	if data, err := ioutil.ReadAll(io.LimitReader(peer, 12)); nil != err || "abcdefghmnop" != string(data) {
+		t.Fatal(string(data), err)
+	}
+	close(flushed)
+
+	if !<-written {
+		t.Fatal("the handler should write to the channel in the event")
+	}
+}
+
+func TestChannelMaxPendingWriteBytes(t *testing.T) {
+
+	// newChannel create a channel of the bootstrap without serving it.
+	newChannel := func(option ...Option) (*channel, net.Conn) {
+		bs := NewBootstrap(append(option, WithMaxPendingWriteBytes(8))...)
+		t.Cleanup(bs.Shutdown)
+
+		p := newDiscardPipeline().AddLast(ExceptionHandlerFunc(func(ctx ExceptionContext, ex Exception) {}))
+		local, peer := net.Pipe()
+		c := NewChannel(128)(1, bs.Context(), p, &pipeTransport{Conn: local}).(*channel)
+		p.(*pipeline).channel = c
+		t.Cleanup(func() { c.Close(nil) })
+		return c, peer
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		c, _ := newChannel(WithWriteOverflowPolicy(WriteOverflowFail))
+		c.Write([]byte("abcd"))
+		c.Write([]byte("efgh"))
+		if 8 != c.Stats().PendingBytes {
+			t.Fatal("unexpected pending bytes:", c.Stats().PendingBytes)
+		}
+		if c.Write([]byte("i")) {
+			t.Fatal("the write should be failed")
+		}
+		if err := c.TryWrite([]byte("i")); !errors.Is(err, ErrWriteQueueFull) {
+			t.Fatal("unexpected error:", err)
+		}
+		if 8 != c.Stats().PendingBytes {
+			t.Fatal("unexpected pending bytes:", c.Stats().PendingBytes)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		c, peer := newChannel(WithWriteOverflowPolicy(WriteOverflowDropOldest))
+		for _, s := range []string{"abcd", "efgh", "ijkl"} {
+			if !c.Write([]byte(s)) {
+				t.Fatal(s, "the write should be queued")
+			}
+		}
+
+		c.serveChannel()
+		if data, err := ioutil.ReadAll(io.LimitReader(peer, 8)); nil != err || "efghijkl" != string(data) {
+			t.Fatal("the oldest entries should be dropped:", string(data), err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		c, peer := newChannel()
+		c.Write([]byte("abcd"))
+		c.Write([]byte("efgh"))
+
+		written := make(chan bool)
+		go func() { written <- c.Write([]byte("ijkl")) }()
+
+		select {
+		case <-written:
+			t.Fatal("the write should be blocked")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		c.serveChannel()
+		if data, err := ioutil.ReadAll(io.LimitReader(peer, 12)); nil != err || "abcdefghijkl" != string(data) {
+			t.Fatal(string(data), err)
+		}
+		if !<-written {
+			t.Fatal("the write should be queued after the bytes are written")
+		}
+	})
+
+	t.Run("large", func(t *testing.T) {
+		c, peer := newChannel(WithWriteOverflowPolicy(WriteOverflowFail))
+		if !c.Write([]byte("0123456789")) {
+			t.Fatal("the message larger than the limit should be queued alone")
+		}
+		if c.Write([]byte("a")) {
+			t.Fatal("the write should be failed")
+		}
+
+		c.serveChannel()
+		if data, err := ioutil.ReadAll(io.LimitReader(peer, 10)); nil != err || "0123456789" != string(data) {
+			t.Fatal(string(data), err)
+		}
+	})
+}
+
+func TestChannelQueueSaturation(t *testing.T) {
+
+	bs := NewBootstrap(WithWriteQueueSize(10), WithQueueSaturation(0.8, 0.2))
+	defer bs.Shutdown()
+
+	events := make(chan QueueSaturationEvent, 16)
+	p := newDiscardPipeline().AddLast(EventHandlerFunc(func(ctx EventContext, event Event) {
+		if e, ok := event.(QueueSaturationEvent); ok {
+			events <- e
+		}
+	}))
+
+	local, peer := net.Pipe()
+	c := NewChannel(128)(1, bs.Context(), p, &pipeTransport{Conn: local}).(*channel)
+	p.(*pipeline).channel = c
+	defer c.Close(nil)
+
+	// the write loop is not served, so the entries are queued.
+	for i := 0; i < 7; i++ {
+		c.Write([]byte("x"))
+	}
+	if 0 != len(events) {
+		t.Fatal("the send queue should not be saturated under the threshold")
+	}
+
+	// the event is edge-triggered.
+	c.Write([]byte("x"))
+	c.Write([]byte("x"))
+	if e := <-events; !e.Saturated || 0.8 != e.Ratio || 0 != len(events) {
+		t.Fatal("unexpected event:", e)
+	}
+
+	c.serveChannel()
+	if data, err := ioutil.ReadAll(io.LimitReader(peer, 9)); nil != err || "xxxxxxxxx" != string(data) {
+		t.Fatal(string(data), err)
+	}
+
+	select {
+	case e := <-events:
+		if e.Saturated || e.Ratio > 0.2 {
+			t.Fatal("unexpected event:", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the send queue should be recovered after the entries are written")
+	}
+}